@@ -19,8 +19,6 @@ import (
 	"encoding/base64"
 	"fmt"
 
-	"istio.io/bots/policybot/pkg/gh"
-
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc/grpclog"
 
@@ -36,6 +34,7 @@ func flakeChaserCmd() *cobra.Command {
 	ca := config.DefaultArgs()
 
 	ca.StartupOptions.GitHubToken = env.RegisterStringVar("GITHUB_TOKEN", ca.StartupOptions.GitHubToken, githubToken).Get()
+	registerGitHubAppEnv(&ca.StartupOptions)
 	ca.StartupOptions.GCPCredentials = env.RegisterStringVar("GCP_CREDS", ca.StartupOptions.GCPCredentials, gcpCreds).Get()
 	ca.StartupOptions.ConfigRepo = env.RegisterStringVar("CONFIG_REPO", ca.StartupOptions.ConfigRepo, configRepo).Get()
 	ca.StartupOptions.ConfigFile = env.RegisterStringVar("CONFIG_FILE", ca.StartupOptions.ConfigFile, configFile).Get()
@@ -63,6 +62,7 @@ func flakeChaserCmd() *cobra.Command {
 	chaserCmd.PersistentFlags().StringVarP(&ca.StartupOptions.ConfigRepo, "configRepo", "", ca.StartupOptions.ConfigRepo, configRepo)
 	chaserCmd.PersistentFlags().StringVarP(&ca.StartupOptions.ConfigFile, "configFile", "", ca.StartupOptions.ConfigFile, configFile)
 	chaserCmd.PersistentFlags().StringVarP(&ca.StartupOptions.GitHubToken, "github_token", "", ca.StartupOptions.GitHubToken, githubToken)
+	attachGitHubAppFlags(chaserCmd, &ca.StartupOptions)
 	chaserCmd.PersistentFlags().StringVarP(&ca.StartupOptions.GCPCredentials, "gcp_creds", "", ca.StartupOptions.GCPCredentials, gcpCreds)
 
 	loggingOptions.AttachCobraFlags(chaserCmd)
@@ -82,7 +82,10 @@ func runFlakeChaser(a *config.Args) error {
 		return fmt.Errorf("unable to decode GCP credentials: %v", err)
 	}
 
-	gc := gh.NewThrottledClient(context.Background(), a.StartupOptions.GitHubToken)
+	gc, err := newGitHubClient(a.StartupOptions)
+	if err != nil {
+		return err
+	}
 
 	store, err := spanner.NewStore(context.Background(), a.SpannerDatabase, creds)
 	if err != nil {
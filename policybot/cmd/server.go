@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -41,15 +42,25 @@ import (
 	"istio.io/bots/policybot/handlers/githubwebhook"
 	"istio.io/bots/policybot/handlers/githubwebhook/filters"
 	"istio.io/bots/policybot/handlers/githubwebhook/filters/cfgmonitor"
+	"istio.io/bots/policybot/handlers/githubwebhook/filters/cherrypicker"
+	"istio.io/bots/policybot/handlers/githubwebhook/filters/commands"
 	"istio.io/bots/policybot/handlers/githubwebhook/filters/labeler"
+	"istio.io/bots/policybot/handlers/githubwebhook/filters/linkedissue"
 	"istio.io/bots/policybot/handlers/githubwebhook/filters/nagger"
+	"istio.io/bots/policybot/handlers/githubwebhook/filters/publisher"
 	"istio.io/bots/policybot/handlers/githubwebhook/filters/refresher"
+	"istio.io/bots/policybot/handlers/githubwebhook/filters/releasenotes"
 	"istio.io/bots/policybot/handlers/githubwebhook/filters/resultgatherer"
+	"istio.io/bots/policybot/handlers/githubwebhook/filters/reviewassigner"
+	"istio.io/bots/policybot/handlers/githubwebhook/filters/sizelabeler"
+	"istio.io/bots/policybot/handlers/githubwebhook/filters/welcomer"
+	"istio.io/bots/policybot/handlers/lifecycle"
 	"istio.io/bots/policybot/handlers/syncer"
 	"istio.io/bots/policybot/handlers/zenhubwebhook"
 	"istio.io/bots/policybot/pkg/blobstorage/gcs"
 	"istio.io/bots/policybot/pkg/config"
 	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
 	"istio.io/bots/policybot/pkg/storage/cache"
 	"istio.io/bots/policybot/pkg/storage/spanner"
 	"istio.io/bots/policybot/pkg/util"
@@ -62,6 +73,9 @@ import (
 const (
 	githubWebhookSecret     = "Secret for the GitHub webhook"
 	githubToken             = "Token to access the GitHub API"
+	githubAppID             = "ID of the GitHub App to act as, instead of using a GitHub token"
+	githubAppInstallationID = "ID of the GitHub App's installation to act as"
+	githubAppPrivateKey     = "Base64-encoded PEM private key of the GitHub App"
 	gcpCreds                = "Base64-encoded credentials to access GCP"
 	configRepo              = "GitHub org/repo/branch where to fetch policybot config"
 	configFile              = "Path to a configuration file"
@@ -78,6 +92,7 @@ func serverCmd() *cobra.Command {
 
 	ca.StartupOptions.GitHubWebhookSecret = env.RegisterStringVar("GITHUB_WEBHOOK_SECRET", ca.StartupOptions.GitHubWebhookSecret, githubWebhookSecret).Get()
 	ca.StartupOptions.GitHubToken = env.RegisterStringVar("GITHUB_TOKEN", ca.StartupOptions.GitHubToken, githubToken).Get()
+	registerGitHubAppEnv(&ca.StartupOptions)
 	ca.StartupOptions.ZenHubToken = env.RegisterStringVar("ZENHUB_TOKEN", ca.StartupOptions.ZenHubToken, zenhubToken).Get()
 	ca.StartupOptions.GCPCredentials = env.RegisterStringVar("GCP_CREDS", ca.StartupOptions.GCPCredentials, gcpCreds).Get()
 	ca.StartupOptions.ConfigRepo = env.RegisterStringVar("CONFIG_REPO", ca.StartupOptions.ConfigRepo, configRepo).Get()
@@ -125,6 +140,7 @@ func serverCmd() *cobra.Command {
 		"github_webhook_secret", "", ca.StartupOptions.GitHubWebhookSecret, githubWebhookSecret)
 	serverCmd.PersistentFlags().StringVarP(&ca.StartupOptions.GitHubToken,
 		"github_token", "", ca.StartupOptions.GitHubToken, githubToken)
+	attachGitHubAppFlags(serverCmd, &ca.StartupOptions)
 	serverCmd.PersistentFlags().StringVarP(&ca.StartupOptions.GCPCredentials,
 		"gcp_creds", "", ca.StartupOptions.GCPCredentials, gcpCreds)
 	serverCmd.PersistentFlags().StringVarP(&ca.StartupOptions.SendGridAPIKey,
@@ -146,6 +162,44 @@ func serverCmd() *cobra.Command {
 	return serverCmd
 }
 
+// registerGitHubAppEnv reads the GitHub App settings from the environment.
+func registerGitHubAppEnv(o *config.StartupOptions) {
+	o.GitHubAppID = env.RegisterIntVar("GITHUB_APP_ID", o.GitHubAppID, githubAppID).Get()
+	o.GitHubAppInstallationID = env.RegisterIntVar("GITHUB_APP_INSTALLATION_ID", o.GitHubAppInstallationID, githubAppInstallationID).Get()
+	o.GitHubAppPrivateKey = env.RegisterStringVar("GITHUB_APP_PRIVATE_KEY", o.GitHubAppPrivateKey, githubAppPrivateKey).Get()
+}
+
+// attachGitHubAppFlags adds the command-line options for the GitHub App settings.
+func attachGitHubAppFlags(cmd *cobra.Command, o *config.StartupOptions) {
+	cmd.PersistentFlags().IntVarP(&o.GitHubAppID, "github_app_id", "", o.GitHubAppID, githubAppID)
+	cmd.PersistentFlags().IntVarP(&o.GitHubAppInstallationID, "github_app_installation_id", "", o.GitHubAppInstallationID, githubAppInstallationID)
+	cmd.PersistentFlags().StringVarP(&o.GitHubAppPrivateKey, "github_app_private_key", "", o.GitHubAppPrivateKey, githubAppPrivateKey)
+}
+
+// newGitHubClient returns a GitHub client acting as the configured GitHub App, or using the GitHub token when no
+// app is configured.
+func newGitHubClient(o config.StartupOptions) (*gh.ThrottledClient, error) {
+	if o.GitHubAppID == 0 {
+		return gh.NewThrottledClient(context.Background(), o.GitHubToken), nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(o.GitHubAppPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode GitHub App private key: %v", err)
+	}
+
+	gc, err := gh.NewThrottledClientForApp(context.Background(), gh.AppCredentials{
+		AppID:          int64(o.GitHubAppID),
+		InstallationID: int64(o.GitHubAppInstallationID),
+		PrivateKey:     key,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create GitHub App client: %v", err)
+	}
+
+	return gc, nil
+}
+
 type dummyIoWriter struct{}
 
 func (dummyIoWriter) Write([]byte) (int, error) { return 0, nil }
@@ -165,13 +219,10 @@ type server struct {
 // function never returns.
 func runServer(base *config.Args) error {
 	for {
-		// copy the baseline config
-		c := *base
-		cfg := &c
-
 		// load the config file
-		if err := cfg.Fetch(); err != nil {
-			if cfg.StartupOptions.ConfigRepo != "" {
+		cfg, err := loadConfig(base)
+		if err != nil {
+			if base.StartupOptions.ConfigRepo != "" {
 				log.Errorf("Unable to load configuration file, waiting for 1 minute and then will try again: %v", err)
 				time.Sleep(time.Minute)
 				continue
@@ -193,6 +244,49 @@ func runServer(base *config.Args) error {
 	}
 }
 
+// loadConfig fills in the rest of the baseline config from the configuration file. Repo-based files are
+// fetched through the GitHub API, which unlike raw file downloads isn't subject to caching, such that a
+// reload sees the content which triggered it.
+func loadConfig(base *config.Args) (*config.Args, error) {
+	if base.StartupOptions.ConfigRepo == "" {
+		// copy the baseline config
+		c := *base
+		if err := c.Fetch(); err != nil {
+			return nil, err
+		}
+
+		return &c, nil
+	}
+
+	url, err := configURL(base.StartupOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	gc, err := newGitHubClient(base.StartupOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.LoadFromURL(context.Background(), gc, url)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.StartupOptions = base.StartupOptions
+	return cfg, nil
+}
+
+// configURL returns the GitHub URL of a repo-based configuration file.
+func configURL(o config.StartupOptions) (string, error) {
+	splits := strings.Split(o.ConfigRepo, "/")
+	if len(splits) != 3 {
+		return "", fmt.Errorf("invalid value for configuration repo, needs to be org/repo/branch, is `%s`", o.ConfigRepo)
+	}
+
+	return "https://github.com/" + splits[0] + "/" + splits[1] + "/blob/" + splits[2] + "/" + o.ConfigFile, nil
+}
+
 func runWithConfig(a *config.Args) error {
 	log.Debugf("Starting with:\n%s", a)
 
@@ -201,7 +295,11 @@ func runWithConfig(a *config.Args) error {
 		return fmt.Errorf("unable to decode GCP credentials: %v", err)
 	}
 
-	gc := gh.NewThrottledClient(context.Background(), a.StartupOptions.GitHubToken)
+	gc, err := newGitHubClient(a.StartupOptions)
+	if err != nil {
+		return err
+	}
+
 	zc := zh.NewThrottledClient(a.StartupOptions.ZenHubToken)
 	_ = util.NewMailer(a.StartupOptions.SendGridAPIKey, a.EmailFrom, a.EmailOriginAddress)
 
@@ -219,16 +317,47 @@ func runWithConfig(a *config.Args) error {
 
 	cache := cache.New(store, a.CacheTTL)
 
+	// only requests explicitly asking for it, such as those issued while syncing, are made conditional
+	gc.EnableETags(store)
+
 	nag, err := nagger.NewNagger(gc, cache, a.Orgs, a.Nags)
 	if err != nil {
 		return fmt.Errorf("unable to create nagger: %v", err)
 	}
 
-	labeler, err := labeler.NewLabeler(gc, cache, a.Orgs, a.AutoLabels)
+	labeler, err := labeler.NewLabeler(gc, cache, a.Orgs, a.AutoLabels, a.Labels)
 	if err != nil {
 		return fmt.Errorf("unable to create labeler: %v", err)
 	}
 
+	sizeLabeler, err := sizelabeler.NewSizeLabeler(gc, a.Orgs, a.SizeLabels)
+	if err != nil {
+		return fmt.Errorf("unable to create size labeler: %v", err)
+	}
+
+	releaseNoteChecker, err := releasenotes.NewReleaseNoteChecker(gc, a.Orgs, a.ReleaseNotes)
+	if err != nil {
+		return fmt.Errorf("unable to create release notes checker: %v", err)
+	}
+
+	cherryPicker, err := cherrypicker.NewCherryPicker(gc, store, a.Orgs)
+	if err != nil {
+		return fmt.Errorf("unable to create cherry-picker: %v", err)
+	}
+
+	welcomer, err := welcomer.NewWelcomer(gc, store, a.Orgs)
+	if err != nil {
+		return fmt.Errorf("unable to create welcomer: %v", err)
+	}
+
+	publisher, err := publisher.NewPublisher(context.Background(), creds, a.EventPublisher)
+	if err != nil {
+		return fmt.Errorf("unable to create event publisher: %v", err)
+	}
+
+	// deferred ahead of the webhook's close, such that the events it flushes still get published
+	defer publisher.Close()
+
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", a.StartupOptions.Port))
 	if err != nil {
 		return fmt.Errorf("unable to listen to port: %v", err)
@@ -253,13 +382,33 @@ func runWithConfig(a *config.Args) error {
 		return fmt.Errorf("unable to create config monitor: %v", err)
 	}
 
+	if a.ConfigRefreshInterval > 0 && a.StartupOptions.ConfigRepo != "" {
+		// poll the config file in addition to listening for push events, in case the webhook isn't wired for the config repo
+		url, err := configURL(a.StartupOptions)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go config.WatchURL(ctx, gc, url, a.ConfigRefreshInterval, s.Close)
+	}
+
 	// github webhook filters (keep refresher first in the list such that other filter see an up-to-date view in storage)
 	filters := []filters.Filter{
 		refresher.NewRefresher(cache, store, gc, a.Orgs),
 		nag,
+		linkedissue.NewLinkedIssueNagger(gc, a.Orgs),
 		labeler,
+		sizeLabeler,
+		releaseNoteChecker,
+		commands.NewCommands(gc, cache, store, a.Orgs),
+		reviewassigner.NewReviewAssigner(gc, store, a.Orgs),
+		welcomer,
+		cherryPicker,
 		monitor,
 		resultgatherer.NewResultGatherer(store, cache, a.Orgs, a.BucketName),
+		publisher,
 	}
 
 	if a.StartupOptions.HTTPSOnly {
@@ -267,11 +416,25 @@ func runWithConfig(a *config.Args) error {
 		router.Headers("X-Forwarded-Proto", "HTTP").HandlerFunc(handleHTTP)
 	}
 
+	var archive storage.Store
+	if a.ArchiveWebhookPayloads {
+		archive = store
+	}
+
+	webhook := githubwebhook.NewHandler(a.StartupOptions.GitHubWebhookSecret,
+		a.WebhookWorkers, a.WebhookQueueSize, a.WebhookEventTimeout, archive, filters...)
+
+	// let the events already received be handled before the server is torn down
+	defer webhook.Close()
+
 	// top-level handlers
-	router.Handle("/githubwebhook", githubwebhook.NewHandler(a.StartupOptions.GitHubWebhookSecret, filters...)).Methods("POST")
+	router.Handle("/githubwebhook", webhook).Methods("POST")
 	router.Handle("/flakechaser", flakechaser.NewHandler(gc, store, cache, a.FlakeChaser)).Methods("GET")
+	router.Handle("/lifecycle", lifecycle.NewHandler(gc, store, a.Orgs)).Methods("GET")
 	router.Handle("/zenhubwebhook", zenhubwebhook.NewHandler(store, cache)).Methods("POST")
-	router.Handle("/sync", syncer.NewHandler(context.Background(), gc, cache, zc, store, a.Orgs)).Methods("GET")
+	router.Handle("/sync", syncer.NewHandler(context.Background(), gc, cache, zc, store, a.Orgs, a.SyncWithGraphQL)).Methods("GET")
+	router.Handle("/admin/sync/{org}/members", syncer.NewMembersHandler(gc, cache, zc, store, a.Orgs)).Methods("GET")
+	router.Handle("/admin/githubwebhook/replay", githubwebhook.NewReplayHandler(webhook)).Methods("GET")
 
 	// UI topics
 	dashboard := dashboard.New(router, a.StartupOptions.GitHubOAuthClientID, a.StartupOptions.GitHubOAuthClientSecret)
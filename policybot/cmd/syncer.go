@@ -17,13 +17,14 @@ package cmd
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 
+	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc/grpclog"
 
 	"istio.io/bots/policybot/pkg/config"
-	"istio.io/bots/policybot/pkg/gh"
 	"istio.io/bots/policybot/pkg/storage/cache"
 	"istio.io/bots/policybot/pkg/storage/spanner"
 	"istio.io/bots/policybot/pkg/syncer"
@@ -36,6 +37,7 @@ func syncerCmd() *cobra.Command {
 	ca := config.DefaultArgs()
 
 	ca.StartupOptions.GitHubToken = env.RegisterStringVar("GITHUB_TOKEN", ca.StartupOptions.GitHubToken, githubToken).Get()
+	registerGitHubAppEnv(&ca.StartupOptions)
 	ca.StartupOptions.ZenHubToken = env.RegisterStringVar("ZENHUB_TOKEN", ca.StartupOptions.ZenHubToken, zenhubToken).Get()
 	ca.StartupOptions.GCPCredentials = env.RegisterStringVar("GCP_CREDS", ca.StartupOptions.GCPCredentials, gcpCreds).Get()
 	ca.StartupOptions.ConfigRepo = env.RegisterStringVar("CONFIG_REPO", ca.StartupOptions.ConfigRepo, configRepo).Get()
@@ -43,33 +45,69 @@ func syncerCmd() *cobra.Command {
 
 	loggingOptions := log.DefaultOptions()
 	var filters string
+	var repos []string
+	var output string
+	var dryRun bool
+	var useGraphQL bool
+	var rateLimitFloor int
+	var resume bool
 
 	syncerCmd := &cobra.Command{
 		Use:   "syncer",
 		Short: "Manually run the GitHub/ZenHub state syncer",
 		Args:  cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" && output != "yaml" {
+				return fmt.Errorf("unknown output format %s, must be one of [text, json, yaml]", output)
+			}
+
 			if err := log.Configure(loggingOptions); err != nil {
 				log.Errorf("Unable to configure logging: %v", err)
 			}
 
+			if output != "text" {
+				// only the sync report should make it to stdout
+				for _, s := range log.Scopes() {
+					s.SetOutputLevel(log.NoneLevel)
+				}
+			}
+
 			// neutralize gRPC logging since it spews out useless junk
 			var dummy = dummyIoWriter{}
 			grpclog.SetLoggerV2(grpclog.NewLoggerV2(dummy, dummy, dummy))
 
 			cmd.SilenceUsage = true
-			return runSyncer(ca, filters)
+			return runSyncer(ca, filters, repos, output, dryRun, useGraphQL, rateLimitFloor, resume)
 		},
 	}
 
 	syncerCmd.PersistentFlags().StringVarP(&ca.StartupOptions.ConfigRepo, "configRepo", "", ca.StartupOptions.ConfigRepo, configRepo)
 	syncerCmd.PersistentFlags().StringVarP(&ca.StartupOptions.ConfigFile, "configFile", "", ca.StartupOptions.ConfigFile, configFile)
 	syncerCmd.PersistentFlags().StringVarP(&ca.StartupOptions.GitHubToken, "github_token", "", ca.StartupOptions.GitHubToken, githubToken)
+	attachGitHubAppFlags(syncerCmd, &ca.StartupOptions)
 	syncerCmd.PersistentFlags().StringVarP(&ca.StartupOptions.ZenHubToken, "zenhub_token", "", ca.StartupOptions.ZenHubToken, zenhubToken)
 	syncerCmd.PersistentFlags().StringVarP(&ca.StartupOptions.GCPCredentials, "gcp_creds", "", ca.StartupOptions.GCPCredentials, gcpCreds)
 
 	syncerCmd.PersistentFlags().StringVarP(&filters,
-		"filter", "", "", "Comma-separated filters to limit what is synced, one or more of [issues, prs, labels, maintainers, members, zenhub, repocomments]")
+		"filter", "", "", "Comma-separated filters to limit what is synced, one or more of [issues, prs, labels, maintainers, members, zenhub, repocomments, events, milestones, teams, statuses, releases, slos]")
+
+	syncerCmd.PersistentFlags().StringSliceVarP(&repos,
+		"repos", "", nil, "Comma-separated list of repos to limit the sync to, in org/repo form")
+
+	syncerCmd.PersistentFlags().StringVarP(&output,
+		"output", "o", "text", "Output format for the sync report, one of [text, json, yaml]")
+
+	syncerCmd.PersistentFlags().BoolVarP(&dryRun,
+		"dry_run", "", false, "Fetch data from GitHub and ZenHub, but only report what would be written to storage")
+
+	syncerCmd.PersistentFlags().BoolVarP(&useGraphQL,
+		"graphql", "", false, "Fetch issues and pull requests in bulk using GitHub's GraphQL API, which takes fewer API calls")
+
+	syncerCmd.PersistentFlags().IntVarP(&rateLimitFloor,
+		"rate_limit_floor", "", 0, "Pause syncing until GitHub's rate limit resets whenever fewer core API calls than this remain, 0 to never pause")
+
+	syncerCmd.PersistentFlags().BoolVarP(&resume,
+		"resume", "", false, "Pick up paging through issues, pull requests, and their comments where an interrupted sync left off")
 
 	loggingOptions.AttachCobraFlags(syncerCmd)
 
@@ -77,7 +115,8 @@ func syncerCmd() *cobra.Command {
 }
 
 // Runs the syncer.
-func runSyncer(a *config.Args, filters string) error {
+func runSyncer(a *config.Args, filters string, repos []string, output string, dryRun bool, useGraphQL bool, rateLimitFloor int,
+	resume bool) error {
 	flags, err := syncer.ConvFilterFlags(filters)
 	if err != nil {
 		return err
@@ -93,7 +132,11 @@ func runSyncer(a *config.Args, filters string) error {
 		return fmt.Errorf("unable to decode GCP credentials: %v", err)
 	}
 
-	gc := gh.NewThrottledClient(context.Background(), a.StartupOptions.GitHubToken)
+	gc, err := newGitHubClient(a.StartupOptions)
+	if err != nil {
+		return err
+	}
+
 	zc := zh.NewThrottledClient(a.StartupOptions.ZenHubToken)
 
 	store, err := spanner.NewStore(context.Background(), a.SpannerDatabase, creds)
@@ -104,6 +147,53 @@ func runSyncer(a *config.Args, filters string) error {
 
 	cache := cache.New(store, a.CacheTTL)
 
-	h := syncer.New(gc, cache, zc, store, a.Orgs)
-	return h.Sync(context.Background(), flags)
+	if !dryRun {
+		// a dry run doesn't write anything, ETags included
+		gc.EnableETags(store)
+	}
+
+	h := syncer.New(gc, cache, zc, store, a.Orgs, dryRun)
+	h.UseGraphQL = useGraphQL
+	h.RateLimitFloor = rateLimitFloor
+	h.Resume = resume
+	report, err := h.Sync(context.Background(), flags, repos)
+	if se, ok := err.(*syncer.SyncError); ok {
+		// the sync completed, but not everything could be synced
+		for _, f := range se.Failures {
+			log.Errorf("%v", f)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if perr := printSyncReport(report, output); perr != nil {
+		return perr
+	}
+
+	return err
+}
+
+func printSyncReport(report *syncer.SyncReport, output string) error {
+	var b []byte
+	var err error
+
+	switch output {
+	case "json":
+		b, err = json.MarshalIndent(report, "", "  ")
+	case "yaml":
+		b, err = yaml.Marshal(report)
+	default:
+		log.Infof("Synced %d orgs, %d repos, and %d users in %v", len(report.Orgs), len(report.Repos), report.Users, report.Duration)
+		log.Infof("Wrote %d issues, %d pull requests, %d comments, and %d events using %d GitHub calls and %d ZenHub calls",
+			report.Stats.Issues, report.Stats.PullRequests, report.Stats.Comments, report.Stats.Events,
+			report.Stats.GitHubCalls, report.Stats.ZenHubCalls)
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to format sync report: %v", err)
+	}
+
+	fmt.Println(string(b))
+	return nil
 }
@@ -57,9 +57,14 @@ func NewMonitor(repo string, file string, notify func()) (filters.Filter, error)
 	return ct, nil
 }
 
+// the webhook events the filter reacts to
+func (m *Monitor) Events() []string {
+	return []string{"push"}
+}
+
 // monitor for changes to policybot's config file
 func (m *Monitor) Handle(context context.Context, event interface{}) {
-	pp, ok := event.(github.PushEvent)
+	pp, ok := event.(*github.PushEvent)
 	if !ok {
 		// not what we're looking for
 		return
@@ -0,0 +1,418 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cherrypicker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/handlers/githubwebhook/filters"
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/pkg/log"
+)
+
+// RetryCommand is a comment which retries the failed cherry-picks of a merged PR
+const RetryCommand = "/retry-cherrypick"
+
+const (
+	defaultTitle = "[{{.Branch}}] {{.Title}}"
+	defaultBody  = "Cherry-pick of #{{.Number}} to {{.Branch}}.\n\n{{.Body}}"
+
+	stateSucceeded = "succeeded"
+	stateFailed    = "failed"
+)
+
+// Cherry-picks merged PRs to the release branches requested through their labels
+type CherryPicker struct {
+	gc        *gh.ThrottledClient
+	store     storage.Store
+	orgs      []config.Org
+	templates map[string]*template.Template // index is the template's text
+}
+
+// the values available to the title and body templates
+type templateData struct {
+	Number int
+	Title  string
+	Body   string
+	Author string
+	Branch string
+}
+
+var scope = log.RegisterScope("cherrypicker", "Cherry-picker for merged PRs", 0)
+
+func NewCherryPicker(gc *gh.ThrottledClient, store storage.Store, orgs []config.Org) (filters.Filter, error) {
+	cp := &CherryPicker{
+		gc:        gc,
+		store:     store,
+		orgs:      orgs,
+		templates: make(map[string]*template.Template),
+	}
+
+	for _, org := range orgs {
+		if org.CherryPick.LabelPrefix == "" {
+			continue
+		}
+
+		for _, text := range []string{title(org.CherryPick), body(org.CherryPick)} {
+			t, err := template.New(org.Name).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cherry-pick template for org %s: %v", org.Name, err)
+			}
+			cp.templates[text] = t
+		}
+	}
+
+	return cp, nil
+}
+
+func title(c config.CherryPick) string {
+	if c.Title == "" {
+		return defaultTitle
+	}
+	return c.Title
+}
+
+func body(c config.CherryPick) string {
+	if c.Body == "" {
+		return defaultBody
+	}
+	return c.Body
+}
+
+// the webhook events the filter reacts to
+func (cp *CherryPicker) Events() []string {
+	return []string{"pull_request", "issue_comment"}
+}
+
+// process an event arriving from GitHub
+func (cp *CherryPicker) Handle(context context.Context, event interface{}) {
+	if filters.IsReplay(context) {
+		// the cherry-picks were done when the event first arrived
+		return
+	}
+
+	switch p := event.(type) {
+	case *github.PullRequestEvent:
+		org := cp.findOrg(p.GetRepo())
+		if org == nil {
+			return
+		}
+
+		pr := p.GetPullRequest()
+
+		var targets []string
+		if gh.ConvertPullRequestAction(p.GetAction(), pr) == "merged" {
+			targets = targetBranches(org.CherryPick, pr.Labels)
+		} else if p.GetAction() == "labeled" && pr.GetMerged() {
+			// the label was added after the PR was merged
+			targets = targetBranches(org.CherryPick, []*github.Label{p.GetLabel()})
+		}
+
+		cp.process(context, org, p.GetRepo(), pr, targets, false)
+
+	case *github.IssueCommentEvent:
+		if p.GetAction() != "created" || !p.GetIssue().IsPullRequest() || !isRetry(p.GetComment().GetBody()) {
+			return
+		}
+
+		org := cp.findOrg(p.GetRepo())
+		if org == nil {
+			return
+		}
+
+		orgLogin := p.GetRepo().GetOwner().GetLogin()
+		repoName := p.GetRepo().GetName()
+		number := p.GetIssue().GetNumber()
+
+		// the comment's payload only covers the issue side of the PR
+		result, _, err := cp.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.PullRequests.Get(context, orgLogin, repoName, number)
+		})
+		if err != nil {
+			scope.Errorf("Unable to get pull request %d in repo %s/%s: %v", number, orgLogin, repoName, err)
+			return
+		}
+
+		pr := result.(*github.PullRequest)
+		if !pr.GetMerged() {
+			scope.Infof("Ignoring %s on pull request %d in repo %s/%s since it isn't merged", RetryCommand, number, orgLogin, repoName)
+			return
+		}
+
+		cp.process(context, org, p.GetRepo(), pr, targetBranches(org.CherryPick, pr.Labels), true)
+	}
+}
+
+func (cp *CherryPicker) findOrg(repo *github.Repository) *config.Org {
+	org := config.FindOrgForRepo(cp.orgs, repo.GetFullName())
+	if org == nil || org.CherryPick.LabelPrefix == "" {
+		return nil
+	}
+	return org
+}
+
+// targetBranches returns the branches requested by the cherry-pick labels among the given ones
+func targetBranches(c config.CherryPick, labels []*github.Label) []string {
+	var result []string
+	for _, label := range labels {
+		name := label.GetName()
+		if strings.HasPrefix(name, c.LabelPrefix) && len(name) > len(c.LabelPrefix) {
+			result = append(result, strings.TrimPrefix(name, c.LabelPrefix))
+		}
+	}
+	return result
+}
+
+// isRetry returns whether a comment holds the retry command on a line of its own
+func isRetry(comment string) bool {
+	for _, line := range strings.Split(comment, "\n") {
+		if strings.TrimSpace(line) == RetryCommand {
+			return true
+		}
+	}
+	return false
+}
+
+// process cherry-picks a PR to each of the target branches it wasn't yet cherry-picked to. Failed cherry-picks
+// are only attempted again when retrying, so conflicts don't lead to repeated attempts.
+func (cp *CherryPicker) process(context context.Context, org *config.Org, repo *github.Repository, pr *github.PullRequest,
+	targets []string, retry bool) {
+	orgLogin := repo.GetOwner().GetLogin()
+	repoName := repo.GetName()
+
+	for _, target := range targets {
+		existing, err := cp.store.ReadCherryPick(context, orgLogin, repoName, int64(pr.GetNumber()), target)
+		if err != nil {
+			scope.Errorf("Unable to read cherry-pick of pull request %d in repo %s/%s to %s: %v",
+				pr.GetNumber(), orgLogin, repoName, target, err)
+			continue
+		}
+
+		if existing != nil && (existing.State == stateSucceeded || !retry) {
+			continue
+		}
+
+		result := &storage.CherryPick{
+			OrgLogin:          orgLogin,
+			RepoName:          repoName,
+			PullRequestNumber: int64(pr.GetNumber()),
+			TargetBranch:      target,
+		}
+
+		var msg string
+		number, err := cp.cherryPick(context, org, orgLogin, repoName, pr, target)
+		if err != nil {
+			scope.Errorf("Unable to cherry-pick pull request %d in repo %s/%s to %s: %v", pr.GetNumber(), orgLogin, repoName, target, err)
+			result.State = stateFailed
+			result.Error = err.Error()
+			msg = fmt.Sprintf("Unable to cherry-pick this PR to %s: %v\n\nResolve the problem and comment `%s` to try again, "+
+				"or cherry-pick it manually.", target, err, RetryCommand)
+		} else {
+			scope.Infof("Cherry-picked pull request %d in repo %s/%s to %s in #%d", pr.GetNumber(), orgLogin, repoName, target, number)
+			result.State = stateSucceeded
+			result.CherryPickNumber = int64(number)
+			msg = fmt.Sprintf("Cherry-picked this PR to %s in #%d.", target, number)
+		}
+		result.UpdatedAt = time.Now()
+
+		if err := cp.store.WriteCherryPicks(context, []*storage.CherryPick{result}); err != nil {
+			scope.Errorf("Unable to record cherry-pick of pull request %d in repo %s/%s to %s: %v",
+				pr.GetNumber(), orgLogin, repoName, target, err)
+		}
+
+		if _, _, err := cp.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Issues.CreateComment(context, orgLogin, repoName, pr.GetNumber(), &github.IssueComment{Body: &msg})
+		}); err != nil {
+			scope.Errorf("Unable to comment on pull request %d in repo %s/%s: %v", pr.GetNumber(), orgLogin, repoName, err)
+		}
+	}
+}
+
+// cherryPick applies the changes of a PR's merge commit on top of the target branch, in a new branch from
+// which a PR is opened against the target branch. Returns the number of the new PR.
+//
+// The git data API can't cherry-pick directly, so this goes through a merge instead: the branch is first pointed
+// to a commit with the target branch's content whose parent is the merge commit's parent. Merging the merge commit
+// into that branch then results in the target branch's content along with the changes of the merge commit.
+// Finally, the branch is pointed to a commit with that content whose parent is the target branch.
+func (cp *CherryPicker) cherryPick(context context.Context, org *config.Org, orgLogin string, repoName string,
+	pr *github.PullRequest, target string) (int, error) {
+	result, _, err := cp.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Git.GetRef(context, orgLogin, repoName, "heads/"+target)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to find branch %s: %v", target, err)
+	}
+	targetSHA := result.(*github.Reference).GetObject().GetSHA()
+
+	targetCommit, err := cp.getCommit(context, orgLogin, repoName, targetSHA)
+	if err != nil {
+		return 0, err
+	}
+
+	pickedSHA := pr.GetMergeCommitSHA()
+	picked, err := cp.getCommit(context, orgLogin, repoName, pickedSHA)
+	if err != nil {
+		return 0, err
+	} else if len(picked.Parents) == 0 {
+		return 0, fmt.Errorf("merge commit %s has no parent", pickedSHA)
+	}
+
+	sibling, err := cp.createCommit(context, orgLogin, repoName, &github.Commit{
+		Message: github.String("Temporary commit for cherry-picking " + pickedSHA),
+		Tree:    &github.Tree{SHA: github.String(targetCommit.GetTree().GetSHA())},
+		Parents: []github.Commit{{SHA: picked.Parents[0].SHA}},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	branch := fmt.Sprintf("cherrypick-%d-to-%s", pr.GetNumber(), target)
+	ref := "refs/heads/" + branch
+	if err := cp.setRef(context, orgLogin, repoName, ref, sibling); err != nil {
+		return 0, err
+	}
+
+	result, _, err = cp.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Repositories.Merge(context, orgLogin, repoName, &github.RepositoryMergeRequest{
+			Base: github.String(branch),
+			Head: github.String(pickedSHA),
+		})
+	})
+	if err != nil {
+		cp.deleteRef(context, orgLogin, repoName, ref)
+		if er, ok := err.(*github.ErrorResponse); ok && er.Response.StatusCode == http.StatusConflict {
+			return 0, fmt.Errorf("the changes conflict with the content of %s", target)
+		}
+		return 0, fmt.Errorf("unable to apply the changes: %v", err)
+	}
+	merge := result.(*github.RepositoryCommit)
+	if merge == nil {
+		// nothing was merged
+		cp.deleteRef(context, orgLogin, repoName, ref)
+		return 0, fmt.Errorf("the changes are already in %s", target)
+	}
+
+	commit, err := cp.createCommit(context, orgLogin, repoName, &github.Commit{
+		Message: github.String(fmt.Sprintf("%s\n\n(cherry picked from commit %s)", picked.GetMessage(), pickedSHA)),
+		Tree:    &github.Tree{SHA: github.String(merge.GetCommit().GetTree().GetSHA())},
+		Parents: []github.Commit{{SHA: github.String(targetSHA)}},
+	})
+	if err != nil {
+		cp.deleteRef(context, orgLogin, repoName, ref)
+		return 0, err
+	}
+
+	if err := cp.setRef(context, orgLogin, repoName, ref, commit); err != nil {
+		cp.deleteRef(context, orgLogin, repoName, ref)
+		return 0, err
+	}
+
+	data := templateData{
+		Number: pr.GetNumber(),
+		Title:  pr.GetTitle(),
+		Body:   pr.GetBody(),
+		Author: pr.GetUser().GetLogin(),
+		Branch: target,
+	}
+
+	var t, b bytes.Buffer
+	if err := cp.templates[title(org.CherryPick)].Execute(&t, data); err != nil {
+		return 0, fmt.Errorf("unable to produce the title of the cherry-pick: %v", err)
+	}
+
+	if err := cp.templates[body(org.CherryPick)].Execute(&b, data); err != nil {
+		return 0, fmt.Errorf("unable to produce the body of the cherry-pick: %v", err)
+	}
+
+	result, _, err = cp.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.PullRequests.Create(context, orgLogin, repoName, &github.NewPullRequest{
+			Title: github.String(t.String()),
+			Body:  github.String(b.String()),
+			Head:  github.String(branch),
+			Base:  github.String(target),
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to open a pull request from %s: %v", branch, err)
+	}
+
+	return result.(*github.PullRequest).GetNumber(), nil
+}
+
+func (cp *CherryPicker) getCommit(context context.Context, orgLogin string, repoName string, sha string) (*github.Commit, error) {
+	result, _, err := cp.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Git.GetCommit(context, orgLogin, repoName, sha)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get commit %s: %v", sha, err)
+	}
+	return result.(*github.Commit), nil
+}
+
+// createCommit creates a commit and returns its SHA
+func (cp *CherryPicker) createCommit(context context.Context, orgLogin string, repoName string, commit *github.Commit) (string, error) {
+	result, _, err := cp.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Git.CreateCommit(context, orgLogin, repoName, commit)
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to create commit: %v", err)
+	}
+	return result.(*github.Commit).GetSHA(), nil
+}
+
+// setRef points a ref to a commit, creating the ref if it doesn't exist yet
+func (cp *CherryPicker) setRef(context context.Context, orgLogin string, repoName string, ref string, sha string) error {
+	r := &github.Reference{
+		Ref:    github.String(ref),
+		Object: &github.GitObject{SHA: github.String(sha)},
+	}
+
+	_, resp, err := cp.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Git.UpdateRef(context, orgLogin, repoName, r, true)
+	})
+	if err == nil {
+		return nil
+	} else if resp == nil || resp.StatusCode != http.StatusUnprocessableEntity {
+		return fmt.Errorf("unable to update %s: %v", ref, err)
+	}
+
+	// the ref doesn't exist yet
+	if _, _, err := cp.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Git.CreateRef(context, orgLogin, repoName, r)
+	}); err != nil {
+		return fmt.Errorf("unable to create %s: %v", ref, err)
+	}
+
+	return nil
+}
+
+func (cp *CherryPicker) deleteRef(context context.Context, orgLogin string, repoName string, ref string) {
+	if _, err := cp.gc.ThrottledCallNoResult(func(client *github.Client) (*github.Response, error) {
+		return client.Git.DeleteRef(context, orgLogin, repoName, ref)
+	}); err != nil {
+		scope.Errorf("Unable to delete %s in repo %s/%s: %v", ref, orgLogin, repoName, err)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cherrypicker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
+)
+
+type fakeStore struct {
+	storage.Store
+
+	cherryPicks map[string]*storage.CherryPick
+}
+
+func (fs *fakeStore) ReadCherryPick(_ context.Context, _ string, _ string, prNumber int64, targetBranch string) (*storage.CherryPick, error) {
+	return fs.cherryPicks[fmt.Sprintf("%d/%s", prNumber, targetBranch)], nil
+}
+
+func (fs *fakeStore) WriteCherryPicks(_ context.Context, cherryPicks []*storage.CherryPick) error {
+	for _, c := range cherryPicks {
+		fs.cherryPicks[fmt.Sprintf("%d/%s", c.PullRequestNumber, c.TargetBranch)] = c
+	}
+	return nil
+}
+
+// fakeGitHub implements the parts of GitHub's API used to cherry-pick PR 42 to release-1.3
+type fakeGitHub struct {
+	conflict bool
+	merges   int
+	commits  []map[string]interface{}
+	pulls    []*github.NewPullRequest
+	comments []string
+	refs     map[string]string
+}
+
+func (fg *fakeGitHub) mux(t *testing.T) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/repos/istio/istio/git/refs/heads/release-1.3", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"ref": "refs/heads/release-1.3", "object": {"sha": "target"}}`)
+	})
+	mux.HandleFunc("/repos/istio/istio/git/commits/target", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"sha": "target", "tree": {"sha": "target-tree"}, "parents": [{"sha": "old"}]}`)
+	})
+	mux.HandleFunc("/repos/istio/istio/git/commits/picked", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"sha": "picked", "message": "Fix a crash", "tree": {"sha": "picked-tree"}, "parents": [{"sha": "base"}]}`)
+	})
+	mux.HandleFunc("/repos/istio/istio/git/commits", func(w http.ResponseWriter, r *http.Request) {
+		var commit map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&commit); err != nil {
+			t.Fatalf("Unable to decode commit: %v", err)
+		}
+
+		fg.commits = append(fg.commits, commit)
+		_, _ = fmt.Fprintf(w, `{"sha": "commit-%d"}`, len(fg.commits))
+	})
+	mux.HandleFunc("/repos/istio/istio/git/refs", func(w http.ResponseWriter, r *http.Request) {
+		var ref github.Reference
+		if err := json.NewDecoder(r.Body).Decode(&ref); err != nil {
+			t.Fatalf("Unable to decode ref: %v", err)
+		}
+
+		fg.refs[ref.GetRef()] = ref.GetObject().GetSHA()
+		_ = json.NewEncoder(w).Encode(ref)
+	})
+	mux.HandleFunc("/repos/istio/istio/git/refs/heads/cherrypick-42-to-release-1.3", func(w http.ResponseWriter, r *http.Request) {
+		ref := "refs/heads/cherrypick-42-to-release-1.3"
+		switch r.Method {
+		case "DELETE":
+			delete(fg.refs, ref)
+			w.WriteHeader(http.StatusNoContent)
+
+		case "PATCH":
+			if _, ok := fg.refs[ref]; !ok {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = fmt.Fprint(w, `{"message": "Reference does not exist"}`)
+				return
+			}
+
+			var update struct {
+				SHA string `json:"sha"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				t.Fatalf("Unable to decode ref update: %v", err)
+			}
+
+			fg.refs[ref] = update.SHA
+			_, _ = fmt.Fprintf(w, `{"ref": "%s", "object": {"sha": "%s"}}`, ref, update.SHA)
+		}
+	})
+	mux.HandleFunc("/repos/istio/istio/merges", func(w http.ResponseWriter, r *http.Request) {
+		fg.merges++
+		if fg.conflict {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprint(w, `{"message": "Merge conflict"}`)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprint(w, `{"sha": "merge", "commit": {"tree": {"sha": "picked-on-target-tree"}}}`)
+	})
+	mux.HandleFunc("/repos/istio/istio/pulls", func(w http.ResponseWriter, r *http.Request) {
+		var pr github.NewPullRequest
+		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
+			t.Fatalf("Unable to decode pull request: %v", err)
+		}
+
+		fg.pulls = append(fg.pulls, &pr)
+		_, _ = fmt.Fprint(w, `{"number": 99}`)
+	})
+	mux.HandleFunc("/repos/istio/istio/pulls/42", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"number": 42, "merged": true, "merge_commit_sha": "picked", "title": "Fix a crash",
+			"labels": [{"name": "cherrypick/release-1.3"}]}`)
+	})
+	mux.HandleFunc("/repos/istio/istio/issues/42/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+			t.Fatalf("Unable to decode comment: %v", err)
+		}
+
+		fg.comments = append(fg.comments, comment.GetBody())
+		_ = json.NewEncoder(w).Encode(comment)
+	})
+
+	return mux
+}
+
+var repo = &github.Repository{
+	Name:     github.String("istio"),
+	FullName: github.String("istio/istio"),
+	Owner:    &github.User{Login: github.String("istio")},
+}
+
+func mergedEvent() *github.PullRequestEvent {
+	return &github.PullRequestEvent{
+		Action: github.String("closed"),
+		Number: github.Int(42),
+		Repo:   repo,
+		PullRequest: &github.PullRequest{
+			Number:         github.Int(42),
+			Title:          github.String("Fix a crash"),
+			Merged:         github.Bool(true),
+			MergeCommitSHA: github.String("picked"),
+			User:           &github.User{Login: github.String("alice")},
+			Labels:         []*github.Label{{Name: github.String("cherrypick/release-1.3")}, {Name: github.String("kind/bug")}},
+		},
+	}
+}
+
+func newCherryPicker(t *testing.T, fg *fakeGitHub) (*CherryPicker, *fakeStore, func()) {
+	server := httptest.NewServer(fg.mux(t))
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{cherryPicks: make(map[string]*storage.CherryPick)}
+	orgs := []config.Org{{
+		Name:       "istio",
+		Repos:      []config.Repo{{Name: "istio"}},
+		CherryPick: config.CherryPick{LabelPrefix: "cherrypick/"},
+	}}
+
+	f, err := NewCherryPicker(gh.NewThrottledClientForClient(client), store, orgs)
+	if err != nil {
+		t.Fatalf("Unable to create cherry-picker: %v", err)
+	}
+
+	return f.(*CherryPicker), store, server.Close
+}
+
+func TestCherryPick(t *testing.T) {
+	fg := &fakeGitHub{refs: make(map[string]string)}
+	cp, store, done := newCherryPicker(t, fg)
+	defer done()
+
+	cp.Handle(context.Background(), mergedEvent())
+
+	if len(fg.commits) != 2 {
+		t.Fatalf("Got %d commits, expecting 2", len(fg.commits))
+	}
+
+	// the temporary commit has the target's content on top of the picked commit's parent
+	if c := fmt.Sprint(fg.commits[0]["tree"], fg.commits[0]["parents"]); c != "target-tree [base]" {
+		t.Errorf("Got temporary commit with tree and parents %s, expecting target-tree [base]", c)
+	}
+
+	// the final commit has the merged content on top of the target
+	if c := fmt.Sprint(fg.commits[1]["tree"], fg.commits[1]["parents"]); c != "picked-on-target-tree [target]" {
+		t.Errorf("Got cherry-pick commit with tree and parents %s, expecting picked-on-target-tree [target]", c)
+	}
+
+	if sha := fg.refs["refs/heads/cherrypick-42-to-release-1.3"]; sha != "commit-2" {
+		t.Errorf("Got branch pointing to %s, expecting commit-2", sha)
+	}
+
+	if len(fg.pulls) != 1 || fg.pulls[0].GetTitle() != "[release-1.3] Fix a crash" || fg.pulls[0].GetBase() != "release-1.3" {
+		t.Errorf("Got pull requests %+v, expecting one against release-1.3", fg.pulls)
+	}
+
+	if len(fg.comments) != 1 || !strings.Contains(fg.comments[0], "#99") {
+		t.Errorf("Got comments %v, expecting one pointing to #99", fg.comments)
+	}
+
+	if c := store.cherryPicks["42/release-1.3"]; c == nil || c.State != stateSucceeded || c.CherryPickNumber != 99 {
+		t.Errorf("Got cherry-pick %+v, expecting a success recorded", c)
+	}
+
+	// the cherry-pick isn't repeated
+	cp.Handle(context.Background(), mergedEvent())
+	if fg.merges != 1 {
+		t.Errorf("Got %d merges, expecting 1", fg.merges)
+	}
+}
+
+func TestCherryPickConflict(t *testing.T) {
+	fg := &fakeGitHub{refs: make(map[string]string), conflict: true}
+	cp, store, done := newCherryPicker(t, fg)
+	defer done()
+
+	cp.Handle(context.Background(), mergedEvent())
+
+	if c := store.cherryPicks["42/release-1.3"]; c == nil || c.State != stateFailed {
+		t.Fatalf("Got cherry-pick %+v, expecting a failure recorded", c)
+	}
+
+	if len(fg.refs) != 0 {
+		t.Errorf("Got refs %v, expecting the temporary branch to be deleted", fg.refs)
+	}
+
+	if len(fg.comments) != 1 || !strings.Contains(fg.comments[0], RetryCommand) {
+		t.Errorf("Got comments %v, expecting one explaining how to retry", fg.comments)
+	}
+
+	// conflicts aren't retried on their own
+	cp.Handle(context.Background(), mergedEvent())
+	if fg.merges != 1 {
+		t.Errorf("Got %d merges, expecting 1", fg.merges)
+	}
+
+	// until asked to
+	fg.conflict = false
+	cp.Handle(context.Background(), &github.IssueCommentEvent{
+		Action:  github.String("created"),
+		Repo:    repo,
+		Issue:   &github.Issue{Number: github.Int(42), PullRequestLinks: &github.PullRequestLinks{}},
+		Comment: &github.IssueComment{Body: github.String("Conflicts resolved.\n" + RetryCommand)},
+	})
+
+	if fg.merges != 2 || len(fg.pulls) != 1 {
+		t.Errorf("Got %d merges and %d pull requests, expecting 2 and 1", fg.merges, len(fg.pulls))
+	}
+
+	if c := store.cherryPicks["42/release-1.3"]; c == nil || c.State != stateSucceeded {
+		t.Errorf("Got cherry-pick %+v, expecting a success recorded", c)
+	}
+}
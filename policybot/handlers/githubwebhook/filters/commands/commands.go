@@ -0,0 +1,226 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/handlers/githubwebhook/filters"
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/bots/policybot/pkg/storage/cache"
+	"istio.io/pkg/log"
+)
+
+// Executes commands found in issue and pull request comments, such as /kind bug or /close
+type Commands struct {
+	gc    *gh.ThrottledClient
+	cache *cache.Cache
+	store storage.Store
+	orgs  []config.Org
+}
+
+// a single command parsed from a comment
+type command struct {
+	name string
+	args []string
+}
+
+var scope = log.RegisterScope("commands", "Comment commands", 0)
+
+func NewCommands(gc *gh.ThrottledClient, cache *cache.Cache, store storage.Store, orgs []config.Org) filters.Filter {
+	return &Commands{
+		gc:    gc,
+		cache: cache,
+		store: store,
+		orgs:  orgs,
+	}
+}
+
+// the webhook events the filter reacts to
+func (c *Commands) Events() []string {
+	return []string{"issue_comment"}
+}
+
+// process an event arriving from GitHub
+func (c *Commands) Handle(context context.Context, event interface{}) {
+	ice, ok := event.(*github.IssueCommentEvent)
+	if !ok {
+		// not what we're looking for
+		return
+	}
+
+	if filters.IsReplay(context) {
+		// the commands were executed when the event first arrived
+		return
+	}
+
+	if ice.GetAction() != "created" {
+		// edits don't re-run commands
+		return
+	}
+
+	repo := ice.GetRepo().GetFullName()
+	if config.FindOrgForRepo(c.orgs, repo) == nil {
+		scope.Infof("Ignoring comment on %d from repo %s since it's not in a monitored repo", ice.GetIssue().GetNumber(), repo)
+		return
+	}
+
+	orgLogin := ice.GetRepo().GetOwner().GetLogin()
+	repoName := ice.GetRepo().GetName()
+	number := ice.GetIssue().GetNumber()
+	author := ice.GetComment().GetUser().GetLogin()
+
+	for _, cmd := range parseCommands(ice.GetComment().GetBody()) {
+		var executed bool
+		switch cmd.name {
+		case "area", "kind", "priority":
+			executed = c.label(context, orgLogin, repoName, number, cmd)
+		case "assign":
+			executed = c.assign(context, orgLogin, repoName, number, author, cmd)
+		case "close":
+			executed = c.setState(context, orgLogin, repoName, number, author, "closed")
+		case "reopen":
+			executed = c.setState(context, orgLogin, repoName, number, author, "open")
+		default:
+			// not a command we know about
+			continue
+		}
+
+		if executed {
+			c.record(context, orgLogin, repoName, number, author, cmd)
+		}
+	}
+}
+
+// label applies a label of the form <command>/<arg>, provided the label exists in the repo.
+func (c *Commands) label(context context.Context, orgLogin string, repoName string, number int, cmd command) bool {
+	if len(cmd.args) == 0 {
+		return false
+	}
+
+	name := cmd.name + "/" + cmd.args[0]
+	label, err := c.cache.ReadLabel(context, orgLogin, repoName, name)
+	if err != nil {
+		scope.Errorf("Unable to read label %s in repo %s/%s: %v", name, orgLogin, repoName, err)
+		return false
+	} else if label == nil {
+		scope.Infof("Ignoring request to apply unknown label %s to %d in repo %s/%s", name, number, orgLogin, repoName)
+		return false
+	}
+
+	if _, _, err := c.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Issues.AddLabelsToIssue(context, orgLogin, repoName, number, []string{name})
+	}); err != nil {
+		scope.Errorf("Unable to apply label %s to %d in repo %s/%s: %v", name, number, orgLogin, repoName, err)
+		return false
+	}
+
+	return true
+}
+
+// assign assigns the given users, or the comment's author when no users are given. Anyone can
+// assign themselves, but only org members can assign others.
+func (c *Commands) assign(context context.Context, orgLogin string, repoName string, number int, author string, cmd command) bool {
+	assignees := []string{author}
+	if len(cmd.args) > 0 {
+		assignees = nil
+		for _, arg := range cmd.args {
+			assignees = append(assignees, strings.TrimPrefix(arg, "@"))
+		}
+	}
+
+	for _, assignee := range assignees {
+		if !strings.EqualFold(assignee, author) && !c.isMember(context, orgLogin, author) {
+			scope.Infof("Ignoring request from non-member %s to assign %s to %d in repo %s/%s", author, assignee, number, orgLogin, repoName)
+			return false
+		}
+	}
+
+	if _, _, err := c.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Issues.AddAssignees(context, orgLogin, repoName, number, assignees)
+	}); err != nil {
+		scope.Errorf("Unable to assign %v to %d in repo %s/%s: %v", assignees, number, orgLogin, repoName, err)
+		return false
+	}
+
+	return true
+}
+
+// setState closes or reopens an issue or pull request, which only org members can do.
+func (c *Commands) setState(context context.Context, orgLogin string, repoName string, number int, author string, state string) bool {
+	if !c.isMember(context, orgLogin, author) {
+		scope.Infof("Ignoring request from non-member %s to change the state of %d in repo %s/%s", author, number, orgLogin, repoName)
+		return false
+	}
+
+	if _, _, err := c.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Issues.Edit(context, orgLogin, repoName, number, &github.IssueRequest{State: &state})
+	}); err != nil {
+		scope.Errorf("Unable to change the state of %d in repo %s/%s to %s: %v", number, orgLogin, repoName, state, err)
+		return false
+	}
+
+	return true
+}
+
+func (c *Commands) isMember(context context.Context, orgLogin string, userLogin string) bool {
+	member, err := c.cache.ReadMember(context, orgLogin, userLogin)
+	if err != nil {
+		scope.Errorf("Unable to read member %s of org %s: %v", userLogin, orgLogin, err)
+		return false
+	}
+
+	return member != nil
+}
+
+// record keeps track of an executed command for auditing purposes.
+func (c *Commands) record(context context.Context, orgLogin string, repoName string, number int, author string, cmd command) {
+	event := &storage.IssueEvent{
+		OrgLogin:    orgLogin,
+		RepoName:    repoName,
+		IssueNumber: int64(number),
+		CreatedAt:   time.Now(),
+		Actor:       author,
+		Action:      strings.TrimSpace("/" + cmd.name + " " + strings.Join(cmd.args, " ")),
+	}
+
+	if err := c.store.WriteIssueEvents(context, []*storage.IssueEvent{event}); err != nil {
+		scope.Errorf("Unable to record command %s on %d in repo %s/%s: %v", event.Action, number, orgLogin, repoName, err)
+	}
+}
+
+// parseCommands extracts the commands from a comment, one per line.
+func parseCommands(body string) []command {
+	var result []command
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") || len(fields[0]) == 1 {
+			continue
+		}
+
+		result = append(result, command{
+			name: strings.ToLower(fields[0][1:]),
+			args: fields[1:],
+		})
+	}
+
+	return result
+}
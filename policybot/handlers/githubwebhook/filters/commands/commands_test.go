@@ -0,0 +1,135 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/bots/policybot/pkg/storage/cache"
+)
+
+type fakeStore struct {
+	storage.Store
+
+	events []*storage.IssueEvent
+}
+
+func (fs *fakeStore) ReadLabel(_ context.Context, _ string, _ string, labelName string) (*storage.Label, error) {
+	if labelName == "kind/bug" {
+		return &storage.Label{LabelName: labelName}, nil
+	}
+	return nil, nil
+}
+
+func (fs *fakeStore) ReadMember(_ context.Context, orgLogin string, userLogin string) (*storage.Member, error) {
+	if userLogin == "maintainer" {
+		return &storage.Member{OrgLogin: orgLogin, UserLogin: userLogin, Role: "member"}, nil
+	}
+	return nil, nil
+}
+
+func (fs *fakeStore) WriteIssueEvents(_ context.Context, events []*storage.IssueEvent) error {
+	fs.events = append(fs.events, events...)
+	return nil
+}
+
+func TestParseCommands(t *testing.T) {
+	body := "Thanks for the report.\n/kind bug\n  /AREA networking  \nsee /tmp/foo\n/\n/assign @alice @bob"
+
+	got := fmt.Sprint(parseCommands(body))
+	expected := "[{kind [bug]} {area [networking]} {assign [@alice @bob]}]"
+	if got != expected {
+		t.Errorf("Got %s, expecting %s", got, expected)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	cases := []struct {
+		author   string
+		body     string
+		calls    []string
+		recorded []string
+	}{
+		{"outsider", "/kind bug", []string{"POST /repos/istio/istio/issues/42/labels"}, []string{"/kind bug"}},
+		{"outsider", "/kind nonsense", nil, nil},
+		{"outsider", "/assign", []string{"POST /repos/istio/istio/issues/42/assignees"}, []string{"/assign"}},
+		{"outsider", "/assign @maintainer", nil, nil},
+		{"outsider", "/close", nil, nil},
+		{"maintainer", "/close\n/frobnicate", []string{"PATCH /repos/istio/istio/issues/42"}, []string{"/close"}},
+		{"maintainer", "/assign @outsider", []string{"POST /repos/istio/istio/issues/42/assignees"}, []string{"/assign @outsider"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.author+" "+c.body, func(t *testing.T) {
+			var calls []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, r.Method+" "+r.URL.Path)
+				if strings.HasSuffix(r.URL.Path, "/labels") {
+					_, _ = w.Write([]byte("[]"))
+				} else {
+					_, _ = w.Write([]byte("{}"))
+				}
+			}))
+			defer server.Close()
+
+			client := github.NewClient(nil)
+			client.BaseURL, _ = url.Parse(server.URL + "/")
+
+			store := &fakeStore{}
+			orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+			f := NewCommands(gh.NewThrottledClientForClient(client), cache.New(store, time.Minute), store, orgs)
+
+			payload, _ := json.Marshal(map[string]interface{}{
+				"action":     "created",
+				"issue":      map[string]interface{}{"number": 42},
+				"comment":    map[string]interface{}{"body": c.body, "user": map[string]interface{}{"login": c.author}},
+				"repository": map[string]interface{}{"name": "istio", "full_name": "istio/istio", "owner": map[string]interface{}{"login": "istio"}},
+			})
+
+			event, err := github.ParseWebHook("issue_comment", payload)
+			if err != nil {
+				t.Fatalf("Unable to parse payload: %v", err)
+			}
+
+			f.Handle(context.Background(), event)
+
+			if fmt.Sprint(calls) != fmt.Sprint(c.calls) {
+				t.Errorf("Got calls %v, expecting %v", calls, c.calls)
+			}
+
+			var recorded []string
+			for _, e := range store.events {
+				recorded = append(recorded, e.Action)
+			}
+
+			if fmt.Sprint(recorded) != fmt.Sprint(c.recorded) {
+				t.Errorf("Got commands %v recorded, expecting %v", recorded, c.recorded)
+			}
+		})
+	}
+}
@@ -16,12 +16,73 @@ package filters
 
 import (
 	"context"
+	"time"
 )
 
 // The interface to a GitHub webhook filter.
-//
-// Note that individual filters are invoked for any events incoming to the
-// bot.
 type Filter interface {
+	// Events returns the webhook event types the filter wants to receive, as reported in
+	// GitHub's X-GitHub-Event header (for example "pull_request"). A filter which returns an
+	// empty list receives every event incoming to the bot.
+	Events() []string
+
 	Handle(context context.Context, event interface{})
 }
+
+type replayKey struct{}
+
+// WithReplay returns a context indicating that events are being replayed from the archive rather
+// than arriving from GitHub. Filters which act on GitHub in response to events, for example by posting
+// comments, can use IsReplay to avoid acting twice on the same event.
+func WithReplay(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replayKey{}, true)
+}
+
+// IsReplay returns whether the event being handled is being replayed from the archive.
+func IsReplay(ctx context.Context) bool {
+	replay, _ := ctx.Value(replayKey{}).(bool)
+	return replay
+}
+
+type receivedAtKey struct{}
+
+// WithReceivedAt returns a context recording when the event being handled was received from GitHub.
+func WithReceivedAt(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, receivedAtKey{}, t)
+}
+
+// ReceivedAt returns when the event being handled was received from GitHub, or the current time if unknown.
+func ReceivedAt(ctx context.Context) time.Time {
+	if t, ok := ctx.Value(receivedAtKey{}).(time.Time); ok {
+		return t
+	}
+	return time.Now()
+}
+
+type payloadKey struct{}
+
+// WithPayload returns a context carrying the raw JSON payload of the event being handled.
+func WithPayload(ctx context.Context, payload []byte) context.Context {
+	return context.WithValue(ctx, payloadKey{}, payload)
+}
+
+// Payload returns the raw JSON payload of the event being handled, for filters needing fields
+// which aren't surfaced by the parsed event. Returns nil if unknown.
+func Payload(ctx context.Context) []byte {
+	payload, _ := ctx.Value(payloadKey{}).([]byte)
+	return payload
+}
+
+type eventTypeKey struct{}
+
+// WithEventType returns a context recording the type of the event being handled, as reported in GitHub's
+// X-GitHub-Event header.
+func WithEventType(ctx context.Context, eventType string) context.Context {
+	return context.WithValue(ctx, eventTypeKey{}, eventType)
+}
+
+// EventType returns the type of the event being handled, or an empty string if unknown.
+func EventType(ctx context.Context) string {
+	eventType, _ := ctx.Value(eventTypeKey{}).(string)
+	return eventType
+}
@@ -17,7 +17,10 @@ package labeler
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"regexp"
+	"strings"
+	"text/template"
 
 	"github.com/google/go-github/v26/github"
 
@@ -35,49 +38,68 @@ type Labeler struct {
 	gc                *gh.ThrottledClient
 	orgs              []config.Org
 	autoLabels        []config.AutoLabel
+	repoAutoLabels    map[string][]config.AutoLabel // index is org/repo
 	singleLineRegexes map[string]*regexp.Regexp
 	multiLineRegexes  map[string]*regexp.Regexp
-	repos             map[string][]config.AutoLabel // index is org/repo, value is org-level auto-labels
+	commentTemplates  map[string]*template.Template // index is the template text
+	labels            map[string]config.LabelDefinition
 }
 
 var scope = log.RegisterScope("labeler", "Issue and PR auto-labeler", 0)
 
-func NewLabeler(gc *gh.ThrottledClient, cache *cache.Cache, orgs []config.Org, autoLabels []config.AutoLabel) (filters.Filter, error) {
+func NewLabeler(gc *gh.ThrottledClient, cache *cache.Cache, orgs []config.Org, autoLabels []config.AutoLabel,
+	labels []config.LabelDefinition) (filters.Filter, error) {
 	l := &Labeler{
 		cache:             cache,
 		gc:                gc,
 		orgs:              orgs,
 		autoLabels:        autoLabels,
+		repoAutoLabels:    make(map[string][]config.AutoLabel),
 		singleLineRegexes: make(map[string]*regexp.Regexp),
 		multiLineRegexes:  make(map[string]*regexp.Regexp),
-		repos:             make(map[string][]config.AutoLabel),
+		commentTemplates:  make(map[string]*template.Template),
+		labels:            make(map[string]config.LabelDefinition),
 	}
 
-	for _, al := range autoLabels {
-		if err := l.processAutoLabelRegexes(al); err != nil {
-			return nil, err
+	for _, ld := range labels {
+		l.labels[ld.Name] = ld
+	}
+
+	for i, al := range autoLabels {
+		if err := l.processAutoLabel(al); err != nil {
+			return nil, fmt.Errorf("invalid global auto label #%d (%s): %v", i+1, al.Name, err)
 		}
 	}
 
 	for _, org := range orgs {
-		for _, al := range org.AutoLabels {
-			if err := l.processAutoLabelRegexes(al); err != nil {
-				return nil, err
+		for i, al := range org.AutoLabels {
+			if err := l.processAutoLabel(al); err != nil {
+				return nil, fmt.Errorf("invalid auto label #%d (%s) for org %s: %v", i+1, al.Name, org.Name, err)
 			}
 		}
-	}
 
-	for _, org := range orgs {
 		for _, repo := range org.Repos {
-			l.repos[org.Name+"/"+repo.Name] = org.AutoLabels
+			for i, al := range repo.AutoLabels {
+				if err := l.processAutoLabel(al); err != nil {
+					return nil, fmt.Errorf("invalid auto label #%d (%s) for repo %s/%s: %v", i+1, al.Name, org.Name, repo.Name, err)
+				}
+			}
+
+			if len(repo.AutoLabels) > 0 {
+				l.repoAutoLabels[org.Name+"/"+repo.Name] = repo.AutoLabels
+			}
 		}
 	}
 
 	return l, nil
 }
 
-// Precompile all the regexes
-func (l *Labeler) processAutoLabelRegexes(al config.AutoLabel) error {
+// Validate an auto label and precompile all its regexes
+func (l *Labeler) processAutoLabel(al config.AutoLabel) error {
+	if err := al.Validate(); err != nil {
+		return err
+	}
+
 	for _, expr := range al.MatchTitle {
 		r, err := regexp.Compile("(?i)" + expr)
 		if err != nil {
@@ -94,6 +116,14 @@ func (l *Labeler) processAutoLabelRegexes(al config.AutoLabel) error {
 		l.multiLineRegexes[expr] = r
 	}
 
+	for _, expr := range al.MatchFiles {
+		r, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression %s: %v", expr, err)
+		}
+		l.singleLineRegexes[expr] = r
+	}
+
 	for _, expr := range al.AbsentLabels {
 		r, err := regexp.Compile("(?i)" + expr)
 		if err != nil {
@@ -102,25 +132,51 @@ func (l *Labeler) processAutoLabelRegexes(al config.AutoLabel) error {
 		l.singleLineRegexes[expr] = r
 	}
 
+	for _, expr := range al.PresentLabels {
+		r, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression %s: %v", expr, err)
+		}
+		l.singleLineRegexes[expr] = r
+	}
+
+	if al.Comment != "" {
+		t, err := template.New(al.Name).Parse(al.Comment)
+		if err != nil {
+			return fmt.Errorf("invalid comment template: %v", err)
+		}
+		l.commentTemplates[al.Comment] = t
+	}
+
 	return nil
 }
 
+// the webhook events the filter reacts to
+func (l *Labeler) Events() []string {
+	return []string{"issues", "pull_request"}
+}
+
 // process an event arriving from GitHub
 func (l *Labeler) Handle(context context.Context, event interface{}) {
+	if filters.IsReplay(context) {
+		// the labels were applied when the event first arrived
+		return
+	}
+
 	action := ""
 	repo := ""
 	number := 0
 	var issue *storage.Issue
 	var pr *storage.PullRequest
 
-	ip, ok := event.(*github.IssueEvent)
+	ip, ok := event.(*github.IssuesEvent)
 	if ok {
-		action = ip.GetEvent()
-		repo = ip.GetIssue().GetRepository().GetFullName()
+		action = ip.GetAction()
+		repo = ip.GetRepo().GetFullName()
 		number = ip.GetIssue().GetNumber()
 		issue, _ = gh.ConvertIssue(
-			ip.GetIssue().GetRepository().GetOwner().GetLogin(),
-			ip.GetIssue().GetRepository().GetName(),
+			ip.GetRepo().GetOwner().GetLogin(),
+			ip.GetRepo().GetName(),
 			ip.GetIssue())
 	}
 
@@ -136,27 +192,145 @@ func (l *Labeler) Handle(context context.Context, event interface{}) {
 			nil)
 	}
 
-	if action != "opened" && action != "review_requested" {
+	if action != "opened" && action != "review_requested" && action != "edited" && action != "reopened" && action != "synchronize" {
 		// not what we care about
 		return
 	}
 
 	// see if the event is in a repo we're monitoring
-	autoLabels, ok := l.repos[repo]
-	if !ok {
+	org := config.FindOrgForRepo(l.orgs, repo)
+	if org == nil {
 		scope.Infof("Ignoring event %d from repo %s since it's not in a monitored repo", number, repo)
 		return
 	}
 
 	scope.Infof("Processing event %d from repo %s", number, repo)
 
+	// org-level auto labels are evaluated ahead of repo-level ones
+	autoLabels := append(append([]config.AutoLabel{}, org.AutoLabels...), l.repoAutoLabels[repo]...)
+
+	// new commits only lead to checking for labels that no longer apply, while edits lead to a full reconciliation
+	changed := action == "edited" || action == "synchronize"
+	reconcile := action == "edited" || action == "reopened"
+
+	if reconcile {
+		// the same event can be delivered several times, so the payload's labels may be out of date
+		if issue != nil {
+			labels, err := l.getLabels(context, issue.OrgLogin, issue.RepoName, number)
+			if err != nil {
+				scope.Errorf("Unable to list the labels of issue %d in repo %s: %v", number, repo, err)
+				return
+			}
+			issue.Labels = labels
+		} else {
+			labels, err := l.getLabels(context, pr.OrgLogin, pr.RepoName, number)
+			if err != nil {
+				scope.Errorf("Unable to list the labels of pull request %d in repo %s: %v", number, repo, err)
+				return
+			}
+			pr.Labels = labels
+		}
+	}
+
 	if issue != nil {
-		l.processIssue(context, issue, autoLabels)
+		if changed {
+			l.removeMismatched(context, issue.OrgLogin, issue.RepoName, issue.IssueNumber, issue.Title, issue.Body, nil, issue.Labels, autoLabels)
+		}
+
+		if action != "synchronize" {
+			l.processIssue(context, issue, autoLabels)
+		}
 	} else {
-		l.processPullRequest(context, pr, autoLabels)
+		// the payload doesn't supply the set of files comprising the PR, so only list them when they matter
+		if l.matchesFiles(autoLabels) {
+			files, err := l.getFiles(context, pr)
+			if err != nil {
+				scope.Errorf("Unable to list all files for pull request %d in repo %s: %v", number, repo, err)
+				return
+			}
+			pr.Files = files
+		}
+
+		if changed {
+			l.removeMismatched(context, pr.OrgLogin, pr.RepoName, pr.PullRequestNumber, pr.Title, pr.Body, pr.Files, pr.Labels, autoLabels)
+		}
+
+		if action != "synchronize" {
+			l.processPullRequest(context, pr, autoLabels)
+		}
 	}
 }
 
+// getLabels returns the names of the labels currently on an issue or PR.
+func (l *Labeler) getLabels(context context.Context, orgLogin string, repoName string, number int) ([]string, error) {
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+
+	var allLabels []string
+	for {
+		labels, resp, err := l.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Issues.ListLabelsByIssue(context, orgLogin, repoName, number, opt)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, label := range labels.([]*github.Label) {
+			allLabels = append(allLabels, label.GetName())
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opt.Page = resp.NextPage
+	}
+
+	return allLabels, nil
+}
+
+// matchesFiles returns whether any of the global auto labels or the given ones has expressions for the files of a PR.
+func (l *Labeler) matchesFiles(orgALs []config.AutoLabel) bool {
+	for _, al := range append(append([]config.AutoLabel{}, l.autoLabels...), orgALs...) {
+		if len(al.MatchFiles) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *Labeler) getFiles(context context.Context, pr *storage.PullRequest) ([]string, error) {
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+
+	var allFiles []string
+	for {
+		files, resp, err := l.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.PullRequests.ListFiles(context, pr.OrgLogin, pr.RepoName, int(pr.PullRequestNumber), opt)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files.([]*github.CommitFile) {
+			allFiles = append(allFiles, f.GetFilename())
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opt.Page = resp.NextPage
+	}
+
+	return allFiles, nil
+}
+
 func (l *Labeler) processIssue(context context.Context, issue *storage.Issue, orgALs []config.AutoLabel) {
 	// get all the issue's labels
 	var labels []*storage.Label
@@ -171,21 +345,26 @@ func (l *Labeler) processIssue(context context.Context, issue *storage.Issue, or
 	}
 
 	// find any matching global auto labels
-	var toApply []string
+	var matched []config.AutoLabel
 	for _, al := range l.autoLabels {
-		if l.matchAutoLabel(al, issue.Title, issue.Body, labels) {
-			toApply = append(toApply, al.Labels...)
+		if l.matchAutoLabel(al, issue.Title, issue.Body, nil, labels) {
+			matched = append(matched, al)
 		}
 	}
 
-	// find any matching org-level auto labels
+	// find any matching org and repo-level auto labels
 	for _, al := range orgALs {
-		if l.matchAutoLabel(al, issue.Title, issue.Body, labels) {
-			toApply = append(toApply, al.Labels...)
+		if l.matchAutoLabel(al, issue.Title, issue.Body, nil, labels) {
+			matched = append(matched, al)
 		}
 	}
 
+	toApply, comments := l.newLabels(matched, issue.Labels, issue.Author)
+	toRemove := labelsToRemove(matched, issue.Labels, toApply)
+
 	if len(toApply) > 0 {
+		l.ensureLabels(context, issue.OrgLogin, issue.RepoName, toApply)
+
 		if _, _, err := l.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
 			return client.Issues.AddLabelsToIssue(context, issue.OrgLogin, issue.RepoName, int(issue.IssueNumber), toApply)
 		}); err != nil {
@@ -194,7 +373,14 @@ func (l *Labeler) processIssue(context context.Context, issue *storage.Issue, or
 		}
 	}
 
-	scope.Infof("Applied %d label(s) to issue %d from repo %s/%s", len(toApply), issue.IssueNumber, issue.OrgLogin, issue.RepoName)
+	l.postComments(context, issue.OrgLogin, issue.RepoName, issue.IssueNumber, comments)
+
+	if !l.removeLabels(context, issue.OrgLogin, issue.RepoName, issue.IssueNumber, toRemove) {
+		return
+	}
+
+	scope.Infof("Applied %d label(s) to and removed %d label(s) from issue %d from repo %s/%s", len(toApply), len(toRemove),
+		issue.IssueNumber, issue.OrgLogin, issue.RepoName)
 }
 
 func (l *Labeler) processPullRequest(context context.Context, pr *storage.PullRequest, orgALs []config.AutoLabel) {
@@ -211,21 +397,26 @@ func (l *Labeler) processPullRequest(context context.Context, pr *storage.PullRe
 	}
 
 	// find any matching global auto labels
-	var toApply []string
+	var matched []config.AutoLabel
 	for _, al := range l.autoLabels {
-		if l.matchAutoLabel(al, pr.Title, pr.Body, labels) {
-			toApply = append(toApply, al.Labels...)
+		if l.matchAutoLabel(al, pr.Title, pr.Body, pr.Files, labels) {
+			matched = append(matched, al)
 		}
 	}
 
-	// find any matching org-level auto labels
+	// find any matching org and repo-level auto labels
 	for _, al := range orgALs {
-		if l.matchAutoLabel(al, pr.Title, pr.Body, labels) {
-			toApply = append(toApply, al.Labels...)
+		if l.matchAutoLabel(al, pr.Title, pr.Body, pr.Files, labels) {
+			matched = append(matched, al)
 		}
 	}
 
+	toApply, comments := l.newLabels(matched, pr.Labels, pr.Author)
+	toRemove := labelsToRemove(matched, pr.Labels, toApply)
+
 	if len(toApply) > 0 {
+		l.ensureLabels(context, pr.OrgLogin, pr.RepoName, toApply)
+
 		if _, _, err := l.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
 			return client.Issues.AddLabelsToIssue(context, pr.OrgLogin, pr.RepoName, int(pr.PullRequestNumber), toApply)
 		}); err != nil {
@@ -234,23 +425,230 @@ func (l *Labeler) processPullRequest(context context.Context, pr *storage.PullRe
 		}
 	}
 
-	scope.Infof("Applied %d label(s) to pr %d from repo %s/%s", len(toApply), pr.PullRequestNumber, pr.OrgLogin, pr.RepoName)
+	l.postComments(context, pr.OrgLogin, pr.RepoName, pr.PullRequestNumber, comments)
+
+	if !l.removeLabels(context, pr.OrgLogin, pr.RepoName, pr.PullRequestNumber, toRemove) {
+		return
+	}
+
+	scope.Infof("Applied %d label(s) to and removed %d label(s) from pr %d from repo %s/%s", len(toApply), len(toRemove),
+		pr.PullRequestNumber, pr.OrgLogin, pr.RepoName)
+}
+
+// ensureLabels creates any of the given labels which are missing from a repo, as per their configured definitions.
+// Labels without a definition are left for GitHub to deal with when they're applied.
+func (l *Labeler) ensureLabels(context context.Context, orgLogin string, repoName string, labels []string) {
+	if org := config.FindOrgForRepo(l.orgs, orgLogin+"/"+repoName); org != nil && org.DisableLabelCreation {
+		return
+	}
+
+	for _, name := range labels {
+		ld, ok := l.labels[name]
+		if !ok {
+			continue
+		}
+
+		existing, err := l.cache.ReadLabel(context, orgLogin, repoName, name)
+		if err != nil {
+			scope.Errorf("Unable to read label %s in repo %s/%s: %v", name, orgLogin, repoName, err)
+			continue
+		} else if existing != nil {
+			continue
+		}
+
+		label, resp, err := l.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Issues.CreateLabel(context, orgLogin, repoName, &github.Label{
+				Name:        &ld.Name,
+				Color:       &ld.Color,
+				Description: &ld.Description,
+			})
+		})
+
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusUnprocessableEntity {
+				// the label exists, storage just hasn't caught up yet
+				continue
+			}
+
+			scope.Errorf("Unable to create label %s in repo %s/%s: %v", name, orgLogin, repoName, err)
+			continue
+		}
+
+		scope.Infof("Created label %s in repo %s/%s", name, orgLogin, repoName)
+
+		if err := l.cache.WriteLabels(context, []*storage.Label{gh.ConvertLabel(orgLogin, repoName, label.(*github.Label))}); err != nil {
+			scope.Errorf("Unable to write label %s in repo %s/%s: %v", name, orgLogin, repoName, err)
+		}
+	}
+}
+
+// newLabels returns the labels of the matched auto labels which aren't already present, along with the comments to
+// post about them. Auto labels whose labels are all present already don't get to comment, so that nothing is said twice.
+func (l *Labeler) newLabels(matched []config.AutoLabel, present []string, author string) ([]string, []string) {
+	have := make(map[string]bool, len(present))
+	for _, label := range present {
+		have[label] = true
+	}
+
+	var toApply []string
+	var comments []string
+	for _, al := range matched {
+		var added []string
+		for _, label := range al.Labels {
+			if !have[label] {
+				have[label] = true
+				added = append(added, label)
+			}
+		}
+
+		toApply = append(toApply, added...)
+
+		if al.Comment == "" || len(added) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		data := struct {
+			Author string
+			Labels string
+		}{author, strings.Join(added, ", ")}
+
+		if err := l.commentTemplates[al.Comment].Execute(&b, data); err != nil {
+			scope.Errorf("Unable to produce comment for auto label %s: %v", al.Name, err)
+			continue
+		}
+
+		comments = append(comments, b.String())
+	}
+
+	return toApply, comments
+}
+
+// labelsToRemove returns the RemoveLabels of the matched auto labels which are present, other than those being applied.
+func labelsToRemove(matched []config.AutoLabel, present []string, toApply []string) []string {
+	have := make(map[string]bool, len(present))
+	for _, label := range present {
+		have[label] = true
+	}
+
+	// labels applied by one rule win over the same labels being removed by another
+	for _, label := range toApply {
+		have[label] = false
+	}
+
+	var toRemove []string
+	for _, al := range matched {
+		for _, label := range al.RemoveLabels {
+			if have[label] {
+				toRemove = append(toRemove, label)
+
+				// in case several auto-labels remove the label
+				have[label] = false
+			}
+		}
+	}
+
+	return toRemove
+}
+
+// removeLabels removes labels from an issue or PR, returning false on failure.
+func (l *Labeler) removeLabels(context context.Context, orgLogin string, repoName string, number int64, labels []string) bool {
+	for _, label := range labels {
+		if _, err := l.gc.ThrottledCallNoResult(func(client *github.Client) (*github.Response, error) {
+			return client.Issues.RemoveLabelForIssue(context, orgLogin, repoName, int(number), label)
+		}); err != nil {
+			scope.Errorf("Unable to remove label %s from %d in repo %s/%s: %v", label, number, orgLogin, repoName, err)
+			return false
+		}
+	}
+
+	return true
+}
+
+func (l *Labeler) postComments(context context.Context, orgLogin string, repoName string, number int64, comments []string) {
+	for _, comment := range comments {
+		body := comment
+		if _, _, err := l.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Issues.CreateComment(context, orgLogin, repoName, int(number), &github.IssueComment{Body: &body})
+		}); err != nil {
+			scope.Errorf("Unable to post comment on %d in repo %s/%s: %v", number, orgLogin, repoName, err)
+			return
+		}
+	}
+}
+
+// removeMismatched removes the labels of RemoveOnMismatch auto-labels whose expressions no longer match a PR or issue.
+// Only the labels named by those auto-labels are ever removed, so labels applied by people are left alone.
+func (l *Labeler) removeMismatched(context context.Context, orgLogin string, repoName string, number int64,
+	title string, body string, files []string, labels []string, orgALs []config.AutoLabel) {
+
+	present := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		present[label] = true
+	}
+
+	var toRemove []string
+	for _, al := range append(append([]config.AutoLabel{}, l.autoLabels...), orgALs...) {
+		if !al.RemoveOnMismatch || l.contentMatch(al, title, body, files) {
+			continue
+		}
+
+		for _, label := range al.Labels {
+			if present[label] {
+				toRemove = append(toRemove, label)
+
+				// in case several auto-labels share the label
+				present[label] = false
+			}
+		}
+	}
+
+	if !l.removeLabels(context, orgLogin, repoName, number, toRemove) {
+		return
+	}
+
+	if len(toRemove) > 0 {
+		scope.Infof("Removed %d label(s) from %d in repo %s/%s", len(toRemove), number, orgLogin, repoName)
+	}
 }
 
-func (l *Labeler) matchAutoLabel(al config.AutoLabel, title string, body string, labels []*storage.Label) bool {
-	// if the title and body don't match, we're done
-	if !l.titleMatch(al, title) && !l.bodyMatch(al, body) {
+func (l *Labeler) matchAutoLabel(al config.AutoLabel, title string, body string, files []string, labels []*storage.Label) bool {
+	// if the title, body, and files don't match, we're done
+	if !l.contentMatch(al, title, body, files) {
 		return false
 	}
 
 	// if any labels match, we're done
+	present := false
 	for _, label := range labels {
-		if l.labelMatch(al, label.LabelName) {
+		if l.labelMatch(al.AbsentLabels, label.LabelName) {
 			return false
 		}
+
+		if l.labelMatch(al.PresentLabels, label.LabelName) {
+			present = true
+		}
 	}
 
-	return true
+	// if required labels are missing, we're done
+	return present || len(al.PresentLabels) == 0
+}
+
+// contentMatch evaluates the title, body, and files conditions of an auto label. With RequireAll, each condition
+// which has expressions must match, and there must be at least one such condition. Otherwise, any matching condition
+// will do.
+func (l *Labeler) contentMatch(al config.AutoLabel, title string, body string, files []string) bool {
+	if al.Require != config.RequireAll {
+		return l.titleMatch(al, title) || l.bodyMatch(al, body) || l.filesMatch(al, files)
+	}
+
+	if len(al.MatchTitle) == 0 && len(al.MatchBody) == 0 && len(al.MatchFiles) == 0 {
+		return false
+	}
+
+	return (len(al.MatchTitle) == 0 || l.titleMatch(al, title)) &&
+		(len(al.MatchBody) == 0 || l.bodyMatch(al, body)) &&
+		(len(al.MatchFiles) == 0 || l.filesMatch(al, files))
 }
 
 func (l *Labeler) titleMatch(al config.AutoLabel, title string) bool {
@@ -275,8 +673,21 @@ func (l *Labeler) bodyMatch(al config.AutoLabel, body string) bool {
 	return false
 }
 
-func (l *Labeler) labelMatch(al config.AutoLabel, label string) bool {
-	for _, expr := range al.AbsentLabels {
+func (l *Labeler) filesMatch(al config.AutoLabel, files []string) bool {
+	for _, expr := range al.MatchFiles {
+		r := l.singleLineRegexes[expr]
+		for _, f := range files {
+			if r.MatchString(f) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (l *Labeler) labelMatch(exprs []string, label string) bool {
+	for _, expr := range exprs {
 		r := l.singleLineRegexes[expr]
 		if r.MatchString(label) {
 			return true
@@ -0,0 +1,492 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labeler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/bots/policybot/pkg/storage/cache"
+)
+
+func TestMatchAutoLabel(t *testing.T) {
+	anyAL := config.AutoLabel{
+		Name:         "any",
+		MatchTitle:   []string{"crash"},
+		MatchBody:    []string{"^panic:"},
+		AbsentLabels: []string{"^area/"},
+		Labels:       []string{"kind/bug"},
+	}
+
+	allAL := anyAL
+	allAL.Name = "all"
+	allAL.Require = config.RequireAll
+
+	filesAL := config.AutoLabel{
+		Name:       "files",
+		MatchTitle: []string{"crash"},
+		MatchFiles: []string{"^pilot/"},
+		Require:    config.RequireAll,
+		Labels:     []string{"area/networking"},
+	}
+
+	presentAL := config.AutoLabel{
+		Name:          "present",
+		MatchTitle:    []string{"crash"},
+		PresentLabels: []string{"^kind/bug$"},
+		AbsentLabels:  []string{"^priority/"},
+		Labels:        []string{"priority/needs-attention"},
+	}
+
+	l, err := NewLabeler(nil, nil, nil, []config.AutoLabel{anyAL, allAL, filesAL, presentAL}, nil)
+	if err != nil {
+		t.Fatalf("Unable to create labeler: %v", err)
+	}
+
+	area := []*storage.Label{{LabelName: "area/security"}}
+	bug := []*storage.Label{{LabelName: "area/security"}, {LabelName: "kind/bug"}}
+	prioritizedBug := []*storage.Label{{LabelName: "kind/bug"}, {LabelName: "priority/p1"}}
+
+	cases := []struct {
+		name     string
+		al       config.AutoLabel
+		title    string
+		body     string
+		files    []string
+		labels   []*storage.Label
+		expected bool
+	}{
+		{"any, title only", anyAL, "Pilot crash", "", nil, nil, true},
+		{"any, body only", anyAL, "Problem", "panic: nil map", nil, nil, true},
+		{"any, neither", anyAL, "Problem", "it broke", nil, nil, false},
+		{"any, absent label present", anyAL, "Pilot crash", "", nil, area, false},
+		{"all, title only", allAL, "Pilot crash", "", nil, nil, false},
+		{"all, body only", allAL, "Problem", "panic: nil map", nil, nil, false},
+		{"all, both", allAL, "Pilot crash", "panic: nil map", nil, nil, true},
+		{"all, absent label present", allAL, "Pilot crash", "panic: nil map", nil, area, false},
+		{"all, files and title", filesAL, "Pilot crash", "", []string{"pilot/pkg/xds.go"}, nil, true},
+		{"all, wrong files", filesAL, "Pilot crash", "", []string{"mixer/adapter.go"}, nil, false},
+		{"all, issue without files", filesAL, "Pilot crash", "", nil, nil, false},
+		{"present, label present", presentAL, "Pilot crash", "", nil, bug, true},
+		{"present, no labels", presentAL, "Pilot crash", "", nil, nil, false},
+		{"present, other labels only", presentAL, "Pilot crash", "", nil, area, false},
+		{"present, absent label also present", presentAL, "Pilot crash", "", nil, prioritizedBug, false},
+		{"present, content mismatch", presentAL, "Problem", "", nil, bug, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := l.(*Labeler).matchAutoLabel(c.al, c.title, c.body, c.files, c.labels); got != c.expected {
+				t.Errorf("Got %v, expecting %v", got, c.expected)
+			}
+		})
+	}
+}
+
+func TestValidateAutoLabel(t *testing.T) {
+	cases := []struct {
+		name string
+		al   config.AutoLabel
+		err  string
+	}{
+		{"valid", config.AutoLabel{MatchTitle: []string{"crash"}, Labels: []string{"kind/bug"}}, ""},
+		{"remove only", config.AutoLabel{MatchBody: []string{"fixed"}, RemoveLabels: []string{"needs-triage"}}, ""},
+		{"no match", config.AutoLabel{Labels: []string{"kind/bug"}}, "will never fire"},
+		{"no labels", config.AutoLabel{MatchTitle: []string{"crash"}}, "no effect"},
+		{"bad require", config.AutoLabel{MatchTitle: []string{"crash"}, Labels: []string{"kind/bug"}, Require: "some"}, "require"},
+		{"empty label", config.AutoLabel{MatchTitle: []string{"crash"}, Labels: []string{""}}, "empty label"},
+		{"whitespace", config.AutoLabel{MatchTitle: []string{"crash"}, Labels: []string{"kind/bug "}}, "whitespace"},
+		{"too long", config.AutoLabel{MatchTitle: []string{"crash"}, Labels: []string{strings.Repeat("x", 51)}}, "longer than 50"},
+		{"conflict", config.AutoLabel{MatchTitle: []string{"crash"}, Labels: []string{"kind/bug"}, RemoveLabels: []string{"kind/bug"}}, "both applies and removes"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.al.Validate()
+			if c.err == "" && err != nil {
+				t.Errorf("Got error %v, expecting none", err)
+			} else if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+				t.Errorf("Got error %v, expecting one containing '%s'", err, c.err)
+			}
+		})
+	}
+}
+
+func TestInvalidGlobalAutoLabel(t *testing.T) {
+	valid := config.AutoLabel{Name: "good", MatchTitle: []string{"crash"}, Labels: []string{"kind/bug"}}
+	invalid := config.AutoLabel{Name: "bad", Labels: []string{"kind/bug"}}
+
+	_, err := NewLabeler(nil, nil, nil, []config.AutoLabel{valid, valid, invalid}, nil)
+	if err == nil || !strings.Contains(err.Error(), "global auto label #3 (bad)") {
+		t.Errorf("Got error %v, expecting one naming the position and auto label", err)
+	}
+}
+
+func TestNewLabelsComments(t *testing.T) {
+	triage := config.AutoLabel{
+		Name:       "triage",
+		MatchTitle: []string{"."},
+		Labels:     []string{"needs-area-label"},
+		Comment:    "@{{.Author}}, {{.Labels}} was added since no area could be determined.",
+	}
+
+	bug := config.AutoLabel{
+		Name:       "bug",
+		MatchTitle: []string{"crash"},
+		Labels:     []string{"kind/bug", "needs-area-label"},
+	}
+
+	l, err := NewLabeler(nil, nil, nil, []config.AutoLabel{triage, bug}, nil)
+	if err != nil {
+		t.Fatalf("Unable to create labeler: %v", err)
+	}
+
+	toApply, comments := l.(*Labeler).newLabels([]config.AutoLabel{triage, bug}, nil, "alice")
+	if fmt.Sprint(toApply) != "[needs-area-label kind/bug]" {
+		t.Errorf("Got labels %v, expecting [needs-area-label kind/bug]", toApply)
+	}
+
+	expected := "@alice, needs-area-label was added since no area could be determined."
+	if len(comments) != 1 || comments[0] != expected {
+		t.Errorf("Got comments %v, expecting [%s]", comments, expected)
+	}
+
+	// no comment when the label is already there
+	toApply, comments = l.(*Labeler).newLabels([]config.AutoLabel{triage, bug}, []string{"needs-area-label"}, "alice")
+	if fmt.Sprint(toApply) != "[kind/bug]" || len(comments) != 0 {
+		t.Errorf("Got labels %v and comments %v, expecting [kind/bug] and no comments", toApply, comments)
+	}
+}
+
+func TestLabelsToRemove(t *testing.T) {
+	triaged := config.AutoLabel{
+		Name:         "triaged",
+		Labels:       []string{"area/networking"},
+		RemoveLabels: []string{"needs-triage", "needs-area-label"},
+	}
+
+	other := config.AutoLabel{
+		Name:   "other",
+		Labels: []string{"needs-area-label"},
+	}
+
+	// absent labels are skipped, and labels applied by another rule are kept
+	toRemove := labelsToRemove([]config.AutoLabel{triaged, other}, []string{"needs-triage", "kind/bug"}, []string{"needs-area-label"})
+	if fmt.Sprint(toRemove) != "[needs-triage]" {
+		t.Errorf("Got labels to remove %v, expecting [needs-triage]", toRemove)
+	}
+}
+
+type fakeStore struct {
+	storage.Store
+
+	labels map[string]*storage.Label
+}
+
+func (fs *fakeStore) ReadLabel(_ context.Context, _ string, _ string, labelName string) (*storage.Label, error) {
+	return fs.labels[labelName], nil
+}
+
+func (fs *fakeStore) WriteLabels(_ context.Context, labels []*storage.Label) error {
+	for _, l := range labels {
+		fs.labels[l.LabelName] = l
+	}
+	return nil
+}
+
+func TestEnsureLabels(t *testing.T) {
+	defs := []config.LabelDefinition{
+		{Name: "kind/bug", Color: "d73a4a", Description: "Something isn't working"},
+		{Name: "area/networking", Color: "0e8a16"},
+	}
+
+	cases := []struct {
+		name            string
+		disableCreation bool
+		created         []string
+	}{
+		{"enabled", false, []string{"kind/bug"}},
+		{"disabled", true, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var created []string
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/repos/istio/istio/labels", func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "POST" {
+					t.Errorf("Got %s request, expecting POST", r.Method)
+				}
+
+				var label github.Label
+				if err := json.NewDecoder(r.Body).Decode(&label); err != nil {
+					t.Fatalf("Unable to decode label: %v", err)
+				}
+				created = append(created, label.GetName())
+
+				w.WriteHeader(http.StatusCreated)
+				_ = json.NewEncoder(w).Encode(&label)
+			})
+
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			client := github.NewClient(nil)
+			client.BaseURL, _ = url.Parse(server.URL + "/")
+
+			store := &fakeStore{labels: map[string]*storage.Label{
+				"area/networking": {OrgLogin: "istio", RepoName: "istio", LabelName: "area/networking"},
+			}}
+
+			orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}, DisableLabelCreation: c.disableCreation}}
+			f, err := NewLabeler(gh.NewThrottledClientForClient(client), cache.New(store, time.Minute), orgs, nil, defs)
+			if err != nil {
+				t.Fatalf("Unable to create labeler: %v", err)
+			}
+
+			// "undefined" has no definition so must be left alone
+			f.(*Labeler).ensureLabels(context.Background(), "istio", "istio", []string{"kind/bug", "area/networking", "undefined"})
+
+			if fmt.Sprint(created) != fmt.Sprint(c.created) {
+				t.Errorf("Got labels %v created, expecting %v", created, c.created)
+			}
+
+			if c.created != nil && store.labels["kind/bug"] == nil {
+				t.Errorf("Created label was not written to storage")
+			}
+		})
+	}
+}
+
+func TestEditedEventIdempotent(t *testing.T) {
+	var current []*github.Label
+	adds := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/issues/42/labels", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			var names []string
+			if err := json.NewDecoder(r.Body).Decode(&names); err != nil {
+				t.Fatalf("Unable to decode labels: %v", err)
+			}
+
+			adds++
+			for _, name := range names {
+				current = append(current, &github.Label{Name: github.String(name)})
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(current)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{labels: map[string]*storage.Label{}}
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	al := config.AutoLabel{Name: "security", MatchTitle: []string{`\[security\]`}, Labels: []string{"area/security"}}
+
+	l, err := NewLabeler(gh.NewThrottledClientForClient(client), cache.New(store, time.Minute), orgs, []config.AutoLabel{al}, nil)
+	if err != nil {
+		t.Fatalf("Unable to create labeler: %v", err)
+	}
+
+	// the title was edited after the issue was opened
+	event := &github.IssuesEvent{
+		Action: github.String("edited"),
+		Issue: &github.Issue{
+			Number: github.Int(42),
+			Title:  github.String("[security] Token leaked in logs"),
+		},
+		Repo: &github.Repository{
+			Name:     github.String("istio"),
+			FullName: github.String("istio/istio"),
+			Owner:    &github.User{Login: github.String("istio")},
+		},
+	}
+
+	l.Handle(context.Background(), event)
+	l.Handle(context.Background(), event)
+
+	if adds != 1 {
+		t.Errorf("Got %d calls to add labels, expecting 1", adds)
+	}
+
+	if len(current) != 1 || current[0].GetName() != "area/security" {
+		t.Errorf("Got labels %v, expecting [area/security]", current)
+	}
+}
+
+func TestAutoLabelPrecedence(t *testing.T) {
+	applied := make(map[string][]string)
+
+	mux := http.NewServeMux()
+	for _, repo := range []string{"istio", "proxy"} {
+		repo := repo
+		mux.HandleFunc("/repos/istio/"+repo+"/issues/42/labels", func(w http.ResponseWriter, r *http.Request) {
+			var names []string
+			if err := json.NewDecoder(r.Body).Decode(&names); err != nil {
+				t.Fatalf("Unable to decode labels: %v", err)
+			}
+			applied[repo] = names
+
+			_, _ = w.Write([]byte("[]"))
+		})
+	}
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	crash := func(label string) config.AutoLabel {
+		return config.AutoLabel{Name: label, MatchTitle: []string{"crash"}, Labels: []string{label}}
+	}
+
+	orgs := []config.Org{{
+		Name:       "istio",
+		AutoLabels: []config.AutoLabel{crash("org")},
+		Repos: []config.Repo{
+			{Name: "istio"},
+			{Name: "proxy", AutoLabels: []config.AutoLabel{crash("repo")}},
+		},
+	}}
+
+	store := &fakeStore{labels: map[string]*storage.Label{}}
+	l, err := NewLabeler(gh.NewThrottledClientForClient(client), cache.New(store, time.Minute), orgs, []config.AutoLabel{crash("global")}, nil)
+	if err != nil {
+		t.Fatalf("Unable to create labeler: %v", err)
+	}
+
+	for _, repo := range []string{"istio", "proxy"} {
+		l.Handle(context.Background(), &github.IssuesEvent{
+			Action: github.String("opened"),
+			Issue: &github.Issue{
+				Number: github.Int(42),
+				Title:  github.String("Envoy crash"),
+			},
+			Repo: &github.Repository{
+				Name:     github.String(repo),
+				FullName: github.String("istio/" + repo),
+				Owner:    &github.User{Login: github.String("istio")},
+			},
+		})
+	}
+
+	if got := fmt.Sprint(applied["istio"]); got != "[global org]" {
+		t.Errorf("Got labels %s applied in istio/istio, expecting [global org]", got)
+	}
+
+	if got := fmt.Sprint(applied["proxy"]); got != "[global org repo]" {
+		t.Errorf("Got labels %s applied in istio/proxy, expecting [global org repo]", got)
+	}
+}
+
+func TestPullRequestFilesListedOnDemand(t *testing.T) {
+	crash := config.AutoLabel{Name: "crash", MatchTitle: []string{"crash"}, Labels: []string{"kind/bug"}}
+	pilot := config.AutoLabel{Name: "pilot", MatchFiles: []string{"^pilot/"}, Labels: []string{"area/networking"}}
+
+	cases := []struct {
+		name       string
+		autoLabels []config.AutoLabel
+		listings   int
+		applied    string
+	}{
+		{"no file expressions", []config.AutoLabel{crash}, 0, "[kind/bug]"},
+		{"file expressions", []config.AutoLabel{crash, pilot}, 1, "[kind/bug area/networking]"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			listings := 0
+			var applied []string
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/repos/istio/istio/pulls/7/files", func(w http.ResponseWriter, r *http.Request) {
+				listings++
+				_, _ = w.Write([]byte(`[{"filename": "pilot/pkg/proxy.go"}]`))
+			})
+			mux.HandleFunc("/repos/istio/istio/issues/7/labels", func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&applied); err != nil {
+					t.Fatalf("Unable to decode labels: %v", err)
+				}
+				_, _ = w.Write([]byte("[]"))
+			})
+
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			client := github.NewClient(nil)
+			client.BaseURL, _ = url.Parse(server.URL + "/")
+
+			store := &fakeStore{labels: map[string]*storage.Label{}}
+			orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+			l, err := NewLabeler(gh.NewThrottledClientForClient(client), cache.New(store, time.Minute), orgs, c.autoLabels, nil)
+			if err != nil {
+				t.Fatalf("Unable to create labeler: %v", err)
+			}
+
+			l.Handle(context.Background(), &github.PullRequestEvent{
+				Action: github.String("opened"),
+				PullRequest: &github.PullRequest{
+					Number: github.Int(7),
+					Title:  github.String("Fix crash in proxy"),
+				},
+				Repo: &github.Repository{
+					Name:     github.String("istio"),
+					FullName: github.String("istio/istio"),
+					Owner:    &github.User{Login: github.String("istio")},
+				},
+			})
+
+			if listings != c.listings {
+				t.Errorf("Got %d file listings, expecting %d", listings, c.listings)
+			}
+
+			if got := fmt.Sprint(applied); got != c.applied {
+				t.Errorf("Got labels %s applied, expecting %s", got, c.applied)
+			}
+		})
+	}
+}
+
+func TestInvalidRepoAutoLabel(t *testing.T) {
+	orgs := []config.Org{{
+		Name:  "istio",
+		Repos: []config.Repo{{Name: "proxy", AutoLabels: []config.AutoLabel{{Name: "bad", MatchTitle: []string{"("}, Labels: []string{"kind/bug"}}}}},
+	}}
+
+	_, err := NewLabeler(nil, nil, orgs, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "auto label #1 (bad) for repo istio/proxy") {
+		t.Errorf("Got error %v, expecting one naming the repo and auto label", err)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package linkedissue
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/handlers/githubwebhook/filters"
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/pkg/log"
+)
+
+// NeedsIssueLabel is applied to PRs which don't reference an issue
+const NeedsIssueLabel = "needs-issue"
+
+const nagSignature = "\n\n_Courtesy of your friendly issue nag_."
+
+// a closing keyword followed by #123, org/repo#123, or the URL of an issue
+var issueReference = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+` +
+	`(?:#\d+|[\w.-]+/[\w.-]+#\d+|https://github\.com/[\w.-]+/[\w.-]+/issues/\d+)\b`)
+
+// Nags PRs which don't reference the issue they address
+type LinkedIssueNagger struct {
+	gc   *gh.ThrottledClient
+	orgs []config.Org
+}
+
+var scope = log.RegisterScope("linkedissue", "Nagger for PRs without a linked issue", 0)
+
+func NewLinkedIssueNagger(gc *gh.ThrottledClient, orgs []config.Org) filters.Filter {
+	return &LinkedIssueNagger{
+		gc:   gc,
+		orgs: orgs,
+	}
+}
+
+// the webhook events the filter reacts to
+func (n *LinkedIssueNagger) Events() []string {
+	return []string{"pull_request"}
+}
+
+// process an event arriving from GitHub
+func (n *LinkedIssueNagger) Handle(context context.Context, event interface{}) {
+	prp, ok := event.(*github.PullRequestEvent)
+	if !ok {
+		// not what we're looking for
+		return
+	}
+
+	if filters.IsReplay(context) {
+		// the nagging was done when the event first arrived
+		return
+	}
+
+	action := prp.GetAction()
+	if action != "opened" && action != "reopened" && action != "edited" && action != "labeled" && action != "unlabeled" {
+		// the title, body, and labels haven't changed
+		return
+	}
+
+	repo := prp.GetRepo().GetFullName()
+	org := config.FindOrgForRepo(n.orgs, repo)
+	if org == nil {
+		scope.Infof("Ignoring PR %d from repo %s since it's not in a monitored repo", prp.GetNumber(), repo)
+		return
+	}
+
+	li := org.LinkedIssue
+	if li.Message == "" {
+		return
+	}
+
+	pr := prp.GetPullRequest()
+	orgLogin := prp.GetRepo().GetOwner().GetLogin()
+	repoName := prp.GetRepo().GetName()
+
+	labeled := false
+	exempt := false
+	for _, label := range pr.Labels {
+		if label.GetName() == NeedsIssueLabel {
+			labeled = true
+		}
+
+		for _, e := range li.ExemptLabels {
+			if label.GetName() == e {
+				exempt = true
+			}
+		}
+	}
+
+	if exempt || hasIssueReference(pr.GetTitle()) || hasIssueReference(pr.GetBody()) {
+		if labeled {
+			scope.Infof("PR %d from repo %s now references an issue", pr.GetNumber(), repo)
+			n.removeNag(context, orgLogin, repoName, pr.GetNumber())
+		}
+		return
+	}
+
+	if !labeled {
+		scope.Infof("Nagging PR %d from repo %s since it doesn't reference an issue", pr.GetNumber(), repo)
+		n.postNag(context, orgLogin, repoName, pr.GetNumber(), li.Message)
+	}
+}
+
+// hasIssueReference returns whether the text references an issue the PR closes
+func hasIssueReference(text string) bool {
+	return issueReference.MatchString(text)
+}
+
+func (n *LinkedIssueNagger) postNag(context context.Context, orgLogin string, repoName string, number int, message string) {
+	if _, _, err := n.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Issues.AddLabelsToIssue(context, orgLogin, repoName, number, []string{NeedsIssueLabel})
+	}); err != nil {
+		scope.Errorf("Unable to set label %s on PR %d from repo %s/%s: %v", NeedsIssueLabel, number, orgLogin, repoName, err)
+		return
+	}
+
+	id, err := n.getNagComment(context, orgLogin, repoName, number)
+	if err != nil {
+		scope.Errorf("Unable to list comments for PR %d from repo %s/%s: %v", number, orgLogin, repoName, err)
+		return
+	} else if id >= 0 {
+		// the PR was already nagged
+		return
+	}
+
+	msg := message + nagSignature
+	if _, _, err := n.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Issues.CreateComment(context, orgLogin, repoName, number, &github.IssueComment{Body: &msg})
+	}); err != nil {
+		scope.Errorf("Unable to attach nagging comment to PR %d from repo %s/%s: %v", number, orgLogin, repoName, err)
+	}
+}
+
+func (n *LinkedIssueNagger) removeNag(context context.Context, orgLogin string, repoName string, number int) {
+	if _, err := n.gc.ThrottledCallNoResult(func(client *github.Client) (*github.Response, error) {
+		return client.Issues.RemoveLabelForIssue(context, orgLogin, repoName, number, NeedsIssueLabel)
+	}); err != nil {
+		scope.Errorf("Unable to remove label %s from PR %d from repo %s/%s: %v", NeedsIssueLabel, number, orgLogin, repoName, err)
+	}
+
+	id, err := n.getNagComment(context, orgLogin, repoName, number)
+	if err != nil {
+		scope.Errorf("Unable to list comments for PR %d from repo %s/%s: %v", number, orgLogin, repoName, err)
+		return
+	} else if id < 0 {
+		return
+	}
+
+	if _, err := n.gc.ThrottledCallNoResult(func(client *github.Client) (*github.Response, error) {
+		return client.Issues.DeleteComment(context, orgLogin, repoName, id)
+	}); err != nil {
+		scope.Errorf("Unable to delete nag comment in PR %d from repo %s/%s: %v", number, orgLogin, repoName, err)
+	}
+}
+
+// getNagComment returns the ID of the nag comment on a PR, or -1 if there's none
+func (n *LinkedIssueNagger) getNagComment(context context.Context, orgLogin string, repoName string, number int) (int64, error) {
+	opt := &github.IssueListCommentsOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	for {
+		comments, resp, err := n.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Issues.ListComments(context, orgLogin, repoName, number, opt)
+		})
+
+		if err != nil {
+			return -1, err
+		}
+
+		for _, comment := range comments.([]*github.IssueComment) {
+			if strings.Contains(comment.GetBody(), nagSignature) {
+				return comment.GetID(), nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return -1, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
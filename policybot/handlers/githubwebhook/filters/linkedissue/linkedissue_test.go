@@ -0,0 +1,143 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package linkedissue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+)
+
+func TestHasIssueReference(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected bool
+	}{
+		{"Fixes #123", true},
+		{"This PR closes istio/api#42.", true},
+		{"resolved: https://github.com/istio/istio/issues/7", true},
+		{"Fixed #1 and cleaned up", true},
+		{"See #123", false},
+		{"Fixes the crash in Pilot", false},
+		{"prefixes #123", false},
+		{"Fixes https://github.com/istio/istio/pull/7", false},
+	}
+
+	for _, c := range cases {
+		if got := hasIssueReference(c.text); got != c.expected {
+			t.Errorf("Got %v for '%s', expecting %v", got, c.text, c.expected)
+		}
+	}
+}
+
+func TestNagLifecycle(t *testing.T) {
+	labeled := false
+	var comments []*github.IssueComment
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/issues/42/labels", func(w http.ResponseWriter, r *http.Request) {
+		labeled = true
+		_ = json.NewEncoder(w).Encode([]*github.Label{{Name: github.String(NeedsIssueLabel)}})
+	})
+	mux.HandleFunc("/repos/istio/istio/issues/42/labels/"+NeedsIssueLabel, func(w http.ResponseWriter, r *http.Request) {
+		labeled = false
+	})
+	mux.HandleFunc("/repos/istio/istio/issues/42/comments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			var comment github.IssueComment
+			if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+				t.Fatalf("Unable to decode comment: %v", err)
+			}
+
+			comment.ID = github.Int64(int64(len(comments) + 1))
+			comments = append(comments, &comment)
+			_ = json.NewEncoder(w).Encode(comment)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(comments)
+	})
+	mux.HandleFunc("/repos/istio/istio/issues/comments/1", func(w http.ResponseWriter, r *http.Request) {
+		comments = nil
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	orgs := []config.Org{{
+		Name:        "istio",
+		Repos:       []config.Repo{{Name: "istio"}},
+		LinkedIssue: config.LinkedIssue{Message: "Please reference the issue this PR addresses.", ExemptLabels: []string{"trivial"}},
+	}}
+	n := NewLinkedIssueNagger(gh.NewThrottledClientForClient(client), orgs)
+
+	event := func(action string, body string, labels ...string) *github.PullRequestEvent {
+		var prLabels []*github.Label
+		for _, label := range labels {
+			prLabels = append(prLabels, &github.Label{Name: github.String(label)})
+		}
+		if labeled {
+			prLabels = append(prLabels, &github.Label{Name: github.String(NeedsIssueLabel)})
+		}
+
+		return &github.PullRequestEvent{
+			Action: github.String(action),
+			Number: github.Int(42),
+			PullRequest: &github.PullRequest{
+				Number: github.Int(42),
+				Title:  github.String("Fix a crash"),
+				Body:   github.String(body),
+				Labels: prLabels,
+			},
+			Repo: &github.Repository{
+				Name:     github.String("istio"),
+				FullName: github.String("istio/istio"),
+				Owner:    &github.User{Login: github.String("istio")},
+			},
+		}
+	}
+
+	n.Handle(context.Background(), event("opened", "Avoids a nil dereference"))
+	if !labeled || len(comments) != 1 {
+		t.Fatalf("Got labeled %v and %d comments, expecting the PR to be labeled and nagged once", labeled, len(comments))
+	}
+
+	// edits which still don't reference an issue don't lead to more nagging
+	n.Handle(context.Background(), event("edited", "Avoids a nil dereference in Pilot"))
+	if !labeled || len(comments) != 1 {
+		t.Errorf("Got labeled %v and %d comments, expecting the PR to be labeled and nagged once", labeled, len(comments))
+	}
+
+	n.Handle(context.Background(), event("edited", "Fixes istio/istio#41"))
+	if labeled || len(comments) != 0 {
+		t.Errorf("Got labeled %v and %d comments, expecting the label and nag to be removed", labeled, len(comments))
+	}
+
+	n.Handle(context.Background(), event("opened", "Typo", "trivial"))
+	if labeled || len(comments) != 0 {
+		t.Errorf("Got labeled %v and %d comments, expecting exempt PRs not to be nagged", labeled, len(comments))
+	}
+}
@@ -116,8 +116,18 @@ func (n *Nagger) processNagRegexes(nag config.Nag) error {
 	return nil
 }
 
+// the webhook events the filter reacts to
+func (n *Nagger) Events() []string {
+	return []string{"pull_request"}
+}
+
 // process an event arriving from GitHub
 func (n *Nagger) Handle(context context.Context, event interface{}) {
+	if filters.IsReplay(context) {
+		// the nagging was done when the event first arrived
+		return
+	}
+
 	prp, ok := event.(*github.PullRequestEvent)
 	if !ok {
 		// not what we're looking for
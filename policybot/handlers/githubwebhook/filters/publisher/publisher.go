@@ -0,0 +1,243 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/google/go-github/v26/github"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/option"
+
+	"istio.io/bots/policybot/handlers/githubwebhook/filters"
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/pkg/log"
+)
+
+// Mirrors GitHub webhook events to a Cloud Pub/Sub topic, for consumption by other services.
+type Publisher struct {
+	// publishes a single message, nil when no topic is configured
+	publish func(context context.Context, data []byte) error
+
+	// releases the Pub/Sub client, nil when no topic is configured
+	close func()
+
+	queue   chan []byte
+	worker  sync.WaitGroup
+	backoff time.Duration
+}
+
+// Envelope is the normalized form of the events published to the topic.
+type Envelope struct {
+	Type      string    `json:"type"`
+	Org       string    `json:"org"`
+	Repo      string    `json:"repo"`
+	Number    int       `json:"number,omitempty"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+const (
+	// the number of events that can be waiting to be published, additional events are dropped
+	queueSize = 1000
+
+	// the number of times publishing an event is attempted before giving up on it
+	maxAttempts = 5
+
+	// how long to wait before retrying a failed publish, doubling with every attempt
+	initialBackoff = time.Second
+)
+
+var scope = log.RegisterScope("publisher", "Publisher of GitHub events to Pub/Sub", 0)
+
+var publishedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "published_events_total",
+	Help: "The number of GitHub webhook events mirrored to Pub/Sub, by result.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(publishedEvents)
+}
+
+// NewPublisher creates a publisher for the configured topic. If no topic is configured, the publisher
+// ignores every event.
+func NewPublisher(ctx context.Context, gcpCreds []byte, cfg config.EventPublisher) (*Publisher, error) {
+	if cfg.Topic == "" {
+		return &Publisher{}, nil
+	}
+
+	client, err := pubsub.NewClient(ctx, cfg.ProjectID, option.WithCredentialsJSON(gcpCreds))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Pub/Sub client: %v", err)
+	}
+
+	topic := client.Topic(cfg.Topic)
+
+	publish := func(context context.Context, data []byte) error {
+		_, err := topic.Publish(context, &pubsub.Message{Data: data}).Get(context)
+		return err
+	}
+
+	closer := func() {
+		topic.Stop()
+		_ = client.Close()
+	}
+
+	return newPublisher(publish, closer, initialBackoff), nil
+}
+
+func newPublisher(publish func(context.Context, []byte) error, closer func(), backoff time.Duration) *Publisher {
+	p := &Publisher{
+		publish: publish,
+		close:   closer,
+		queue:   make(chan []byte, queueSize),
+		backoff: backoff,
+	}
+
+	p.worker.Add(1)
+	go p.run()
+
+	return p
+}
+
+// the webhook events the filter reacts to, all of them
+func (p *Publisher) Events() []string {
+	return nil
+}
+
+// queue an event arriving from GitHub for publishing, without waiting for it to be published
+func (p *Publisher) Handle(context context.Context, event interface{}) {
+	if p.publish == nil {
+		return
+	}
+
+	if filters.IsReplay(context) {
+		// the event was published when it first arrived
+		return
+	}
+
+	envelope := newEnvelope(filters.EventType(context), filters.ReceivedAt(context), event)
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		scope.Errorf("Unable to encode event %T: %v", event, err)
+		return
+	}
+
+	select {
+	case p.queue <- data:
+	default:
+		scope.Warnf("Publishing queue is full, dropping event %T", event)
+		publishedEvents.WithLabelValues("dropped").Inc()
+	}
+}
+
+// Close waits for the queued events to be published and releases the Pub/Sub client.
+func (p *Publisher) Close() {
+	if p.publish == nil {
+		return
+	}
+
+	close(p.queue)
+	p.worker.Wait()
+
+	if p.close != nil {
+		p.close()
+	}
+}
+
+func (p *Publisher) run() {
+	defer p.worker.Done()
+
+	for data := range p.queue {
+		if err := p.publishWithRetry(data); err != nil {
+			scope.Errorf("Unable to publish event after %d attempts: %v", maxAttempts, err)
+			publishedEvents.WithLabelValues("failed").Inc()
+			continue
+		}
+
+		publishedEvents.WithLabelValues("published").Inc()
+	}
+}
+
+func (p *Publisher) publishWithRetry(data []byte) error {
+	backoff := p.backoff
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = p.publish(context.Background(), data); err == nil {
+			return nil
+		}
+
+		if attempt < maxAttempts {
+			scope.Warnf("Unable to publish event, retrying in %v: %v", backoff, err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	return err
+}
+
+// newEnvelope extracts the fields common to GitHub events. Fields an event doesn't carry are left empty.
+func newEnvelope(eventType string, receivedAt time.Time, event interface{}) *Envelope {
+	e := &Envelope{
+		Type:      eventType,
+		Timestamp: receivedAt,
+	}
+
+	if ev, ok := event.(interface{ GetRepo() *github.Repository }); ok {
+		e.Org = ev.GetRepo().GetOwner().GetLogin()
+		e.Repo = ev.GetRepo().GetName()
+	}
+
+	if ev, ok := event.(interface{ GetSender() *github.User }); ok {
+		e.Actor = ev.GetSender().GetLogin()
+	}
+
+	if ev, ok := event.(interface{ GetAction() string }); ok {
+		e.Action = ev.GetAction()
+	}
+
+	switch ev := event.(type) {
+	case *github.IssueEvent:
+		e.Org = ev.GetIssue().GetRepository().GetOwner().GetLogin()
+		e.Repo = ev.GetIssue().GetRepository().GetName()
+		e.Number = ev.GetIssue().GetNumber()
+		e.Actor = ev.GetActor().GetLogin()
+		e.Action = ev.GetEvent()
+	case *github.IssuesEvent:
+		e.Number = ev.GetIssue().GetNumber()
+	case *github.IssueCommentEvent:
+		e.Number = ev.GetIssue().GetNumber()
+	case *github.PullRequestEvent:
+		e.Number = ev.GetNumber()
+	case *github.PullRequestReviewEvent:
+		e.Number = ev.GetPullRequest().GetNumber()
+	case *github.PullRequestReviewCommentEvent:
+		e.Number = ev.GetPullRequest().GetNumber()
+	case *github.PushEvent:
+		// push events describe their repo differently
+		e.Org = ev.GetRepo().GetOwner().GetLogin()
+		e.Repo = ev.GetRepo().GetName()
+	}
+
+	return e
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/handlers/githubwebhook/filters"
+	"istio.io/bots/policybot/pkg/config"
+)
+
+func TestPublish(t *testing.T) {
+	var mu sync.Mutex
+	var published [][]byte
+	attempts := 0
+
+	publish := func(_ context.Context, data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts++
+		if attempts == 1 {
+			return errors.New("unavailable")
+		}
+
+		published = append(published, data)
+		return nil
+	}
+
+	p := newPublisher(publish, nil, time.Millisecond)
+
+	receivedAt := time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC)
+	ctx := filters.WithEventType(filters.WithReceivedAt(context.Background(), receivedAt), "pull_request")
+
+	p.Handle(ctx, &github.PullRequestEvent{
+		Action: github.String("opened"),
+		Number: github.Int(7),
+		Repo: &github.Repository{
+			Name:  github.String("istio"),
+			Owner: &github.User{Login: github.String("istio")},
+		},
+		Sender: &github.User{Login: github.String("alice")},
+	})
+
+	// replayed events were published when they first arrived
+	p.Handle(filters.WithReplay(ctx), &github.PullRequestEvent{Action: github.String("closed")})
+
+	p.Close()
+
+	if attempts != 2 || len(published) != 1 {
+		t.Fatalf("Got %d attempts publishing %d events, expecting a retry publishing a single event", attempts, len(published))
+	}
+
+	var e Envelope
+	if err := json.Unmarshal(published[0], &e); err != nil {
+		t.Fatalf("Unable to decode published event: %v", err)
+	}
+
+	expected := Envelope{
+		Type:      "pull_request",
+		Org:       "istio",
+		Repo:      "istio",
+		Number:    7,
+		Actor:     "alice",
+		Action:    "opened",
+		Timestamp: receivedAt,
+	}
+
+	if !e.Timestamp.Equal(expected.Timestamp) {
+		t.Errorf("Got timestamp %v, expecting %v", e.Timestamp, expected.Timestamp)
+	}
+
+	e.Timestamp = expected.Timestamp
+	if e != expected {
+		t.Errorf("Got envelope %+v, expecting %+v", e, expected)
+	}
+}
+
+func TestUnconfigured(t *testing.T) {
+	p, err := NewPublisher(context.Background(), nil, config.EventPublisher{})
+	if err != nil {
+		t.Fatalf("Unable to create publisher: %v", err)
+	}
+
+	p.Handle(context.Background(), &github.IssuesEvent{Action: github.String("opened")})
+	p.Close()
+}
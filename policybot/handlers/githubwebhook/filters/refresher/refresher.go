@@ -16,6 +16,9 @@ package refresher
 
 import (
 	"context"
+	"encoding/json"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v26/github"
@@ -23,6 +26,7 @@ import (
 	"istio.io/bots/policybot/handlers/githubwebhook/filters"
 	"istio.io/bots/policybot/pkg/config"
 	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/maintainers"
 	"istio.io/bots/policybot/pkg/storage"
 	"istio.io/bots/policybot/pkg/storage/cache"
 	"istio.io/pkg/log"
@@ -30,7 +34,7 @@ import (
 
 // Updates the DB based on incoming GitHub webhook events.
 type Refresher struct {
-	repos map[string]bool
+	orgs  []config.Org
 	cache *cache.Cache
 	store storage.Store
 	gc    *gh.ThrottledClient
@@ -39,20 +43,17 @@ type Refresher struct {
 var scope = log.RegisterScope("refresher", "Dynamic database refresher", 0)
 
 func NewRefresher(cache *cache.Cache, store storage.Store, gc *gh.ThrottledClient, orgs []config.Org) filters.Filter {
-	r := &Refresher{
-		repos: make(map[string]bool),
+	return &Refresher{
+		orgs:  orgs,
 		cache: cache,
 		store: store,
 		gc:    gc,
 	}
+}
 
-	for _, org := range orgs {
-		for _, repo := range org.Repos {
-			r.repos[org.Name+"/"+repo.Name] = true
-		}
-	}
-
-	return r
+// the webhook events the filter reacts to
+func (r *Refresher) Events() []string {
+	return []string{"issues", "issue_comment", "pull_request", "pull_request_review", "pull_request_review_comment", "commit_comment", "milestone", "label", "push"}
 }
 
 // accept an event arriving from GitHub
@@ -61,42 +62,64 @@ func (r *Refresher) Handle(context context.Context, event interface{}) {
 	case *github.IssueEvent:
 		scope.Infof("Received IssueEvent: %s, %d, %s", p.GetIssue().GetRepository().GetFullName(), p.GetIssue().GetNumber(), p.GetEvent())
 
-		if !r.repos[p.GetIssue().GetRepository().GetFullName()] {
+		if !r.monitored(p.GetIssue().GetRepository().GetFullName()) {
 			scope.Infof("Ignoring issue %d from repo %s since it's not in a monitored repo", p.GetIssue().GetNumber(), p.GetIssue().GetRepository().GetFullName())
 			return
 		}
 
-		issue, discoveredUsers := gh.ConvertIssue(
-			p.GetIssue().GetRepository().GetOwner().GetLogin(),
-			p.GetIssue().GetRepository().GetName(),
-			p.GetIssue())
-		issues := []*storage.Issue{issue}
-		if err := r.cache.WriteIssues(context, issues); err != nil {
-			scope.Errorf(err.Error())
+		orgLogin := p.GetIssue().GetRepository().GetOwner().GetLogin()
+		repoName := p.GetIssue().GetRepository().GetName()
+
+		r.refreshIssue(context, orgLogin, repoName, p.GetIssue(), &storage.IssueEvent{
+			CreatedAt: p.GetCreatedAt(),
+			Actor:     p.GetActor().GetLogin(),
+			Action:    p.GetEvent(),
+			Label:     gh.ConvertIssueEventLabel(p),
+			Assignee:  gh.ConvertIssueEventAssignee(p),
+		}, p.GetLabel(), p.GetAssignee())
+
+	case *github.IssuesEvent:
+		scope.Infof("Received IssuesEvent: %s, %d, %s", p.GetRepo().GetFullName(), p.GetIssue().GetNumber(), p.GetAction())
+
+		if !r.monitored(p.GetRepo().GetFullName()) {
+			scope.Infof("Ignoring issue %d from repo %s since it's not in a monitored repo", p.GetIssue().GetNumber(), p.GetRepo().GetFullName())
+			return
+		}
+
+		orgLogin := p.GetRepo().GetOwner().GetLogin()
+		repoName := p.GetRepo().GetName()
+
+		if p.GetAction() == "deleted" || p.GetAction() == "transferred" {
+			if err := r.cache.DeleteIssue(context, orgLogin, repoName, int64(p.GetIssue().GetNumber())); err != nil {
+				scope.Errorf("Unable to delete issue %d from repo %s/%s: %v", p.GetIssue().GetNumber(), orgLogin, repoName, err)
+				return
+			}
+
+			if p.GetAction() == "transferred" {
+				r.writeTransferredIssue(context, orgLogin, repoName, p.GetIssue().GetNumber())
+			}
 			return
 		}
 
 		event := &storage.IssueEvent{
-			OrgLogin:    issue.OrgLogin,
-			RepoName:    issue.RepoName,
-			IssueNumber: issue.IssueNumber,
-			CreatedAt:   p.GetCreatedAt(),
-			Actor:       p.GetActor().GetLogin(),
-			Action:      p.GetEvent(),
+			CreatedAt: issueTime(context, p.GetAction(), p.GetIssue()),
+			Actor:     p.GetSender().GetLogin(),
+			Action:    p.GetAction(),
 		}
 
-		events := []*storage.IssueEvent{event}
-		if err := r.store.WriteIssueEvents(context, events); err != nil {
-			scope.Error(err.Error())
-			return
+		switch p.GetAction() {
+		case "labeled", "unlabeled":
+			event.Label = p.GetLabel().GetName()
+		case "assigned", "unassigned":
+			event.Assignee = p.GetAssignee().GetLogin()
 		}
 
-		r.syncUsers(context, discoveredUsers)
+		r.refreshIssue(context, orgLogin, repoName, p.GetIssue(), event, p.GetLabel(), p.GetAssignee())
 
 	case *github.IssueCommentEvent:
 		scope.Infof("Received IssueCommentEvent: %s, %d, %s", p.GetRepo().GetFullName(), p.GetIssue().GetNumber(), p.GetAction())
 
-		if !r.repos[p.GetRepo().GetFullName()] {
+		if !r.monitored(p.GetRepo().GetFullName()) {
 			scope.Infof("Ignoring issue comment for issue %d from repo %s since it's not in a monitored repo", p.GetIssue().GetNumber(), p.GetRepo().GetFullName())
 			return
 		}
@@ -106,14 +129,21 @@ func (r *Refresher) Handle(context context.Context, event interface{}) {
 			p.GetRepo().GetName(),
 			p.GetIssue().GetNumber(),
 			p.GetComment())
-		issueComments := []*storage.IssueComment{issueComment}
-		if err := r.cache.WriteIssueComments(context, issueComments); err == nil {
+
+		var err error
+		if p.GetAction() == "deleted" {
+			err = r.cache.DeleteIssueComment(context, issueComment.OrgLogin, issueComment.RepoName, issueComment.IssueNumber, issueComment.IssueCommentID)
+		} else {
+			err = r.cache.WriteIssueComments(context, []*storage.IssueComment{issueComment})
+		}
+
+		if err == nil {
 			event := &storage.IssueCommentEvent{
 				OrgLogin:       issueComment.OrgLogin,
 				RepoName:       issueComment.RepoName,
 				IssueNumber:    issueComment.IssueNumber,
 				IssueCommentID: p.GetComment().GetID(),
-				CreatedAt:      time.Now(),
+				CreatedAt:      commentTime(context, p.GetAction(), p.GetComment().GetCreatedAt(), p.GetComment().GetUpdatedAt()),
 				Actor:          p.GetSender().GetLogin(),
 				Action:         p.GetAction(),
 			}
@@ -130,7 +160,7 @@ func (r *Refresher) Handle(context context.Context, event interface{}) {
 	case *github.PullRequestEvent:
 		scope.Infof("Received PullRequestEvent: %s, %d, %s", p.GetRepo().GetFullName(), p.GetNumber(), p.GetAction())
 
-		if !r.repos[p.GetRepo().GetFullName()] {
+		if !r.monitored(p.GetRepo().GetFullName()) {
 			scope.Infof("Ignoring PR %d from repo %s since it's not in a monitored repo", p.PullRequest.Number, p.GetRepo().GetFullName())
 			return
 		}
@@ -167,18 +197,36 @@ func (r *Refresher) Handle(context context.Context, event interface{}) {
 			p.GetRepo().GetName(),
 			p.GetPullRequest(),
 			allFiles)
+
+		var assignee string
+		switch p.GetAction() {
+		case "labeled", "unlabeled":
+			pr.Labels = changeLabels(pr.Labels, p.GetAction(), p.GetLabel().GetName())
+		case "assigned", "unassigned":
+			assignee = p.GetAssignee().GetLogin()
+			pr.Assignees = changeAssignees(pr.Assignees, p.GetAction(), assignee)
+			if p.GetAction() == "assigned" {
+				discoveredUsers = append(discoveredUsers, gh.ConvertUser(p.GetAssignee()))
+			}
+		}
+
 		prs := []*storage.PullRequest{pr}
 		if err := r.cache.WritePullRequests(context, prs); err != nil {
 			scope.Errorf(err.Error())
 		}
 
+		if err := r.store.WriteAllPullRequestReviewers(context, prs); err != nil {
+			scope.Errorf(err.Error())
+		}
+
 		event := &storage.PullRequestEvent{
 			OrgLogin:          pr.OrgLogin,
 			RepoName:          pr.RepoName,
 			PullRequestNumber: pr.PullRequestNumber,
-			CreatedAt:         time.Now(),
+			CreatedAt:         pullRequestTime(context, p.GetAction(), p.GetPullRequest()),
 			Actor:             p.GetSender().GetLogin(),
-			Action:            p.GetAction(),
+			Action:            gh.ConvertPullRequestAction(p.GetAction(), p.GetPullRequest()),
+			Assignee:          assignee,
 		}
 
 		events := []*storage.PullRequestEvent{event}
@@ -192,7 +240,7 @@ func (r *Refresher) Handle(context context.Context, event interface{}) {
 	case *github.PullRequestReviewEvent:
 		scope.Infof("Received PullRequestReviewEvent: %s, %d, %s", p.GetRepo().GetFullName(), p.GetPullRequest().GetNumber(), p.GetAction())
 
-		if !r.repos[p.GetRepo().GetFullName()] {
+		if !r.monitored(p.GetRepo().GetFullName()) {
 			scope.Infof("Ignoring PR review for PR %d from repo %s since it's not in a monitored repo", p.PullRequest.Number, p.GetRepo().GetFullName())
 			return
 		}
@@ -212,7 +260,7 @@ func (r *Refresher) Handle(context context.Context, event interface{}) {
 			RepoName:            review.RepoName,
 			PullRequestNumber:   review.PullRequestNumber,
 			PullRequestReviewID: p.GetReview().GetID(),
-			CreatedAt:           time.Now(),
+			CreatedAt:           reviewTime(context, p.GetAction(), p.GetReview()),
 			Actor:               p.GetSender().GetLogin(),
 			Action:              p.GetAction(),
 		}
@@ -225,10 +273,10 @@ func (r *Refresher) Handle(context context.Context, event interface{}) {
 
 		r.syncUsers(context, discoveredUsers)
 
-	case github.PullRequestReviewCommentEvent:
+	case *github.PullRequestReviewCommentEvent:
 		scope.Infof("Received PullRequestReviewCommentEvent: %s, %d, %s", p.GetRepo().GetFullName(), p.GetPullRequest().GetNumber(), p.GetAction())
 
-		if !r.repos[p.GetRepo().GetFullName()] {
+		if !r.monitored(p.GetRepo().GetFullName()) {
 			scope.Infof("Ignoring PR review comment for PR %d from repo %s since it's not in a monitored repo", p.PullRequest.Number, p.GetRepo().GetFullName())
 			return
 		}
@@ -238,9 +286,18 @@ func (r *Refresher) Handle(context context.Context, event interface{}) {
 			p.GetRepo().GetName(),
 			p.GetPullRequest().GetNumber(),
 			p.GetComment())
-		comments := []*storage.PullRequestReviewComment{comment}
-		if err := r.cache.WritePullRequestReviewComments(context, comments); err != nil {
-			scope.Errorf(err.Error())
+
+		if p.GetAction() == "deleted" {
+			if err := r.cache.DeletePullRequestReviewComment(context, comment.OrgLogin, comment.RepoName, comment.PullRequestNumber,
+				comment.PullRequestReviewCommentID); err != nil {
+				scope.Errorf(err.Error())
+			}
+		} else {
+			// edits overwrite the stored comment
+			comments := []*storage.PullRequestReviewComment{comment}
+			if err := r.cache.WritePullRequestReviewComments(context, comments); err != nil {
+				scope.Errorf(err.Error())
+			}
 		}
 
 		event := &storage.PullRequestReviewCommentEvent{
@@ -248,7 +305,7 @@ func (r *Refresher) Handle(context context.Context, event interface{}) {
 			RepoName:                   comment.RepoName,
 			PullRequestNumber:          comment.PullRequestNumber,
 			PullRequestReviewCommentID: p.GetComment().GetID(),
-			CreatedAt:                  time.Now(),
+			CreatedAt:                  commentTime(context, p.GetAction(), p.GetComment().GetCreatedAt(), p.GetComment().GetUpdatedAt()),
 			Actor:                      p.GetSender().GetLogin(),
 			Action:                     p.GetAction(),
 		}
@@ -264,7 +321,7 @@ func (r *Refresher) Handle(context context.Context, event interface{}) {
 	case *github.CommitCommentEvent:
 		scope.Infof("Received CommitCommentEvent: %s, %s", p.GetRepo().GetFullName(), p.GetAction())
 
-		if !r.repos[p.GetRepo().GetFullName()] {
+		if !r.monitored(p.GetRepo().GetFullName()) {
 			scope.Infof("Ignoring repo comment from repo %s since it's not in a monitored repo", p.GetRepo().GetFullName())
 			return
 		}
@@ -282,7 +339,7 @@ func (r *Refresher) Handle(context context.Context, event interface{}) {
 			OrgLogin:      comment.OrgLogin,
 			RepoName:      comment.RepoName,
 			RepoCommentID: p.GetComment().GetID(),
-			CreatedAt:     time.Now(),
+			CreatedAt:     commentTime(context, p.GetAction(), p.GetComment().GetCreatedAt(), p.GetComment().GetUpdatedAt()),
 			Actor:         p.GetSender().GetLogin(),
 			Action:        p.GetAction(),
 		}
@@ -295,6 +352,86 @@ func (r *Refresher) Handle(context context.Context, event interface{}) {
 
 		r.syncUsers(context, discoveredUsers)
 
+	case *github.MilestoneEvent:
+		scope.Infof("Received MilestoneEvent: %s, %d, %s", p.GetRepo().GetFullName(), p.GetMilestone().GetNumber(), p.GetAction())
+
+		if !r.monitored(p.GetRepo().GetFullName()) {
+			scope.Infof("Ignoring milestone %d from repo %s since it's not in a monitored repo", p.GetMilestone().GetNumber(), p.GetRepo().GetFullName())
+			return
+		}
+
+		milestone := gh.ConvertMilestone(p.GetRepo().GetOwner().GetLogin(), p.GetRepo().GetName(), p.GetMilestone())
+
+		if p.GetAction() == "deleted" {
+			if err := r.store.DeleteMilestones(context, milestone.OrgLogin, milestone.RepoName, []int64{milestone.MilestoneNumber}); err != nil {
+				scope.Errorf(err.Error())
+			}
+			return
+		}
+
+		if err := r.store.WriteMilestones(context, []*storage.Milestone{milestone}); err != nil {
+			scope.Errorf(err.Error())
+		}
+
+	case *github.LabelEvent:
+		scope.Infof("Received LabelEvent: %s, %s, %s", p.GetRepo().GetFullName(), p.GetLabel().GetName(), p.GetAction())
+
+		if !r.monitored(p.GetRepo().GetFullName()) {
+			scope.Infof("Ignoring label %s from repo %s since it's not in a monitored repo", p.GetLabel().GetName(), p.GetRepo().GetFullName())
+			return
+		}
+
+		label := gh.ConvertLabel(p.GetRepo().GetOwner().GetLogin(), p.GetRepo().GetName(), p.GetLabel())
+
+		if p.GetAction() == "deleted" {
+			if err := r.cache.DeleteLabel(context, label.OrgLogin, label.RepoName, label.LabelName); err != nil {
+				scope.Errorf(err.Error())
+			}
+			return
+		}
+
+		if err := r.cache.WriteLabels(context, []*storage.Label{label}); err != nil {
+			scope.Errorf(err.Error())
+			return
+		}
+
+		if p.GetAction() == "edited" {
+			// labels are stored by name, so a renamed label leaves its old row behind
+			if oldName := renamedFrom(context); oldName != "" && oldName != label.LabelName {
+				if err := r.cache.DeleteLabel(context, label.OrgLogin, label.RepoName, oldName); err != nil {
+					scope.Errorf(err.Error())
+				}
+			}
+		}
+
+	case *github.PushEvent:
+		scope.Infof("Received PushEvent: %s, %s", p.GetRepo().GetFullName(), p.GetRef())
+
+		if !r.monitored(p.GetRepo().GetFullName()) {
+			scope.Infof("Ignoring push to repo %s since it's not a monitored repo", p.GetRepo().GetFullName())
+			return
+		}
+
+		if p.GetRef() != "refs/heads/"+p.GetRepo().GetDefaultBranch() {
+			// only the default branch determines who the maintainers are
+			return
+		}
+
+		if !ownersChanged(p) {
+			return
+		}
+
+		repo := &storage.Repo{
+			OrgLogin:      p.GetRepo().GetOwner().GetLogin(),
+			RepoName:      p.GetRepo().GetName(),
+			DefaultBranch: p.GetRepo().GetDefaultBranch(),
+		}
+
+		scope.Infof("Refreshing maintainers of repo %s/%s following a change to its owners", repo.OrgLogin, repo.RepoName)
+		if err := maintainers.RefreshRepo(context, r.gc, r.cache, r.store, repo); err != nil {
+			scope.Errorf("Unable to refresh maintainers of repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+		}
+
 	default:
 		// not what we're looking for
 		scope.Debugf("Unknown event received: %T %+v", p, p)
@@ -302,8 +439,282 @@ func (r *Refresher) Handle(context context.Context, event interface{}) {
 	}
 }
 
+// monitored returns whether events from the given org/repo should be recorded.
+func (r *Refresher) monitored(fullName string) bool {
+	return config.FindOrgForRepo(r.orgs, fullName) != nil
+}
+
+// fetchIssue gets the current state of an issue from GitHub, returning nil if that fails.
+func (r *Refresher) fetchIssue(context context.Context, orgLogin string, repoName string, issueNumber int) *github.Issue {
+	result, _, err := r.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Issues.Get(context, orgLogin, repoName, issueNumber)
+	})
+
+	if err != nil {
+		scope.Errorf("Unable to get issue %d from repo %s/%s, using the event's payload instead: %v", issueNumber, orgLogin, repoName, err)
+		return nil
+	}
+
+	return result.(*github.Issue)
+}
+
+// newerIssue returns the stored version of the given issue if it was more recently updated, or nil otherwise.
+func (r *Refresher) newerIssue(context context.Context, issue *storage.Issue) *storage.Issue {
+	existing, err := r.cache.ReadIssue(context, issue.OrgLogin, issue.RepoName, int(issue.IssueNumber))
+	if err != nil || existing == nil || !existing.UpdatedAt.After(issue.UpdatedAt) {
+		return nil
+	}
+
+	return existing
+}
+
+// refreshIssue records the state of an issue following a change to it, along with the event describing the change.
+// The label and assignee are the ones added or removed by the event, if any.
+func (r *Refresher) refreshIssue(context context.Context, orgLogin string, repoName string, ghIssue *github.Issue,
+	event *storage.IssueEvent, label *github.Label, assignee *github.User) {
+	if event.Action == "closed" || event.Action == "reopened" {
+		// events can arrive out of order, so get the authoritative state rather than trusting the payload
+		if fetched := r.fetchIssue(context, orgLogin, repoName, ghIssue.GetNumber()); fetched != nil {
+			ghIssue = fetched
+		}
+	}
+
+	issue, discoveredUsers := gh.ConvertIssue(orgLogin, repoName, ghIssue)
+
+	labelChange := event.Action == "labeled" || event.Action == "unlabeled"
+	if labelChange {
+		issue.Labels = changeLabels(issue.Labels, event.Action, label.GetName())
+	}
+
+	assigneeChange := event.Action == "assigned" || event.Action == "unassigned"
+	if assigneeChange {
+		issue.Assignees = changeAssignees(issue.Assignees, event.Action, assignee.GetLogin())
+		if event.Action == "assigned" {
+			discoveredUsers = append(discoveredUsers, gh.ConvertUser(assignee))
+		}
+	}
+
+	newer := r.newerIssue(context, issue)
+	if newer != nil && (labelChange || assigneeChange) {
+		// keep the rest of what's stored, but the label or assignee change is still news
+		updated := *newer
+		if labelChange {
+			updated.Labels = changeLabels(newer.Labels, event.Action, label.GetName())
+		} else {
+			updated.Assignees = changeAssignees(newer.Assignees, event.Action, assignee.GetLogin())
+		}
+		issue = &updated
+		newer = nil
+	}
+
+	if newer != nil {
+		scope.Infof("Not updating issue %d in repo %s/%s since a more recent version is already stored", issue.IssueNumber, orgLogin, repoName)
+	} else {
+		issues := []*storage.Issue{issue}
+		if err := r.cache.WriteIssues(context, issues); err != nil {
+			scope.Errorf(err.Error())
+			return
+		}
+
+		if err := r.store.WriteAllIssueAssignees(context, issues); err != nil {
+			scope.Errorf(err.Error())
+			return
+		}
+
+		if err := r.store.WriteAllIssueLabels(context, issues); err != nil {
+			scope.Errorf(err.Error())
+			return
+		}
+	}
+
+	event.OrgLogin = issue.OrgLogin
+	event.RepoName = issue.RepoName
+	event.IssueNumber = issue.IssueNumber
+
+	events := []*storage.IssueEvent{event}
+	if err := r.store.WriteIssueEvents(context, events); err != nil {
+		scope.Error(err.Error())
+		return
+	}
+
+	r.syncUsers(context, discoveredUsers)
+}
+
+// writeTransferredIssue records an issue under the repo it was transferred to, if that repo is monitored.
+func (r *Refresher) writeTransferredIssue(context context.Context, orgLogin string, repoName string, issueNumber int) {
+	result, _, err := r.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		// GitHub redirects requests for a transferred issue to its new location
+		return client.Issues.Get(context, orgLogin, repoName, issueNumber)
+	})
+
+	if err != nil {
+		scope.Errorf("Unable to get transferred issue %d from repo %s/%s: %v", issueNumber, orgLogin, repoName, err)
+		return
+	}
+
+	issue := result.(*github.Issue)
+
+	transfer := gh.ConvertIssueTransfer(orgLogin, repoName, issueNumber, issue)
+	if transfer == nil {
+		scope.Errorf("Unable to determine where issue %d from repo %s/%s was transferred to", issueNumber, orgLogin, repoName)
+		return
+	}
+
+	if err := r.store.WriteIssueTransfers(context, []*storage.IssueTransfer{transfer}); err != nil {
+		scope.Errorf("Unable to record transfer of issue %d from repo %s/%s: %v", issueNumber, orgLogin, repoName, err)
+	}
+
+	newOrgLogin := transfer.NewOrgLogin
+	newRepoName := transfer.NewRepoName
+	if !r.monitored(newOrgLogin + "/" + newRepoName) {
+		scope.Infof("Ignoring issue %d transferred to repo %s/%s since it's not a monitored repo", issue.GetNumber(), newOrgLogin, newRepoName)
+		return
+	}
+
+	storageIssue, discoveredUsers := gh.ConvertIssue(newOrgLogin, newRepoName, issue)
+	issues := []*storage.Issue{storageIssue}
+	if err := r.cache.WriteIssues(context, issues); err != nil {
+		scope.Errorf(err.Error())
+		return
+	}
+
+	if err := r.store.WriteAllIssueAssignees(context, issues); err != nil {
+		scope.Errorf(err.Error())
+		return
+	}
+
+	if err := r.store.WriteAllIssueLabels(context, issues); err != nil {
+		scope.Errorf(err.Error())
+		return
+	}
+
+	r.syncUsers(context, discoveredUsers)
+}
+
 func (r *Refresher) syncUsers(context context.Context, users []*storage.User) {
 	if err := r.cache.WriteUsers(context, users); err != nil {
 		scope.Errorf("Unable to write users: %v", err)
 	}
 }
+
+// changeLabels applies a labeled or unlabeled action to a set of label names. The label set in the payload of
+// such events sometimes lags behind the change itself, so the change is applied explicitly.
+func changeLabels(labels []string, action string, label string) []string {
+	return changeSet(labels, action == "labeled", label)
+}
+
+// changeAssignees applies an assigned or unassigned action to a set of user logins. Like labels, the assignees in
+// the payload of such events can lag behind the change itself.
+func changeAssignees(assignees []string, action string, assignee string) []string {
+	return changeSet(assignees, action == "assigned", assignee)
+}
+
+// changeSet adds a value to or removes it from a set of values, keeping at most one copy of it.
+func changeSet(values []string, add bool, value string) []string {
+	result := make([]string, 0, len(values)+1)
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+
+	if add {
+		result = append(result, value)
+	}
+
+	return result
+}
+
+// ownersChanged returns whether a push touched the CODEOWNERS file or any OWNERS file.
+func ownersChanged(p *github.PushEvent) bool {
+	for _, commit := range p.Commits {
+		for _, files := range [][]string{commit.Added, commit.Modified, commit.Removed} {
+			for _, f := range files {
+				if f == "CODEOWNERS" || (path.Base(f) == "OWNERS" && !strings.HasPrefix(f, "vendor/")) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// renamedFrom returns the previous name of a label that's been renamed, or an empty string if the
+// name didn't change. The go-github event types don't surface name changes, so this is pulled
+// out of the raw payload.
+func renamedFrom(context context.Context) string {
+	var payload struct {
+		Changes struct {
+			Name struct {
+				From string `json:"from"`
+			} `json:"name"`
+		} `json:"changes"`
+	}
+
+	if err := json.Unmarshal(filters.Payload(context), &payload); err != nil {
+		return ""
+	}
+
+	return payload.Changes.Name.From
+}
+
+// eventTime returns the given time, or when the event was received if the payload didn't supply a time.
+func eventTime(context context.Context, t time.Time) time.Time {
+	if t.IsZero() {
+		return filters.ReceivedAt(context)
+	}
+	return t
+}
+
+// commentTime returns when a comment event happened, based on the comment's own timestamps.
+func commentTime(context context.Context, action string, createdAt time.Time, updatedAt time.Time) time.Time {
+	switch action {
+	case "created":
+		return eventTime(context, createdAt)
+	case "edited":
+		return eventTime(context, updatedAt)
+	default:
+		// deletions don't carry a timestamp of their own
+		return filters.ReceivedAt(context)
+	}
+}
+
+// pullRequestTime returns when a pull request event happened, based on the pull request's own timestamps.
+func pullRequestTime(context context.Context, action string, pr *github.PullRequest) time.Time {
+	switch action {
+	case "opened":
+		return eventTime(context, pr.GetCreatedAt())
+	case "closed":
+		if pr.GetMerged() {
+			return eventTime(context, pr.GetMergedAt())
+		}
+		return eventTime(context, pr.GetClosedAt())
+	default:
+		// other changes to the PR bump its update time
+		return eventTime(context, pr.GetUpdatedAt())
+	}
+}
+
+// issueTime returns when an issue event happened, based on the issue's own timestamps.
+func issueTime(context context.Context, action string, issue *github.Issue) time.Time {
+	switch action {
+	case "opened":
+		return eventTime(context, issue.GetCreatedAt())
+	case "closed":
+		return eventTime(context, issue.GetClosedAt())
+	default:
+		// other changes to the issue bump its update time
+		return eventTime(context, issue.GetUpdatedAt())
+	}
+}
+
+// reviewTime returns when a pull request review event happened, based on the review's own timestamps.
+func reviewTime(context context.Context, action string, review *github.PullRequestReview) time.Time {
+	if action == "submitted" {
+		return eventTime(context, review.GetSubmittedAt())
+	}
+
+	// dismissals and edits don't carry a timestamp of their own
+	return filters.ReceivedAt(context)
+}
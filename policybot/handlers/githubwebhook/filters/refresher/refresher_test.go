@@ -0,0 +1,972 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package refresher_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/handlers/githubwebhook/filters"
+	"istio.io/bots/policybot/handlers/githubwebhook/filters/refresher"
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/bots/policybot/pkg/storage/cache"
+)
+
+// fakeStore records the writes performed by the refresher
+type fakeStore struct {
+	storage.Store
+
+	prComments      []*storage.PullRequestReviewComment
+	deletedComments []int64
+	issueComments   map[int64]*storage.IssueComment
+	commentEvents   []*storage.IssueCommentEvent
+	prCommentEvents []*storage.PullRequestReviewCommentEvent
+	prReviewEvents  []*storage.PullRequestReviewEvent
+	users           []*storage.User
+	milestones      []*storage.Milestone
+	deletedIssues   []int64
+	labels          map[string]*storage.Label
+	issues          map[int64]*storage.Issue
+	issueEvents     []*storage.IssueEvent
+	prs             []*storage.PullRequest
+	prEvents        []*storage.PullRequestEvent
+	prReviewers     []*storage.PullRequestReviewer
+}
+
+func (fs *fakeStore) ReadIssue(_ context.Context, _ string, _ string, number int) (*storage.Issue, error) {
+	return fs.issues[int64(number)], nil
+}
+
+func (fs *fakeStore) WriteIssues(_ context.Context, issues []*storage.Issue) error {
+	if fs.issues == nil {
+		fs.issues = make(map[int64]*storage.Issue)
+	}
+
+	for _, issue := range issues {
+		fs.issues[issue.IssueNumber] = issue
+	}
+	return nil
+}
+
+func (fs *fakeStore) WriteAllIssueAssignees(_ context.Context, _ []*storage.Issue) error {
+	return nil
+}
+
+func (fs *fakeStore) WriteAllPullRequestReviewers(_ context.Context, prs []*storage.PullRequest) error {
+	for _, pr := range prs {
+		for _, reviewer := range pr.RequestedReviewers {
+			fs.prReviewers = append(fs.prReviewers, &storage.PullRequestReviewer{
+				OrgLogin:          pr.OrgLogin,
+				RepoName:          pr.RepoName,
+				PullRequestNumber: pr.PullRequestNumber,
+				UserLogin:         reviewer,
+			})
+		}
+	}
+	return nil
+}
+
+func (fs *fakeStore) WriteAllIssueLabels(_ context.Context, _ []*storage.Issue) error {
+	return nil
+}
+
+func (fs *fakeStore) WriteIssueEvents(_ context.Context, events []*storage.IssueEvent) error {
+	fs.issueEvents = append(fs.issueEvents, events...)
+	return nil
+}
+
+func (fs *fakeStore) ReadLabel(_ context.Context, orgLogin string, repoName string, labelName string) (*storage.Label, error) {
+	return fs.labels[orgLogin+"/"+repoName+"/"+labelName], nil
+}
+
+func (fs *fakeStore) WriteLabels(_ context.Context, labels []*storage.Label) error {
+	if fs.labels == nil {
+		fs.labels = make(map[string]*storage.Label)
+	}
+
+	for _, l := range labels {
+		fs.labels[l.OrgLogin+"/"+l.RepoName+"/"+l.LabelName] = l
+	}
+	return nil
+}
+
+func (fs *fakeStore) DeleteLabel(_ context.Context, orgLogin string, repoName string, labelName string) error {
+	delete(fs.labels, orgLogin+"/"+repoName+"/"+labelName)
+	return nil
+}
+
+func (fs *fakeStore) DeleteIssue(_ context.Context, _ string, _ string, issueNumber int64) error {
+	fs.deletedIssues = append(fs.deletedIssues, issueNumber)
+	return nil
+}
+
+func (fs *fakeStore) WritePullRequestReviews(_ context.Context, _ []*storage.PullRequestReview) error {
+	return nil
+}
+
+func (fs *fakeStore) WritePullRequestReviewEvents(_ context.Context, events []*storage.PullRequestReviewEvent) error {
+	fs.prReviewEvents = append(fs.prReviewEvents, events...)
+	return nil
+}
+
+func (fs *fakeStore) WriteMilestones(_ context.Context, milestones []*storage.Milestone) error {
+	fs.milestones = append(fs.milestones, milestones...)
+	return nil
+}
+
+func (fs *fakeStore) WritePullRequestReviewComments(_ context.Context, comments []*storage.PullRequestReviewComment) error {
+	fs.prComments = append(fs.prComments, comments...)
+	return nil
+}
+
+func (fs *fakeStore) WriteIssueComments(_ context.Context, comments []*storage.IssueComment) error {
+	if fs.issueComments == nil {
+		fs.issueComments = make(map[int64]*storage.IssueComment)
+	}
+
+	for _, c := range comments {
+		fs.issueComments[c.IssueCommentID] = c
+	}
+	return nil
+}
+
+func (fs *fakeStore) DeleteIssueComment(_ context.Context, _ string, _ string, _ int64, commentID int64) error {
+	delete(fs.issueComments, commentID)
+	return nil
+}
+
+func (fs *fakeStore) WriteIssueCommentEvents(_ context.Context, events []*storage.IssueCommentEvent) error {
+	fs.commentEvents = append(fs.commentEvents, events...)
+	return nil
+}
+
+func (fs *fakeStore) DeletePullRequestReviewComment(_ context.Context, _ string, _ string, _ int64, commentID int64) error {
+	fs.deletedComments = append(fs.deletedComments, commentID)
+	return nil
+}
+
+func (fs *fakeStore) WritePullRequestReviewCommentEvents(_ context.Context, events []*storage.PullRequestReviewCommentEvent) error {
+	fs.prCommentEvents = append(fs.prCommentEvents, events...)
+	return nil
+}
+
+func (fs *fakeStore) WritePullRequests(_ context.Context, prs []*storage.PullRequest) error {
+	fs.prs = append(fs.prs, prs...)
+	return nil
+}
+
+func (fs *fakeStore) WritePullRequestEvents(_ context.Context, events []*storage.PullRequestEvent) error {
+	fs.prEvents = append(fs.prEvents, events...)
+	return nil
+}
+
+func (fs *fakeStore) WriteUsers(_ context.Context, users []*storage.User) error {
+	fs.users = append(fs.users, users...)
+	return nil
+}
+
+const prReviewCommentPayload = `{
+	"action": "created",
+	"comment": {
+		"id": 1234,
+		"body": "Please fix this",
+		"created_at": "2019-06-01T10:00:00Z",
+		"updated_at": "2019-06-01T10:00:00Z",
+		"user": {"login": "reviewer"}
+	},
+	"pull_request": {
+		"number": 42
+	},
+	"repository": {
+		"name": "istio",
+		"full_name": "istio/istio",
+		"owner": {"login": "istio"}
+	},
+	"sender": {"login": "reviewer"}
+}`
+
+func TestPullRequestReviewCommentEvent(t *testing.T) {
+	event, err := github.ParseWebHook("pull_request_review_comment", []byte(prReviewCommentPayload))
+	if err != nil {
+		t.Fatalf("Unable to parse payload: %v", err)
+	}
+
+	store := &fakeStore{}
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	r := refresher.NewRefresher(cache.New(store, time.Minute), store, nil, orgs)
+
+	r.Handle(context.Background(), event)
+
+	if len(store.prComments) != 1 {
+		t.Fatalf("Got %d review comments, expecting 1", len(store.prComments))
+	}
+
+	comment := store.prComments[0]
+	if comment.OrgLogin != "istio" || comment.RepoName != "istio" || comment.PullRequestNumber != 42 || comment.PullRequestReviewCommentID != 1234 {
+		t.Errorf("Got unexpected review comment %+v", comment)
+	}
+
+	if len(store.prCommentEvents) != 1 {
+		t.Fatalf("Got %d review comment events, expecting 1", len(store.prCommentEvents))
+	}
+
+	ev := store.prCommentEvents[0]
+	if ev.PullRequestReviewCommentID != 1234 || ev.Actor != "reviewer" || ev.Action != "created" {
+		t.Errorf("Got unexpected review comment event %+v", ev)
+	}
+
+	if want := time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC); !ev.CreatedAt.Equal(want) {
+		t.Errorf("Got event time %v, expecting the comment's creation time %v", ev.CreatedAt, want)
+	}
+}
+
+func TestPullRequestReviewCommentDeleted(t *testing.T) {
+	payload := strings.Replace(prReviewCommentPayload, `"action": "created"`, `"action": "deleted"`, 1)
+	event, err := github.ParseWebHook("pull_request_review_comment", []byte(payload))
+	if err != nil {
+		t.Fatalf("Unable to parse payload: %v", err)
+	}
+
+	store := &fakeStore{}
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	r := refresher.NewRefresher(cache.New(store, time.Minute), store, nil, orgs)
+
+	r.Handle(context.Background(), event)
+
+	if len(store.prComments) != 0 {
+		t.Errorf("Got %d review comments written, expecting none", len(store.prComments))
+	}
+
+	if len(store.deletedComments) != 1 || store.deletedComments[0] != 1234 {
+		t.Errorf("Got review comments %v deleted, expecting [1234]", store.deletedComments)
+	}
+
+	if len(store.prCommentEvents) != 1 || store.prCommentEvents[0].Action != "deleted" {
+		t.Errorf("Got review comment events %+v, expecting a single deleted event", store.prCommentEvents)
+	}
+}
+
+const issueCommentPayload = `{
+	"action": "%s",
+	"comment": {
+		"id": 5678,
+		"body": "Any update?",
+		"created_at": "2019-06-01T10:00:00Z",
+		"updated_at": "2019-06-01T10:00:00Z",
+		"user": {"login": "commenter"}
+	},
+	"issue": {
+		"number": 42
+	},
+	"repository": {
+		"name": "istio",
+		"full_name": "istio/istio",
+		"owner": {"login": "istio"}
+	},
+	"sender": {"login": "commenter"}
+}`
+
+func TestIssueCommentDeleted(t *testing.T) {
+	store := &fakeStore{}
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	r := refresher.NewRefresher(cache.New(store, time.Minute), store, nil, orgs)
+
+	for _, action := range []string{"created", "deleted"} {
+		event, err := github.ParseWebHook("issue_comment", []byte(fmt.Sprintf(issueCommentPayload, action)))
+		if err != nil {
+			t.Fatalf("Unable to parse payload: %v", err)
+		}
+
+		r.Handle(context.Background(), event)
+
+		if action == "created" && store.issueComments[5678] == nil {
+			t.Fatalf("Comment was not written")
+		}
+	}
+
+	if _, ok := store.issueComments[5678]; ok {
+		t.Errorf("Comment is still stored after being deleted")
+	}
+
+	if len(store.commentEvents) != 2 || store.commentEvents[1].Action != "deleted" {
+		t.Errorf("Got comment events %+v, expecting created and deleted events", store.commentEvents)
+	}
+}
+
+const prReviewPayload = `{
+	"action": "%s",
+	"review": {
+		"id": 99,
+		"state": "approved",
+		"submitted_at": "2019-06-02T08:30:00Z",
+		"user": {"login": "reviewer"}
+	},
+	"pull_request": {
+		"number": 42
+	},
+	"repository": {
+		"name": "istio",
+		"full_name": "istio/istio",
+		"owner": {"login": "istio"}
+	},
+	"organization": {"login": "istio"},
+	"sender": {"login": "reviewer"}
+}`
+
+func TestPullRequestReviewEventTime(t *testing.T) {
+	received := time.Date(2019, 6, 3, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		action   string
+		expected time.Time
+	}{
+		{"submitted", time.Date(2019, 6, 2, 8, 30, 0, 0, time.UTC)},
+
+		// dismissals don't have a timestamp in the payload, so the time the event was received is used
+		{"dismissed", received},
+	}
+
+	for _, c := range cases {
+		t.Run(c.action, func(t *testing.T) {
+			event, err := github.ParseWebHook("pull_request_review", []byte(fmt.Sprintf(prReviewPayload, c.action)))
+			if err != nil {
+				t.Fatalf("Unable to parse payload: %v", err)
+			}
+
+			store := &fakeStore{}
+			orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+			r := refresher.NewRefresher(cache.New(store, time.Minute), store, nil, orgs)
+
+			r.Handle(filters.WithReceivedAt(context.Background(), received), event)
+
+			if len(store.prReviewEvents) != 1 {
+				t.Fatalf("Got %d review events, expecting 1", len(store.prReviewEvents))
+			}
+
+			if got := store.prReviewEvents[0].CreatedAt; !got.Equal(c.expected) {
+				t.Errorf("Got event time %v, expecting %v", got, c.expected)
+			}
+		})
+	}
+}
+
+const milestonePayload = `{
+	"action": "edited",
+	"milestone": {
+		"number": 3,
+		"title": "1.3",
+		"state": "open",
+		"open_issues": 12,
+		"closed_issues": 30
+	},
+	"repository": {
+		"name": "istio",
+		"full_name": "istio/istio",
+		"owner": {"login": "istio"}
+	},
+	"sender": {"login": "release-manager"}
+}`
+
+func TestMilestoneEvent(t *testing.T) {
+	event, err := github.ParseWebHook("milestone", []byte(milestonePayload))
+	if err != nil {
+		t.Fatalf("Unable to parse payload: %v", err)
+	}
+
+	store := &fakeStore{}
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	r := refresher.NewRefresher(cache.New(store, time.Minute), store, nil, orgs)
+
+	r.Handle(context.Background(), event)
+
+	if len(store.milestones) != 1 {
+		t.Fatalf("Got %d milestones, expecting 1", len(store.milestones))
+	}
+
+	m := store.milestones[0]
+	if m.OrgLogin != "istio" || m.RepoName != "istio" || m.MilestoneNumber != 3 || m.OpenIssues != 12 || m.ClosedIssues != 30 {
+		t.Errorf("Got unexpected milestone %+v", m)
+	}
+}
+
+func TestAllReposMonitored(t *testing.T) {
+	cases := []struct {
+		repo     string
+		expected int
+	}{
+		{"brand-new", 1},
+		{"excluded", 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.repo, func(t *testing.T) {
+			payload := strings.Replace(milestonePayload, "istio/istio", "istio/"+c.repo, -1)
+			event, err := github.ParseWebHook("milestone", []byte(payload))
+			if err != nil {
+				t.Fatalf("Unable to parse payload: %v", err)
+			}
+
+			store := &fakeStore{}
+			orgs := []config.Org{{Name: "istio", AllRepos: true, ExcludeRepos: []string{"excluded"}}}
+			r := refresher.NewRefresher(cache.New(store, time.Minute), store, nil, orgs)
+
+			r.Handle(context.Background(), event)
+
+			if len(store.milestones) != c.expected {
+				t.Errorf("Got %d milestones, expecting %d", len(store.milestones), c.expected)
+			}
+		})
+	}
+}
+
+const issueDeletedPayload = `{
+	"action": "deleted",
+	"issue": {
+		"number": 77,
+		"title": "Flaky test"
+	},
+	"repository": {
+		"name": "istio",
+		"full_name": "istio/istio",
+		"owner": {"login": "istio"}
+	},
+	"sender": {"login": "admin"}
+}`
+
+func TestIssueDeletedEvent(t *testing.T) {
+	event, err := github.ParseWebHook("issues", []byte(issueDeletedPayload))
+	if err != nil {
+		t.Fatalf("Unable to parse payload: %v", err)
+	}
+
+	store := &fakeStore{}
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	r := refresher.NewRefresher(cache.New(store, time.Minute), store, nil, orgs)
+
+	r.Handle(context.Background(), event)
+
+	if len(store.deletedIssues) != 1 || store.deletedIssues[0] != 77 {
+		t.Errorf("Got deleted issues %v, expecting [77]", store.deletedIssues)
+	}
+}
+
+const labelPayload = `{
+	"action": "%s",
+	"label": {
+		"name": "%s",
+		"color": "ff0000"
+	},
+	%s
+	"repository": {
+		"name": "istio",
+		"full_name": "istio/istio",
+		"owner": {"login": "istio"}
+	},
+	"sender": {"login": "admin"}
+}`
+
+func TestLabelEvents(t *testing.T) {
+	store := &fakeStore{}
+	c := cache.New(store, time.Minute)
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	r := refresher.NewRefresher(c, store, nil, orgs)
+
+	handle := func(action string, name string, changes string) {
+		payload := fmt.Sprintf(labelPayload, action, name, changes)
+		event, err := github.ParseWebHook("label", []byte(payload))
+		if err != nil {
+			t.Fatalf("Unable to parse payload: %v", err)
+		}
+
+		r.Handle(filters.WithPayload(context.Background(), []byte(payload)), event)
+	}
+
+	labelExists := func(name string) bool {
+		l, err := c.ReadLabel(context.Background(), "istio", "istio", name)
+		if err != nil {
+			t.Fatalf("Unable to read label %s: %v", name, err)
+		}
+		return l != nil
+	}
+
+	handle("created", "kind/bug", "")
+	if !labelExists("kind/bug") {
+		t.Fatalf("Expecting label kind/bug to exist after creation")
+	}
+
+	handle("edited", "type/bug", `"changes": {"name": {"from": "kind/bug"}},`)
+	if labelExists("kind/bug") {
+		t.Errorf("Expecting label kind/bug to be gone after rename")
+	}
+	if !labelExists("type/bug") {
+		t.Fatalf("Expecting label type/bug to exist after rename")
+	}
+
+	handle("deleted", "type/bug", "")
+	if labelExists("type/bug") {
+		t.Errorf("Expecting label type/bug to be gone after deletion")
+	}
+
+	if len(store.labels) != 0 {
+		t.Errorf("Got %d labels in the store, expecting none", len(store.labels))
+	}
+}
+
+const pushPayload = `{
+	"ref": "refs/heads/%s",
+	"commits": [
+		{"id": "abc123", "added": [], "removed": [], "modified": ["%s"]}
+	],
+	"repository": {
+		"name": "istio",
+		"full_name": "istio/istio",
+		"default_branch": "master",
+		"owner": {"name": "istio", "login": "istio"}
+	},
+	"sender": {"login": "admin"}
+}`
+
+func TestPushEventsNotAffectingOwners(t *testing.T) {
+	cases := []struct {
+		name   string
+		branch string
+		file   string
+	}{
+		{"other branch", "release-1.3", "pilot/OWNERS"},
+		{"other file", "master", "pilot/pkg/model/config.go"},
+		{"vendored file", "master", "vendor/github.com/foo/bar/OWNERS"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			event, err := github.ParseWebHook("push", []byte(fmt.Sprintf(pushPayload, c.branch, c.file)))
+			if err != nil {
+				t.Fatalf("Unable to parse payload: %v", err)
+			}
+
+			// the store and GitHub client would fail if used
+			store := &fakeStore{}
+			orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+			r := refresher.NewRefresher(cache.New(store, time.Minute), store, nil, orgs)
+
+			r.Handle(context.Background(), event)
+		})
+	}
+}
+
+func TestIssueClosedEventRefetchesIssue(t *testing.T) {
+	eventTime := time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC)
+	fetchedTime := eventTime.Add(time.Hour)
+
+	cases := []struct {
+		name          string
+		storedUpdated time.Time
+		expectedState string
+	}{
+		{"not stored", time.Time{}, "closed"},
+		{"stored older", eventTime, "closed"},
+		{"stored newer", fetchedTime.Add(time.Hour), "open"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/repos/istio/istio/issues/42", func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewEncoder(w).Encode(&github.Issue{
+					Number:    github.Int(42),
+					State:     github.String("closed"),
+					UpdatedAt: &fetchedTime,
+				})
+			})
+
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			client := github.NewClient(nil)
+			client.BaseURL, _ = url.Parse(server.URL + "/")
+
+			store := &fakeStore{}
+			if !c.storedUpdated.IsZero() {
+				store.issues = map[int64]*storage.Issue{
+					42: {OrgLogin: "istio", RepoName: "istio", IssueNumber: 42, State: "open", UpdatedAt: c.storedUpdated},
+				}
+			}
+
+			orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+			r := refresher.NewRefresher(cache.New(store, time.Minute), store, gh.NewThrottledClientForClient(client), orgs)
+
+			// the payload lags behind the issue's actual state
+			r.Handle(context.Background(), &github.IssuesEvent{
+				Action: github.String("closed"),
+				Sender: &github.User{Login: github.String("closer")},
+				Issue: &github.Issue{
+					Number:    github.Int(42),
+					State:     github.String("open"),
+					UpdatedAt: &eventTime,
+					ClosedAt:  &eventTime,
+				},
+				Repo: &github.Repository{
+					Name:     github.String("istio"),
+					FullName: github.String("istio/istio"),
+					Owner:    &github.User{Login: github.String("istio")},
+				},
+			})
+
+			if store.issues[42] == nil || store.issues[42].State != c.expectedState {
+				t.Errorf("Got stored issue %+v, expecting state %s", store.issues[42], c.expectedState)
+			}
+
+			if len(store.issueEvents) != 1 {
+				t.Fatalf("Got %d issue events, expecting 1", len(store.issueEvents))
+			}
+
+			if e := store.issueEvents[0]; e.Action != "closed" || e.Actor != "closer" || !e.CreatedAt.Equal(eventTime) {
+				t.Errorf("Got event %+v, expecting closer to have closed the issue at %v", e, eventTime)
+			}
+		})
+	}
+}
+
+const labeledIssuePayload = `{
+	"action": "labeled",
+	"label": {"name": "kind/bug"},
+	"issue": {
+		"number": 42,
+		"labels": [{"name": "area/networking"}]
+	},
+	"repository": {
+		"name": "istio",
+		"full_name": "istio/istio",
+		"owner": {"login": "istio"}
+	},
+	"sender": {"login": "triager"}
+}`
+
+func TestLabelChangeEvents(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/pulls/7/files", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("[]"))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	repo := &github.Repository{
+		Name:     github.String("istio"),
+		FullName: github.String("istio/istio"),
+		Owner:    &github.User{Login: github.String("istio")},
+	}
+
+	// the payloads' label sets lag behind the changes
+	bug := &github.Label{Name: github.String("kind/bug")}
+	area := &github.Label{Name: github.String("area/networking")}
+
+	store := &fakeStore{}
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	r := refresher.NewRefresher(cache.New(store, time.Minute), store, gh.NewThrottledClientForClient(client), orgs)
+
+	issueEvent, err := github.ParseWebHook("issues", []byte(labeledIssuePayload))
+	if err != nil {
+		t.Fatalf("Unable to parse payload: %v", err)
+	}
+
+	r.Handle(context.Background(), issueEvent)
+
+	if got := fmt.Sprint(store.issues[42].Labels); got != "[area/networking kind/bug]" {
+		t.Errorf("Got issue labels %s, expecting [area/networking kind/bug]", got)
+	}
+
+	if len(store.issueEvents) != 1 || store.issueEvents[0].Label != "kind/bug" || store.issueEvents[0].Actor != "triager" {
+		t.Errorf("Got issue events %+v, expecting triager to have added kind/bug", store.issueEvents)
+	}
+
+	r.Handle(context.Background(), &github.PullRequestEvent{
+		Action:       github.String("unlabeled"),
+		Number:       github.Int(7),
+		Label:        bug,
+		Repo:         repo,
+		Organization: &github.Organization{Login: github.String("istio")},
+		PullRequest: &github.PullRequest{
+			Number: github.Int(7),
+			Labels: []*github.Label{area, bug},
+		},
+	})
+
+	if len(store.prs) != 1 || fmt.Sprint(store.prs[0].Labels) != "[area/networking]" {
+		t.Errorf("Got PRs %+v, expecting one with labels [area/networking]", store.prs)
+	}
+}
+
+func TestAssigneeChangeEvents(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/pulls/7/files", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("[]"))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	repo := &github.Repository{
+		Name:     github.String("istio"),
+		FullName: github.String("istio/istio"),
+		Owner:    &github.User{Login: github.String("istio")},
+	}
+
+	// the payloads' assignee sets lag behind the changes
+	alice := &github.User{Login: github.String("alice")}
+	bob := &github.User{Login: github.String("bob")}
+
+	store := &fakeStore{}
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	r := refresher.NewRefresher(cache.New(store, time.Minute), store, gh.NewThrottledClientForClient(client), orgs)
+
+	r.Handle(context.Background(), &github.IssuesEvent{
+		Action:   github.String("assigned"),
+		Sender:   &github.User{Login: github.String("triager")},
+		Assignee: bob,
+		Repo:     repo,
+		Issue: &github.Issue{
+			Number:    github.Int(42),
+			Assignees: []*github.User{alice},
+		},
+	})
+
+	if got := fmt.Sprint(store.issues[42].Assignees); got != "[alice bob]" {
+		t.Errorf("Got issue assignees %s, expecting [alice bob]", got)
+	}
+
+	r.Handle(context.Background(), &github.IssuesEvent{
+		Action:   github.String("unassigned"),
+		Sender:   &github.User{Login: github.String("alice")},
+		Assignee: alice,
+		Repo:     repo,
+		Issue: &github.Issue{
+			Number:    github.Int(42),
+			Assignees: []*github.User{alice, bob},
+		},
+	})
+
+	if got := fmt.Sprint(store.issues[42].Assignees); got != "[bob]" {
+		t.Errorf("Got issue assignees %s, expecting [bob]", got)
+	}
+
+	if len(store.issueEvents) != 2 {
+		t.Fatalf("Got %d issue events, expecting 2", len(store.issueEvents))
+	}
+
+	if e := store.issueEvents[0]; e.Action != "assigned" || e.Actor != "triager" || e.Assignee != "bob" {
+		t.Errorf("Got event %+v, expecting triager to have assigned bob", e)
+	}
+
+	if e := store.issueEvents[1]; e.Action != "unassigned" || e.Actor != "alice" || e.Assignee != "alice" {
+		t.Errorf("Got event %+v, expecting alice to have unassigned alice", e)
+	}
+
+	for _, action := range []string{"assigned", "unassigned"} {
+		r.Handle(context.Background(), &github.PullRequestEvent{
+			Action:       github.String(action),
+			Number:       github.Int(7),
+			Assignee:     alice,
+			Repo:         repo,
+			Organization: &github.Organization{Login: github.String("istio")},
+			Sender:       &github.User{Login: github.String("triager")},
+			PullRequest: &github.PullRequest{
+				Number:    github.Int(7),
+				Assignees: []*github.User{bob},
+			},
+		})
+	}
+
+	if len(store.prs) != 2 {
+		t.Fatalf("Got %d pull request writes, expecting 2", len(store.prs))
+	}
+
+	if got := fmt.Sprint(store.prs[0].Assignees); got != "[bob alice]" {
+		t.Errorf("Got PR assignees %s, expecting [bob alice]", got)
+	}
+
+	if got := fmt.Sprint(store.prs[1].Assignees); got != "[bob]" {
+		t.Errorf("Got PR assignees %s, expecting [bob]", got)
+	}
+
+	if len(store.prEvents) != 2 {
+		t.Fatalf("Got %d PR events, expecting 2", len(store.prEvents))
+	}
+
+	for i, action := range []string{"assigned", "unassigned"} {
+		if e := store.prEvents[i]; e.Action != action || e.Actor != "triager" || e.Assignee != "alice" {
+			t.Errorf("Got event %+v, expecting triager to have %s alice", e, action)
+		}
+	}
+}
+
+func TestMergedPullRequestEvent(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/pulls/7/files", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("[]"))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{}
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	r := refresher.NewRefresher(cache.New(store, time.Minute), store, gh.NewThrottledClientForClient(client), orgs)
+
+	closedAt := time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC)
+	mergedAt := closedAt.Add(-time.Second)
+
+	for _, merged := range []bool{false, true} {
+		r.Handle(context.Background(), &github.PullRequestEvent{
+			Action: github.String("closed"),
+			Number: github.Int(7),
+			Repo: &github.Repository{
+				Name:     github.String("istio"),
+				FullName: github.String("istio/istio"),
+				Owner:    &github.User{Login: github.String("istio")},
+			},
+			Organization: &github.Organization{Login: github.String("istio")},
+			PullRequest: &github.PullRequest{
+				Number:   github.Int(7),
+				Merged:   github.Bool(merged),
+				ClosedAt: &closedAt,
+				MergedAt: &mergedAt,
+			},
+		})
+	}
+
+	if len(store.prEvents) != 2 {
+		t.Fatalf("Got %d events, expecting 2", len(store.prEvents))
+	}
+
+	if e := store.prEvents[0]; e.Action != "closed" || !e.CreatedAt.Equal(closedAt) {
+		t.Errorf("Got event %+v, expecting a closed event at %v", e, closedAt)
+	}
+
+	if e := store.prEvents[1]; e.Action != "merged" || !e.CreatedAt.Equal(mergedAt) {
+		t.Errorf("Got event %+v, expecting a merged event at %v", e, mergedAt)
+	}
+}
+
+func TestReviewRequestedEvent(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/pulls/7/files", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("[]"))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{}
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	r := refresher.NewRefresher(cache.New(store, time.Minute), store, gh.NewThrottledClientForClient(client), orgs)
+
+	r.Handle(context.Background(), &github.PullRequestEvent{
+		Action: github.String("review_requested"),
+		Number: github.Int(7),
+		Repo: &github.Repository{
+			Name:     github.String("istio"),
+			FullName: github.String("istio/istio"),
+			Owner:    &github.User{Login: github.String("istio")},
+		},
+		Organization: &github.Organization{Login: github.String("istio")},
+		PullRequest: &github.PullRequest{
+			Number:             github.Int(7),
+			RequestedReviewers: []*github.User{{Login: github.String("alice")}, {Login: github.String("bob")}},
+		},
+	})
+
+	if len(store.prReviewers) != 2 || store.prReviewers[0].UserLogin != "alice" || store.prReviewers[1].UserLogin != "bob" {
+		t.Fatalf("Got reviewers %+v, expecting alice and bob", store.prReviewers)
+	}
+
+	if r := store.prReviewers[0]; r.OrgLogin != "istio" || r.RepoName != "istio" || r.PullRequestNumber != 7 {
+		t.Errorf("Got reviewer %+v, expecting one for PR 7 in istio/istio", r)
+	}
+}
+
+func TestReadyForReviewEvent(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/pulls/7/files", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("[]"))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{}
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	r := refresher.NewRefresher(cache.New(store, time.Minute), store, gh.NewThrottledClientForClient(client), orgs)
+
+	for _, action := range []string{"opened", "ready_for_review"} {
+		r.Handle(context.Background(), &github.PullRequestEvent{
+			Action: github.String(action),
+			Number: github.Int(7),
+			Repo: &github.Repository{
+				Name:     github.String("istio"),
+				FullName: github.String("istio/istio"),
+				Owner:    &github.User{Login: github.String("istio")},
+			},
+			Organization: &github.Organization{Login: github.String("istio")},
+			PullRequest: &github.PullRequest{
+				Number:    github.Int(7),
+				Draft:     github.Bool(action == "opened"),
+				Mergeable: github.Bool(true),
+			},
+		})
+	}
+
+	if len(store.prs) != 2 {
+		t.Fatalf("Got %d pull request writes, expecting 2", len(store.prs))
+	}
+
+	if !store.prs[0].Draft || store.prs[1].Draft {
+		t.Errorf("Got draft %v then %v, expecting true then false", store.prs[0].Draft, store.prs[1].Draft)
+	}
+
+	if !store.prs[1].Mergeable {
+		t.Errorf("Got pull request %+v, expecting it to be mergeable", store.prs[1])
+	}
+
+	if len(store.prEvents) != 2 || store.prEvents[1].Action != "ready_for_review" {
+		t.Errorf("Got events %+v, expecting a ready_for_review event", store.prEvents)
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releasenotes
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/handlers/githubwebhook/filters"
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/pkg/log"
+)
+
+const (
+	// StatusContext is the context of the commit status reporting whether a PR has release notes
+	StatusContext = "release-notes"
+
+	// NeededLabel is applied to PRs which are missing release notes
+	NeededLabel = "do-not-merge/release-note-needed"
+)
+
+// a fenced block of the form ```release-note ... ``` in a PR's body
+var noteBlock = regexp.MustCompile("(?s)```release-note[ \t]*\r?\n(.*?)```")
+
+// Checks that PRs come with release notes
+type ReleaseNoteChecker struct {
+	gc           *gh.ThrottledClient
+	orgs         []config.Org
+	repos        map[string]bool
+	files        []*regexp.Regexp
+	exemptLabels map[string]bool
+}
+
+var scope = log.RegisterScope("releasenotes", "PR release notes checker", 0)
+
+func NewReleaseNoteChecker(gc *gh.ThrottledClient, orgs []config.Org, rn config.ReleaseNotes) (filters.Filter, error) {
+	c := &ReleaseNoteChecker{
+		gc:           gc,
+		orgs:         orgs,
+		repos:        make(map[string]bool, len(rn.Repos)),
+		exemptLabels: make(map[string]bool, len(rn.ExemptLabels)),
+	}
+
+	for _, repo := range rn.Repos {
+		c.repos[repo] = true
+	}
+
+	for _, label := range rn.ExemptLabels {
+		c.exemptLabels[label] = true
+	}
+
+	for _, expr := range rn.Files {
+		r, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %s: %v", expr, err)
+		}
+		c.files = append(c.files, r)
+	}
+
+	return c, nil
+}
+
+// the webhook events the filter reacts to
+func (c *ReleaseNoteChecker) Events() []string {
+	return []string{"pull_request"}
+}
+
+// process an event arriving from GitHub
+func (c *ReleaseNoteChecker) Handle(context context.Context, event interface{}) {
+	prp, ok := event.(*github.PullRequestEvent)
+	if !ok {
+		// not what we're looking for
+		return
+	}
+
+	if filters.IsReplay(context) {
+		// the status was set when the event first arrived
+		return
+	}
+
+	action := prp.GetAction()
+	if action != "opened" && action != "reopened" && action != "synchronize" && action != "edited" &&
+		action != "labeled" && action != "unlabeled" {
+		// nothing affecting the release notes has changed
+		return
+	}
+
+	repo := prp.GetRepo().GetFullName()
+	if !c.repos[repo] {
+		return
+	}
+
+	if config.FindOrgForRepo(c.orgs, repo) == nil {
+		scope.Infof("Ignoring PR %d from repo %s since it's not in a monitored repo", prp.GetNumber(), repo)
+		return
+	}
+
+	pr := prp.GetPullRequest()
+	orgLogin := prp.GetRepo().GetOwner().GetLogin()
+	repoName := prp.GetRepo().GetName()
+
+	var labels []string
+	for _, label := range pr.Labels {
+		labels = append(labels, label.GetName())
+	}
+
+	state, description := c.check(pr.GetBody(), labels, nil)
+	if state != "success" && len(c.files) > 0 {
+		files, err := c.getFiles(context, orgLogin, repoName, pr.GetNumber())
+		if err != nil {
+			scope.Errorf("Unable to list all files for pull request %d in repo %s: %v", pr.GetNumber(), repo, err)
+			return
+		}
+		state, description = c.check(pr.GetBody(), labels, files)
+	}
+
+	if _, _, err := c.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Repositories.CreateStatus(context, orgLogin, repoName, pr.GetHead().GetSHA(), &github.RepoStatus{
+			State:       &state,
+			Description: &description,
+			Context:     github.String(StatusContext),
+		})
+	}); err != nil {
+		scope.Errorf("Unable to set release notes status on pr %d in repo %s: %v", pr.GetNumber(), repo, err)
+		return
+	}
+
+	needed := false
+	for _, label := range labels {
+		if label == NeededLabel {
+			needed = true
+			break
+		}
+	}
+
+	if state == "success" && needed {
+		if _, err := c.gc.ThrottledCallNoResult(func(client *github.Client) (*github.Response, error) {
+			return client.Issues.RemoveLabelForIssue(context, orgLogin, repoName, pr.GetNumber(), NeededLabel)
+		}); err != nil {
+			scope.Errorf("Unable to remove label %s from pr %d in repo %s: %v", NeededLabel, pr.GetNumber(), repo, err)
+			return
+		}
+	} else if state != "success" && !needed {
+		if _, _, err := c.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Issues.AddLabelsToIssue(context, orgLogin, repoName, pr.GetNumber(), []string{NeededLabel})
+		}); err != nil {
+			scope.Errorf("Unable to set label %s on pr %d in repo %s: %v", NeededLabel, pr.GetNumber(), repo, err)
+			return
+		}
+	}
+
+	scope.Infof("PR %d from repo %s release notes check: %s, %s", pr.GetNumber(), repo, state, description)
+}
+
+// check returns the state and description of the release notes status for a PR with the given body, labels, and
+// changed files
+func (c *ReleaseNoteChecker) check(body string, labels []string, files []string) (string, string) {
+	for _, label := range labels {
+		if c.exemptLabels[label] {
+			return "success", fmt.Sprintf("Release notes not needed, labeled %s", label)
+		}
+	}
+
+	for _, m := range noteBlock.FindAllStringSubmatch(body, -1) {
+		if strings.TrimSpace(m[1]) != "" {
+			return "success", "Release notes found in the description"
+		}
+	}
+
+	for _, file := range files {
+		for _, r := range c.files {
+			if r.MatchString(file) {
+				return "success", fmt.Sprintf("Release notes found in %s", file)
+			}
+		}
+	}
+
+	return "failure", "Add a release-note block to the description or a release notes file"
+}
+
+func (c *ReleaseNoteChecker) getFiles(context context.Context, orgLogin string, repoName string, number int) ([]string, error) {
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+
+	var allFiles []string
+	for {
+		files, resp, err := c.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.PullRequests.ListFiles(context, orgLogin, repoName, number, opt)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files.([]*github.CommitFile) {
+			allFiles = append(allFiles, f.GetFilename())
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opt.Page = resp.NextPage
+	}
+
+	return allFiles, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releasenotes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+)
+
+var rn = config.ReleaseNotes{
+	Repos:        []string{"istio/istio"},
+	Files:        []string{"^releasenotes/"},
+	ExemptLabels: []string{"release-note-none"},
+}
+
+func TestCheck(t *testing.T) {
+	f, err := NewReleaseNoteChecker(nil, nil, rn)
+	if err != nil {
+		t.Fatalf("Unable to create release notes checker: %v", err)
+	}
+	c := f.(*ReleaseNoteChecker)
+
+	cases := []struct {
+		name   string
+		body   string
+		labels []string
+		files  []string
+		state  string
+	}{
+		{"nothing", "Fixes a crash", nil, []string{"pilot/main.go"}, "failure"},
+		{"block", "Fixes a crash\n\n```release-note\nFixed a crash in Pilot.\n```\n", nil, nil, "success"},
+		{"empty block", "Fixes a crash\n\n```release-note\n\n```\n", nil, nil, "failure"},
+		{"other block", "```go\nfmt.Println()\n```", nil, nil, "failure"},
+		{"file", "Fixes a crash", nil, []string{"pilot/main.go", "releasenotes/notes/crash.yaml"}, "success"},
+		{"exempt", "Fixes a typo", []string{"release-note-none"}, nil, "success"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if state, description := c.check(tc.body, tc.labels, tc.files); state != tc.state {
+				t.Errorf("Got %s (%s), expecting %s", state, description, tc.state)
+			}
+		})
+	}
+}
+
+func TestEditedBody(t *testing.T) {
+	var states []string
+	labels := map[string]bool{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/statuses/abc", func(w http.ResponseWriter, r *http.Request) {
+		var status github.RepoStatus
+		if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+			t.Fatalf("Unable to decode status: %v", err)
+		}
+
+		if status.GetContext() != StatusContext {
+			t.Errorf("Got status context %s, expecting %s", status.GetContext(), StatusContext)
+		}
+
+		states = append(states, status.GetState())
+		_ = json.NewEncoder(w).Encode(status)
+	})
+	mux.HandleFunc("/repos/istio/istio/pulls/42/files", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*github.CommitFile{{Filename: github.String("pilot/main.go")}})
+	})
+	mux.HandleFunc("/repos/istio/istio/issues/42/labels", func(w http.ResponseWriter, r *http.Request) {
+		labels[NeededLabel] = true
+		_ = json.NewEncoder(w).Encode([]*github.Label{{Name: github.String(NeededLabel)}})
+	})
+	mux.HandleFunc("/repos/istio/istio/issues/42/labels/"+NeededLabel, func(w http.ResponseWriter, r *http.Request) {
+		delete(labels, NeededLabel)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	c, err := NewReleaseNoteChecker(gh.NewThrottledClientForClient(client), orgs, rn)
+	if err != nil {
+		t.Fatalf("Unable to create release notes checker: %v", err)
+	}
+
+	event := func(action string, body string) *github.PullRequestEvent {
+		var prLabels []*github.Label
+		for label := range labels {
+			prLabels = append(prLabels, &github.Label{Name: github.String(label)})
+		}
+
+		return &github.PullRequestEvent{
+			Action: github.String(action),
+			Number: github.Int(42),
+			PullRequest: &github.PullRequest{
+				Number: github.Int(42),
+				Body:   github.String(body),
+				Head:   &github.PullRequestBranch{SHA: github.String("abc")},
+				Labels: prLabels,
+			},
+			Repo: &github.Repository{
+				Name:     github.String("istio"),
+				FullName: github.String("istio/istio"),
+				Owner:    &github.User{Login: github.String("istio")},
+			},
+		}
+	}
+
+	c.Handle(context.Background(), event("opened", "Fixes a crash"))
+	if len(states) != 1 || states[0] != "failure" || !labels[NeededLabel] {
+		t.Fatalf("Got states %v and labels %v, expecting a failure and %s", states, labels, NeededLabel)
+	}
+
+	c.Handle(context.Background(), event("edited", "Fixes a crash\n\n```release-note\nFixed a crash in Pilot.\n```"))
+	if len(states) != 2 || states[1] != "success" || labels[NeededLabel] {
+		t.Errorf("Got states %v and labels %v, expecting a success and no %s", states, labels, NeededLabel)
+	}
+}
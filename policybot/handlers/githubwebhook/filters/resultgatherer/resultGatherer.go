@@ -67,6 +67,11 @@ func NewResultGatherer(store storage.Store,
 	return r
 }
 
+// the webhook events the filter reacts to
+func (r *ResultGatherer) Events() []string {
+	return []string{"pull_request", "check_run"}
+}
+
 // accept an event arriving from GitHub
 func (r *ResultGatherer) Handle(context context.Context, event interface{}) {
 	switch p := event.(type) {
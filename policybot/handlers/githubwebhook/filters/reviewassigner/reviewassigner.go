@@ -0,0 +1,264 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reviewassigner
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/handlers/githubwebhook/filters"
+	"istio.io/bots/policybot/pkg/codeowners"
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/pkg/log"
+)
+
+// Requests reviews for new PRs from the maintainers of the files they change
+type ReviewAssigner struct {
+	gc    *gh.ThrottledClient
+	store storage.Store
+	orgs  []config.Org
+}
+
+var scope = log.RegisterScope("reviewassigner", "PR review assigner", 0)
+
+func NewReviewAssigner(gc *gh.ThrottledClient, store storage.Store, orgs []config.Org) filters.Filter {
+	return &ReviewAssigner{
+		gc:    gc,
+		store: store,
+		orgs:  orgs,
+	}
+}
+
+// the webhook events the filter reacts to
+func (ra *ReviewAssigner) Events() []string {
+	return []string{"pull_request"}
+}
+
+// process an event arriving from GitHub
+func (ra *ReviewAssigner) Handle(context context.Context, event interface{}) {
+	prp, ok := event.(*github.PullRequestEvent)
+	if !ok {
+		// not what we're looking for
+		return
+	}
+
+	if filters.IsReplay(context) {
+		// the reviewers were requested when the event first arrived
+		return
+	}
+
+	if prp.GetAction() != "opened" {
+		return
+	}
+
+	repo := prp.GetRepo().GetFullName()
+	org := config.FindOrgForRepo(ra.orgs, repo)
+	if org == nil {
+		scope.Infof("Ignoring PR %d from repo %s since it's not in a monitored repo", prp.GetNumber(), repo)
+		return
+	}
+
+	ras := org.ReviewAssignment
+	if ras.Count <= 0 {
+		return
+	}
+
+	pr := prp.GetPullRequest()
+	for _, label := range pr.Labels {
+		if ras.OptOutLabel != "" && label.GetName() == ras.OptOutLabel {
+			scope.Infof("Not requesting reviewers for PR %d from repo %s since it's labeled %s", pr.GetNumber(), repo, ras.OptOutLabel)
+			return
+		}
+	}
+
+	orgLogin := prp.GetRepo().GetOwner().GetLogin()
+	repoName := prp.GetRepo().GetName()
+
+	files, err := ra.getFiles(context, orgLogin, repoName, pr.GetNumber())
+	if err != nil {
+		scope.Errorf("Unable to list all files for pull request %d in repo %s: %v", pr.GetNumber(), repo, err)
+		return
+	}
+
+	reviewers, teams, err := ra.chooseReviewers(context, orgLogin, repoName, pr.GetUser().GetLogin(), files, ras.Count)
+	if err != nil {
+		scope.Errorf("Unable to choose reviewers for pull request %d in repo %s: %v", pr.GetNumber(), repo, err)
+		return
+	}
+
+	if len(reviewers) == 0 && len(teams) == 0 {
+		scope.Infof("No reviewers found for pull request %d in repo %s", pr.GetNumber(), repo)
+		return
+	}
+
+	if _, _, err := ra.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.PullRequests.RequestReviewers(context, orgLogin, repoName, pr.GetNumber(), github.ReviewersRequest{
+			Reviewers:     reviewers,
+			TeamReviewers: teams,
+		})
+	}); err != nil {
+		scope.Errorf("Unable to request reviewers for pull request %d in repo %s: %v", pr.GetNumber(), repo, err)
+		return
+	}
+
+	scope.Infof("Requested reviews from %v and teams %v for pull request %d in repo %s", reviewers, teams, pr.GetNumber(), repo)
+}
+
+func (ra *ReviewAssigner) getFiles(context context.Context, orgLogin string, repoName string, number int) ([]string, error) {
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+
+	var allFiles []string
+	for {
+		files, resp, err := ra.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.PullRequests.ListFiles(context, orgLogin, repoName, number, opt)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files.([]*github.CommitFile) {
+			allFiles = append(allFiles, f.GetFilename())
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opt.Page = resp.NextPage
+	}
+
+	return allFiles, nil
+}
+
+// chooseReviewers picks up to count reviewers for the given files. For each file, the maintainers with the most
+// specific path covering the file get a point, and the maintainers with the most points win. When no maintainer
+// covers any of the files, the owners of the root of the repo in its CODEOWNERS file are used instead, which can
+// include teams.
+func (ra *ReviewAssigner) chooseReviewers(context context.Context, orgLogin string, repoName string, author string,
+	files []string, count int) ([]string, []string, error) {
+
+	var candidates []*storage.Maintainer
+	if err := ra.store.QueryMaintainersByOrg(context, orgLogin, func(m *storage.Maintainer) error {
+		if !m.Emeritus && !strings.EqualFold(m.UserLogin, author) {
+			candidates = append(candidates, m)
+		}
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	scores := make(map[string]int)
+	for _, file := range files {
+		best := -1
+		owners := make(map[string]bool)
+		for _, m := range candidates {
+			for _, p := range m.Paths {
+				pattern := strings.TrimPrefix(p, repoName+"/")
+				if pattern == p || !codeowners.Match(pattern, file) {
+					// not a path within this repo, or not one covering the file
+					continue
+				}
+
+				s := specificity(pattern)
+				if s > best {
+					best = s
+					owners = make(map[string]bool)
+				}
+
+				if s == best {
+					owners[m.UserLogin] = true
+				}
+			}
+		}
+
+		for o := range owners {
+			scores[o]++
+		}
+	}
+
+	if len(scores) > 0 {
+		return topScores(scores, count), nil, nil
+	}
+
+	return ra.rootOwners(context, orgLogin, repoName, author, count)
+}
+
+// rootOwners returns the users and teams owning the root of a repo in its CODEOWNERS file.
+func (ra *ReviewAssigner) rootOwners(context context.Context, orgLogin string, repoName string, author string,
+	count int) ([]string, []string, error) {
+
+	co, err := ra.store.ReadCodeOwners(context, orgLogin, repoName)
+	if err != nil || co == nil {
+		return nil, nil, err
+	}
+
+	var users []string
+	var teams []string
+	for _, owner := range codeowners.Owners(codeowners.Parse(strings.Join(co.Lines, "\n")), "") {
+		if len(users)+len(teams) >= count {
+			break
+		}
+
+		if i := strings.Index(owner, "/"); i >= 0 {
+			// review requests name teams by their slug alone
+			teams = append(teams, owner[i+1:])
+		} else if !strings.EqualFold(owner, author) {
+			users = append(users, owner)
+		}
+	}
+
+	return users, teams, nil
+}
+
+// specificity ranks a path by the number of literal segments it contains, such that a/b/** ranks above a/**.
+func specificity(pattern string) int {
+	result := 0
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment != "" && !strings.ContainsAny(segment, "*?") {
+			result++
+		}
+	}
+
+	return result
+}
+
+// topScores returns up to count logins with the highest scores, breaking ties alphabetically.
+func topScores(scores map[string]int, count int) []string {
+	logins := make([]string, 0, len(scores))
+	for login := range scores {
+		logins = append(logins, login)
+	}
+
+	sort.Slice(logins, func(i, j int) bool {
+		if scores[logins[i]] != scores[logins[j]] {
+			return scores[logins[i]] > scores[logins[j]]
+		}
+		return logins[i] < logins[j]
+	})
+
+	if len(logins) > count {
+		logins = logins[:count]
+	}
+
+	return logins
+}
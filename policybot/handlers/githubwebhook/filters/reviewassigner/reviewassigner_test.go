@@ -0,0 +1,89 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reviewassigner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"istio.io/bots/policybot/pkg/storage"
+)
+
+type fakeStore struct {
+	storage.Store
+
+	maintainers []*storage.Maintainer
+	codeOwners  *storage.CodeOwners
+}
+
+func (fs *fakeStore) QueryMaintainersByOrg(_ context.Context, _ string, cb func(*storage.Maintainer) error) error {
+	for _, m := range fs.maintainers {
+		if err := cb(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *fakeStore) ReadCodeOwners(_ context.Context, _ string, _ string) (*storage.CodeOwners, error) {
+	return fs.codeOwners, nil
+}
+
+func TestChooseReviewers(t *testing.T) {
+	store := &fakeStore{
+		maintainers: []*storage.Maintainer{
+			{UserLogin: "root", Paths: []string{"istio/**"}},
+			{UserLogin: "pilot", Paths: []string{"istio/pilot/**"}},
+			{UserLogin: "xds", Paths: []string{"istio/pilot/pkg/xds/**"}},
+			{UserLogin: "mixer", Paths: []string{"istio/mixer/**", "proxy/**"}},
+			{UserLogin: "retired", Paths: []string{"istio/pilot/**"}, Emeritus: true},
+			{UserLogin: "proxy", Paths: []string{"proxy/src/**"}},
+		},
+		codeOwners: &storage.CodeOwners{Lines: []string{"* @alice @istio/wg-networking", "/docs/ @bob"}},
+	}
+
+	cases := []struct {
+		name   string
+		repo   string
+		author string
+		files  []string
+		count  int
+		users  []string
+		teams  []string
+	}{
+		{"most specific wins", "istio", "someone", []string{"pilot/pkg/xds/ads.go"}, 3, []string{"xds"}, nil},
+		{"most files wins", "istio", "someone",
+			[]string{"pilot/pkg/xds/ads.go", "pilot/main.go", "pilot/cmd/run.go", "mixer/adapter.go"}, 2, []string{"pilot", "mixer"}, nil},
+		{"top N", "istio", "someone", []string{"pilot/main.go", "mixer/adapter.go", "xds/README.md"}, 2, []string{"mixer", "pilot"}, nil},
+		{"author excluded", "istio", "xds", []string{"pilot/pkg/xds/ads.go"}, 3, []string{"pilot"}, nil},
+		{"fallback to CODEOWNERS", "api", "someone", []string{"README.md"}, 3, []string{"alice"}, []string{"wg-networking"}},
+		{"fallback author excluded", "api", "alice", []string{"README.md"}, 3, nil, []string{"wg-networking"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ra := &ReviewAssigner{store: store}
+			users, teams, err := ra.chooseReviewers(context.Background(), "istio", c.repo, c.author, c.files, c.count)
+			if err != nil {
+				t.Fatalf("Got error %v", err)
+			}
+
+			if fmt.Sprint(users) != fmt.Sprint(c.users) || fmt.Sprint(teams) != fmt.Sprint(c.teams) {
+				t.Errorf("Got reviewers %v and teams %v, expecting %v and %v", users, teams, c.users, c.teams)
+			}
+		})
+	}
+}
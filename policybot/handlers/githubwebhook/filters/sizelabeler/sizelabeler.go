@@ -0,0 +1,209 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sizelabeler
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/handlers/githubwebhook/filters"
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/pkg/log"
+)
+
+// Labels PRs according to the number of lines they change
+type SizeLabeler struct {
+	gc           *gh.ThrottledClient
+	orgs         []config.Org
+	sizes        []config.SizeLabel
+	excludeFiles []*regexp.Regexp
+}
+
+var scope = log.RegisterScope("sizelabeler", "PR size labeler", 0)
+
+func NewSizeLabeler(gc *gh.ThrottledClient, orgs []config.Org, sl config.SizeLabels) (filters.Filter, error) {
+	s := &SizeLabeler{
+		gc:    gc,
+		orgs:  orgs,
+		sizes: sl.Labels,
+	}
+
+	for _, expr := range sl.ExcludeFiles {
+		r, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %s: %v", expr, err)
+		}
+		s.excludeFiles = append(s.excludeFiles, r)
+	}
+
+	return s, nil
+}
+
+// the webhook events the filter reacts to
+func (s *SizeLabeler) Events() []string {
+	return []string{"pull_request"}
+}
+
+// process an event arriving from GitHub
+func (s *SizeLabeler) Handle(context context.Context, event interface{}) {
+	prp, ok := event.(*github.PullRequestEvent)
+	if !ok {
+		// not what we're looking for
+		return
+	}
+
+	if filters.IsReplay(context) {
+		// the labels were taken care of when the event first arrived
+		return
+	}
+
+	action := prp.GetAction()
+	if action != "opened" && action != "reopened" && action != "synchronize" {
+		// the size of the PR hasn't changed
+		return
+	}
+
+	if len(s.sizes) == 0 {
+		return
+	}
+
+	repo := prp.GetRepo().GetFullName()
+	if config.FindOrgForRepo(s.orgs, repo) == nil {
+		scope.Infof("Ignoring PR %d from repo %s since it's not in a monitored repo", prp.GetNumber(), repo)
+		return
+	}
+
+	pr := prp.GetPullRequest()
+	orgLogin := prp.GetRepo().GetOwner().GetLogin()
+	repoName := prp.GetRepo().GetName()
+
+	lines := pr.GetAdditions() + pr.GetDeletions()
+	if len(s.excludeFiles) > 0 {
+		// the payload's stats cover all files, so go through the files one by one
+		files, err := s.getFiles(context, orgLogin, repoName, pr.GetNumber())
+		if err != nil {
+			scope.Errorf("Unable to list all files for pull request %d in repo %s: %v", pr.GetNumber(), repo, err)
+			return
+		}
+		lines = s.countLines(files)
+	}
+
+	size := s.sizeLabel(lines)
+
+	var toRemove []string
+	present := false
+	for _, label := range pr.Labels {
+		if label.GetName() == size {
+			present = true
+		} else if s.isSizeLabel(label.GetName()) {
+			toRemove = append(toRemove, label.GetName())
+		}
+	}
+
+	for _, label := range toRemove {
+		if _, err := s.gc.ThrottledCallNoResult(func(client *github.Client) (*github.Response, error) {
+			return client.Issues.RemoveLabelForIssue(context, orgLogin, repoName, pr.GetNumber(), label)
+		}); err != nil {
+			scope.Errorf("Unable to remove label %s from pr %d in repo %s: %v", label, pr.GetNumber(), repo, err)
+			return
+		}
+	}
+
+	if !present {
+		if _, _, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Issues.AddLabelsToIssue(context, orgLogin, repoName, pr.GetNumber(), []string{size})
+		}); err != nil {
+			scope.Errorf("Unable to set label %s on pr %d in repo %s: %v", size, pr.GetNumber(), repo, err)
+			return
+		}
+	}
+
+	scope.Infof("PR %d from repo %s changes %d lines, labeled as %s", pr.GetNumber(), repo, lines, size)
+}
+
+func (s *SizeLabeler) getFiles(context context.Context, orgLogin string, repoName string, number int) ([]*github.CommitFile, error) {
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+
+	var allFiles []*github.CommitFile
+	for {
+		files, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.PullRequests.ListFiles(context, orgLogin, repoName, number, opt)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		allFiles = append(allFiles, files.([]*github.CommitFile)...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opt.Page = resp.NextPage
+	}
+
+	return allFiles, nil
+}
+
+// countLines returns the number of lines added and deleted in the given files, skipping excluded files
+func (s *SizeLabeler) countLines(files []*github.CommitFile) int {
+	lines := 0
+	for _, f := range files {
+		if !s.excluded(f.GetFilename()) {
+			lines += f.GetAdditions() + f.GetDeletions()
+		}
+	}
+
+	return lines
+}
+
+func (s *SizeLabeler) excluded(file string) bool {
+	for _, r := range s.excludeFiles {
+		if r.MatchString(file) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sizeLabel returns the label for a PR changing the given number of lines
+func (s *SizeLabeler) sizeLabel(lines int) string {
+	for _, size := range s.sizes {
+		if size.MaxLines == 0 || lines <= size.MaxLines {
+			return size.Label
+		}
+	}
+
+	// larger than even the largest size
+	return s.sizes[len(s.sizes)-1].Label
+}
+
+func (s *SizeLabeler) isSizeLabel(label string) bool {
+	for _, size := range s.sizes {
+		if size.Label == label {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sizelabeler
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/config"
+)
+
+func TestSizeLabel(t *testing.T) {
+	sl := config.SizeLabels{
+		Labels: []config.SizeLabel{
+			{Label: "size/XS", MaxLines: 9},
+			{Label: "size/S", MaxLines: 49},
+			{Label: "size/M", MaxLines: 249},
+			{Label: "size/L"},
+		},
+		ExcludeFiles: []string{"^vendor/", `\.pb\.go$`},
+	}
+
+	f, err := NewSizeLabeler(nil, nil, sl)
+	if err != nil {
+		t.Fatalf("Unable to create size labeler: %v", err)
+	}
+	s := f.(*SizeLabeler)
+
+	cases := []struct {
+		lines    int
+		expected string
+	}{
+		{0, "size/XS"},
+		{9, "size/XS"},
+		{10, "size/S"},
+		{249, "size/M"},
+		{250, "size/L"},
+		{100000, "size/L"},
+	}
+
+	for _, c := range cases {
+		if got := s.sizeLabel(c.lines); got != c.expected {
+			t.Errorf("Got %s for %d lines, expecting %s", got, c.lines, c.expected)
+		}
+	}
+
+	files := []*github.CommitFile{
+		{Filename: github.String("pilot/pkg/model/config.go"), Additions: github.Int(20), Deletions: github.Int(5)},
+		{Filename: github.String("pkg/api/mesh.pb.go"), Additions: github.Int(5000), Deletions: github.Int(3000)},
+		{Filename: github.String("vendor/github.com/foo/bar.go"), Additions: github.Int(700)},
+	}
+
+	if lines := s.countLines(files); lines != 25 {
+		t.Errorf("Got %d lines, expecting 25", lines)
+	}
+}
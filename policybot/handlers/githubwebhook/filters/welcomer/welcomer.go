@@ -0,0 +1,181 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package welcomer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/handlers/githubwebhook/filters"
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/pkg/log"
+)
+
+// Posts a welcome comment on the first issue or PR someone opens in an org
+type Welcomer struct {
+	gc        *gh.ThrottledClient
+	store     storage.Store
+	orgs      []config.Org
+	templates map[string]*template.Template
+}
+
+var scope = log.RegisterScope("welcomer", "Welcomer for first-time contributors", 0)
+
+func NewWelcomer(gc *gh.ThrottledClient, store storage.Store, orgs []config.Org) (filters.Filter, error) {
+	w := &Welcomer{
+		gc:        gc,
+		store:     store,
+		orgs:      orgs,
+		templates: make(map[string]*template.Template),
+	}
+
+	for _, org := range orgs {
+		if org.Welcome.Message == "" {
+			continue
+		}
+
+		t, err := template.New(org.Name).Parse(org.Welcome.Message)
+		if err != nil {
+			return nil, fmt.Errorf("invalid welcome message template for org %s: %v", org.Name, err)
+		}
+		w.templates[org.Name] = t
+	}
+
+	return w, nil
+}
+
+// the webhook events the filter reacts to
+func (w *Welcomer) Events() []string {
+	return []string{"issues", "pull_request"}
+}
+
+// process an event arriving from GitHub
+func (w *Welcomer) Handle(context context.Context, event interface{}) {
+	if filters.IsReplay(context) {
+		// the welcome was posted when the event first arrived
+		return
+	}
+
+	var action string
+	var repo *github.Repository
+	var number int
+	var author string
+	var createdAt time.Time
+
+	switch p := event.(type) {
+	case *github.IssuesEvent:
+		action = p.GetAction()
+		repo = p.GetRepo()
+		number = p.GetIssue().GetNumber()
+		author = p.GetIssue().GetUser().GetLogin()
+		createdAt = p.GetIssue().GetCreatedAt()
+
+	case *github.PullRequestEvent:
+		action = p.GetAction()
+		repo = p.GetRepo()
+		number = p.GetNumber()
+		author = p.GetPullRequest().GetUser().GetLogin()
+		createdAt = p.GetPullRequest().GetCreatedAt()
+
+	default:
+		// not what we're looking for
+		return
+	}
+
+	if action != "opened" {
+		return
+	}
+
+	if author == "" || strings.HasSuffix(author, "[bot]") {
+		return
+	}
+
+	org := config.FindOrgForRepo(w.orgs, repo.GetFullName())
+	if org == nil {
+		scope.Infof("Ignoring event %d from repo %s since it's not in a monitored repo", number, repo.GetFullName())
+		return
+	}
+
+	t := w.templates[org.Name]
+	if t == nil {
+		return
+	}
+
+	orgLogin := repo.GetOwner().GetLogin()
+	repoName := repo.GetName()
+
+	welcome, err := w.shouldWelcome(context, orgLogin, repoName, author, createdAt)
+	if err != nil {
+		scope.Errorf("Unable to determine whether %s is a first-time contributor to repo %s: %v", author, repo.GetFullName(), err)
+		return
+	} else if !welcome {
+		return
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, struct {
+		Author string
+		Repo   string
+	}{author, repoName}); err != nil {
+		scope.Errorf("Unable to produce welcome message for %s in repo %s: %v", author, repo.GetFullName(), err)
+		return
+	}
+	body := b.String()
+
+	if _, _, err := w.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Issues.CreateComment(context, orgLogin, repoName, number, &github.IssueComment{Body: &body})
+	}); err != nil {
+		scope.Errorf("Unable to post welcome message on %d in repo %s: %v", number, repo.GetFullName(), err)
+		return
+	}
+
+	// remember the welcome so that it's not repeated, even if this contribution is deleted
+	if err := w.store.WriteFirstInteractions(context, []*storage.FirstInteraction{{
+		OrgLogin:  orgLogin,
+		RepoName:  repoName,
+		UserLogin: author,
+		CreatedAt: createdAt,
+	}}); err != nil {
+		scope.Errorf("Unable to record welcome of %s in repo %s: %v", author, repo.GetFullName(), err)
+	}
+
+	scope.Infof("Welcomed first-time contributor %s on %d in repo %s", author, number, repo.GetFullName())
+}
+
+// shouldWelcome returns whether the user has yet to be welcomed to the repo and has no earlier contributions to the org
+func (w *Welcomer) shouldWelcome(context context.Context, orgLogin string, repoName string, userLogin string,
+	createdAt time.Time) (bool, error) {
+	fi, err := w.store.ReadFirstInteraction(context, orgLogin, repoName, userLogin)
+	if err != nil {
+		return false, err
+	} else if fi != nil {
+		return false, nil
+	}
+
+	count, err := w.store.QueryContributionCount(context, orgLogin, userLogin, createdAt)
+	if err != nil {
+		return false, err
+	}
+
+	return count == 0, nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package welcomer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
+)
+
+type fakeStore struct {
+	storage.Store
+
+	contributions map[string]int64
+	interactions  map[string]*storage.FirstInteraction
+}
+
+func (fs *fakeStore) ReadFirstInteraction(_ context.Context, orgLogin string, repoName string,
+	userLogin string) (*storage.FirstInteraction, error) {
+	return fs.interactions[orgLogin+"/"+repoName+"/"+userLogin], nil
+}
+
+func (fs *fakeStore) WriteFirstInteractions(_ context.Context, interactions []*storage.FirstInteraction) error {
+	for _, fi := range interactions {
+		fs.interactions[fi.OrgLogin+"/"+fi.RepoName+"/"+fi.UserLogin] = fi
+	}
+	return nil
+}
+
+func (fs *fakeStore) QueryContributionCount(_ context.Context, _ string, userLogin string, _ time.Time) (int64, error) {
+	return fs.contributions[userLogin], nil
+}
+
+func TestWelcome(t *testing.T) {
+	var comments []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/issues/42/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+			t.Fatalf("Unable to decode comment: %v", err)
+		}
+
+		comments = append(comments, comment.GetBody())
+		_ = json.NewEncoder(w).Encode(comment)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	orgs := []config.Org{{
+		Name:    "istio",
+		Repos:   []config.Repo{{Name: "istio"}},
+		Welcome: config.Welcome{Message: "Welcome @{{.Author}}! Please read the {{.Repo}} contributing guide."},
+	}}
+
+	cases := []struct {
+		name          string
+		author        string
+		contributions int64
+		welcomed      bool
+		expected      int
+	}{
+		{"first-time contributor", "newcomer", 0, false, 1},
+		{"prior contributions", "regular", 3, false, 0},
+		{"already welcomed", "returning", 0, true, 0},
+		{"bot", "dependabot[bot]", 0, false, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			comments = nil
+			store := &fakeStore{
+				contributions: map[string]int64{c.author: c.contributions},
+				interactions:  map[string]*storage.FirstInteraction{},
+			}
+
+			if c.welcomed {
+				store.interactions["istio/istio/"+c.author] = &storage.FirstInteraction{}
+			}
+
+			w, err := NewWelcomer(gh.NewThrottledClientForClient(client), store, orgs)
+			if err != nil {
+				t.Fatalf("Unable to create welcomer: %v", err)
+			}
+
+			event := &github.IssuesEvent{
+				Action: github.String("opened"),
+				Issue: &github.Issue{
+					Number: github.Int(42),
+					User:   &github.User{Login: github.String(c.author)},
+				},
+				Repo: &github.Repository{
+					Name:     github.String("istio"),
+					FullName: github.String("istio/istio"),
+					Owner:    &github.User{Login: github.String("istio")},
+				},
+			}
+
+			w.Handle(context.Background(), event)
+			w.Handle(context.Background(), event)
+
+			if len(comments) != c.expected {
+				t.Fatalf("Got %d comments, expecting %d", len(comments), c.expected)
+			}
+
+			if c.expected > 0 {
+				expected := "Welcome @" + c.author + "! Please read the istio contributing guide."
+				if comments[0] != expected {
+					t.Errorf("Got comment '%s', expecting '%s'", comments[0], expected)
+				}
+
+				if store.interactions["istio/istio/"+c.author] == nil {
+					t.Errorf("Welcome was not recorded")
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidTemplate(t *testing.T) {
+	orgs := []config.Org{{Name: "istio", Welcome: config.Welcome{Message: "Welcome {{.Author"}}}
+	if _, err := NewWelcomer(nil, nil, orgs); err == nil {
+		t.Error("Expecting an error for an invalid welcome message template")
+	}
+}
@@ -15,42 +15,301 @@
 package githubwebhook
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/v26/github"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"istio.io/bots/policybot/handlers/githubwebhook/filters"
+	"istio.io/bots/policybot/pkg/storage"
 	"istio.io/bots/policybot/pkg/util"
+	"istio.io/pkg/cache"
+	"istio.io/pkg/log"
 )
 
-// Decodes and dispatches GitHub webhook calls
-type handler struct {
-	secret  []byte
-	filters []filters.Filter
+const (
+	// GitHub assigns a unique id to each event, which is preserved when the event is redelivered
+	deliveryHeader = "X-GitHub-Delivery"
+
+	// how many recent delivery ids to remember, and for how long
+	maxTrackedDeliveries = 10000
+	deliveryTTL          = 24 * time.Hour
+)
+
+// Handler decodes GitHub webhook calls and queues the resulting events, which are
+// dispatched to the filters by a pool of workers. This lets GitHub get a response
+// quickly, regardless of how long the filters take.
+type Handler struct {
+	secret       []byte
+	eventTimeout time.Duration
+	queue        chan queuedEvent
+	workers      sync.WaitGroup
+
+	// filters keyed by the webhook event types they want, and filters which want everything
+	routes   map[string][]filters.Filter
+	catchAll []filters.Filter
+
+	// protects closed and sending on the queue
+	mu     sync.RWMutex
+	closed bool
+
+	// recently queued delivery ids, used to drop redeliveries
+	deliveriesMu sync.Mutex
+	deliveries   cache.ExpiringCache
+
+	// where raw payloads are archived for later replay, nil if they aren't
+	archive storage.Store
 }
 
-func NewHandler(githubWebhookSecret string, filters ...filters.Filter) http.Handler {
-	return &handler{
-		secret:  []byte(githubWebhookSecret),
-		filters: filters,
+type queuedEvent struct {
+	eventType  string
+	event      interface{}
+	deliveryID string
+	payload    []byte
+	receivedAt time.Time
+}
+
+var scope = log.RegisterScope("githubwebhook", "GitHub webhook handler", 0)
+
+var webhookErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "webhook_errors_total",
+	Help: "The number of errors encountered while handling GitHub webhook events.",
+}, []string{"reason"})
+
+var webhookDuplicates = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "webhook_duplicate_deliveries_total",
+	Help: "The number of GitHub webhook deliveries skipped because the event had already been received.",
+})
+
+func init() {
+	prometheus.MustRegister(webhookErrors)
+	prometheus.MustRegister(webhookDuplicates)
+}
+
+// NewHandler creates a handler which dispatches events using the given number of workers. Up to queueSize
+// events can be waiting for a worker at any one time, and each event is given eventTimeout to be handled
+// by all the filters. If archive is not nil, the raw payload of every event is written to it such that
+// the event can be replayed later.
+func NewHandler(githubWebhookSecret string, workers int, queueSize int, eventTimeout time.Duration,
+	archive storage.Store, fs ...filters.Filter) *Handler {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	h := &Handler{
+		secret:       []byte(githubWebhookSecret),
+		eventTimeout: eventTimeout,
+		queue:        make(chan queuedEvent, queueSize),
+		routes:       make(map[string][]filters.Filter),
+		deliveries:   cache.NewLRU(deliveryTTL, time.Minute, maxTrackedDeliveries),
+		archive:      archive,
+	}
+
+	for _, f := range fs {
+		events := f.Events()
+		if len(events) == 0 {
+			h.catchAll = append(h.catchAll, f)
+			continue
+		}
+
+		for _, e := range events {
+			h.routes[e] = append(h.routes[e], f)
+		}
 	}
+
+	for i := 0; i < workers; i++ {
+		h.workers.Add(1)
+		go h.worker()
+	}
+
+	return h
 }
 
-func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	payload, err := github.ValidatePayload(r, h.secret)
 	if err != nil {
 		util.RenderError(w, err)
 		return
 	}
 
-	event, err := github.ParseWebHook(github.WebHookType(r), payload)
+	eventType := github.WebHookType(r)
+	event, err := github.ParseWebHook(eventType, payload)
 	if err != nil {
 		util.RenderError(w, err)
 		return
 	}
 
-	// dispatch to all the registered filters
-	for _, filter := range h.filters {
-		filter.Handle(r.Context(), event)
+	if ping, ok := event.(*github.PingEvent); ok {
+		// sent by GitHub when the webhook is registered, so operators can confirm it's wired up
+		scope.Infof("Received ping for webhook %d: %s", ping.GetHookID(), ping.GetZen())
+		_, _ = w.Write([]byte("pong"))
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.closed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	deliveryID := r.Header.Get(deliveryHeader)
+
+	h.deliveriesMu.Lock()
+	defer h.deliveriesMu.Unlock()
+
+	if deliveryID != "" {
+		if _, seen := h.deliveries.Get(deliveryID); seen {
+			// already queued, let GitHub know we have it so it stops redelivering
+			scope.Debugf("Skipping duplicate delivery %s of event %T", deliveryID, event)
+			webhookDuplicates.Inc()
+			return
+		}
+	}
+
+	select {
+	case h.queue <- queuedEvent{eventType, event, deliveryID, payload, time.Now()}:
+		if deliveryID != "" {
+			h.deliveries.Set(deliveryID, true)
+		}
+	default:
+		// GitHub redelivers the event when it doesn't get a successful response
+		scope.Warnf("Event queue is full, rejecting event %T", event)
+		webhookErrors.WithLabelValues("queue_full").Inc()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// Close stops accepting new events and waits for the queued events to be dispatched.
+func (h *Handler) Close() {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mu.Unlock()
+
+	h.workers.Wait()
+}
+
+func (h *Handler) worker() {
+	defer h.workers.Done()
+
+	for qe := range h.queue {
+		ctx := filters.WithPayload(filters.WithReceivedAt(context.Background(), qe.receivedAt), qe.payload)
+		ctx = filters.WithEventType(ctx, qe.eventType)
+		ctx, cancel := h.eventContext(ctx)
+
+		if h.archive != nil {
+			h.archivePayload(ctx, qe)
+		}
+
+		h.route(ctx, qe.eventType, qe.event)
+		cancel()
+	}
+}
+
+// route dispatches an event to the filters interested in its type.
+func (h *Handler) route(ctx context.Context, eventType string, event interface{}) {
+	for _, filter := range h.routes[eventType] {
+		dispatch(ctx, filter, event)
+	}
+
+	for _, filter := range h.catchAll {
+		dispatch(ctx, filter, event)
+	}
+}
+
+func (h *Handler) archivePayload(ctx context.Context, qe queuedEvent) {
+	if qe.deliveryID == "" {
+		scope.Warnf("Unable to archive event %T since it has no delivery id", qe.event)
+		return
+	}
+
+	payload := &storage.WebhookPayload{
+		DeliveryID: qe.deliveryID,
+		EventType:  qe.eventType,
+		ReceivedAt: qe.receivedAt,
+		Payload:    string(qe.payload),
+	}
+
+	// losing the archived copy shouldn't prevent the event from being handled
+	if err := h.archive.WriteWebhookPayloads(ctx, []*storage.WebhookPayload{payload}); err != nil {
+		scope.Errorf("Unable to archive delivery %s of event %T: %v", qe.deliveryID, qe.event, err)
+		webhookErrors.WithLabelValues("archive").Inc()
+	}
+}
+
+// Replay dispatches the archived events received in the [from, to) time range to the filters again, in the order
+// they were originally received. Only events of the given types are replayed, or all events if no types are given.
+// The filters can tell the events are being replayed by calling filters.IsReplay on their context.
+func (h *Handler) Replay(ctx context.Context, from time.Time, to time.Time, eventTypes []string) error {
+	if h.archive == nil {
+		return errors.New("webhook payloads are not being archived")
 	}
+
+	types := make(map[string]bool, len(eventTypes))
+	for _, t := range eventTypes {
+		types[t] = true
+	}
+
+	ctx = filters.WithReplay(ctx)
+
+	count := 0
+	err := h.archive.QueryWebhookPayloads(ctx, from, to, func(p *storage.WebhookPayload) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if len(types) > 0 && !types[p.EventType] {
+			return nil
+		}
+
+		payload := []byte(p.Payload)
+		event, err := github.ParseWebHook(p.EventType, payload)
+		if err != nil {
+			scope.Errorf("Unable to parse archived delivery %s: %v", p.DeliveryID, err)
+			return nil
+		}
+
+		scope.Debugf("Replaying delivery %s of event %T", p.DeliveryID, event)
+
+		eventCtx := filters.WithEventType(filters.WithPayload(filters.WithReceivedAt(ctx, p.ReceivedAt), payload), p.EventType)
+		eventCtx, cancel := h.eventContext(eventCtx)
+		h.route(eventCtx, p.EventType, event)
+		cancel()
+
+		count++
+		return nil
+	})
+
+	scope.Infof("Replayed %d webhook events received between %v and %v", count, from, to)
+	return err
+}
+
+func (h *Handler) eventContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if h.eventTimeout <= 0 {
+		return context.WithCancel(parent)
+	}
+
+	return context.WithTimeout(parent, h.eventTimeout)
+}
+
+// dispatch delivers an event to a single filter, making sure a panicking filter doesn't prevent
+// the remaining filters from seeing the event.
+func dispatch(context context.Context, filter filters.Filter, event interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			scope.Errorf("Filter %T panicked while handling event %T: %v\n%s", filter, event, r, debug.Stack())
+			webhookErrors.WithLabelValues("panic").Inc()
+		}
+	}()
+
+	filter.Handle(context, event)
 }
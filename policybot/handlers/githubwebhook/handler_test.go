@@ -0,0 +1,298 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package githubwebhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/handlers/githubwebhook/filters"
+	"istio.io/bots/policybot/pkg/storage"
+)
+
+const testSecret = "secret"
+
+// slowFilter blocks in Handle until released.
+type slowFilter struct {
+	started chan struct{}
+	release chan struct{}
+	handled chan struct{}
+}
+
+func newSlowFilter() *slowFilter {
+	return &slowFilter{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+		handled: make(chan struct{}, 10),
+	}
+}
+
+func (f *slowFilter) Events() []string {
+	return nil
+}
+
+func (f *slowFilter) Handle(context context.Context, event interface{}) {
+	f.started <- struct{}{}
+	<-f.release
+	f.handled <- struct{}{}
+}
+
+func post(h http.Handler, deliveryID string) int {
+	return postEvent(h, "push", deliveryID)
+}
+
+func postEvent(h http.Handler, eventType string, deliveryID string) int {
+	payload := `{"delivery": "` + deliveryID + `"}`
+
+	mac := hmac.New(sha1.New, []byte(testSecret))
+	_, _ = mac.Write([]byte(payload))
+
+	r := httptest.NewRequest("POST", "/githubwebhook", strings.NewReader(payload))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-GitHub-Event", eventType)
+	r.Header.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+	r.Header.Set("X-GitHub-Delivery", deliveryID)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestSlowFilterDoesNotBlockResponse(t *testing.T) {
+	f := newSlowFilter()
+	h := NewHandler(testSecret, 1, 10, time.Minute, nil, f)
+
+	done := make(chan int)
+	go func() {
+		done <- post(h, "1")
+	}()
+
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Errorf("Got status %d, expecting %d", code, http.StatusOK)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Response was blocked by the filter")
+	}
+
+	<-f.started
+	close(f.release)
+
+	// closing must wait for the in-flight event to be handled
+	h.Close()
+
+	select {
+	case <-f.handled:
+	default:
+		t.Error("Close returned before the event was handled")
+	}
+
+	if code := post(h, "2"); code != http.StatusServiceUnavailable {
+		t.Errorf("Got status %d after close, expecting %d", code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	f := newSlowFilter()
+	h := NewHandler(testSecret, 1, 1, time.Minute, nil, f)
+
+	// the first event occupies the only worker
+	if code := post(h, "3"); code != http.StatusOK {
+		t.Fatalf("Got status %d, expecting %d", code, http.StatusOK)
+	}
+	<-f.started
+
+	// the second event fills the queue
+	if code := post(h, "4"); code != http.StatusOK {
+		t.Fatalf("Got status %d, expecting %d", code, http.StatusOK)
+	}
+
+	// and the third has nowhere to go
+	if code := post(h, "5"); code != http.StatusServiceUnavailable {
+		t.Errorf("Got status %d, expecting %d", code, http.StatusServiceUnavailable)
+	}
+
+	close(f.release)
+	h.Close()
+
+	if len(f.handled) != 2 {
+		t.Errorf("Got %d handled events, expecting 2", len(f.handled))
+	}
+}
+
+func TestDuplicateDeliveriesAreSkipped(t *testing.T) {
+	f := newSlowFilter()
+	close(f.release)
+	h := NewHandler(testSecret, 1, 10, time.Minute, nil, f)
+
+	for i := 0; i < 3; i++ {
+		if code := post(h, "abc"); code != http.StatusOK {
+			t.Errorf("Got status %d, expecting %d", code, http.StatusOK)
+		}
+	}
+
+	if code := post(h, "def"); code != http.StatusOK {
+		t.Errorf("Got status %d, expecting %d", code, http.StatusOK)
+	}
+
+	h.Close()
+
+	if len(f.handled) != 2 {
+		t.Errorf("Got %d handled events, expecting 2", len(f.handled))
+	}
+}
+
+// recordingFilter records the events it receives.
+type recordingFilter struct {
+	events   []string
+	mu       sync.Mutex
+	seen     []interface{}
+	payloads []string
+	replays  int
+}
+
+func (f *recordingFilter) Events() []string {
+	return f.events
+}
+
+func (f *recordingFilter) Handle(context context.Context, event interface{}) {
+	f.mu.Lock()
+	f.seen = append(f.seen, event)
+	f.payloads = append(f.payloads, string(filters.Payload(context)))
+	if filters.IsReplay(context) {
+		f.replays++
+	}
+	f.mu.Unlock()
+}
+
+func TestEventRouting(t *testing.T) {
+	prOnly := &recordingFilter{events: []string{"pull_request"}}
+	everything := &recordingFilter{}
+	h := NewHandler(testSecret, 2, 10, time.Minute, nil, prOnly, everything)
+
+	for i, eventType := range []string{"issues", "pull_request", "issue_comment", "pull_request"} {
+		if code := postEvent(h, eventType, strconv.Itoa(i)); code != http.StatusOK {
+			t.Errorf("Got status %d for %s, expecting %d", code, eventType, http.StatusOK)
+		}
+	}
+
+	h.Close()
+
+	if len(prOnly.seen) != 2 {
+		t.Errorf("Got %d events for the pull request filter, expecting 2", len(prOnly.seen))
+	}
+
+	for _, e := range prOnly.seen {
+		if _, ok := e.(*github.PullRequestEvent); !ok {
+			t.Errorf("Pull request filter received %T", e)
+		}
+	}
+
+	if len(everything.seen) != 4 {
+		t.Errorf("Got %d events for the catch-all filter, expecting 4", len(everything.seen))
+	}
+}
+
+func TestPing(t *testing.T) {
+	everything := &recordingFilter{}
+	h := NewHandler(testSecret, 1, 10, time.Minute, nil, everything)
+
+	if code := postEvent(h, "ping", "1"); code != http.StatusOK {
+		t.Errorf("Got status %d, expecting %d", code, http.StatusOK)
+	}
+
+	h.Close()
+
+	if len(everything.seen) != 0 {
+		t.Errorf("Got %d events dispatched, expecting none", len(everything.seen))
+	}
+}
+
+// fakeArchive implements the parts of storage.Store used to archive webhook payloads
+type fakeArchive struct {
+	storage.Store
+
+	mu       sync.Mutex
+	payloads []*storage.WebhookPayload
+}
+
+func (fa *fakeArchive) WriteWebhookPayloads(_ context.Context, payloads []*storage.WebhookPayload) error {
+	fa.mu.Lock()
+	fa.payloads = append(fa.payloads, payloads...)
+	fa.mu.Unlock()
+	return nil
+}
+
+func (fa *fakeArchive) QueryWebhookPayloads(_ context.Context, from time.Time, to time.Time, cb func(*storage.WebhookPayload) error) error {
+	for _, p := range fa.payloads {
+		if !p.ReceivedAt.Before(from) && p.ReceivedAt.Before(to) {
+			if err := cb(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestReplay(t *testing.T) {
+	archive := &fakeArchive{}
+	f := &recordingFilter{}
+	h := NewHandler(testSecret, 1, 10, time.Minute, archive, f)
+
+	start := time.Now()
+	for i, eventType := range []string{"issues", "pull_request", "issues"} {
+		if code := postEvent(h, eventType, strconv.Itoa(i)); code != http.StatusOK {
+			t.Errorf("Got status %d for %s, expecting %d", code, eventType, http.StatusOK)
+		}
+	}
+	h.Close()
+
+	if len(archive.payloads) != 3 {
+		t.Fatalf("Got %d archived payloads, expecting 3", len(archive.payloads))
+	}
+
+	if err := h.Replay(context.Background(), start, time.Now().Add(time.Minute), []string{"issues"}); err != nil {
+		t.Fatalf("Unable to replay events: %v", err)
+	}
+
+	if len(f.seen) != 5 || f.replays != 2 {
+		t.Fatalf("Got %d events with %d replays, expecting 5 events with 2 replays", len(f.seen), f.replays)
+	}
+
+	for _, e := range f.seen[3:] {
+		if _, ok := e.(*github.IssuesEvent); !ok {
+			t.Errorf("Replayed %T, expecting only issue events", e)
+		}
+	}
+
+	// the filters get the archived payloads of the replayed events
+	for i, archived := range []int{0, 2} {
+		if got, want := f.payloads[3+i], archive.payloads[archived].Payload; got != want {
+			t.Errorf("Got payload %q for replayed event %d, expecting %q", got, i, want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package githubwebhook
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"istio.io/bots/policybot/pkg/util"
+)
+
+type replayHandler struct {
+	webhook *Handler
+}
+
+// NewReplayHandler returns a handler that replays the archived webhook events received between the times
+// given by the from and to query parameters (in RFC 3339 format). The optional events query parameter is a
+// comma-separated list of the event types to replay.
+func NewReplayHandler(webhook *Handler) http.Handler {
+	return &replayHandler{
+		webhook: webhook,
+	}
+}
+
+func (h *replayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	from, err := time.Parse(time.RFC3339, r.URL.Query().Get("from"))
+	if err != nil {
+		util.RenderError(w, util.HTTPErrorf(http.StatusBadRequest, "invalid from time: %v", err))
+		return
+	}
+
+	to, err := time.Parse(time.RFC3339, r.URL.Query().Get("to"))
+	if err != nil {
+		util.RenderError(w, util.HTTPErrorf(http.StatusBadRequest, "invalid to time: %v", err))
+		return
+	}
+
+	var eventTypes []string
+	if v := r.URL.Query().Get("events"); v != "" {
+		eventTypes = strings.Split(v, ",")
+	}
+
+	if err := h.webhook.Replay(r.Context(), from, to, eventTypes); err != nil {
+		util.RenderError(w, err)
+	}
+}
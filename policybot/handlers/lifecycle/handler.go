@@ -0,0 +1,47 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lifecycle
+
+import (
+	"net/http"
+
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/lifecycle"
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/pkg/log"
+)
+
+var scope = log.RegisterScope("lifecycle", "Issue lifecycle manager", 0)
+
+type handler struct {
+	manager *lifecycle.Manager
+}
+
+// NewHandler creates a handler which manages the lifecycle of inactive issues when invoked.
+func NewHandler(gc *gh.ThrottledClient, store storage.Store, orgs []config.Org) http.Handler {
+	return &handler{
+		manager: lifecycle.New(gc, store, orgs),
+	}
+}
+
+// Handle kicks off lifecycle management
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	scope.Infof("Handle request for issue lifecycle management")
+	if err := h.manager.Run(r.Context()); err != nil {
+		scope.Errorf("Unable to manage issue lifecycles: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
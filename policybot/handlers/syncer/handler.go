@@ -17,8 +17,12 @@ package syncer
 import (
 	"context"
 	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
 
 	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/util"
 
 	"istio.io/bots/policybot/pkg/config"
 	"istio.io/bots/policybot/pkg/storage"
@@ -32,9 +36,12 @@ type handler struct {
 }
 
 func NewHandler(ctx context.Context, gc *gh.ThrottledClient, cache *cache.Cache,
-	zc *zh.ThrottledClient, store storage.Store, orgs []config.Org) http.Handler {
+	zc *zh.ThrottledClient, store storage.Store, orgs []config.Org, useGraphQL bool) http.Handler {
+	s := syncer.New(gc, cache, zc, store, orgs, false)
+	s.UseGraphQL = useGraphQL
+
 	return &handler{
-		syncer: syncer.New(gc, cache, zc, store, orgs),
+		syncer: s,
 	}
 }
 
@@ -46,8 +53,32 @@ func (h *handler) ServeHTTP(_ http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = h.syncer.Sync(r.Context(), flags); err != nil {
+	var repos []string
+	if v := r.URL.Query().Get("repos"); v != "" {
+		repos = strings.Split(v, ",")
+	}
+
+	if _, err = h.syncer.Sync(r.Context(), flags, repos); err != nil {
 		// TODO: render error
 		_ = err
 	}
 }
+
+type membersHandler struct {
+	syncer *syncer.Syncer
+}
+
+// NewMembersHandler returns a handler that refreshes the membership of the org named by the {org} path variable.
+func NewMembersHandler(gc *gh.ThrottledClient, cache *cache.Cache,
+	zc *zh.ThrottledClient, store storage.Store, orgs []config.Org) http.Handler {
+	return &membersHandler{
+		syncer: syncer.New(gc, cache, zc, store, orgs, false),
+	}
+}
+
+func (h *membersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	org := mux.Vars(r)["org"]
+	if err := h.syncer.SyncMembers(r.Context(), org); err != nil {
+		util.RenderError(w, err)
+	}
+}
@@ -85,6 +85,14 @@ func (h *handler) storePipeline(context context.Context, orgLogin string, repoNa
 		Pipeline:    pipeline,
 	}
 
+	// moving the issue doesn't change its estimate, so keep the one recorded by the syncer
+	if existing, err := h.store.ReadIssuePipeline(context, r.OrgLogin, r.RepoName, issueNumber); err != nil {
+		scope.Errorf("Unable to read pipeline of issue %d in repo %s/%s: %v", issueNumber, r.OrgLogin, r.RepoName, err)
+		return
+	} else if existing != nil {
+		issuePipeline.Estimate = existing.Estimate
+	}
+
 	if err := h.store.WriteIssuePipelines(context, []*storage.IssuePipeline{issuePipeline}); err != nil {
 		scope.Errorf("Unable to write pipeline to storage: %v", err)
 	}
@@ -0,0 +1,132 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package codeowners implements the gitignore-style path patterns used in CODEOWNERS files.
+//
+// Patterns are first normalized into a form anchored at the root of the repo, where '**' matches
+// any number of path segments, '*' matches anything within a segment, and '?' matches a single
+// character within a segment. A normalized pattern matches a file if it matches the file's path
+// or any of the directories containing the file, except for patterns ending in '/*' which only
+// match the files directly within a directory.
+package codeowners
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Rule is a single line of a CODEOWNERS file.
+type Rule struct {
+	Pattern string   // normalized pattern
+	Owners  []string // user logins or org/team names, without the leading @
+}
+
+// Parse extracts the rules from the content of a CODEOWNERS file, in the order they appear.
+func Parse(content string) []Rule {
+	var rules []Rule
+	for _, line := range strings.Split(content, "\n") {
+		l := strings.Trim(line, " \t\r")
+		if strings.HasPrefix(l, "#") || l == "" {
+			// skip comment lines or empty lines
+			continue
+		}
+
+		fields := strings.Fields(l)
+		owners := make([]string, 0, len(fields)-1)
+		for _, owner := range fields[1:] {
+			owners = append(owners, strings.TrimPrefix(owner, "@"))
+		}
+
+		rules = append(rules, Rule{
+			Pattern: Normalize(fields[0]),
+			Owners:  owners,
+		})
+	}
+
+	return rules
+}
+
+// Owners returns the owners of the given file path. Per GitHub's semantics, only the last
+// matching rule counts.
+func Owners(rules []Rule, path string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if Match(rules[i].Pattern, path) {
+			return rules[i].Owners
+		}
+	}
+
+	return nil
+}
+
+// Normalize converts a pattern as written in a CODEOWNERS file into its normalized form.
+func Normalize(pattern string) string {
+	p := strings.TrimSpace(pattern)
+	if p == "*" || p == "/" || p == "" {
+		return "**"
+	}
+
+	dirOnly := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+
+	// patterns containing a slash other than a trailing one are relative to the root of the repo,
+	// the others can match at any level
+	anchored := strings.HasPrefix(p, "/") || strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	if !anchored && !strings.HasPrefix(p, "**") {
+		p = "**/" + p
+	}
+
+	if dirOnly {
+		p += "/**"
+	}
+
+	return p
+}
+
+// Match returns whether a normalized pattern matches the given file path.
+func Match(pattern string, path string) bool {
+	return compile(pattern).MatchString(strings.TrimPrefix(path, "/"))
+}
+
+func compile(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	// a pattern also matches everything within the directories it matches, except when it's
+	// explicitly about the direct contents of a directory
+	if !strings.HasSuffix(pattern, "/*") && !strings.HasSuffix(pattern, "**") {
+		b.WriteString("(/.*)?")
+	}
+
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
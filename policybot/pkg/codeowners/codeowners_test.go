@@ -0,0 +1,120 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codeowners_test
+
+import (
+	"testing"
+
+	"istio.io/bots/policybot/pkg/codeowners"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		pattern    string
+		normalized string
+	}{
+		{"*", "**"},
+		{"*.md", "**/*.md"},
+		{"/dir/", "dir/**"},
+		{"dir/", "**/dir/**"},
+		{"dir/subdir/*", "dir/subdir/*"},
+		{"/foo/bar", "foo/bar"},
+		{"docs/**", "docs/**"},
+		{"**/logs", "**/logs"},
+	}
+
+	for _, c := range cases {
+		if got := codeowners.Normalize(c.pattern); got != c.normalized {
+			t.Errorf("Normalize(%q): got %q, expecting %q", c.pattern, got, c.normalized)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*", "README.md", true},
+		{"*", "pilot/pkg/model/service.go", true},
+
+		{"*.md", "README.md", true},
+		{"*.md", "docs/setup/install.md", true},
+		{"*.md", "pilot/main.go", false},
+
+		{"/dir/", "dir/a.go", true},
+		{"/dir/", "dir/sub/b.go", true},
+		{"/dir/", "other/dir/a.go", false},
+		{"/dir/", "dir", false},
+
+		{"dir/", "other/dir/a.go", true},
+
+		{"dir/subdir/*", "dir/subdir/a.go", true},
+		{"dir/subdir/*", "dir/subdir/nested/b.go", false},
+		{"dir/subdir/*", "other/dir/subdir/a.go", false},
+
+		{"/foo/bar", "foo/bar", true},
+		{"/foo/bar", "foo/bar/baz.go", true},
+		{"/foo/bar", "foo/barn/baz.go", false},
+
+		{"docs/**", "docs/a/b/c.md", true},
+		{"**/logs", "build/logs/out.txt", true},
+		{"**/logs", "logs/out.txt", true},
+	}
+
+	for _, c := range cases {
+		if got := codeowners.Match(codeowners.Normalize(c.pattern), c.path); got != c.match {
+			t.Errorf("Match(%q, %q): got %v, expecting %v", c.pattern, c.path, got, c.match)
+		}
+	}
+}
+
+func TestOwners(t *testing.T) {
+	rules := codeowners.Parse(`
+# default owners
+*           @alice
+
+*.md        @docs-team-lead
+/pilot/     @bob @istio/networking
+/pilot/docs/*
+`)
+
+	cases := []struct {
+		path   string
+		owners []string
+	}{
+		{"main.go", []string{"alice"}},
+		{"README.md", []string{"docs-team-lead"}},
+		{"pilot/main.go", []string{"bob", "istio/networking"}},
+		{"pilot/README.md", []string{"bob", "istio/networking"}},
+		{"pilot/docs/arch.md", []string{}},
+	}
+
+	for _, c := range cases {
+		got := codeowners.Owners(rules, c.path)
+		if len(got) != len(c.owners) {
+			t.Errorf("Owners(%q): got %v, expecting %v", c.path, got, c.owners)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != c.owners[i] {
+				t.Errorf("Owners(%q): got %v, expecting %v", c.path, got, c.owners)
+				break
+			}
+		}
+	}
+}
@@ -17,6 +17,7 @@ package config
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -26,6 +27,9 @@ type StartupOptions struct {
 	ConfigRepo              string
 	GitHubWebhookSecret     string
 	GitHubToken             string
+	GitHubAppID             int
+	GitHubAppInstallationID int
+	GitHubAppPrivateKey     string
 	GCPCredentials          string
 	SendGridAPIKey          string
 	ZenHubToken             string
@@ -98,17 +102,233 @@ type AutoLabel struct {
 	// MatchBody represents content that must be in the PR or issue's body
 	MatchBody []string // regexes
 
+	// MatchFiles represents files that must be in the PR, ignored for issues
+	MatchFiles []string // regexes
+
 	// AbsentLabels represents labels that must not be on the PR or issue
 	AbsentLabels []string // regexes
 
-	// The labels to apply when any of the Match* expressions match and none of the Absent* expressions do.
+	// PresentLabels represents labels of which at least one must be on the PR or issue
+	PresentLabels []string // regexes
+
+	// Require controls how the Match* expressions combine, either RequireAny (the default) or RequireAll.
+	Require string
+
+	// The labels to apply when the Match* expressions match as per Require, none of the Absent* expressions do, and
+	// at least one of the Present* expressions does, if there are any.
 	Labels []string
+
+	// The labels to remove under the same conditions as Labels are applied, if present.
+	RemoveLabels []string
+
+	// Comment is an optional comment to post when the labels are applied, as a Go template. The template can refer to
+	// {{.Author}}, the login of the PR or issue's author, and {{.Labels}}, the comma-separated labels being applied.
+	Comment string
+
+	// RemoveOnMismatch indicates that the labels should be removed when a PR or issue is edited such that
+	// none of the Match* expressions match anymore.
+	RemoveOnMismatch bool
+}
+
+// maxLabelLength is the longest label name GitHub accepts.
+const maxLabelLength = 50
+
+// Validate checks that an auto label is well-formed and can actually fire, returning an error describing how to fix
+// it otherwise. Regular expressions and comment templates are checked when they're compiled.
+func (al *AutoLabel) Validate() error {
+	if len(al.MatchTitle) == 0 && len(al.MatchBody) == 0 && len(al.MatchFiles) == 0 {
+		return fmt.Errorf("has no MatchTitle, MatchBody, or MatchFiles expressions and will never fire")
+	}
+
+	if len(al.Labels) == 0 && len(al.RemoveLabels) == 0 {
+		return fmt.Errorf("has no Labels or RemoveLabels and would have no effect")
+	}
+
+	if al.Require != "" && al.Require != RequireAny && al.Require != RequireAll {
+		return fmt.Errorf("has invalid require value '%s', expecting '%s' or '%s'", al.Require, RequireAny, RequireAll)
+	}
+
+	applied := make(map[string]bool, len(al.Labels))
+	for _, label := range al.Labels {
+		if err := validateLabelName(label); err != nil {
+			return err
+		}
+		applied[label] = true
+	}
+
+	for _, label := range al.RemoveLabels {
+		if err := validateLabelName(label); err != nil {
+			return err
+		}
+
+		if applied[label] {
+			return fmt.Errorf("both applies and removes label '%s'", label)
+		}
+	}
+
+	return nil
+}
+
+func validateLabelName(label string) error {
+	if label == "" {
+		return fmt.Errorf("has an empty label name")
+	} else if strings.TrimSpace(label) != label {
+		return fmt.Errorf("has label '%s' with leading or trailing whitespace", label)
+	} else if len(label) > maxLabelLength {
+		return fmt.Errorf("has label '%s' longer than %d characters", label, maxLabelLength)
+	}
+
+	return nil
+}
+
+// ReviewAssignment controls how reviewers are requested for new pull requests.
+type ReviewAssignment struct {
+	// Count is the number of reviewers to request, 0 to disable review assignment
+	Count int `json:"count"`
+
+	// OptOutLabel is a label which prevents reviewers from being requested when present on a pull request
+	OptOutLabel string `json:"optoutlabel"`
+}
+
+// Welcome controls the comment posted on the first issue or pull request someone opens in an org.
+type Welcome struct {
+	// Message to post, as a Go template which can refer to {{.Author}}, the login of the new contributor, and
+	// {{.Repo}}, the name of the repo. No message is posted when empty.
+	Message string `json:"message"`
+}
+
+// LinkedIssue controls the nag posted on pull requests which don't reference the issue they address.
+type LinkedIssue struct {
+	// Message to post on pull requests which don't reference an issue. Pull requests aren't checked when empty.
+	Message string `json:"message"`
+
+	// ExemptLabels are labels which indicate a pull request doesn't need an issue, such as trivial
+	ExemptLabels []string `json:"exemptlabels"`
+}
+
+// CherryPick controls the automatic cherry-picking of merged pull requests to release branches.
+type CherryPick struct {
+	// LabelPrefix identifies the labels requesting cherry-picks, the rest of such a label being the target branch.
+	// For example, with a prefix of cherrypick/, the cherrypick/release-1.3 label requests a cherry-pick to the
+	// release-1.3 branch. Pull requests aren't cherry-picked when empty.
+	LabelPrefix string `json:"labelprefix"`
+
+	// Title and Body of the pull requests opened against the target branches, as Go templates which can refer to
+	// {{.Number}}, {{.Title}}, {{.Body}}, and {{.Author}} of the original pull request, and {{.Branch}}, the target
+	// branch. Defaults are used when empty.
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// ResponseSLO controls the tracking of how long it takes for org members to first respond to issues.
+type ResponseSLO struct {
+	// ThresholdHours is the number of hours an issue can wait for a first response from an org member before it's
+	// considered in breach of the SLO, 0 to never consider issues in breach
+	ThresholdHours int `json:"thresholdhours"`
+
+	// ExcludeMemberIssues leaves out issues opened by org members, which don't need a response from the org
+	ExcludeMemberIssues bool `json:"excludememberissues"`
+}
+
+// Lifecycle controls how issues without activity are marked as stale, then as rotten, and finally closed.
+type Lifecycle struct {
+	// StaleDays is the number of days without activity after which an issue is labeled lifecycle/stale, 0 to
+	// disable lifecycle management
+	StaleDays int `json:"staledays"`
+
+	// RottenDays is the number of additional days without activity after which a stale issue is labeled
+	// lifecycle/rotten, 0 to never escalate
+	RottenDays int `json:"rottendays"`
+
+	// CloseDays is the number of additional days without activity after which a rotten issue is closed, 0 to
+	// never close
+	CloseDays int `json:"closedays"`
+
+	// ExemptLabels identifies issues which are left alone no matter how long they've been inactive
+	ExemptLabels []string `json:"exemptlabels"`
+
+	// Comments to post on issues as they go through each stage, no comment is posted for empty ones
+	StaleComment  string `json:"stalecomment"`
+	RottenComment string `json:"rottencomment"`
+	CloseComment  string `json:"closecomment"`
+
+	// DryRun logs the intended actions without carrying them out
+	DryRun bool `json:"dryrun"`
+}
+
+// LabelDefinition describes a label which the bot creates in repos when applying it for the first time.
+type LabelDefinition struct {
+	// Name of the label
+	Name string `json:"name"`
+
+	// Color of the label, as six hex digits without a leading #
+	Color string `json:"color"`
+
+	// Description of the label
+	Description string `json:"description"`
+}
+
+// SizeLabel is applied to PRs changing at most a given number of lines.
+type SizeLabel struct {
+	// Label to apply
+	Label string `json:"label"`
+
+	// MaxLines is the largest number of added and deleted lines for the label to apply, 0 for no limit
+	MaxLines int `json:"maxlines"`
 }
 
+// SizeLabels controls the labeling of PRs according to how many lines they change.
+type SizeLabels struct {
+	// Labels ordered from smallest to largest, for example size/XS up to 9 lines, size/S up to 49 lines, and so on.
+	// PRs aren't labeled by size when empty.
+	Labels []SizeLabel `json:"labels"`
+
+	// ExcludeFiles represents files whose changes don't count toward a PR's size, such as generated or vendored files
+	ExcludeFiles []string `json:"excludefiles"` // regexes
+}
+
+// ReleaseNotes controls the check that PRs come with release notes.
+type ReleaseNotes struct {
+	// Repos in which PRs are checked, of the form org/repo. PRs aren't checked when empty.
+	Repos []string `json:"repos"`
+
+	// Files represents files which hold release notes, such as entries under releasenotes/. A PR changing any of
+	// them passes the check, as does one with a release-note fenced block in its body.
+	Files []string `json:"files"` // regexes
+
+	// ExemptLabels are labels which indicate a PR doesn't need release notes, such as release-note-none
+	ExemptLabels []string `json:"exemptlabels"`
+}
+
+// EventPublisher controls the mirroring of GitHub webhook events to a Cloud Pub/Sub topic.
+type EventPublisher struct {
+	// ProjectID is the GCP project holding the topic
+	ProjectID string `json:"project_id"`
+
+	// Topic is the name of the topic events are published to. Events aren't published when empty.
+	Topic string `json:"topic"`
+}
+
+// How an auto label's Match* expressions combine.
+const (
+	// RequireAny applies the labels if any of the title, body, or files match.
+	RequireAny = "any"
+
+	// RequireAll applies the labels only if each of the title, body, and files match, ignoring those with no
+	// expressions. Issues have no files, so rules with MatchFiles never match issues.
+	RequireAll = "all"
+)
+
 // Configuration for an individual repo.
 type Repo struct {
 	// Name of the repo
 	Name string `json:"name"`
+
+	// AutoLabels specific to this repo, evaluated after the global and org-level ones
+	AutoLabels []AutoLabel `json:"autolabels"`
+
+	// Lifecycle management of the repo's inactive issues, replacing the org's when set
+	Lifecycle *Lifecycle `json:"lifecycle"`
 }
 
 // Configuration for an individual GitHub organization.
@@ -119,11 +339,89 @@ type Org struct {
 	// Per-repo configuration
 	Repos []Repo `json:"repos"`
 
+	// AllRepos indicates that every repo in the org is monitored, not just the ones listed in Repos
+	AllRepos bool `json:"allrepos"`
+
+	// ExcludeRepos lists repos which are not monitored, even when AllRepos is set
+	ExcludeRepos []string `json:"excluderepos"`
+
+	// DisableLabelCreation prevents the bot from creating missing labels in the org's repos, for orgs which manage
+	// their labels manually
+	DisableLabelCreation bool `json:"disablelabelcreation"`
+
+	// Lifecycle management of the org's inactive issues
+	Lifecycle Lifecycle `json:"lifecycle"`
+
+	// Automatic assignment of reviewers to the org's pull requests
+	ReviewAssignment ReviewAssignment `json:"reviewassignment"`
+
+	// Welcome message for first-time contributors to the org's repos
+	Welcome Welcome `json:"welcome"`
+
+	// Nagging of the org's pull requests which don't reference an issue
+	LinkedIssue LinkedIssue `json:"linkedissue"`
+
+	// Cherry-picking of the org's merged pull requests to release branches
+	CherryPick CherryPick `json:"cherrypick"`
+
+	// Tracking of the time it takes for the org to first respond to issues
+	ResponseSLO ResponseSLO `json:"responseslo"`
+
 	// Nags to apply within this organization
 	Nags       []Nag       `json:"nags"`
 	AutoLabels []AutoLabel `json:"autolabels"`
 }
 
+// LifecycleFor returns the lifecycle management settings of the named repo within the org, which are the org's
+// unless the repo has its own.
+func (o *Org) LifecycleFor(repoName string) Lifecycle {
+	for _, r := range o.Repos {
+		if r.Name == repoName && r.Lifecycle != nil {
+			return *r.Lifecycle
+		}
+	}
+
+	return o.Lifecycle
+}
+
+// MonitorsRepo returns whether the named repo within the org is being monitored.
+func (o *Org) MonitorsRepo(repoName string) bool {
+	for _, r := range o.ExcludeRepos {
+		if r == repoName {
+			return false
+		}
+	}
+
+	if o.AllRepos {
+		return true
+	}
+
+	for _, r := range o.Repos {
+		if r.Name == repoName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindOrgForRepo returns the org monitoring the given repo, expressed in org/repo form, or nil if the repo
+// isn't monitored.
+func FindOrgForRepo(orgs []Org, fullName string) *Org {
+	splits := strings.SplitN(fullName, "/", 2)
+	if len(splits) != 2 {
+		return nil
+	}
+
+	for i := range orgs {
+		if orgs[i].Name == splits[0] && orgs[i].MonitorsRepo(splits[1]) {
+			return &orgs[i]
+		}
+	}
+
+	return nil
+}
+
 // Args represents the set of options that control the behavior of the bot.
 type Args struct {
 	// StartupOptions are set when the process starts and cannot be updated afterwards
@@ -144,6 +442,15 @@ type Args struct {
 	// Global auto-labeling
 	AutoLabels []AutoLabel `json:"autolabels"`
 
+	// Labeling of PRs by size
+	SizeLabels SizeLabels `json:"sizelabels"`
+
+	// Enforcement of release notes on PRs
+	ReleaseNotes ReleaseNotes `json:"releasenotes"`
+
+	// Definitions of the labels applied by the bot, used to create the labels in repos which don't have them yet
+	Labels []LabelDefinition `json:"labels"`
+
 	// Name to use as sender when sending emails
 	EmailFrom string `json:"email_from"`
 
@@ -155,6 +462,28 @@ type Args struct {
 
 	// The amount of time cache state is kept around before being discarded
 	CacheTTL time.Duration `json:"cache_ttl"`
+
+	// How often to poll a repo-based configuration file for changes, 0 to disable polling
+	ConfigRefreshInterval time.Duration `json:"config_refresh_interval"`
+
+	// The number of workers dispatching GitHub webhook events to filters
+	WebhookWorkers int `json:"webhook_workers"`
+
+	// The number of GitHub webhook events that can be waiting for a worker, additional events are rejected
+	WebhookQueueSize int `json:"webhook_queue_size"`
+
+	// The amount of time the filters have to handle a single GitHub webhook event
+	WebhookEventTimeout time.Duration `json:"webhook_event_timeout"`
+
+	// Whether to archive the raw payload of GitHub webhook events, so the events can be replayed later
+	ArchiveWebhookPayloads bool `json:"archive_webhook_payloads"`
+
+	// Whether the periodic syncs fetch issues and pull requests in bulk through GitHub's GraphQL API, which takes
+	// fewer API calls than the REST API
+	SyncWithGraphQL bool `json:"sync_with_graphql"`
+
+	// Mirroring of GitHub webhook events to other services
+	EventPublisher EventPublisher `json:"event_publisher"`
 }
 
 func DefaultArgs() *Args {
@@ -162,7 +491,10 @@ func DefaultArgs() *Args {
 		StartupOptions: StartupOptions{
 			Port: 8080,
 		},
-		CacheTTL: 15 * time.Minute,
+		CacheTTL:            15 * time.Minute,
+		WebhookWorkers:      4,
+		WebhookQueueSize:    100,
+		WebhookEventTimeout: 5 * time.Minute,
 	}
 }
 
@@ -186,9 +518,13 @@ func (a *Args) String() string {
 	_, _ = fmt.Fprintf(buf, "Orgs: %+v\n", a.Orgs)
 	_, _ = fmt.Fprintf(buf, "Nags: %+v\n", a.Nags)
 	_, _ = fmt.Fprintf(buf, "AutoLabels: %+v\n", a.AutoLabels)
+	_, _ = fmt.Fprintf(buf, "SizeLabels: %+v\n", a.SizeLabels)
+	_, _ = fmt.Fprintf(buf, "ReleaseNotes: %+v\n", a.ReleaseNotes)
+	_, _ = fmt.Fprintf(buf, "Labels: %+v\n", a.Labels)
 	_, _ = fmt.Fprintf(buf, "EmailFrom: %s\n", a.EmailFrom)
 	_, _ = fmt.Fprintf(buf, "EmailOriginAddress: %s\n", a.EmailOriginAddress)
 	_, _ = fmt.Fprintf(buf, "CacheTTL: %s\n", a.CacheTTL)
+	_, _ = fmt.Fprintf(buf, "ConfigRefreshInterval: %s\n", a.ConfigRefreshInterval)
 
 	return buf.String()
 }
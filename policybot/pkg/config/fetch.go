@@ -15,12 +15,19 @@
 package config
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/ghodss/yaml"
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/pkg/log"
 )
 
 // Given a partially initialize config arg, load a local file or GitHub-based file
@@ -63,3 +70,100 @@ func (a *Args) Fetch() error {
 
 	return nil
 }
+
+// LoadFromURL fetches a YAML configuration file hosted in a GitHub repo and returns the parsed result. The
+// URL is expected to be of the form https://github.com/<org>/<repo>/blob/<branch>/<path>. Any data not
+// specified in the file is left at its default value.
+func LoadFromURL(context context.Context, gc *gh.ThrottledClient, url string) (*Args, error) {
+	b, _, err := fetchURL(context, gc, url)
+	if err != nil {
+		return nil, err
+	}
+
+	a := DefaultArgs()
+	if err = yaml.Unmarshal(b, a); err != nil {
+		return nil, fmt.Errorf("unable to parse configuration file from %s: %v", url, err)
+	}
+
+	return a, nil
+}
+
+// WatchURL polls the GitHub-hosted configuration file at the given URL every interval and invokes
+// notify whenever its content changes. This returns once the context is canceled.
+func WatchURL(context context.Context, gc *gh.ThrottledClient, url string, interval time.Duration, notify func()) {
+	_, sha, err := fetchURL(context, gc, url)
+	if err != nil {
+		log.Warnf("Unable to fetch initial configuration file from %s: %v", url, err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-context.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_, current, err := fetchURL(context, gc, url)
+		if err != nil {
+			log.Warnf("Unable to refresh configuration file from %s: %v", url, err)
+			continue
+		}
+
+		if current != sha {
+			log.Infof("Detected change to configuration file %s", url)
+			sha = current
+			notify()
+		}
+	}
+}
+
+// fetchURL returns the content of a GitHub-hosted file along with the SHA of its blob.
+func fetchURL(context context.Context, gc *gh.ThrottledClient, url string) ([]byte, string, error) {
+	org, repo, ref, path, err := parseURL(url)
+	if err != nil {
+		return nil, "", err
+	}
+
+	opt := &github.RepositoryContentGetOptions{
+		Ref: ref,
+	}
+
+	fc, _, _, err := gc.ThrottledCallTwoResult(func(client *github.Client) (interface{}, interface{}, *github.Response, error) {
+		return client.Repositories.GetContents(context, org, repo, path, opt)
+	})
+
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to fetch configuration file from %s: %v", url, err)
+	}
+
+	rc := fc.(*github.RepositoryContent)
+	if rc == nil {
+		return nil, "", fmt.Errorf("unable to fetch configuration file from %s: not a file", url)
+	}
+
+	content, err := rc.GetContent()
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to decode configuration file from %s: %v", url, err)
+	}
+
+	return []byte(content), rc.GetSHA(), nil
+}
+
+// parseURL splits a URL of the form https://github.com/<org>/<repo>/blob/<branch>/<path> into its components.
+func parseURL(url string) (org string, repo string, ref string, path string, err error) {
+	const prefix = "https://github.com/"
+
+	if !strings.HasPrefix(url, prefix) {
+		return "", "", "", "", fmt.Errorf("invalid configuration URL %s, needs to start with %s", url, prefix)
+	}
+
+	splits := strings.SplitN(strings.TrimPrefix(url, prefix), "/", 5)
+	if len(splits) != 5 || splits[2] != "blob" {
+		return "", "", "", "", fmt.Errorf("invalid configuration URL %s, needs to be %s<org>/<repo>/blob/<branch>/<path>", url, prefix)
+	}
+
+	return splits[0], splits[1], splits[3], splits[4], nil
+}
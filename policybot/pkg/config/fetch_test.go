@@ -0,0 +1,131 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/gh"
+)
+
+func TestParseURL(t *testing.T) {
+	cases := []struct {
+		url  string
+		org  string
+		repo string
+		ref  string
+		path string
+		ok   bool
+	}{
+		{"https://github.com/istio/bots/blob/master/policybot.yaml", "istio", "bots", "master", "policybot.yaml", true},
+		{"https://github.com/istio/.github/blob/release-1.3/policybot/config/policybot.yaml",
+			"istio", ".github", "release-1.3", "policybot/config/policybot.yaml", true},
+		{"https://github.com/istio/bots/tree/master/policybot.yaml", "", "", "", "", false},
+		{"https://github.com/istio/bots/blob/master", "", "", "", "", false},
+		{"https://github.com/istio/bots", "", "", "", "", false},
+		{"https://raw.githubusercontent.com/istio/bots/master/policybot.yaml", "", "", "", "", false},
+		{"istio/bots/blob/master/policybot.yaml", "", "", "", "", false},
+		{"", "", "", "", "", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.url, func(t *testing.T) {
+			org, repo, ref, path, err := parseURL(c.url)
+			if !c.ok {
+				if err == nil {
+					t.Errorf("Got success, expecting an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Got error %v, expecting success", err)
+			}
+
+			if org != c.org || repo != c.repo || ref != c.ref || path != c.path {
+				t.Errorf("Got %s %s %s %s, expecting %s %s %s %s", org, repo, ref, path, c.org, c.repo, c.ref, c.path)
+			}
+		})
+	}
+}
+
+func TestLoadFromURL(t *testing.T) {
+	const content = `
+email_from: policybot@istio.io
+config_refresh_interval: 60000000000
+orgs:
+  - name: istio
+    repos:
+      - name: istio
+`
+
+	var ref string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/.github/contents/policybot/policybot.yaml", func(w http.ResponseWriter, r *http.Request) {
+		ref = r.URL.Query().Get("ref")
+		_ = json.NewEncoder(w).Encode(&github.RepositoryContent{
+			Type:     github.String("file"),
+			Encoding: github.String("base64"),
+			Content:  github.String(base64.StdEncoding.EncodeToString([]byte(content))),
+			SHA:      github.String("abc123"),
+		})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	gc := gh.NewThrottledClientForClient(client)
+
+	a, err := LoadFromURL(context.Background(), gc, "https://github.com/istio/.github/blob/release-1.3/policybot/policybot.yaml")
+	if err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if ref != "release-1.3" {
+		t.Errorf("Got ref %q, expecting the branch from the URL", ref)
+	}
+
+	if a.EmailFrom != "policybot@istio.io" || a.ConfigRefreshInterval != time.Minute {
+		t.Errorf("Got email from %q and refresh interval %s, expecting the values from the file", a.EmailFrom, a.ConfigRefreshInterval)
+	}
+
+	if len(a.Orgs) != 1 || a.Orgs[0].Name != "istio" || len(a.Orgs[0].Repos) != 1 || a.Orgs[0].Repos[0].Name != "istio" {
+		t.Errorf("Got orgs %+v, expecting istio/istio", a.Orgs)
+	}
+
+	// settings which aren't in the file keep their default
+	if a.CacheTTL != DefaultArgs().CacheTTL || a.StartupOptions.Port != DefaultArgs().StartupOptions.Port {
+		t.Errorf("Got cache TTL %s and port %d, expecting the defaults", a.CacheTTL, a.StartupOptions.Port)
+	}
+
+	if _, err = LoadFromURL(context.Background(), gc, "https://github.com/istio/.github/blob/master/missing.yaml"); err == nil {
+		t.Errorf("Got success for a missing file, expecting an error")
+	}
+
+	if _, err = LoadFromURL(context.Background(), gc, "https://github.com/istio/.github"); err == nil {
+		t.Errorf("Got success for an invalid URL, expecting an error")
+	}
+}
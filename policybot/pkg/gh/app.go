@@ -0,0 +1,175 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gh
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+	"golang.org/x/oauth2"
+)
+
+// AppCredentials identify the installation of a GitHub App the bot acts as, instead of using a personal access token.
+type AppCredentials struct {
+	AppID          int64
+	InstallationID int64
+	PrivateKey     []byte // PEM-encoded, as downloaded from the app's settings
+}
+
+const (
+	// how long the JWTs authenticating as the app itself are valid for, GitHub allows at most 10 minutes
+	appTokenLifetime = 9 * time.Minute
+
+	// installation tokens expire after an hour, they're replaced this long before they do
+	installationTokenRefreshMargin = 5 * time.Minute
+)
+
+// NewThrottledClientForApp returns a throttled client authenticated as an installation of a GitHub App. The
+// installation token is minted on first use and replaced before it expires. Calls made while a replacement
+// token can't be obtained fail with the reason why.
+func NewThrottledClientForApp(context context.Context, creds AppCredentials) (*ThrottledClient, error) {
+	key, err := parsePrivateKey(creds.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	appClient := github.NewClient(&http.Client{
+		Transport: &appTransport{appID: creds.AppID, key: key, base: http.DefaultTransport},
+	})
+
+	return newThrottledClientForTokenSource(context, newInstallationTokenSource(context, appClient, creds.InstallationID)), nil
+}
+
+// newInstallationTokenSource returns a token source minting installation tokens through the given client, which
+// must be authenticated as the app. The tokens are cached until they near their expiry, and the source can
+// be used concurrently.
+func newInstallationTokenSource(context context.Context, appClient *github.Client, installationID int64) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &installationTokenSource{
+		context:        context,
+		client:         appClient,
+		installationID: installationID,
+	})
+}
+
+type installationTokenSource struct {
+	context        context.Context
+	client         *github.Client
+	installationID int64
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	token, _, err := s.client.Apps.CreateInstallationToken(s.context, s.installationID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create token for GitHub App installation %d: %v", s.installationID, err)
+	}
+
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		TokenType:   "token",
+
+		// the token source considers the token expired at this point and mints a new one
+		Expiry: token.GetExpiresAt().Add(-installationTokenRefreshMargin),
+	}, nil
+}
+
+// appTransport authenticates requests as a GitHub App, as needed to mint installation tokens.
+type appTransport struct {
+	appID int64
+	key   *rsa.PrivateKey
+	base  http.RoundTripper
+}
+
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := appJWT(t.appID, t.key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// requests must not be modified by round trippers, so the headers are set on a copy
+	r := req.WithContext(req.Context())
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Authorization", "Bearer "+jwt)
+
+	return t.base.RoundTrip(r)
+}
+
+// appJWT returns a JSON Web Token identifying a GitHub App, signed with the app's private key.
+func appJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		// backdated to allow for clock drift between us and GitHub
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appTokenLifetime).Unix(),
+		"iss": strconv.FormatInt(appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("unable to sign GitHub App token: %v", err)
+	}
+
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// parsePrivateKey decodes a GitHub App's PEM-encoded RSA private key.
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("unable to decode GitHub App private key, expecting PEM data")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse GitHub App private key: %v", err)
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GitHub App private key is not an RSA key")
+	}
+
+	return key, nil
+}
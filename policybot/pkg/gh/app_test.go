@@ -0,0 +1,189 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gh
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+)
+
+func TestAppJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %v", err)
+	}
+
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	parsed, err := parsePrivateKey(pemKey)
+	if err != nil {
+		t.Fatalf("Unable to parse key: %v", err)
+	}
+
+	now := time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC)
+	jwt, err := appJWT(1234, parsed, now)
+	if err != nil {
+		t.Fatalf("Unable to create JWT: %v", err)
+	}
+
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Got JWT %s, expecting three parts", jwt)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("Unable to decode signature: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("Invalid signature: %v", err)
+	}
+
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims struct {
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+		Iss string `json:"iss"`
+	}
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatalf("Unable to decode claims: %v", err)
+	}
+
+	if claims.Iss != "1234" || claims.Iat >= now.Unix() || claims.Exp <= now.Unix() || claims.Exp-claims.Iat > 600 {
+		t.Errorf("Got claims %+v, expecting a token issued by app 1234 valid for at most 10 minutes around %v", claims, now)
+	}
+
+	if _, err := parsePrivateKey([]byte("not a key")); err == nil {
+		t.Error("Expecting an error parsing an invalid key")
+	}
+}
+
+func TestInstallationTokenRefresh(t *testing.T) {
+	var mu sync.Mutex
+	minted := 0
+	failMinting := false
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if failMinting {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		minted++
+
+		// the first token is about to expire, so it needs replacing right away
+		expiresAt := time.Now().Add(time.Hour)
+		if minted == 1 {
+			expiresAt = time.Now().Add(time.Minute)
+		}
+
+		_, _ = fmt.Fprintf(w, `{"token": "token-%d", "expires_at": %q}`, minted, expiresAt.Format(time.RFC3339))
+	})
+
+	var seen []string
+	mux.HandleFunc("/repos/istio/istio", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get("Authorization"))
+		mu.Unlock()
+
+		_, _ = w.Write([]byte(`{"name": "istio"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL + "/")
+
+	appClient := github.NewClient(nil)
+	appClient.BaseURL = baseURL
+
+	tc := newThrottledClientForTokenSource(context.Background(), newInstallationTokenSource(context.Background(), appClient, 42))
+	tc.client.BaseURL = baseURL
+	tc.MaxRetries = 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := getRepo(tc); err != nil {
+				t.Errorf("Unable to get repo: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := getRepo(tc); err != nil {
+		t.Fatalf("Unable to get repo: %v", err)
+	}
+
+	mu.Lock()
+	if minted != 2 {
+		t.Errorf("Minted %d tokens, expecting the short-lived one to be replaced once", minted)
+	}
+
+	if last := seen[len(seen)-1]; last != "token token-2" {
+		t.Errorf("Got authorization %q, expecting the replacement token", last)
+	}
+
+	// the replacement is good for an hour, so minting failures don't matter until then
+	failMinting = true
+	mu.Unlock()
+
+	if _, err := getRepo(tc); err != nil {
+		t.Errorf("Unable to get repo: %v", err)
+	}
+}
+
+func TestInstallationTokenRefreshFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL + "/")
+
+	appClient := github.NewClient(nil)
+	appClient.BaseURL = baseURL
+
+	tc := newThrottledClientForTokenSource(context.Background(), newInstallationTokenSource(context.Background(), appClient, 42))
+	tc.client.BaseURL = baseURL
+
+	if _, err := getRepo(tc); err == nil || !strings.Contains(err.Error(), "installation 42") {
+		t.Errorf("Got error %v, expecting a failure to create an installation token", err)
+	}
+}
@@ -15,6 +15,8 @@
 package gh
 
 import (
+	"strings"
+
 	"github.com/google/go-github/v26/github"
 
 	"istio.io/bots/policybot/pkg/storage"
@@ -37,18 +39,19 @@ func ConvertIssue(orgLogin string, repoName string, issue *github.Issue) (*stora
 	}
 
 	return &storage.Issue{
-		OrgLogin:    orgLogin,
-		RepoName:    repoName,
-		IssueNumber: int64(issue.GetNumber()),
-		Title:       issue.GetTitle(),
-		Body:        issue.GetBody(),
-		Labels:      labels,
-		CreatedAt:   issue.GetCreatedAt(),
-		UpdatedAt:   issue.GetUpdatedAt(),
-		ClosedAt:    issue.GetClosedAt(),
-		State:       issue.GetState(),
-		Author:      issue.GetUser().GetLogin(),
-		Assignees:   assignees,
+		OrgLogin:        orgLogin,
+		RepoName:        repoName,
+		IssueNumber:     int64(issue.GetNumber()),
+		Title:           issue.GetTitle(),
+		Body:            issue.GetBody(),
+		Labels:          labels,
+		CreatedAt:       issue.GetCreatedAt(),
+		UpdatedAt:       issue.GetUpdatedAt(),
+		ClosedAt:        issue.GetClosedAt(),
+		State:           issue.GetState(),
+		Author:          issue.GetUser().GetLogin(),
+		Assignees:       assignees,
+		MilestoneNumber: int64(issue.GetMilestone().GetNumber()),
 	}, discoveredUsers
 }
 
@@ -60,14 +63,15 @@ func ConvertIssueComment(orgLogin string, repoName string, issueNumber int, issu
 	}
 
 	return &storage.IssueComment{
-		OrgLogin:       orgLogin,
-		RepoName:       repoName,
-		IssueNumber:    int64(issueNumber),
-		IssueCommentID: issueComment.GetID(),
-		Body:           issueComment.GetBody(),
-		CreatedAt:      issueComment.GetCreatedAt(),
-		UpdatedAt:      issueComment.GetUpdatedAt(),
-		Author:         issueComment.GetUser().GetLogin(),
+		OrgLogin:          orgLogin,
+		RepoName:          repoName,
+		IssueNumber:       int64(issueNumber),
+		IssueCommentID:    issueComment.GetID(),
+		Body:              issueComment.GetBody(),
+		CreatedAt:         issueComment.GetCreatedAt(),
+		UpdatedAt:         issueComment.GetUpdatedAt(),
+		Author:            issueComment.GetUser().GetLogin(),
+		AuthorAssociation: issueComment.GetAuthorAssociation(),
 	}, discoveredUsers
 }
 
@@ -112,10 +116,23 @@ func ConvertOrg(o *github.Organization) *storage.Org {
 // Maps from a GitHub repo to a storage repo. Also returns the set of
 func ConvertRepo(r *github.Repository) *storage.Repo {
 	return &storage.Repo{
-		OrgLogin:    r.Organization.GetLogin(),
-		RepoName:    r.GetName(),
-		Description: r.GetDescription(),
-		RepoNumber:  r.GetID(),
+		OrgLogin:      r.Organization.GetLogin(),
+		RepoName:      r.GetName(),
+		Description:   r.GetDescription(),
+		RepoNumber:    r.GetID(),
+		DefaultBranch: r.GetDefaultBranch(),
+	}
+}
+
+// Maps from a GitHub team to a storage team.
+func ConvertTeam(orgLogin string, t *github.Team) *storage.Team {
+	return &storage.Team{
+		OrgLogin:     orgLogin,
+		TeamID:       t.GetID(),
+		TeamSlug:     t.GetSlug(),
+		TeamName:     t.GetName(),
+		Description:  t.GetDescription(),
+		ParentTeamID: t.GetParent().GetID(),
 	}
 }
 
@@ -130,6 +147,76 @@ func ConvertLabel(orgLogin string, repoName string, l *github.Label) *storage.La
 	}
 }
 
+// Maps from a GitHub combined status to a storage combined status.
+func ConvertCombinedStatus(orgLogin string, repoName string, cs *github.CombinedStatus) *storage.CombinedStatus {
+	contexts := make([]string, len(cs.Statuses))
+	states := make([]string, len(cs.Statuses))
+	for i, status := range cs.Statuses {
+		contexts[i] = status.GetContext()
+		states[i] = status.GetState()
+	}
+
+	return &storage.CombinedStatus{
+		OrgLogin:      orgLogin,
+		RepoName:      repoName,
+		SHA:           cs.GetSHA(),
+		State:         cs.GetState(),
+		Contexts:      contexts,
+		ContextStates: states,
+	}
+}
+
+// Maps from a GitHub check run to a storage check run.
+func ConvertCheckRun(orgLogin string, repoName string, cr *github.CheckRun) *storage.CheckRun {
+	return &storage.CheckRun{
+		OrgLogin:    orgLogin,
+		RepoName:    repoName,
+		SHA:         cr.GetHeadSHA(),
+		CheckRunID:  cr.GetID(),
+		Name:        cr.GetName(),
+		Status:      cr.GetStatus(),
+		Conclusion:  cr.GetConclusion(),
+		StartedAt:   cr.GetStartedAt().Time,
+		CompletedAt: cr.GetCompletedAt().Time,
+	}
+}
+
+// Maps from a GitHub milestone to a storage milestone.
+func ConvertMilestone(orgLogin string, repoName string, m *github.Milestone) *storage.Milestone {
+	return &storage.Milestone{
+		OrgLogin:        orgLogin,
+		RepoName:        repoName,
+		MilestoneNumber: int64(m.GetNumber()),
+		Title:           m.GetTitle(),
+		State:           m.GetState(),
+		Description:     m.GetDescription(),
+		DueOn:           m.GetDueOn(),
+		OpenIssues:      int64(m.GetOpenIssues()),
+		ClosedIssues:    int64(m.GetClosedIssues()),
+	}
+}
+
+// Maps from a GitHub release to a storage release. Also returns the set of
+// users discovered in the input.
+func ConvertRelease(orgLogin string, repoName string, r *github.RepositoryRelease) (*storage.Release, []*storage.User) {
+	discoveredUsers := []*storage.User{
+		ConvertUser(r.GetAuthor()),
+	}
+
+	return &storage.Release{
+		OrgLogin:    orgLogin,
+		RepoName:    repoName,
+		ReleaseID:   r.GetID(),
+		TagName:     r.GetTagName(),
+		Name:        r.GetName(),
+		Draft:       r.GetDraft(),
+		Prerelease:  r.GetPrerelease(),
+		CreatedAt:   r.GetCreatedAt().Time,
+		PublishedAt: r.GetPublishedAt().Time,
+		Author:      r.GetAuthor().GetLogin(),
+	}, discoveredUsers
+}
+
 // Maps from a GitHub pr to a storage pr. Also returns the set of
 // users discovered in the input.
 func ConvertPullRequest(orgLogin string, repoName string, pr *github.PullRequest, files []string) (*storage.PullRequest, []*storage.User) {
@@ -152,6 +239,10 @@ func ConvertPullRequest(orgLogin string, repoName string, pr *github.PullRequest
 		discoveredUsers = append(discoveredUsers, ConvertUser(user))
 	}
 
+	if pr.MergedBy != nil {
+		discoveredUsers = append(discoveredUsers, ConvertUser(pr.MergedBy))
+	}
+
 	return &storage.PullRequest{
 		OrgLogin:           orgLogin,
 		RepoName:           repoName,
@@ -168,9 +259,73 @@ func ConvertPullRequest(orgLogin string, repoName string, pr *github.PullRequest
 		Title:              pr.GetTitle(),
 		Body:               pr.GetBody(),
 		Author:             pr.GetUser().GetLogin(),
+		MilestoneNumber:    int64(pr.GetMilestone().GetNumber()),
+		Additions:          int64(pr.GetAdditions()),
+		Deletions:          int64(pr.GetDeletions()),
+		ChangedFiles:       int64(pr.GetChangedFiles()),
+		MergedBy:           pr.GetMergedBy().GetLogin(),
+		MergeCommitSHA:     pr.GetMergeCommitSHA(),
+		Draft:              pr.GetDraft(),
+		Mergeable:          pr.GetMergeable(),
 	}, discoveredUsers
 }
 
+// Maps from the action of a GitHub pr event to the action recorded in storage. GitHub reports merges as
+// the pr being closed, these are recorded as "merged" to tell them apart from prs closed without merging.
+func ConvertPullRequestAction(action string, pr *github.PullRequest) string {
+	if action == "closed" && pr.GetMerged() {
+		return "merged"
+	}
+
+	return action
+}
+
+// Returns the name of the label added or removed by a GitHub issue event, or an empty string
+// for events which aren't about labels.
+func ConvertIssueEventLabel(e *github.IssueEvent) string {
+	if e.GetEvent() != "labeled" && e.GetEvent() != "unlabeled" {
+		return ""
+	}
+
+	return e.GetLabel().GetName()
+}
+
+// Returns the login of the user assigned or unassigned by a GitHub issue event, or an empty string
+// for events which aren't about assignees.
+func ConvertIssueEventAssignee(e *github.IssueEvent) string {
+	if e.GetEvent() != "assigned" && e.GetEvent() != "unassigned" {
+		return ""
+	}
+
+	return e.GetAssignee().GetLogin()
+}
+
+// Maps from the current state of an issue fetched from its original location to a storage issue transfer. GitHub
+// redirects requests for a transferred issue to its new location, so the issue is reported under a different repo.
+// Returns nil if the issue still lives in the original repo.
+func ConvertIssueTransfer(orgLogin string, repoName string, issueNumber int, issue *github.Issue) *storage.IssueTransfer {
+	// the repository URL is of the form https://api.github.com/repos/<org>/<repo>
+	splits := strings.Split(issue.GetRepositoryURL(), "/")
+	if len(splits) < 2 {
+		return nil
+	}
+
+	newOrgLogin := splits[len(splits)-2]
+	newRepoName := splits[len(splits)-1]
+	if newOrgLogin == orgLogin && newRepoName == repoName {
+		return nil
+	}
+
+	return &storage.IssueTransfer{
+		OrgLogin:       orgLogin,
+		RepoName:       repoName,
+		IssueNumber:    int64(issueNumber),
+		NewOrgLogin:    newOrgLogin,
+		NewRepoName:    newRepoName,
+		NewIssueNumber: int64(issue.GetNumber()),
+	}
+}
+
 // Maps from a GitHub pr comment to a storage pr comment. Also returns the set of
 // users discovered in the input.
 func ConvertPullRequestReviewComment(orgLogin string, repoName string, prNumber int,
@@ -188,6 +343,7 @@ func ConvertPullRequestReviewComment(orgLogin string, repoName string, prNumber
 		CreatedAt:                  comment.GetCreatedAt(),
 		UpdatedAt:                  comment.GetUpdatedAt(),
 		Author:                     comment.GetUser().GetLogin(),
+		AuthorAssociation:          comment.GetAuthorAssociation(),
 	}, discoveredUsers
 }
 
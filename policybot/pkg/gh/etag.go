@@ -0,0 +1,133 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gh
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/pkg/log"
+)
+
+type etagsKey struct{}
+
+// WithETags returns a context which makes the GET requests issued with it conditional on the ETags GitHub returned
+// for the same requests previously, provided ETags are enabled for the client. GitHub doesn't count requests for
+// unchanged data against the rate limit.
+func WithETags(ctx context.Context) context.Context {
+	return context.WithValue(ctx, etagsKey{}, true)
+}
+
+// etagTransport sends conditional requests to GitHub based on stored ETags. When GitHub reports the data is unchanged,
+// the stored response is returned instead, so callers are none the wiser.
+type etagTransport struct {
+	hits int64 // accessed atomically, keep first for alignment
+	base http.RoundTripper
+
+	mu    sync.RWMutex
+	store storage.Store
+}
+
+func (t *etagTransport) setStore(store storage.Store) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.store = store
+}
+
+func (t *etagTransport) getStore() storage.Store {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.store
+}
+
+func (t *etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	store := t.getStore()
+	if store == nil || req.Method != http.MethodGet || req.Context().Value(etagsKey{}) == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	url := req.URL.String()
+	cached, err := store.ReadETag(req.Context(), url)
+	if err != nil {
+		log.Warnf("Unable to read the ETag for %s: %v", url, err)
+		cached = nil
+	}
+
+	if cached != nil {
+		// round trippers aren't allowed to modify the request they're given
+		r := new(http.Request)
+		*r = *req
+		r.Header = make(http.Header, len(req.Header)+1)
+		for k, v := range req.Header {
+			r.Header[k] = v
+		}
+		r.Header.Set("If-None-Match", cached.ETag)
+		req = r
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		atomic.AddInt64(&t.hits, 1)
+		_ = resp.Body.Close()
+
+		// keep the headers of the actual response, which carry the current rate limits
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Header.Set("Content-Type", "application/json; charset=utf-8")
+		if cached.Link != "" {
+			resp.Header.Set("Link", cached.Link)
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(cached.Body))
+		resp.ContentLength = int64(len(cached.Body))
+
+		return resp, nil
+	}
+
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err := store.WriteETags(req.Context(), []*storage.ETag{{
+		URL:       url,
+		ETag:      etag,
+		Link:      resp.Header.Get("Link"),
+		Body:      body,
+		UpdatedAt: time.Now(),
+	}}); err != nil {
+		log.Warnf("Unable to write the ETag for %s: %v", url, err)
+	}
+
+	return resp, nil
+}
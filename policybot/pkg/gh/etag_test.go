@@ -0,0 +1,109 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/storage"
+)
+
+type fakeETagStore struct {
+	storage.Store
+
+	etags map[string]*storage.ETag
+}
+
+func (fs *fakeETagStore) ReadETag(_ context.Context, url string) (*storage.ETag, error) {
+	return fs.etags[url], nil
+}
+
+func (fs *fakeETagStore) WriteETags(_ context.Context, etags []*storage.ETag) error {
+	for _, e := range etags {
+		fs.etags[e.URL] = e
+	}
+	return nil
+}
+
+func TestETags(t *testing.T) {
+	var conditional []bool
+
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/labels", func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match") != "")
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Link", fmt.Sprintf(`<%s/repos/istio/istio/labels?page=2>; rel="next"`, server.URL))
+		_, _ = fmt.Fprint(w, `[{"name": "kind/bug"}]`)
+	})
+
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	etags := &etagTransport{base: http.DefaultTransport}
+	client := github.NewClient(&http.Client{Transport: etags})
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	tc := NewThrottledClientForClient(client)
+	tc.etags = etags
+	tc.EnableETags(&fakeETagStore{etags: make(map[string]*storage.ETag)})
+
+	listLabels := func(ctx context.Context) ([]*github.Label, *github.Response) {
+		labels, resp, err := tc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Issues.ListLabels(ctx, "istio", "istio", nil)
+		})
+
+		if err != nil {
+			t.Fatalf("Got error %v, expecting success", err)
+		}
+
+		return labels.([]*github.Label), resp
+	}
+
+	for i := 0; i < 2; i++ {
+		labels, resp := listLabels(WithETags(context.Background()))
+		if len(labels) != 1 || labels[0].GetName() != "kind/bug" {
+			t.Errorf("Got labels %v on call %d, expecting kind/bug", labels, i)
+		}
+
+		if resp.NextPage != 2 {
+			t.Errorf("Got next page %d on call %d, expecting 2", resp.NextPage, i)
+		}
+	}
+
+	// requests are only conditional when asked for
+	_, _ = listLabels(context.Background())
+
+	expected := []bool{false, true, false}
+	if fmt.Sprint(conditional) != fmt.Sprint(expected) {
+		t.Errorf("Got conditional requests %v, expecting %v", conditional, expected)
+	}
+
+	if tc.ETagHits() != 1 {
+		t.Errorf("Got %d ETag hits, expecting 1", tc.ETagHits())
+	}
+}
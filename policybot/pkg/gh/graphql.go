@@ -0,0 +1,527 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+)
+
+// BulkPullRequest holds a pull request along with its reviews and changed files, as fetched by QueryPullRequestsBulk.
+type BulkPullRequest struct {
+	PullRequest *github.PullRequest
+	Reviews     []*github.PullRequestReview
+	Files       []string
+
+	// Incomplete is set when the pull request has more labels, assignees, requested reviewers,
+	// reviews, or files than fit in a single bulk query. The data for such pull requests needs
+	// to be fetched individually.
+	Incomplete bool
+}
+
+// BulkIssue holds an issue along with its most recent comments, as fetched by QueryIssuesBulk.
+type BulkIssue struct {
+	Issue    *github.Issue
+	Comments []*github.IssueComment
+
+	// Incomplete is set when the issue has more labels or assignees than fit in a single bulk query.
+	// Such issues need to be fetched individually.
+	Incomplete bool
+
+	// IncompleteComments is set when the issue has more comments than fit in a single bulk query, in
+	// which case Comments holds only the most recent ones.
+	IncompleteComments bool
+}
+
+// how many pull requests or issues to fetch per query, and how many of each nested item per pull request or issue
+const (
+	bulkPullRequestPageSize = 25
+	bulkIssuePageSize       = 50
+	bulkNestedPageSize      = 100
+)
+
+const bulkPullRequestQuery = `
+query($owner: String!, $name: String!, $pageSize: Int!, $nestedSize: Int!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    pullRequests(first: $pageSize, after: $cursor, orderBy: {field: UPDATED_AT, direction: DESC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        number title body state createdAt updatedAt closedAt mergedAt headRefOid isDraft mergeable
+        author { login avatarUrl }
+        mergedBy { login avatarUrl }
+        mergeCommit { oid }
+        milestone { number }
+        labels(first: $nestedSize) { pageInfo { hasNextPage } nodes { name } }
+        assignees(first: $nestedSize) { pageInfo { hasNextPage } nodes { login avatarUrl } }
+        reviewRequests(first: $nestedSize) {
+          pageInfo { hasNextPage }
+          nodes { requestedReviewer { ... on User { login avatarUrl } } }
+        }
+        reviews(first: $nestedSize) {
+          pageInfo { hasNextPage }
+          nodes { databaseId body state submittedAt author { login avatarUrl } }
+        }
+        files(first: $nestedSize) { pageInfo { hasNextPage } nodes { path } }
+      }
+    }
+  }
+}`
+
+// the issue-level data of issues or pull requests, depending on which connection is substituted in
+const bulkIssueQuery = `
+query($owner: String!, $name: String!, $pageSize: Int!, $nestedSize: Int!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    items: %s(first: $pageSize, after: $cursor, orderBy: {field: UPDATED_AT, direction: DESC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        number title body state createdAt updatedAt closedAt
+        author { login avatarUrl }
+        milestone { number }
+        labels(first: $nestedSize) { pageInfo { hasNextPage } nodes { name } }
+        assignees(first: $nestedSize) { pageInfo { hasNextPage } nodes { login avatarUrl } }
+        comments(last: $nestedSize) {
+          pageInfo { hasPreviousPage }
+          nodes { databaseId body createdAt updatedAt authorAssociation author { login avatarUrl } }
+        }
+      }
+    }
+  }
+}`
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+type graphQLPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type graphQLActor struct {
+	Login     string `json:"login"`
+	AvatarURL string `json:"avatarUrl"`
+}
+
+type graphQLPullRequest struct {
+	Number      int           `json:"number"`
+	Title       string        `json:"title"`
+	Body        string        `json:"body"`
+	State       string        `json:"state"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	UpdatedAt   time.Time     `json:"updatedAt"`
+	ClosedAt    *time.Time    `json:"closedAt"`
+	MergedAt    *time.Time    `json:"mergedAt"`
+	HeadRefOid  string        `json:"headRefOid"`
+	IsDraft     bool          `json:"isDraft"`
+	Mergeable   string        `json:"mergeable"`
+	Author      *graphQLActor `json:"author"`
+	MergedBy    *graphQLActor `json:"mergedBy"`
+	MergeCommit *struct {
+		Oid string `json:"oid"`
+	} `json:"mergeCommit"`
+	Milestone *struct {
+		Number int `json:"number"`
+	} `json:"milestone"`
+	Labels struct {
+		PageInfo graphQLPageInfo `json:"pageInfo"`
+		Nodes    []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"labels"`
+	Assignees struct {
+		PageInfo graphQLPageInfo `json:"pageInfo"`
+		Nodes    []*graphQLActor `json:"nodes"`
+	} `json:"assignees"`
+	ReviewRequests struct {
+		PageInfo graphQLPageInfo `json:"pageInfo"`
+		Nodes    []struct {
+			// empty when the review was requested from a team
+			RequestedReviewer graphQLActor `json:"requestedReviewer"`
+		} `json:"nodes"`
+	} `json:"reviewRequests"`
+	Reviews struct {
+		PageInfo graphQLPageInfo `json:"pageInfo"`
+		Nodes    []struct {
+			DatabaseID  int64         `json:"databaseId"`
+			Body        string        `json:"body"`
+			State       string        `json:"state"`
+			SubmittedAt time.Time     `json:"submittedAt"`
+			Author      *graphQLActor `json:"author"`
+		} `json:"nodes"`
+	} `json:"reviews"`
+	Files struct {
+		PageInfo graphQLPageInfo `json:"pageInfo"`
+		Nodes    []struct {
+			Path string `json:"path"`
+		} `json:"nodes"`
+	} `json:"files"`
+}
+
+type graphQLIssue struct {
+	Number    int           `json:"number"`
+	Title     string        `json:"title"`
+	Body      string        `json:"body"`
+	State     string        `json:"state"`
+	CreatedAt time.Time     `json:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+	ClosedAt  *time.Time    `json:"closedAt"`
+	Author    *graphQLActor `json:"author"`
+	Milestone *struct {
+		Number int `json:"number"`
+	} `json:"milestone"`
+	Labels struct {
+		PageInfo graphQLPageInfo `json:"pageInfo"`
+		Nodes    []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"labels"`
+	Assignees struct {
+		PageInfo graphQLPageInfo `json:"pageInfo"`
+		Nodes    []*graphQLActor `json:"nodes"`
+	} `json:"assignees"`
+	Comments struct {
+		PageInfo struct {
+			HasPreviousPage bool `json:"hasPreviousPage"`
+		} `json:"pageInfo"`
+		Nodes []struct {
+			DatabaseID        int64         `json:"databaseId"`
+			Body              string        `json:"body"`
+			CreatedAt         time.Time     `json:"createdAt"`
+			UpdatedAt         time.Time     `json:"updatedAt"`
+			AuthorAssociation string        `json:"authorAssociation"`
+			Author            *graphQLActor `json:"author"`
+		} `json:"nodes"`
+	} `json:"comments"`
+}
+
+type bulkIssueResponse struct {
+	Data struct {
+		Repository *struct {
+			Items struct {
+				PageInfo graphQLPageInfo `json:"pageInfo"`
+				Nodes    []*graphQLIssue `json:"nodes"`
+			} `json:"items"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []graphQLError `json:"errors"`
+}
+
+type bulkPullRequestResponse struct {
+	Data struct {
+		Repository *struct {
+			PullRequests struct {
+				PageInfo graphQLPageInfo       `json:"pageInfo"`
+				Nodes    []*graphQLPullRequest `json:"nodes"`
+			} `json:"pullRequests"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []graphQLError `json:"errors"`
+}
+
+// QueryPullRequestsBulk uses GitHub's GraphQL API to fetch a page of a repo's pull requests together with their
+// reviews and changed files, most recently updated first. Pass the returned cursor back in to get the next page,
+// an empty cursor is returned once there are no more pages.
+//
+// The pull requests and reviews are populated with the same fields as those returned by the REST API, such that
+// they convert to identical storage records.
+func (tc *ThrottledClient) QueryPullRequestsBulk(context context.Context, orgLogin string, repoName string,
+	cursor string) ([]*BulkPullRequest, string, error) {
+
+	vars := map[string]interface{}{
+		"owner":      orgLogin,
+		"name":       repoName,
+		"pageSize":   bulkPullRequestPageSize,
+		"nestedSize": bulkNestedPageSize,
+	}
+
+	if cursor != "" {
+		vars["cursor"] = cursor
+	}
+
+	result, _, _, err := tc.ThrottledCallTwoResult(func(client *github.Client) (interface{}, interface{}, *github.Response, error) {
+		req, err := client.NewRequest("POST", "graphql", &graphQLRequest{Query: bulkPullRequestQuery, Variables: vars})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		var r bulkPullRequestResponse
+		resp, err := client.Do(context, req, &r)
+		return &r, nil, resp, err
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	r := result.(*bulkPullRequestResponse)
+	if err := queryErrors(r.Errors); err != nil {
+		return nil, "", err
+	}
+
+	if r.Data.Repository == nil {
+		return nil, "", fmt.Errorf("repo %s/%s not found", orgLogin, repoName)
+	}
+
+	prs := r.Data.Repository.PullRequests
+	bulk := make([]*BulkPullRequest, len(prs.Nodes))
+	for i, pr := range prs.Nodes {
+		bulk[i] = convertGraphQLPullRequest(pr)
+	}
+
+	next := ""
+	if prs.PageInfo.HasNextPage {
+		next = prs.PageInfo.EndCursor
+	}
+
+	return bulk, next, nil
+}
+
+// QueryIssuesBulk uses GitHub's GraphQL API to fetch a page of a repo's issues together with their labels, assignees,
+// and most recent comments, most recently updated first. The REST API lists pull requests as issues too, so set
+// pullRequests to get the same issue-level data for the repo's pull requests. Pass the returned cursor back in to
+// get the next page, an empty cursor is returned once there are no more pages.
+//
+// The issues and comments are populated with the same fields as those returned by the REST API, such that they
+// convert to identical storage records.
+func (tc *ThrottledClient) QueryIssuesBulk(context context.Context, orgLogin string, repoName string, pullRequests bool,
+	cursor string) ([]*BulkIssue, string, error) {
+
+	connection := "issues"
+	if pullRequests {
+		connection = "pullRequests"
+	}
+
+	vars := map[string]interface{}{
+		"owner":      orgLogin,
+		"name":       repoName,
+		"pageSize":   bulkIssuePageSize,
+		"nestedSize": bulkNestedPageSize,
+	}
+
+	if cursor != "" {
+		vars["cursor"] = cursor
+	}
+
+	query := fmt.Sprintf(bulkIssueQuery, connection)
+	result, _, _, err := tc.ThrottledCallTwoResult(func(client *github.Client) (interface{}, interface{}, *github.Response, error) {
+		req, err := client.NewRequest("POST", "graphql", &graphQLRequest{Query: query, Variables: vars})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		var r bulkIssueResponse
+		resp, err := client.Do(context, req, &r)
+		return &r, nil, resp, err
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	r := result.(*bulkIssueResponse)
+	if err := queryErrors(r.Errors); err != nil {
+		return nil, "", err
+	}
+
+	if r.Data.Repository == nil {
+		return nil, "", fmt.Errorf("repo %s/%s not found", orgLogin, repoName)
+	}
+
+	items := r.Data.Repository.Items
+	bulk := make([]*BulkIssue, len(items.Nodes))
+	for i, issue := range items.Nodes {
+		bulk[i] = convertGraphQLIssue(issue)
+	}
+
+	next := ""
+	if items.PageInfo.HasNextPage {
+		next = items.PageInfo.EndCursor
+	}
+
+	return bulk, next, nil
+}
+
+func queryErrors(errs []graphQLError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Message
+	}
+	return fmt.Errorf("GraphQL query failed: %s", strings.Join(msgs, "; "))
+}
+
+// convertGraphQLIssue maps a GraphQL issue or pull request to the types returned by the REST API's issue endpoints.
+func convertGraphQLIssue(issue *graphQLIssue) *BulkIssue {
+	// the REST API doesn't have a merged state, merged PRs are just closed
+	state := strings.ToLower(issue.State)
+	if state == "merged" {
+		state = "closed"
+	}
+
+	ghi := &github.Issue{
+		Number:    github.Int(issue.Number),
+		Title:     github.String(issue.Title),
+		Body:      github.String(issue.Body),
+		State:     github.String(state),
+		CreatedAt: &issue.CreatedAt,
+		UpdatedAt: &issue.UpdatedAt,
+		ClosedAt:  issue.ClosedAt,
+		User:      convertGraphQLActor(issue.Author),
+	}
+
+	if issue.Milestone != nil {
+		ghi.Milestone = &github.Milestone{Number: github.Int(issue.Milestone.Number)}
+	}
+
+	for _, l := range issue.Labels.Nodes {
+		ghi.Labels = append(ghi.Labels, &github.Label{Name: github.String(l.Name)})
+	}
+
+	for _, a := range issue.Assignees.Nodes {
+		ghi.Assignees = append(ghi.Assignees, convertGraphQLActor(a))
+	}
+
+	bulk := &BulkIssue{
+		Issue:              ghi,
+		Incomplete:         issue.Labels.PageInfo.HasNextPage || issue.Assignees.PageInfo.HasNextPage,
+		IncompleteComments: issue.Comments.PageInfo.HasPreviousPage,
+	}
+
+	for _, c := range issue.Comments.Nodes {
+		createdAt := c.CreatedAt
+		updatedAt := c.UpdatedAt
+		bulk.Comments = append(bulk.Comments, &github.IssueComment{
+			ID:                github.Int64(c.DatabaseID),
+			Body:              github.String(c.Body),
+			CreatedAt:         &createdAt,
+			UpdatedAt:         &updatedAt,
+			AuthorAssociation: github.String(c.AuthorAssociation),
+			User:              convertGraphQLActor(c.Author),
+		})
+	}
+
+	return bulk
+}
+
+// convertGraphQLPullRequest maps a GraphQL pull request to the types returned by the REST API.
+func convertGraphQLPullRequest(pr *graphQLPullRequest) *BulkPullRequest {
+	// the REST API doesn't have a merged state, merged PRs are just closed
+	state := strings.ToLower(pr.State)
+	if state == "merged" {
+		state = "closed"
+	}
+
+	ghpr := &github.PullRequest{
+		Number:    github.Int(pr.Number),
+		Title:     github.String(pr.Title),
+		Body:      github.String(pr.Body),
+		State:     github.String(state),
+		CreatedAt: &pr.CreatedAt,
+		UpdatedAt: &pr.UpdatedAt,
+		ClosedAt:  pr.ClosedAt,
+		MergedAt:  pr.MergedAt,
+		User:      convertGraphQLActor(pr.Author),
+		Head:      &github.PullRequestBranch{SHA: github.String(pr.HeadRefOid)},
+		Draft:     github.Bool(pr.IsDraft),
+	}
+
+	if pr.MergedBy != nil {
+		ghpr.MergedBy = convertGraphQLActor(pr.MergedBy)
+	}
+
+	if pr.MergeCommit != nil {
+		ghpr.MergeCommitSHA = github.String(pr.MergeCommit.Oid)
+	}
+
+	// UNKNOWN while GitHub is still computing mergeability, which the REST API reports as null
+	switch pr.Mergeable {
+	case "MERGEABLE":
+		ghpr.Mergeable = github.Bool(true)
+	case "CONFLICTING":
+		ghpr.Mergeable = github.Bool(false)
+	}
+
+	if pr.Milestone != nil {
+		ghpr.Milestone = &github.Milestone{Number: github.Int(pr.Milestone.Number)}
+	}
+
+	for _, l := range pr.Labels.Nodes {
+		ghpr.Labels = append(ghpr.Labels, &github.Label{Name: github.String(l.Name)})
+	}
+
+	for _, a := range pr.Assignees.Nodes {
+		ghpr.Assignees = append(ghpr.Assignees, convertGraphQLActor(a))
+	}
+
+	for _, rr := range pr.ReviewRequests.Nodes {
+		if rr.RequestedReviewer.Login != "" {
+			r := rr.RequestedReviewer
+			ghpr.RequestedReviewers = append(ghpr.RequestedReviewers, convertGraphQLActor(&r))
+		}
+	}
+
+	bulk := &BulkPullRequest{
+		PullRequest: ghpr,
+		Incomplete: pr.Labels.PageInfo.HasNextPage || pr.Assignees.PageInfo.HasNextPage ||
+			pr.ReviewRequests.PageInfo.HasNextPage || pr.Reviews.PageInfo.HasNextPage || pr.Files.PageInfo.HasNextPage,
+	}
+
+	for _, r := range pr.Reviews.Nodes {
+		review := &github.PullRequestReview{
+			ID:    github.Int64(r.DatabaseID),
+			Body:  github.String(r.Body),
+			State: github.String(r.State),
+			User:  convertGraphQLActor(r.Author),
+		}
+
+		// pending reviews haven't been submitted yet
+		if !r.SubmittedAt.IsZero() {
+			t := r.SubmittedAt
+			review.SubmittedAt = &t
+		}
+
+		bulk.Reviews = append(bulk.Reviews, review)
+	}
+
+	for _, f := range pr.Files.Nodes {
+		bulk.Files = append(bulk.Files, f.Path)
+	}
+
+	return bulk
+}
+
+func convertGraphQLActor(a *graphQLActor) *github.User {
+	if a == nil {
+		// the REST API reports deleted accounts as the ghost user
+		return &github.User{Login: github.String("ghost")}
+	}
+
+	return &github.User{
+		Login:     github.String(a.Login),
+		AvatarURL: github.String(a.AvatarURL),
+	}
+}
@@ -16,18 +16,107 @@ package gh
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/go-github/v26/github"
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/oauth2"
 
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/pkg/cache"
 	"istio.io/pkg/log"
 )
 
+const (
+	// DefaultMaxRetries is the default number of times a rate limited call is retried
+	DefaultMaxRetries = 10
+
+	// DefaultMaxRetryDelay is the default upper bound on how long to wait before retrying a rate limited call
+	DefaultMaxRetryDelay = 15 * time.Minute
+
+	// the starting delay when GitHub doesn't tell us how long to wait
+	baseRetryDelay = time.Second
+)
+
 // ThrottledClient is used to throttle our use of the GitHub API in order to
 // prevent hitting rate limits.
 type ThrottledClient struct {
+	calls  int64 // accessed atomically, keep first for alignment
 	client *github.Client
+
+	// MaxRetries is the number of times a rate limited call is retried before giving up and returning the error.
+	MaxRetries int
+
+	// MaxRetryDelay caps how long to wait before retrying a rate limited call.
+	MaxRetryDelay time.Duration
+
+	// replaceable for testing
+	sleep func(context.Context, time.Duration) error
+
+	// nil for clients created around an existing GitHub client
+	etags *etagTransport
+
+	// the most recent rate limits reported by GitHub
+	rateMu     sync.Mutex
+	coreRate   github.Rate
+	searchRate github.Rate
+
+	// invoked whenever the remaining core budget is below lowBudgetFloor
+	lowBudgetFloor int
+	lowBudget      func(RateLimitSnapshot)
+
+	// users fetched by GetUserCached, and the lookups currently in flight
+	usersMu     sync.Mutex
+	users       cache.ExpiringCache
+	userLookups map[string]*userLookup
+}
+
+// RateLimitSnapshot captures GitHub's rate limits as reported by the most recent responses. The rates are all zero
+// until a response has been seen.
+type RateLimitSnapshot struct {
+	Core   github.Rate
+	Search github.Rate
+}
+
+func (s RateLimitSnapshot) String() string {
+	return fmt.Sprintf("core %d/%d resetting at %v, search %d/%d", s.Core.Remaining, s.Core.Limit,
+		s.Core.Reset.Time.Format(time.RFC3339), s.Search.Remaining, s.Search.Limit)
+}
+
+var (
+	rateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "github_rate_limit_remaining",
+		Help: "The number of GitHub API calls left in the current rate limit window.",
+	}, []string{"category"})
+
+	rateLimitLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "github_rate_limit",
+		Help: "The number of GitHub API calls allowed per rate limit window.",
+	}, []string{"category"})
+
+	rateLimitReset = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "github_rate_limit_reset_timestamp_seconds",
+		Help: "When the current GitHub rate limit window ends, in seconds since the epoch.",
+	}, []string{"category"})
+
+	gitHubCallsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_calls_total",
+		Help: "The number of GitHub API calls which got a response, including retries.",
+	}, []string{"category"})
+)
+
+func init() {
+	prometheus.MustRegister(rateLimitRemaining)
+	prometheus.MustRegister(rateLimitLimit)
+	prometheus.MustRegister(rateLimitReset)
+	prometheus.MustRegister(gitHubCallsTotal)
 }
 
 func NewThrottledClient(context context.Context, githubToken string) *ThrottledClient {
@@ -35,73 +124,322 @@ func NewThrottledClient(context context.Context, githubToken string) *ThrottledC
 		&oauth2.Token{AccessToken: githubToken},
 	)
 
+	return newThrottledClientForTokenSource(context, src)
+}
+
+func newThrottledClientForTokenSource(context context.Context, src oauth2.TokenSource) *ThrottledClient {
+	hc := oauth2.NewClient(context, src)
+	etags := &etagTransport{base: hc.Transport}
+	hc.Transport = etags
+
+	tc := NewThrottledClientForClient(github.NewClient(hc))
+	tc.etags = etags
+	return tc
+}
+
+// NewThrottledClientForClient returns a throttled client which wraps the given client.
+func NewThrottledClientForClient(client *github.Client) *ThrottledClient {
 	return &ThrottledClient{
-		client: github.NewClient(oauth2.NewClient(context, src)),
+		client:        client,
+		MaxRetries:    DefaultMaxRetries,
+		MaxRetryDelay: DefaultMaxRetryDelay,
+		sleep:         sleepContext,
+		users:         cache.NewLRU(userCacheTTL, time.Minute, maxCachedUsers),
+		userLookups:   make(map[string]*userLookup),
+	}
+}
+
+// sleepContext waits for the given duration, returning early with the context's error if it's canceled.
+func sleepContext(context context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-context.Done():
+		return context.Err()
 	}
 }
 
 // ThrottledCall invokes the given callback and watches for error returns indicating a GitHub rate limit errors.
-// If a rate limit error is detected, the call is tried again based on the reset time
-// specified in the error.
-func (tc ThrottledClient) ThrottledCall(cb func(client *github.Client) (interface{}, *github.Response, error)) (interface{}, *github.Response, error) {
-	for {
+// If a rate limit or transient server error is detected, the call is tried again once the limit resets or after
+// backing off, up to MaxRetries times.
+func (tc *ThrottledClient) ThrottledCall(cb func(client *github.Client) (interface{}, *github.Response, error)) (interface{}, *github.Response, error) {
+	for attempt := 0; ; attempt++ {
+		atomic.AddInt64(&tc.calls, 1)
 		result, resp, err := cb(tc.client)
-		if err == nil {
-			return result, resp, nil
-		}
-
-		_, ok := err.(*github.RateLimitError)
-		if !ok {
+		tc.recordRate(resp)
+		if err == nil || !tc.backoff(attempt, resp, err) {
 			return result, resp, err
 		}
-
-		sleep(resp)
 	}
 }
 
 // ThrottledCallNoResult invokes the given callback and watches for error returns indicating a GitHub rate limit errors.
-// If a rate limit error is detected, the call is tried again based on the reset time
-// specified in the error.
+// If a rate limit or transient server error is detected, the call is tried again once the limit resets or after
+// backing off, up to MaxRetries times.
 func (tc *ThrottledClient) ThrottledCallNoResult(cb func(*github.Client) (*github.Response, error)) (*github.Response, error) {
-	for {
+	for attempt := 0; ; attempt++ {
+		atomic.AddInt64(&tc.calls, 1)
 		resp, err := cb(tc.client)
-		if err == nil {
-			return resp, nil
-		}
-
-		_, ok := err.(*github.RateLimitError)
-		if !ok {
+		tc.recordRate(resp)
+		if err == nil || !tc.backoff(attempt, resp, err) {
 			return resp, err
 		}
-
-		sleep(resp)
 	}
 }
 
 // ThrottledCallTwoResult invokes the given callback and watches for error returns indicating a GitHub rate limit errors.
-// If a rate limit error is detected, the call is tried again based on the reset time
-// specified in the error.
+// If a rate limit or transient server error is detected, the call is tried again once the limit resets or after
+// backing off, up to MaxRetries times.
 func (tc *ThrottledClient) ThrottledCallTwoResult(cb func(*github.Client) (interface{}, interface{}, *github.Response, error)) (interface{},
 	interface{}, *github.Response, error) {
 
-	for {
+	for attempt := 0; ; attempt++ {
+		atomic.AddInt64(&tc.calls, 1)
 		result1, result2, resp, err := cb(tc.client)
-		if err == nil {
-			return result1, result2, resp, nil
-		}
-
-		_, ok := err.(*github.RateLimitError)
-		if !ok {
+		tc.recordRate(resp)
+		if err == nil || !tc.backoff(attempt, resp, err) {
 			return result1, result2, resp, err
 		}
+	}
+}
 
-		sleep(resp)
+// BulkCall invokes the given callback for each of the items using a bounded pool of goroutines, and returns
+// the results and errors in the same order as the input items. Callbacks which report a rate limit error are
+// retried once the limit resets, up to MaxRetries times. Items which haven't been processed by the time the
+// context is canceled report the context's error. Only callbacks which return the response of a GitHub call are
+// counted and retried here, others such as those calling ZenHub or going through ThrottledCall do their own
+// counting and retrying.
+func (tc *ThrottledClient) BulkCall(context context.Context, items []interface{},
+	fn func(item interface{}) (interface{}, *github.Response, error), concurrency int) ([]interface{}, []error) {
+
+	if concurrency <= 0 {
+		concurrency = 1
 	}
+
+	results := make([]interface{}, len(items))
+	errs := make([]error, len(items))
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range work {
+				if err := context.Err(); err != nil {
+					errs[index] = err
+					continue
+				}
+
+				for attempt := 0; ; attempt++ {
+					result, resp, err := fn(items[index])
+					if resp == nil {
+						// the callback didn't call GitHub itself, so it's left to count and retry its own calls
+						results[index] = result
+						errs[index] = err
+						break
+					}
+
+					atomic.AddInt64(&tc.calls, 1)
+					tc.recordRate(resp)
+					if err == nil || !tc.backoff(attempt, resp, err) {
+						results[index] = result
+						errs[index] = err
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	for i := range items {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	return results, errs
 }
 
-func sleep(resp *github.Response) {
-	// wait for the reset time
-	// TODO: would be nice to wait in a cancellable way, per a context
-	log.Debugf("Waiting for GitHub rate limit reset at %s", resp.Rate.Reset.UTC().String())
-	time.Sleep(time.Until(resp.Rate.Reset.Time))
+// Calls returns the number of GitHub API calls made through this client so far, including retries.
+func (tc *ThrottledClient) Calls() int64 {
+	return atomic.LoadInt64(&tc.calls)
+}
+
+// EnableETags makes the requests issued with a context obtained from WithETags conditional, based on the ETags
+// kept in the given store. This has no effect on clients created with NewThrottledClientForClient.
+func (tc *ThrottledClient) EnableETags(store storage.Store) {
+	if tc.etags != nil {
+		tc.etags.setStore(store)
+	}
+}
+
+// ETagHits returns the number of calls made through this client so far which GitHub answered with a 304, such that
+// the response was served from storage.
+func (tc *ThrottledClient) ETagHits() int64 {
+	if tc.etags == nil {
+		return 0
+	}
+
+	return atomic.LoadInt64(&tc.etags.hits)
+}
+
+// RemainingBudget returns the number of core and search API calls left in the current rate limit windows, along
+// with the time at which the core limit resets. The values are those reported by the most recent responses, and
+// are all zero until a response has been seen.
+func (tc *ThrottledClient) RemainingBudget() (core int, search int, reset time.Time) {
+	tc.rateMu.Lock()
+	defer tc.rateMu.Unlock()
+
+	return tc.coreRate.Remaining, tc.searchRate.Remaining, tc.coreRate.Reset.Time
+}
+
+// RateLimitSnapshot returns the rate limits reported by the most recent responses.
+func (tc *ThrottledClient) RateLimitSnapshot() RateLimitSnapshot {
+	tc.rateMu.Lock()
+	defer tc.rateMu.Unlock()
+
+	return RateLimitSnapshot{Core: tc.coreRate, Search: tc.searchRate}
+}
+
+// SetLowBudgetCallback arranges for cb to be invoked after any call which leaves fewer than floor core API calls in
+// the current rate limit window. The callback runs before the call returns, so it can delay the caller by blocking.
+// A nil callback removes the current one.
+func (tc *ThrottledClient) SetLowBudgetCallback(floor int, cb func(RateLimitSnapshot)) {
+	tc.rateMu.Lock()
+	defer tc.rateMu.Unlock()
+
+	tc.lowBudgetFloor = floor
+	tc.lowBudget = cb
+}
+
+func (tc *ThrottledClient) recordRate(resp *github.Response) {
+	// responses without rate information, such as when the request never made it to GitHub, are ignored
+	if resp == nil || resp.Response == nil || resp.Request == nil || resp.Rate.Limit == 0 {
+		return
+	}
+
+	// search calls are limited separately from everything else
+	category := "core"
+	if strings.Contains(resp.Request.URL.Path, "/search/") {
+		category = "search"
+	}
+
+	gitHubCallsTotal.WithLabelValues(category).Inc()
+	rateLimitRemaining.WithLabelValues(category).Set(float64(resp.Rate.Remaining))
+	rateLimitLimit.WithLabelValues(category).Set(float64(resp.Rate.Limit))
+	rateLimitReset.WithLabelValues(category).Set(float64(resp.Rate.Reset.Unix()))
+
+	tc.rateMu.Lock()
+	if category == "search" {
+		tc.searchRate = resp.Rate
+	} else {
+		tc.coreRate = resp.Rate
+	}
+
+	var cb func(RateLimitSnapshot)
+	if category == "core" && tc.lowBudget != nil && resp.Rate.Remaining < tc.lowBudgetFloor {
+		cb = tc.lowBudget
+	}
+	snapshot := RateLimitSnapshot{Core: tc.coreRate, Search: tc.searchRate}
+	tc.rateMu.Unlock()
+
+	if cb != nil {
+		cb(snapshot)
+	}
+}
+
+// backoff determines whether a failed call should be retried and if so, waits until it's time to do so. The wait
+// is abandoned, and the call not retried, if the context of the failed request is canceled in the meantime.
+func (tc *ThrottledClient) backoff(attempt int, resp *github.Response, err error) bool {
+	if attempt >= tc.MaxRetries {
+		return false
+	}
+
+	delay, ok := retryDelay(resp, err)
+	if !ok {
+		return false
+	}
+
+	if delay <= 0 {
+		// GitHub didn't say how long to wait, so back off exponentially
+		delay = baseRetryDelay << uint(attempt)
+	}
+
+	// spread out retries so concurrent callers don't all hit GitHub at the same instant
+	delay += time.Duration(rand.Int63n(int64(delay)/10 + 1))
+
+	if tc.MaxRetryDelay > 0 && delay > tc.MaxRetryDelay {
+		delay = tc.MaxRetryDelay
+	}
+
+	// the callbacks don't get a context, but the one they used for the request is available from the response
+	ctx := context.Background()
+	if resp != nil && resp.Response != nil && resp.Request != nil {
+		ctx = resp.Request.Context()
+	}
+
+	log.Debugf("GitHub call failed with %v, retrying in %v (attempt %d of %d)", err, delay, attempt+1, tc.MaxRetries)
+	return tc.sleep(ctx, delay) == nil
+}
+
+// idempotent returns whether sending a request several times has the same effect as sending it once.
+func idempotent(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// retryDelay returns whether the error indicates a rate limit or a transient server error, along with how long
+// GitHub asked us to wait. A zero delay means GitHub didn't say.
+func retryDelay(resp *github.Response, err error) (time.Duration, bool) {
+	switch e := err.(type) {
+	case *github.RateLimitError:
+		return time.Until(e.Rate.Reset.Time), true
+
+	case *github.AbuseRateLimitError:
+		if e.RetryAfter != nil {
+			return *e.RetryAfter, true
+		}
+		return 0, true
+	}
+
+	if resp == nil || resp.Response == nil {
+		return 0, false
+	}
+
+	// secondary rate limits aren't always reported in a way the GitHub library recognizes, and GitHub regularly
+	// fails with transient 5xx errors. A request which failed that way may still have taken effect though, so
+	// only requests which can safely be repeated are retried.
+	serverError := resp.StatusCode >= http.StatusInternalServerError && idempotent(resp.Request)
+	if resp.StatusCode != http.StatusForbidden && !serverError {
+		return 0, false
+	}
+
+	if ra := resp.Header.Get("Retry-After"); ra != "" {
+		if secs, err := strconv.ParseInt(ra, 10, 64); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+
+	if serverError {
+		return 0, true
+	}
+
+	if resp.Rate.Remaining == 0 && time.Until(resp.Rate.Reset.Time) > 0 {
+		return time.Until(resp.Rate.Reset.Time), true
+	}
+
+	return 0, false
 }
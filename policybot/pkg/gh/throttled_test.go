@@ -0,0 +1,370 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+)
+
+const abuseBody = `{"message":"You have triggered an abuse detection mechanism.",` +
+	`"documentation_url":"https://developer.github.com/v3/#abuse-rate-limits"}`
+
+// newTestClient returns a client talking to a server which fails the first failures calls with a 403, the given
+// body, and Retry-After header before succeeding.
+func newTestClient(failures int, body string, retryAfter string) (*ThrottledClient, *[]time.Duration, func()) {
+	return newTestClientWithStatus(http.StatusForbidden, failures, body, retryAfter)
+}
+
+// newTestClientWithStatus is like newTestClient, with the failures using the given status code.
+func newTestClientWithStatus(status int, failures int, body string, retryAfter string) (*ThrottledClient, *[]time.Duration, func()) {
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			_, _ = fmt.Fprint(w, body)
+			return
+		}
+
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4321")
+		w.Header().Set("X-RateLimit-Reset", "1560000000")
+		_, _ = fmt.Fprint(w, `{"name":"istio"}`)
+	})
+	server := httptest.NewServer(mux)
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	var delays []time.Duration
+	tc := NewThrottledClientForClient(client)
+	tc.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	return tc, &delays, server.Close
+}
+
+func getRepo(tc *ThrottledClient) (*github.Repository, error) {
+	repo, _, err := tc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Repositories.Get(context.Background(), "istio", "istio")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return repo.(*github.Repository), nil
+}
+
+func TestRetryAfter(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+	}{
+		{"abuse", abuseBody},
+		{"unrecognized", `{"message":"You have exceeded a secondary rate limit."}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tc, delays, done := newTestClient(1, c.body, "30")
+			defer done()
+
+			repo, err := getRepo(tc)
+			if err != nil {
+				t.Fatalf("Got error %v, expecting success", err)
+			}
+
+			if repo.GetName() != "istio" {
+				t.Errorf("Got repo %q, expecting istio", repo.GetName())
+			}
+
+			if tc.Calls() != 2 {
+				t.Errorf("Got %d calls, expecting 2", tc.Calls())
+			}
+
+			if len(*delays) != 1 || (*delays)[0] < 30*time.Second || (*delays)[0] > 33*time.Second {
+				t.Errorf("Got delays %v, expecting a single delay of about 30s", *delays)
+			}
+		})
+	}
+}
+
+func TestRetryDelayIsCapped(t *testing.T) {
+	tc, delays, done := newTestClient(1, abuseBody, "3600")
+	defer done()
+
+	tc.MaxRetryDelay = time.Minute
+
+	if _, err := getRepo(tc); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if len(*delays) != 1 || (*delays)[0] != time.Minute {
+		t.Errorf("Got delays %v, expecting a single delay of 1m", *delays)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	tc, delays, done := newTestClient(3, abuseBody, "")
+	defer done()
+
+	if _, err := getRepo(tc); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if len(*delays) != 3 {
+		t.Fatalf("Got delays %v, expecting 3", *delays)
+	}
+
+	for i, d := range *delays {
+		base := baseRetryDelay << uint(i)
+		if d < base || d > base+base/10 {
+			t.Errorf("Got delay %v for attempt %d, expecting between %v and %v", d, i, base, base+base/10)
+		}
+	}
+}
+
+func TestGiveUpAfterMaxRetries(t *testing.T) {
+	tc, delays, done := newTestClient(10, abuseBody, "1")
+	defer done()
+
+	tc.MaxRetries = 2
+
+	if _, err := getRepo(tc); err == nil {
+		t.Fatal("Got success, expecting an error")
+	}
+
+	if tc.Calls() != 3 {
+		t.Errorf("Got %d calls, expecting 3", tc.Calls())
+	}
+
+	if len(*delays) != 2 {
+		t.Errorf("Got delays %v, expecting 2", *delays)
+	}
+}
+
+func TestNoRetryOnOtherErrors(t *testing.T) {
+	tc, delays, done := newTestClient(1, `{"message":"Must have admin rights to Repository."}`, "")
+	defer done()
+
+	if _, err := getRepo(tc); err == nil {
+		t.Fatal("Got success, expecting an error")
+	}
+
+	if len(*delays) != 0 {
+		t.Errorf("Got delays %v, expecting none", *delays)
+	}
+}
+
+func TestRetryOnServerErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, http.StatusServiceUnavailable} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			tc, delays, done := newTestClientWithStatus(status, 2, `{"message":"Server Error"}`, "")
+			defer done()
+
+			if _, err := getRepo(tc); err != nil {
+				t.Fatalf("Got error %v, expecting success", err)
+			}
+
+			if tc.Calls() != 3 {
+				t.Errorf("Got %d calls, expecting 3", tc.Calls())
+			}
+
+			if len(*delays) != 2 {
+				t.Errorf("Got delays %v, expecting 2", *delays)
+			}
+		})
+	}
+}
+
+func TestNoRetryOnServerErrorsForPosts(t *testing.T) {
+	posts := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = fmt.Fprint(w, `{"message":"Server Error"}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	var delays []time.Duration
+	tc := NewThrottledClientForClient(client)
+	tc.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	// the comment may have been created in spite of the error, so posting it again could duplicate it
+	_, _, err := tc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Issues.CreateComment(context.Background(), "istio", "istio", 1, &github.IssueComment{Body: github.String("Hi")})
+	})
+
+	if err == nil {
+		t.Fatal("Got success, expecting an error")
+	}
+
+	if posts != 1 {
+		t.Errorf("Got %d posts, expecting 1", posts)
+	}
+
+	if len(delays) != 0 {
+		t.Errorf("Got delays %v, expecting none", delays)
+	}
+}
+
+func TestBulkCallCountsAttempts(t *testing.T) {
+	tc, delays, done := newTestClient(1, abuseBody, "1")
+	defer done()
+
+	_, errs := tc.BulkCall(context.Background(), []interface{}{"istio", "istio"}, func(item interface{}) (interface{}, *github.Response, error) {
+		return tc.client.Repositories.Get(context.Background(), "istio", item.(string))
+	}, 1)
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Got error %v for item %d, expecting success", err, i)
+		}
+	}
+
+	if tc.Calls() != 3 {
+		t.Errorf("Got %d calls, expecting 3", tc.Calls())
+	}
+
+	if len(*delays) != 1 {
+		t.Errorf("Got delays %v, expecting 1", *delays)
+	}
+
+	// callbacks which don't call GitHub, like ZenHub lookups, are neither counted nor retried
+	var invocations int32
+	_, errs = tc.BulkCall(context.Background(), []interface{}{1, 2}, func(item interface{}) (interface{}, *github.Response, error) {
+		atomic.AddInt32(&invocations, 1)
+		return nil, nil, &github.RateLimitError{Message: "ZenHub rate limit exceeded"}
+	}, 2)
+
+	for i, err := range errs {
+		if _, ok := err.(*github.RateLimitError); !ok {
+			t.Errorf("Got error %v for item %d, expecting the callback's error", err, i)
+		}
+	}
+
+	if invocations != 2 {
+		t.Errorf("Got %d invocations, expecting 2", invocations)
+	}
+
+	if tc.Calls() != 3 || len(*delays) != 1 {
+		t.Errorf("Got %d calls and delays %v, expecting ZenHub lookups to leave the 3 GitHub calls alone", tc.Calls(), *delays)
+	}
+}
+
+func TestBackoffStopsWhenCanceled(t *testing.T) {
+	tc, _, done := newTestClient(1, abuseBody, "3600")
+	defer done()
+
+	tc.sleep = sleepContext
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := tc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Repositories.Get(ctx, "istio", "istio")
+	})
+
+	if err == nil {
+		t.Fatal("Got success, expecting an error")
+	}
+
+	if tc.Calls() != 1 {
+		t.Errorf("Got %d calls, expecting 1", tc.Calls())
+	}
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Took %v to give up, expecting the wait to end with the context", elapsed)
+	}
+}
+
+func TestRemainingBudget(t *testing.T) {
+	tc, _, done := newTestClient(0, "", "")
+	defer done()
+
+	if core, search, _ := tc.RemainingBudget(); core != 0 || search != 0 {
+		t.Errorf("Got budget %d/%d before any call, expecting 0/0", core, search)
+	}
+
+	if _, err := getRepo(tc); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	core, search, reset := tc.RemainingBudget()
+	if core != 4321 || search != 0 {
+		t.Errorf("Got budget %d/%d, expecting 4321/0", core, search)
+	}
+
+	if reset.Unix() != 1560000000 {
+		t.Errorf("Got reset time %v, expecting %v", reset, time.Unix(1560000000, 0))
+	}
+}
+
+func TestRateLimitSnapshot(t *testing.T) {
+	tc, _, done := newTestClient(0, "", "")
+	defer done()
+
+	var notified []RateLimitSnapshot
+	tc.SetLowBudgetCallback(5000, func(s RateLimitSnapshot) { notified = append(notified, s) })
+
+	if _, err := getRepo(tc); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	s := tc.RateLimitSnapshot()
+	if s.Core.Remaining != 4321 || s.Core.Limit != 5000 || s.Search.Limit != 0 {
+		t.Errorf("Got snapshot %v, expecting 4321 of 5000 core calls left", s)
+	}
+
+	if len(notified) != 1 || notified[0].Core.Remaining != 4321 {
+		t.Errorf("Got notifications %v, expecting one for 4321 remaining calls", notified)
+	}
+
+	// above the floor
+	tc.SetLowBudgetCallback(1000, func(s RateLimitSnapshot) { notified = append(notified, s) })
+	if _, err := getRepo(tc); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if len(notified) != 1 {
+		t.Errorf("Got notifications %v, expecting no more once above the floor", notified)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gh
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+)
+
+const (
+	// how long users fetched by GetUserCached are remembered, long enough to cover a full sync
+	userCacheTTL = time.Hour
+
+	// the most users remembered at once
+	maxCachedUsers = 50000
+)
+
+// a lookup of a single user by GetUserCached, shared by all concurrent lookups of that user
+type userLookup struct {
+	done chan struct{}
+	user *github.User
+	err  error
+}
+
+// GetUserCached returns the GitHub user with the given login. Concurrent lookups of the same login share a
+// single API call, and users are remembered for a while such that a sync fetches each user at most once.
+// Failed lookups aren't remembered.
+func (tc *ThrottledClient) GetUserCached(context context.Context, login string) (*github.User, error) {
+	tc.usersMu.Lock()
+	if u, ok := tc.users.Get(login); ok {
+		tc.usersMu.Unlock()
+		return u.(*github.User), nil
+	}
+
+	if l, ok := tc.userLookups[login]; ok {
+		tc.usersMu.Unlock()
+
+		select {
+		case <-l.done:
+			return l.user, l.err
+		case <-context.Done():
+			return nil, context.Err()
+		}
+	}
+
+	l := &userLookup{done: make(chan struct{})}
+	tc.userLookups[login] = l
+	tc.usersMu.Unlock()
+
+	u, _, err := tc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Users.Get(context, login)
+	})
+
+	tc.usersMu.Lock()
+	delete(tc.userLookups, login)
+	if err == nil {
+		l.user = u.(*github.User)
+		tc.users.Set(login, l.user)
+	}
+	l.err = err
+	tc.usersMu.Unlock()
+
+	close(l.done)
+	return l.user, l.err
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gh
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+)
+
+func TestGetUserCached(t *testing.T) {
+	var calls int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/alice" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		atomic.AddInt32(&calls, 1)
+		started <- struct{}{}
+		<-release
+
+		_, _ = w.Write([]byte(`{"login": "alice", "name": "Alice"}`))
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	tc := NewThrottledClientForClient(client)
+	tc.MaxRetries = 0
+
+	var wg sync.WaitGroup
+	users := make([]*github.User, 2)
+	for i := range users {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			u, err := tc.GetUserCached(context.Background(), "alice")
+			if err != nil {
+				t.Errorf("Unable to get user: %v", err)
+			}
+			users[i] = u
+		}(i)
+	}
+
+	// hold the call until the second lookup has had a chance to join it
+	<-started
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Got %d calls, expecting the concurrent lookups to share one", n)
+	}
+
+	for _, u := range users {
+		if u.GetName() != "Alice" {
+			t.Errorf("Got user %+v, expecting Alice", u)
+		}
+	}
+
+	// later lookups are served from the cache
+	if _, err := tc.GetUserCached(context.Background(), "alice"); err != nil {
+		t.Errorf("Unable to get user: %v", err)
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Got %d calls, expecting the cached user to be used", n)
+	}
+
+	// failures aren't cached
+	for i := 0; i < 2; i++ {
+		if _, err := tc.GetUserCached(context.Background(), "bob"); err == nil {
+			t.Error("Expecting an error getting an unknown user")
+		}
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/pkg/log"
+)
+
+// The labels applied to issues as they go through their lifecycle
+const (
+	StaleLabel  = "lifecycle/stale"
+	RottenLabel = "lifecycle/rotten"
+)
+
+var scope = log.RegisterScope("lifecycle", "Issue lifecycle manager", 0)
+
+// Manager marks issues without activity as stale, then as rotten, and finally closes them. Any activity
+// on an issue other than the bot's own resets the clock. The bot's actions are recorded as issue events.
+type Manager struct {
+	gc    *gh.ThrottledClient
+	store storage.Store
+	orgs  []config.Org
+}
+
+// New creates a lifecycle manager.
+func New(gc *gh.ThrottledClient, store storage.Store, orgs []config.Org) *Manager {
+	return &Manager{
+		gc:    gc,
+		store: store,
+		orgs:  orgs,
+	}
+}
+
+// Run goes through the issues of every repo which has lifecycle management enabled.
+func (m *Manager) Run(context context.Context) error {
+	// the bot's own comments and labels don't count as activity
+	result, _, err := m.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Users.Get(context, "")
+	})
+	if err != nil {
+		return fmt.Errorf("unable to get the bot's identity: %v", err)
+	}
+	botLogin := result.(*github.User).GetLogin()
+
+	now := time.Now()
+	for i := range m.orgs {
+		org := &m.orgs[i]
+		if !enabled(org) {
+			continue
+		}
+
+		repos, err := m.getRepos(context, org)
+		if err != nil {
+			return fmt.Errorf("unable to list the repos of org %s: %v", org.Name, err)
+		}
+
+		for _, repo := range repos {
+			lc := org.LifecycleFor(repo)
+			if lc.StaleDays <= 0 {
+				continue
+			}
+
+			if err := m.processRepo(context, org.Name, repo, lc, botLogin, now); err != nil {
+				return fmt.Errorf("unable to process issues in repo %s/%s: %v", org.Name, repo, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// enabled returns whether lifecycle management is enabled for the org or any of its repos.
+func enabled(org *config.Org) bool {
+	if org.Lifecycle.StaleDays > 0 {
+		return true
+	}
+
+	for _, r := range org.Repos {
+		if r.Lifecycle != nil && r.Lifecycle.StaleDays > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Manager) getRepos(context context.Context, org *config.Org) ([]string, error) {
+	if !org.AllRepos {
+		var repos []string
+		for _, r := range org.Repos {
+			if org.MonitorsRepo(r.Name) {
+				repos = append(repos, r.Name)
+			}
+		}
+		return repos, nil
+	}
+
+	opt := &github.RepositoryListByOrgOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	var repos []string
+	for {
+		result, resp, err := m.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Repositories.ListByOrg(context, org.Name, opt)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range result.([]*github.Repository) {
+			if org.MonitorsRepo(r.GetName()) {
+				repos = append(repos, r.GetName())
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opt.Page = resp.NextPage
+	}
+
+	return repos, nil
+}
+
+func (m *Manager) processRepo(context context.Context, orgLogin string, repoName string, lc config.Lifecycle,
+	botLogin string, now time.Time) error {
+
+	staleBefore := now.Add(-days(lc.StaleDays))
+	rottenBefore := staleBefore.Add(-days(lc.RottenDays))
+	closeBefore := rottenBefore.Add(-days(lc.CloseDays))
+
+	stale, err := m.inactiveIssues(context, orgLogin, repoName, botLogin, staleBefore)
+	if err != nil {
+		return err
+	}
+
+	rotten := make(map[int64]*storage.Issue)
+	if lc.RottenDays > 0 {
+		if rotten, err = m.inactiveIssues(context, orgLogin, repoName, botLogin, rottenBefore); err != nil {
+			return err
+		}
+	}
+
+	closing := make(map[int64]*storage.Issue)
+	if lc.RottenDays > 0 && lc.CloseDays > 0 {
+		if closing, err = m.inactiveIssues(context, orgLogin, repoName, botLogin, closeBefore); err != nil {
+			return err
+		}
+	}
+
+	for number, issue := range stale {
+		if hasAnyLabel(issue, lc.ExemptLabels) {
+			continue
+		}
+
+		// issues move one stage at a time
+		switch {
+		case closing[number] != nil && hasLabel(issue, RottenLabel):
+			m.close(context, issue, lc, botLogin)
+		case rotten[number] != nil && hasLabel(issue, StaleLabel):
+			m.advance(context, issue, StaleLabel, RottenLabel, lc.RottenComment, lc.DryRun, botLogin)
+		case !hasLabel(issue, StaleLabel) && !hasLabel(issue, RottenLabel):
+			m.advance(context, issue, "", StaleLabel, lc.StaleComment, lc.DryRun, botLogin)
+		}
+	}
+
+	// issues which have seen activity since being labeled start over
+	for _, label := range []string{StaleLabel, RottenLabel} {
+		if err := m.store.QueryIssuesByLabel(context, orgLogin, repoName, label, func(issue *storage.Issue) error {
+			if issue.State == "open" && stale[issue.IssueNumber] == nil {
+				m.removeLabel(context, issue, label, lc.DryRun)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Manager) inactiveIssues(context context.Context, orgLogin string, repoName string, botLogin string,
+	before time.Time) (map[int64]*storage.Issue, error) {
+	result := make(map[int64]*storage.Issue)
+	err := m.store.QueryIssuesByActivity(context, orgLogin, repoName, botLogin, before, func(issue *storage.Issue) error {
+		result[issue.IssueNumber] = issue
+		return nil
+	})
+
+	return result, err
+}
+
+// advance moves an issue to the next stage of its lifecycle.
+func (m *Manager) advance(context context.Context, issue *storage.Issue, from string, to string, comment string, dryRun bool,
+	botLogin string) {
+	if dryRun {
+		scope.Infof("Dry run: would label issue %d in repo %s/%s as %s", issue.IssueNumber, issue.OrgLogin, issue.RepoName, to)
+		return
+	}
+
+	if _, _, err := m.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Issues.AddLabelsToIssue(context, issue.OrgLogin, issue.RepoName, int(issue.IssueNumber), []string{to})
+	}); err != nil {
+		scope.Errorf("Unable to label issue %d in repo %s/%s as %s: %v", issue.IssueNumber, issue.OrgLogin, issue.RepoName, to, err)
+		return
+	}
+
+	scope.Infof("Labeled issue %d in repo %s/%s as %s", issue.IssueNumber, issue.OrgLogin, issue.RepoName, to)
+	m.record(context, issue, botLogin, "labeled", to)
+
+	if from != "" {
+		m.removeLabel(context, issue, from, false)
+	}
+
+	m.comment(context, issue, comment)
+}
+
+func (m *Manager) close(context context.Context, issue *storage.Issue, lc config.Lifecycle, botLogin string) {
+	if lc.DryRun {
+		scope.Infof("Dry run: would close issue %d in repo %s/%s", issue.IssueNumber, issue.OrgLogin, issue.RepoName)
+		return
+	}
+
+	m.comment(context, issue, lc.CloseComment)
+
+	state := "closed"
+	if _, _, err := m.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Issues.Edit(context, issue.OrgLogin, issue.RepoName, int(issue.IssueNumber), &github.IssueRequest{State: &state})
+	}); err != nil {
+		scope.Errorf("Unable to close issue %d in repo %s/%s: %v", issue.IssueNumber, issue.OrgLogin, issue.RepoName, err)
+		return
+	}
+
+	scope.Infof("Closed issue %d in repo %s/%s", issue.IssueNumber, issue.OrgLogin, issue.RepoName)
+	m.record(context, issue, botLogin, "closed", "")
+}
+
+// record keeps track of an action taken on an issue. Since the actions are the bot's own, they don't count as
+// activity on the issue.
+func (m *Manager) record(context context.Context, issue *storage.Issue, botLogin string, action string, label string) {
+	event := &storage.IssueEvent{
+		OrgLogin:    issue.OrgLogin,
+		RepoName:    issue.RepoName,
+		IssueNumber: issue.IssueNumber,
+		CreatedAt:   time.Now(),
+		Actor:       botLogin,
+		Action:      action,
+		Label:       label,
+	}
+
+	if err := m.store.WriteIssueEvents(context, []*storage.IssueEvent{event}); err != nil {
+		scope.Errorf("Unable to record %s event for issue %d in repo %s/%s: %v", action, issue.IssueNumber, issue.OrgLogin, issue.RepoName, err)
+	}
+}
+
+func (m *Manager) removeLabel(context context.Context, issue *storage.Issue, label string, dryRun bool) {
+	if dryRun {
+		scope.Infof("Dry run: would remove label %s from issue %d in repo %s/%s", label, issue.IssueNumber, issue.OrgLogin, issue.RepoName)
+		return
+	}
+
+	if _, err := m.gc.ThrottledCallNoResult(func(client *github.Client) (*github.Response, error) {
+		return client.Issues.RemoveLabelForIssue(context, issue.OrgLogin, issue.RepoName, int(issue.IssueNumber), label)
+	}); err != nil {
+		scope.Errorf("Unable to remove label %s from issue %d in repo %s/%s: %v", label, issue.IssueNumber, issue.OrgLogin, issue.RepoName, err)
+	}
+}
+
+func (m *Manager) comment(context context.Context, issue *storage.Issue, body string) {
+	if body == "" {
+		return
+	}
+
+	if _, _, err := m.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Issues.CreateComment(context, issue.OrgLogin, issue.RepoName, int(issue.IssueNumber), &github.IssueComment{Body: &body})
+	}); err != nil {
+		scope.Errorf("Unable to comment on issue %d in repo %s/%s: %v", issue.IssueNumber, issue.OrgLogin, issue.RepoName, err)
+	}
+}
+
+func hasLabel(issue *storage.Issue, label string) bool {
+	for _, l := range issue.Labels {
+		if l == label {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasAnyLabel(issue *storage.Issue, labels []string) bool {
+	for _, l := range labels {
+		if hasLabel(issue, l) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func days(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
@@ -0,0 +1,223 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
+)
+
+type fakeStore struct {
+	storage.Store
+
+	issues       []*storage.Issue
+	lastActivity map[int64]time.Time
+	events       []*storage.IssueEvent
+}
+
+func (fs *fakeStore) WriteIssueEvents(_ context.Context, events []*storage.IssueEvent) error {
+	fs.events = append(fs.events, events...)
+	return nil
+}
+
+func (fs *fakeStore) QueryIssuesByActivity(_ context.Context, _ string, _ string, ignoredActor string, before time.Time,
+	cb func(*storage.Issue) error) error {
+	if ignoredActor != "bot" {
+		return nil
+	}
+
+	for _, issue := range fs.issues {
+		if fs.lastActivity[issue.IssueNumber].Before(before) {
+			if err := cb(issue); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (fs *fakeStore) QueryIssuesByLabel(_ context.Context, _ string, _ string, labelName string, cb func(*storage.Issue) error) error {
+	for _, issue := range fs.issues {
+		if hasLabel(issue, labelName) {
+			if err := cb(issue); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	now := time.Now()
+	ago := func(d int) time.Time { return now.Add(-days(d)) }
+
+	store := &fakeStore{
+		issues: []*storage.Issue{
+			{OrgLogin: "istio", RepoName: "istio", IssueNumber: 1, State: "open"},
+			{OrgLogin: "istio", RepoName: "istio", IssueNumber: 2, State: "open"},
+			{OrgLogin: "istio", RepoName: "istio", IssueNumber: 3, State: "open", Labels: []string{StaleLabel}},
+			{OrgLogin: "istio", RepoName: "istio", IssueNumber: 4, State: "open", Labels: []string{RottenLabel}},
+			{OrgLogin: "istio", RepoName: "istio", IssueNumber: 5, State: "open", Labels: []string{StaleLabel}},
+			{OrgLogin: "istio", RepoName: "istio", IssueNumber: 6, State: "open"},
+			{OrgLogin: "istio", RepoName: "istio", IssueNumber: 7, State: "open", Labels: []string{"lifecycle/frozen"}},
+		},
+		lastActivity: map[int64]time.Time{
+			1: ago(10),  // active
+			2: ago(40),  // becomes stale
+			3: ago(70),  // becomes rotten
+			4: ago(100), // gets closed
+			5: ago(5),   // commented on after being labeled stale
+			6: ago(100), // must go through being stale first
+			7: ago(100), // exempt
+		},
+	}
+
+	lc := config.Lifecycle{StaleDays: 30, RottenDays: 30, CloseDays: 30, StaleComment: "stale", CloseComment: "closing",
+		ExemptLabels: []string{"lifecycle/frozen"}}
+
+	expected := []string{
+		"DELETE /repos/istio/istio/issues/3/labels/lifecycle/stale",
+		"DELETE /repos/istio/istio/issues/5/labels/lifecycle/stale",
+		"GET /user",
+		"PATCH /repos/istio/istio/issues/4",
+		"POST /repos/istio/istio/issues/2/comments",
+		"POST /repos/istio/istio/issues/2/labels",
+		"POST /repos/istio/istio/issues/3/labels",
+		"POST /repos/istio/istio/issues/4/comments",
+		"POST /repos/istio/istio/issues/6/comments",
+		"POST /repos/istio/istio/issues/6/labels",
+	}
+
+	expectedEvents := []string{"2 labeled lifecycle/stale", "3 labeled lifecycle/rotten", "4 closed ", "6 labeled lifecycle/stale"}
+
+	cases := []struct {
+		name           string
+		dryRun         bool
+		expected       []string
+		expectedEvents []string
+	}{
+		{"live", false, expected, expectedEvents},
+		{"dry run", true, []string{"GET /user"}, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var calls []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, r.Method+" "+r.URL.Path)
+				switch {
+				case r.URL.Path == "/user":
+					_, _ = w.Write([]byte(`{"login": "bot"}`))
+				case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/labels"):
+					_, _ = w.Write([]byte("[]"))
+				default:
+					_, _ = w.Write([]byte("{}"))
+				}
+			}))
+			defer server.Close()
+
+			client := github.NewClient(nil)
+			client.BaseURL, _ = url.Parse(server.URL + "/")
+
+			lc.DryRun = c.dryRun
+			orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}, Lifecycle: lc}}
+			m := New(gh.NewThrottledClientForClient(client), store, orgs)
+
+			if err := m.Run(context.Background()); err != nil {
+				t.Fatalf("Got error %v", err)
+			}
+
+			sort.Strings(calls)
+			if !reflect.DeepEqual(calls, c.expected) {
+				t.Errorf("Got calls\n%v\nexpecting\n%v", calls, c.expected)
+			}
+
+			var events []string
+			for _, e := range store.events {
+				if e.Actor != "bot" {
+					t.Errorf("Got event %+v, expecting it to be recorded as the bot's", e)
+				}
+				events = append(events, fmt.Sprintf("%d %s %s", e.IssueNumber, e.Action, e.Label))
+			}
+			store.events = nil
+
+			sort.Strings(events)
+			if !reflect.DeepEqual(events, c.expectedEvents) {
+				t.Errorf("Got events %v, expecting %v", events, c.expectedEvents)
+			}
+		})
+	}
+}
+
+func TestRepoLifecycle(t *testing.T) {
+	store := &fakeStore{
+		issues: []*storage.Issue{
+			{OrgLogin: "istio", RepoName: "api", IssueNumber: 1, State: "open"},
+		},
+		lastActivity: map[int64]time.Time{
+			1: time.Now().Add(-days(10)),
+		},
+	}
+
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch {
+		case r.URL.Path == "/user":
+			_, _ = w.Write([]byte(`{"login": "bot"}`))
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/labels"):
+			_, _ = w.Write([]byte("[]"))
+		default:
+			_, _ = w.Write([]byte("{}"))
+		}
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	// lifecycle management is only enabled for the api repo, with a shorter threshold
+	orgs := []config.Org{{
+		Name: "istio",
+		Repos: []config.Repo{
+			{Name: "istio"},
+			{Name: "api", Lifecycle: &config.Lifecycle{StaleDays: 7}},
+		},
+	}}
+
+	m := New(gh.NewThrottledClientForClient(client), store, orgs)
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+
+	expected := []string{"GET /user", "POST /repos/istio/api/issues/1/labels"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Got calls %v, expecting %v", calls, expected)
+	}
+}
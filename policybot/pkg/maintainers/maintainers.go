@@ -0,0 +1,410 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package maintainers determines who maintains which paths of a repo, based on the repo's CODEOWNERS
+// file or, failing that, its OWNERS files.
+package maintainers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/codeowners"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/bots/policybot/pkg/storage/cache"
+	"istio.io/pkg/log"
+)
+
+var scope = log.RegisterScope("maintainers", "Maintainer discovery", 0)
+
+// the branch to assume for repos whose default branch isn't known
+const fallbackBranch = "master"
+
+// Builder accumulates the maintainers of an org, one repo at a time.
+type Builder struct {
+	ctx         context.Context
+	gc          *gh.ThrottledClient
+	cache       *cache.Cache
+	store       storage.Store
+	org         *storage.Org
+	users       map[string]*storage.User
+	maintainers map[string]*storage.Maintainer
+
+	// team members by org/team, for the teams referenced in CODEOWNERS files
+	teamMembers map[string][]string
+}
+
+// NewBuilder returns a builder for the maintainers of the given org. The users map is consulted before
+// looking users up, and any users discovered along the way are added to it.
+func NewBuilder(ctx context.Context, gc *gh.ThrottledClient, cache *cache.Cache, store storage.Store,
+	org *storage.Org, users map[string]*storage.User) *Builder {
+	return &Builder{
+		ctx:         ctx,
+		gc:          gc,
+		cache:       cache,
+		store:       store,
+		org:         org,
+		users:       users,
+		maintainers: make(map[string]*storage.Maintainer),
+		teamMembers: make(map[string][]string),
+	}
+}
+
+// AddRepo adds the maintainers of a repo, based on its CODEOWNERS file if it has one, or on its OWNERS files otherwise.
+func (b *Builder) AddRepo(repo *storage.Repo) error {
+	fc, _, _, err := b.gc.ThrottledCallTwoResult(func(client *github.Client) (interface{}, interface{}, *github.Response, error) {
+		return client.Repositories.GetContents(b.ctx, repo.OrgLogin, repo.RepoName, "CODEOWNERS", nil)
+	})
+
+	if err == nil {
+		return b.AddCODEOWNERS(repo, fc.(*github.RepositoryContent))
+	}
+
+	return b.AddOWNERS(repo)
+}
+
+// Maintainers returns the maintainers accumulated so far.
+func (b *Builder) Maintainers() []*storage.Maintainer {
+	result := make([]*storage.Maintainer, 0, len(b.maintainers))
+	for _, maintainer := range b.maintainers {
+		result = append(result, maintainer)
+	}
+	return result
+}
+
+// AddCODEOWNERS adds the maintainers listed in a repo's CODEOWNERS file.
+func (b *Builder) AddCODEOWNERS(repo *storage.Repo, fc *github.RepositoryContent) error {
+	content, err := fc.GetContent()
+	if err != nil {
+		return fmt.Errorf("unable to read CODEOWNERS body from repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+	}
+
+	lines := strings.Split(content, "\n")
+
+	scope.Debugf("%d lines in CODEOWNERS file for repo %s/%s", len(lines), repo.OrgLogin, repo.RepoName)
+
+	// keep the raw file around such that ownership can be resolved later on
+	if err := b.store.WriteCodeOwners(b.ctx, []*storage.CodeOwners{{
+		OrgLogin: repo.OrgLogin,
+		RepoName: repo.RepoName,
+		Lines:    lines,
+	}}); err != nil {
+		return fmt.Errorf("unable to write CODEOWNERS for repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+	}
+
+	// go through each rule of the CODEOWNERS file
+	for _, rule := range codeowners.Parse(content) {
+		path := rule.Pattern
+
+		var logins []string
+		for _, owner := range rule.Owners {
+			if !strings.Contains(owner, "/") {
+				logins = append(logins, owner)
+				continue
+			}
+
+			// expand org/team entries into the team's members
+			members, err := b.getTeamMembers(owner)
+			if err != nil {
+				scope.Warnf("Couldn't get members of team %s: %v", owner, err)
+				continue
+			}
+			logins = append(logins, members...)
+		}
+
+		for _, login := range logins {
+			// add the path to this maintainer's list
+			scope.Debugf("User '%s' can review path '%s/%s/%s'", login, repo.OrgLogin, repo.RepoName, path)
+
+			maintainer, err := b.getMaintainer(login)
+			if maintainer == nil || err != nil {
+				scope.Warnf("Couldn't get info on potential maintainer %s: %v", login, err)
+				continue
+			}
+
+			maintainer.Paths = append(maintainer.Paths, repo.RepoName+"/"+path)
+		}
+	}
+
+	return nil
+}
+
+type ownersFile struct {
+	Approvers []string `json:"approvers"`
+	Reviewers []string `json:"reviewers"`
+}
+
+// AddOWNERS adds the approvers and reviewers listed in the OWNERS files on a repo's default branch.
+func (b *Builder) AddOWNERS(repo *storage.Repo) error {
+	branch := repo.DefaultBranch
+	if branch == "" {
+		branch = fallbackBranch
+	}
+
+	br, _, err := b.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Repositories.GetBranch(b.ctx, repo.OrgLogin, repo.RepoName, branch)
+	})
+
+	if err != nil {
+		return fmt.Errorf("unable to get branch %s in repo %s/%s: %v", branch, repo.OrgLogin, repo.RepoName, err)
+	}
+
+	// pin everything to the branch's head so the tree and the file contents agree
+	sha := br.(*github.Branch).GetCommit().GetSHA()
+
+	tree, _, err := b.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Git.GetTree(b.ctx, repo.OrgLogin, repo.RepoName, sha, true)
+	})
+
+	if err != nil {
+		return fmt.Errorf("unable to get tree in repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+	}
+
+	files := make(map[string]ownersFile)
+	for _, entry := range tree.(*github.Tree).Entries {
+		components := strings.Split(entry.GetPath(), "/")
+		if components[len(components)-1] == "OWNERS" && components[0] != "vendor" { // HACK: skip Go's vendor directory
+
+			blob, _, err := b.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+				return client.Git.GetBlob(b.ctx, repo.OrgLogin, repo.RepoName, entry.GetSHA())
+			})
+
+			if err != nil {
+				return fmt.Errorf("unable to get %s from repo %s/%s: %v", entry.GetPath(), repo.OrgLogin, repo.RepoName, err)
+			}
+
+			content, err := decodeBlob(blob.(*github.Blob))
+			if err != nil {
+				return fmt.Errorf("unable to read %s body from repo %s/%s: %v", entry.GetPath(), repo.OrgLogin, repo.RepoName, err)
+			}
+
+			var f ownersFile
+			if err := yaml.Unmarshal(content, &f); err != nil {
+				return fmt.Errorf("unable to parse %s body from repo %s/%s: %v", entry.GetPath(), repo.OrgLogin, repo.RepoName, err)
+			}
+
+			files[entry.GetPath()] = f
+		}
+	}
+
+	scope.Debugf("%d OWNERS files found in repo %s/%s", len(files), repo.OrgLogin, repo.RepoName)
+
+	for path, file := range files {
+		// an OWNERS file covers everything within its directory
+		p := codeowners.Normalize("/" + strings.TrimSuffix(path, "OWNERS"))
+
+		for _, user := range file.Approvers {
+			maintainer, err := b.getMaintainer(user)
+			if maintainer == nil || err != nil {
+				scope.Warnf("Couldn't get info on potential maintainer %s: %v", user, err)
+				continue
+			}
+
+			scope.Debugf("User '%s' can approve path %s/%s/%s", user, repo.OrgLogin, repo.RepoName, p)
+
+			maintainer.Paths = append(maintainer.Paths, repo.RepoName+"/"+p)
+		}
+
+		for _, user := range file.Reviewers {
+			maintainer, err := b.getMaintainer(user)
+			if maintainer == nil || err != nil {
+				scope.Warnf("Couldn't get info on potential maintainer %s: %v", user, err)
+				continue
+			}
+
+			scope.Debugf("User '%s' can review path %s/%s/%s", user, repo.OrgLogin, repo.RepoName, p)
+
+			maintainer.ReviewerPaths = append(maintainer.ReviewerPaths, repo.RepoName+"/"+p)
+		}
+	}
+
+	return nil
+}
+
+// getTeamMembers returns the logins of the members of a team given in org/team form, based on the
+// team membership recorded by the syncer. Teams that haven't been synced yet are looked up on GitHub.
+// The result is remembered for the lifetime of the builder.
+func (b *Builder) getTeamMembers(team string) ([]string, error) {
+	if members, ok := b.teamMembers[team]; ok {
+		return members, nil
+	}
+
+	parts := strings.SplitN(team, "/", 2)
+
+	t, err := b.store.ReadTeamBySlug(b.ctx, parts[0], parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("unable to read information from storage for team %s: %v", team, err)
+	}
+
+	var members []string
+	if t == nil {
+		scope.Warnf("Team %s isn't known yet, looking up its members on GitHub", team)
+		if members, err = b.fetchTeamMembers(team, parts[0], parts[1]); err != nil {
+			return nil, err
+		}
+	} else if err = b.store.QueryTeamMembers(b.ctx, t.OrgLogin, t.TeamID, func(member *storage.TeamMember) error {
+		members = append(members, member.UserLogin)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("unable to read members from storage for team %s: %v", team, err)
+	}
+
+	b.teamMembers[team] = members
+	return members, nil
+}
+
+// fetchTeamMembers returns the logins of the members of a team, as reported by GitHub.
+func (b *Builder) fetchTeamMembers(team string, orgLogin string, teamSlug string) ([]string, error) {
+	t, _, err := b.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		return client.Teams.GetTeamBySlug(b.ctx, orgLogin, teamSlug)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get information for team %s: %v", team, err)
+	}
+
+	opt := &github.TeamListTeamMembersOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	var members []string
+	for {
+		users, resp, err := b.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Teams.ListTeamMembers(b.ctx, t.(*github.Team).GetID(), opt)
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to list members of team %s: %v", team, err)
+		}
+
+		for _, user := range users.([]*github.User) {
+			b.users[user.GetLogin()] = gh.ConvertUser(user)
+			members = append(members, user.GetLogin())
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opt.ListOptions.Page = resp.NextPage
+	}
+
+	return members, nil
+}
+
+func (b *Builder) getMaintainer(login string) (*storage.Maintainer, error) {
+	user, ok := b.users[login]
+	if !ok {
+		var err error
+		user, err = b.cache.ReadUser(b.ctx, login)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read information from storage for user %s: %v", login, err)
+		}
+	}
+
+	if user == nil {
+		// couldn't find user info, ask GitHub directly
+		u, err := b.gc.GetUserCached(b.ctx, login)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read information from GitHub on user %s: %v", login, err)
+		}
+
+		user = gh.ConvertUser(u)
+		b.users[user.UserLogin] = user
+	}
+
+	maintainer, ok := b.maintainers[user.UserLogin]
+	if !ok {
+		// unknown maintainer, so create a record
+		maintainer = &storage.Maintainer{
+			OrgLogin:  b.org.OrgLogin,
+			UserLogin: user.UserLogin,
+		}
+		b.maintainers[user.UserLogin] = maintainer
+	}
+
+	return maintainer, nil
+}
+
+// decodeBlob returns the raw content of a Git blob
+func decodeBlob(blob *github.Blob) ([]byte, error) {
+	switch blob.GetEncoding() {
+	case "base64":
+		// GitHub wraps base64 content over multiple lines, which the decoder skips over
+		return base64.StdEncoding.DecodeString(blob.GetContent())
+	case "utf-8", "":
+		return []byte(blob.GetContent()), nil
+	default:
+		return nil, fmt.Errorf("unsupported blob encoding %s", blob.GetEncoding())
+	}
+}
+
+// RefreshRepo recomputes the maintainers of a single repo, leaving what's known about the
+// org's other repos untouched.
+func RefreshRepo(ctx context.Context, gc *gh.ThrottledClient, cache *cache.Cache, store storage.Store, repo *storage.Repo) error {
+	org := &storage.Org{OrgLogin: repo.OrgLogin}
+	users := make(map[string]*storage.User)
+	b := NewBuilder(ctx, gc, cache, store, org, users)
+
+	// start from the org's current maintainers, minus whatever they had in this repo
+	if err := store.QueryMaintainersByOrg(ctx, org.OrgLogin, func(m *storage.Maintainer) error {
+		m.Paths = withoutRepo(m.Paths, repo.RepoName)
+		m.ReviewerPaths = withoutRepo(m.ReviewerPaths, repo.RepoName)
+		b.maintainers[m.UserLogin] = m
+		return nil
+	}); err != nil {
+		return fmt.Errorf("unable to read maintainers of org %s: %v", org.OrgLogin, err)
+	}
+
+	if err := b.AddRepo(repo); err != nil {
+		return err
+	}
+
+	// drop anyone who no longer maintains anything
+	for login, m := range b.maintainers {
+		if len(m.Paths) == 0 && len(m.ReviewerPaths) == 0 && !m.Emeritus {
+			delete(b.maintainers, login)
+		}
+	}
+
+	discoveredUsers := make([]*storage.User, 0, len(users))
+	for _, user := range users {
+		discoveredUsers = append(discoveredUsers, user)
+	}
+
+	if err := cache.WriteUsers(ctx, discoveredUsers); err != nil {
+		return fmt.Errorf("unable to write users: %v", err)
+	}
+
+	return store.WriteAllMaintainers(ctx, org.OrgLogin, b.Maintainers())
+}
+
+// withoutRepo returns the given maintainer paths, minus those within the named repo.
+func withoutRepo(paths []string, repoName string) []string {
+	var result []string
+	for _, p := range paths {
+		if !strings.HasPrefix(p, repoName+"/") {
+			result = append(result, p)
+		}
+	}
+	return result
+}
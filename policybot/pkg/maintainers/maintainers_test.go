@@ -0,0 +1,303 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package maintainers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/bots/policybot/pkg/storage/cache"
+)
+
+// fakeStore implements the parts of storage.Store exercised by the tests
+type fakeStore struct {
+	storage.Store
+
+	codeOwners  []*storage.CodeOwners
+	maintainers []*storage.Maintainer
+	users       []*storage.User
+	teams       []*storage.Team
+	teamMembers []*storage.TeamMember
+}
+
+func (fs *fakeStore) ReadTeamBySlug(_ context.Context, orgLogin string, teamSlug string) (*storage.Team, error) {
+	for _, t := range fs.teams {
+		if t.OrgLogin == orgLogin && t.TeamSlug == teamSlug {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+func (fs *fakeStore) QueryTeamMembers(_ context.Context, orgLogin string, teamID int64, cb func(*storage.TeamMember) error) error {
+	for _, m := range fs.teamMembers {
+		if m.OrgLogin == orgLogin && m.TeamID == teamID {
+			if err := cb(m); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (fs *fakeStore) WriteCodeOwners(_ context.Context, codeOwners []*storage.CodeOwners) error {
+	fs.codeOwners = append(fs.codeOwners, codeOwners...)
+	return nil
+}
+
+func (fs *fakeStore) QueryMaintainersByOrg(_ context.Context, _ string, cb func(*storage.Maintainer) error) error {
+	for _, m := range fs.maintainers {
+		if err := cb(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *fakeStore) WriteAllMaintainers(_ context.Context, _ string, maintainers []*storage.Maintainer) error {
+	fs.maintainers = maintainers
+	return nil
+}
+
+func (fs *fakeStore) ReadUser(_ context.Context, login string) (*storage.User, error) {
+	return &storage.User{UserLogin: login}, nil
+}
+
+func (fs *fakeStore) WriteUsers(_ context.Context, users []*storage.User) error {
+	fs.users = append(fs.users, users...)
+	return nil
+}
+
+func TestAddOWNERSUsesDefaultBranch(t *testing.T) {
+	mux := http.NewServeMux()
+	// the most recent commit in the repo lives on a release branch and must not be used
+	mux.HandleFunc("/repos/istio/istio/commits", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `[{"sha": "release123"}]`)
+	})
+	mux.HandleFunc("/repos/istio/istio/git/trees/release123", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Got tree read for the release branch, expecting the default branch")
+		_, _ = fmt.Fprint(w, `{"sha": "release123", "tree": []}`)
+	})
+	mux.HandleFunc("/repos/istio/istio/branches/main", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"name": "main", "commit": {"sha": "abc123"}}`)
+	})
+	mux.HandleFunc("/repos/istio/istio/git/trees/abc123", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"sha": "abc123", "tree": [{"path": "pilot/OWNERS", "type": "blob", "sha": "def456"}]}`)
+	})
+	mux.HandleFunc("/repos/istio/istio/git/blobs/def456", func(w http.ResponseWriter, r *http.Request) {
+		content := base64.StdEncoding.EncodeToString([]byte("approvers:\n- alice\nreviewers:\n- bob\n"))
+		_, _ = fmt.Fprintf(w, `{"sha": "def456", "encoding": "base64", "content": "%s\n"}`, content)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	users := map[string]*storage.User{"alice": {UserLogin: "alice"}, "bob": {UserLogin: "bob"}}
+	b := NewBuilder(context.Background(), gh.NewThrottledClientForClient(client), nil, nil, &storage.Org{OrgLogin: "istio"}, users)
+
+	repo := &storage.Repo{OrgLogin: "istio", RepoName: "istio", DefaultBranch: "main"}
+	if err := b.AddOWNERS(repo); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	m, ok := b.maintainers["alice"]
+	if !ok {
+		t.Fatalf("Expecting alice to be discovered as a maintainer")
+	}
+
+	if len(m.Paths) != 1 || m.Paths[0] != "istio/pilot/**" {
+		t.Errorf("Got paths %v, expecting [istio/pilot/**]", m.Paths)
+	}
+
+	m, ok = b.maintainers["bob"]
+	if !ok {
+		t.Fatalf("Expecting bob to be discovered as a maintainer")
+	}
+
+	if len(m.Paths) != 0 || len(m.ReviewerPaths) != 1 || m.ReviewerPaths[0] != "istio/pilot/**" {
+		t.Errorf("Got paths %v and reviewer paths %v, expecting [] and [istio/pilot/**]", m.Paths, m.ReviewerPaths)
+	}
+}
+
+func TestAddCODEOWNERSExpandsTeams(t *testing.T) {
+	teamMemberCalls := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/istio/teams/networking", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"id": 7, "slug": "networking"}`)
+	})
+	mux.HandleFunc("/teams/7/members", func(w http.ResponseWriter, r *http.Request) {
+		teamMemberCalls++
+		_, _ = fmt.Fprint(w, `[{"login": "bob"}, {"login": "carol"}]`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{}
+	users := map[string]*storage.User{"alice": {UserLogin: "alice"}}
+	b := NewBuilder(context.Background(), gh.NewThrottledClientForClient(client), nil, store, &storage.Org{OrgLogin: "istio"}, users)
+
+	content := "# a comment\n" +
+		"/pilot/ @alice @istio/networking\n" +
+		"/mixer/ @istio/networking\n"
+	fc := &github.RepositoryContent{Content: &content}
+
+	repo := &storage.Repo{OrgLogin: "istio", RepoName: "istio"}
+	if err := b.AddCODEOWNERS(repo, fc); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	expected := map[string][]string{
+		"alice": {"istio/pilot/**"},
+		"bob":   {"istio/pilot/**", "istio/mixer/**"},
+		"carol": {"istio/pilot/**", "istio/mixer/**"},
+	}
+
+	if len(b.maintainers) != len(expected) {
+		t.Errorf("Got %d maintainers, expecting %d", len(b.maintainers), len(expected))
+	}
+
+	for login, paths := range expected {
+		m, ok := b.maintainers[login]
+		if !ok {
+			t.Errorf("Expecting %s to be discovered as a maintainer", login)
+			continue
+		}
+
+		if fmt.Sprint(m.Paths) != fmt.Sprint(paths) {
+			t.Errorf("Got paths %v for %s, expecting %v", m.Paths, login, paths)
+		}
+	}
+
+	if teamMemberCalls != 1 {
+		t.Errorf("Got %d team member lookups, expecting 1", teamMemberCalls)
+	}
+
+	if len(store.codeOwners) != 1 || store.codeOwners[0].RepoName != "istio" {
+		t.Errorf("Got CODEOWNERS %v written, expecting the file for repo istio", store.codeOwners)
+	}
+}
+
+func TestAddCODEOWNERSUsesSyncedTeams(t *testing.T) {
+	// the team has been synced, so GitHub shouldn't be asked about it
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Got unexpected GitHub request for %s", r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{
+		teams: []*storage.Team{{OrgLogin: "istio", TeamID: 3, TeamSlug: "release-managers"}},
+		teamMembers: []*storage.TeamMember{
+			{OrgLogin: "istio", TeamID: 3, UserLogin: "bob"},
+			{OrgLogin: "istio", TeamID: 3, UserLogin: "carol"},
+		},
+	}
+
+	users := map[string]*storage.User{
+		"alice": {UserLogin: "alice"},
+		"bob":   {UserLogin: "bob"},
+		"carol": {UserLogin: "carol"},
+	}
+	b := NewBuilder(context.Background(), gh.NewThrottledClientForClient(client), nil, store, &storage.Org{OrgLogin: "istio"}, users)
+
+	content := "/release/ @alice @istio/release-managers\n"
+	fc := &github.RepositoryContent{Content: &content}
+
+	repo := &storage.Repo{OrgLogin: "istio", RepoName: "istio"}
+	if err := b.AddCODEOWNERS(repo, fc); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if _, ok := b.maintainers["istio/release-managers"]; ok {
+		t.Errorf("Got the team recorded as a maintainer, expecting its members instead")
+	}
+
+	for _, login := range []string{"alice", "bob", "carol"} {
+		m, ok := b.maintainers[login]
+		if !ok {
+			t.Errorf("Expecting %s to be discovered as a maintainer", login)
+			continue
+		}
+
+		if len(m.Paths) != 1 || m.Paths[0] != "istio/release/**" {
+			t.Errorf("Got paths %v for %s, expecting [istio/release/**]", m.Paths, login)
+		}
+	}
+
+	if len(b.maintainers) != 3 {
+		t.Errorf("Got %d maintainers, expecting 3", len(b.maintainers))
+	}
+}
+
+func TestRefreshRepoKeepsOtherRepos(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/contents/CODEOWNERS", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"type": "file", "name": "CODEOWNERS", "path": "CODEOWNERS", "content": "/galley/ @carol\n"}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{
+		maintainers: []*storage.Maintainer{
+			{OrgLogin: "istio", UserLogin: "alice", Paths: []string{"istio/pilot/**", "proxy/src/**"}},
+			{OrgLogin: "istio", UserLogin: "bob", ReviewerPaths: []string{"istio/mixer/**"}},
+		},
+	}
+
+	repo := &storage.Repo{OrgLogin: "istio", RepoName: "istio"}
+	if err := RefreshRepo(context.Background(), gh.NewThrottledClientForClient(client), cache.New(store, time.Minute), store, repo); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	expected := map[string][]string{
+		"alice": {"proxy/src/**"},
+		"carol": {"istio/galley/**"},
+	}
+
+	if len(store.maintainers) != len(expected) {
+		t.Fatalf("Got maintainers %v, expecting %v", store.maintainers, expected)
+	}
+
+	for _, m := range store.maintainers {
+		if fmt.Sprint(m.Paths) != fmt.Sprint(expected[m.UserLogin]) || len(m.ReviewerPaths) != 0 {
+			t.Errorf("Got paths %v and reviewer paths %v for %s, expecting %v", m.Paths, m.ReviewerPaths, m.UserLogin, expected[m.UserLogin])
+		}
+	}
+}
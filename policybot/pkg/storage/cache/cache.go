@@ -39,6 +39,7 @@ type Cache struct {
 	pullRequestReviewCache        cache.ExpiringCache
 	pipelineCache                 cache.ExpiringCache
 	maintainerCache               cache.ExpiringCache
+	memberCache                   cache.ExpiringCache
 	repoCommentCache              cache.ExpiringCache
 	testResultCache               cache.ExpiringCache
 }
@@ -65,6 +66,7 @@ func New(store storage.Store, entryTTL time.Duration) *Cache {
 		pullRequestReviewCache:        cache.NewTTL(entryTTL, evictionInterval),
 		pipelineCache:                 cache.NewTTL(entryTTL, evictionInterval),
 		maintainerCache:               cache.NewTTL(entryTTL, evictionInterval),
+		memberCache:                   cache.NewTTL(entryTTL, evictionInterval),
 		repoCommentCache:              cache.NewTTL(entryTTL, evictionInterval),
 		testResultCache:               cache.NewTTL(entryTTL, evictionInterval),
 	}
@@ -154,6 +156,28 @@ func (c *Cache) ReadLabel(context context.Context, orgLogin string, repoName str
 	return result, err
 }
 
+// Writes to DB and if successful, updates the cache
+func (c *Cache) WriteLabels(context context.Context, labels []*storage.Label) error {
+	err := c.store.WriteLabels(context, labels)
+	if err == nil {
+		for _, label := range labels {
+			c.labelCache.Set(label.OrgLogin+label.RepoName+label.LabelName, label)
+		}
+	}
+
+	return err
+}
+
+// Deletes from DB and if successful, evicts the label from the cache
+func (c *Cache) DeleteLabel(context context.Context, orgLogin string, repoName string, labelName string) error {
+	err := c.store.DeleteLabel(context, orgLogin, repoName, labelName)
+	if err == nil {
+		c.labelCache.Remove(orgLogin + repoName + labelName)
+	}
+
+	return err
+}
+
 // Reads from cache and if not found reads from DB
 func (c *Cache) ReadIssue(context context.Context, orgLogin string, repoName string, issueNumber int) (*storage.Issue, error) {
 	key := orgLogin + repoName + strconv.Itoa(issueNumber)
@@ -181,6 +205,18 @@ func (c *Cache) WriteIssues(context context.Context, issues []*storage.Issue) er
 	return err
 }
 
+// Deletes from DB and if successful, evicts the issue and its pipeline from the cache
+func (c *Cache) DeleteIssue(context context.Context, orgLogin string, repoName string, issueNumber int64) error {
+	err := c.store.DeleteIssue(context, orgLogin, repoName, issueNumber)
+	if err == nil {
+		key := orgLogin + repoName + strconv.Itoa(int(issueNumber))
+		c.issueCache.Remove(key)
+		c.pipelineCache.Remove(key)
+	}
+
+	return err
+}
+
 // Reads from cache and if not found reads from DB
 func (c *Cache) ReadIssueComment(context context.Context, orgLogin string, repoName string, issueNumber int,
 	issueCommentID int) (*storage.IssueComment, error) {
@@ -197,6 +233,17 @@ func (c *Cache) ReadIssueComment(context context.Context, orgLogin string, repoN
 	return result, err
 }
 
+// Reads from DB and if found, updates the cache. The cache isn't consulted since it's keyed by issue number.
+func (c *Cache) ReadIssueCommentByID(context context.Context, orgLogin string, repoName string,
+	issueCommentID int64) (*storage.IssueComment, error) {
+	result, err := c.store.ReadIssueCommentByID(context, orgLogin, repoName, issueCommentID)
+	if err == nil && result != nil {
+		c.issueCommentCache.Set(orgLogin+repoName+strconv.Itoa(int(result.IssueNumber))+strconv.Itoa(int(issueCommentID)), result)
+	}
+
+	return result, err
+}
+
 // Writes to DB and if successful, updates the cache
 func (c *Cache) WriteIssueComments(context context.Context, issueComments []*storage.IssueComment) error {
 	err := c.store.WriteIssueComments(context, issueComments)
@@ -219,7 +266,7 @@ func (c *Cache) ReadPullRequest(context context.Context, orgLogin string, repoNa
 
 	result, err := c.store.ReadPullRequest(context, orgLogin, repoName, prNumber)
 	if err == nil {
-		c.pullRequestReviewCache.Set(key, result)
+		c.pullRequestCache.Set(key, result)
 	}
 
 	return result, err
@@ -237,6 +284,17 @@ func (c *Cache) WritePullRequests(context context.Context, prs []*storage.PullRe
 	return err
 }
 
+// Deletes from DB and if successful, evicts the comment from the cache
+func (c *Cache) DeleteIssueComment(context context.Context, orgLogin string, repoName string, issueNumber int64,
+	issueCommentID int64) error {
+	err := c.store.DeleteIssueComment(context, orgLogin, repoName, issueNumber, issueCommentID)
+	if err == nil {
+		c.issueCommentCache.Remove(orgLogin + repoName + strconv.Itoa(int(issueNumber)) + strconv.Itoa(int(issueCommentID)))
+	}
+
+	return err
+}
+
 // Reads from cache and if not found reads from DB
 func (c *Cache) ReadPullRequestReviewComment(context context.Context, orgLogin string, repoName string, prNumber int,
 	prCommentID int) (*storage.PullRequestReviewComment, error) {
@@ -268,15 +326,26 @@ func (c *Cache) WritePullRequestReviewComments(context context.Context, prCommen
 	return err
 }
 
+// Deletes from DB and if successful, evicts the comment from the cache
+func (c *Cache) DeletePullRequestReviewComment(context context.Context, orgLogin string, repoName string, prNumber int64,
+	prCommentID int64) error {
+	err := c.store.DeletePullRequestReviewComment(context, orgLogin, repoName, prNumber, prCommentID)
+	if err == nil {
+		c.pullRequestReviewCommentCache.Remove(orgLogin + repoName + strconv.Itoa(int(prNumber)) + strconv.Itoa(int(prCommentID)))
+	}
+
+	return err
+}
+
 // Reads from cache and if not found reads from DB
-func (c *Cache) ReadPullRequestReview(context context.Context, orgLogin string, repoName string, prNumber int,
-	prReviewID int) (*storage.PullRequestReview, error) {
-	key := orgLogin + repoName + strconv.Itoa(prNumber) + strconv.Itoa(prReviewID)
+func (c *Cache) ReadPullRequestReview(context context.Context, orgLogin string, repoName string,
+	reviewID int64) (*storage.PullRequestReview, error) {
+	key := orgLogin + repoName + strconv.Itoa(int(reviewID))
 	if value, ok := c.pullRequestReviewCache.Get(key); ok {
 		return value.(*storage.PullRequestReview), nil
 	}
 
-	result, err := c.store.ReadPullRequestReview(context, orgLogin, repoName, prNumber, prReviewID)
+	result, err := c.store.ReadPullRequestReview(context, orgLogin, repoName, reviewID)
 	if err == nil {
 		c.pullRequestReviewCache.Set(key, result)
 	}
@@ -284,6 +353,17 @@ func (c *Cache) ReadPullRequestReview(context context.Context, orgLogin string,
 	return result, err
 }
 
+// Reads from DB and if found, updates the cache. The cache isn't consulted since it can't tell which review is the latest.
+func (c *Cache) ReadLatestReviewByReviewer(context context.Context, orgLogin string, repoName string, prNumber int64,
+	reviewerLogin string) (*storage.PullRequestReview, error) {
+	result, err := c.store.ReadLatestReviewByReviewer(context, orgLogin, repoName, prNumber, reviewerLogin)
+	if err == nil && result != nil {
+		c.pullRequestReviewCache.Set(orgLogin+repoName+strconv.Itoa(int(result.PullRequestReviewID)), result)
+	}
+
+	return result, err
+}
+
 // Writes to DB and if successful, updates the cache
 func (c *Cache) WritePullRequestReviews(context context.Context, prReviews []*storage.PullRequestReview) error {
 	err := c.store.WritePullRequestReviews(context, prReviews)
@@ -291,7 +371,6 @@ func (c *Cache) WritePullRequestReviews(context context.Context, prReviews []*st
 		for _, review := range prReviews {
 			c.pullRequestReviewCache.Set(review.OrgLogin+
 				review.RepoName+
-				strconv.Itoa(int(review.PullRequestNumber))+
 				strconv.Itoa(int(review.PullRequestReviewID)), review)
 		}
 	}
@@ -362,3 +441,18 @@ func (c *Cache) ReadMaintainer(context context.Context, orgLogin string, userLog
 
 	return result, err
 }
+
+// Reads from cache and if not found reads from DB
+func (c *Cache) ReadMember(context context.Context, orgLogin string, userLogin string) (*storage.Member, error) {
+	key := orgLogin + userLogin
+	if value, ok := c.memberCache.Get(key); ok {
+		return value.(*storage.Member), nil
+	}
+
+	result, err := c.store.ReadMember(context, orgLogin, userLogin)
+	if err == nil {
+		c.memberCache.Set(key, result)
+	}
+
+	return result, err
+}
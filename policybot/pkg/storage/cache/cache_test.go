@@ -0,0 +1,83 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"istio.io/bots/policybot/pkg/storage"
+)
+
+// fakeStore holds reviews keyed by ID and counts the reads which reach it
+type fakeStore struct {
+	storage.Store
+
+	reviews map[int64]*storage.PullRequestReview
+	reads   int
+}
+
+func (fs *fakeStore) ReadPullRequestReview(_ context.Context, _ string, _ string, reviewID int64) (*storage.PullRequestReview, error) {
+	fs.reads++
+	return fs.reviews[reviewID], nil
+}
+
+func (fs *fakeStore) WritePullRequestReviews(_ context.Context, reviews []*storage.PullRequestReview) error {
+	for _, review := range reviews {
+		fs.reviews[review.PullRequestReviewID] = review
+	}
+	return nil
+}
+
+func TestReadPullRequestReview(t *testing.T) {
+	store := &fakeStore{reviews: map[int64]*storage.PullRequestReview{
+		42: {OrgLogin: "istio", RepoName: "istio", PullRequestNumber: 7, PullRequestReviewID: 42, Author: "alice", State: "APPROVED"},
+	}}
+	c := New(store, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		review, err := c.ReadPullRequestReview(context.Background(), "istio", "istio", 42)
+		if err != nil {
+			t.Fatalf("Got error %v, expecting success", err)
+		}
+
+		if review == nil || review.PullRequestNumber != 7 || review.Author != "alice" {
+			t.Errorf("Got review %+v, expecting alice's review of PR 7", review)
+		}
+	}
+
+	if store.reads != 1 {
+		t.Errorf("Got %d reads from the store, expecting the second lookup to be served from the cache", store.reads)
+	}
+
+	// reviews which were just written are found by ID without going back to the store
+	written := &storage.PullRequestReview{OrgLogin: "istio", RepoName: "istio", PullRequestNumber: 8, PullRequestReviewID: 43, Author: "bob"}
+	if err := c.WritePullRequestReviews(context.Background(), []*storage.PullRequestReview{written}); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if review, err := c.ReadPullRequestReview(context.Background(), "istio", "istio", 43); err != nil || review != written {
+		t.Errorf("Got review %+v and error %v, expecting bob's review of PR 8", review, err)
+	}
+
+	if store.reads != 1 {
+		t.Errorf("Got %d reads from the store, expecting the written review to be served from the cache", store.reads)
+	}
+
+	if review, err := c.ReadPullRequestReview(context.Background(), "istio", "istio", 99); err != nil || review != nil {
+		t.Errorf("Got review %+v and error %v for an unknown review, expecting neither", review, err)
+	}
+}
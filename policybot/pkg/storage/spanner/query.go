@@ -18,10 +18,12 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/spanner"
 	"google.golang.org/api/iterator"
 
+	"istio.io/bots/policybot/pkg/codeowners"
 	"istio.io/bots/policybot/pkg/storage"
 )
 
@@ -39,6 +41,26 @@ func (s store) QueryMembersByOrg(context context.Context, orgLogin string, cb fu
 	return err
 }
 
+func (s store) QueryTeamMembers(context context.Context, orgLogin string, teamID int64, cb func(*storage.TeamMember) error) error {
+	sql := `SELECT * FROM TeamMembers
+	WHERE OrgLogin = @orgLogin AND
+	TeamID = @teamID;`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["teamID"] = teamID
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		member := &storage.TeamMember{}
+		if err := row.ToStruct(member); err != nil {
+			return err
+		}
+
+		return cb(member)
+	})
+
+	return err
+}
+
 func (s store) QueryMaintainersByOrg(context context.Context, orgLogin string, cb func(*storage.Maintainer) error) error {
 	iter := s.client.Single().Query(context, spanner.Statement{SQL: fmt.Sprintf("SELECT * FROM Maintainers WHERE OrgLogin = '%s'", orgLogin)})
 	err := iter.Do(func(row *spanner.Row) error {
@@ -68,6 +90,249 @@ func (s store) QueryIssuesByRepo(context context.Context, orgLogin string, repoN
 	return err
 }
 
+func (s store) QueryIssuesUpdatedSince(context context.Context, orgLogin string, repoName string, since time.Time,
+	cb func(*storage.Issue) error) error {
+	sql := `SELECT * FROM Issues
+	WHERE OrgLogin = @orgLogin AND
+	RepoName = @repoName AND
+	UpdatedAt >= @since;`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["repoName"] = repoName
+	stmt.Params["since"] = since
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		issue := &storage.Issue{}
+		if err := row.ToStruct(issue); err != nil {
+			return err
+		}
+
+		return cb(issue)
+	})
+
+	return err
+}
+
+func (s store) QueryIssuesByAssignee(context context.Context, orgLogin string, userLogin string, cb func(*storage.Issue) error) error {
+	sql := `SELECT Issues.* FROM Issues
+	JOIN IssueAssignees ON Issues.OrgLogin = IssueAssignees.OrgLogin AND
+	Issues.RepoName = IssueAssignees.RepoName AND
+	Issues.IssueNumber = IssueAssignees.IssueNumber
+	WHERE IssueAssignees.OrgLogin = @orgLogin AND
+	IssueAssignees.UserLogin = @userLogin;`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["userLogin"] = userLogin
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		issue := &storage.Issue{}
+		if err := row.ToStruct(issue); err != nil {
+			return err
+		}
+
+		return cb(issue)
+	})
+
+	return err
+}
+
+func (s store) QueryPullRequestsByReviewer(context context.Context, orgLogin string, userLogin string,
+	cb func(*storage.PullRequest) error) error {
+	sql := `SELECT PullRequests.* FROM PullRequests
+	JOIN PullRequestReviewers ON PullRequests.OrgLogin = PullRequestReviewers.OrgLogin AND
+	PullRequests.RepoName = PullRequestReviewers.RepoName AND
+	PullRequests.PullRequestNumber = PullRequestReviewers.PullRequestNumber
+	WHERE PullRequestReviewers.OrgLogin = @orgLogin AND
+	PullRequestReviewers.UserLogin = @userLogin;`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["userLogin"] = userLogin
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		pr := &storage.PullRequest{}
+		if err := rowToPullRequest(row, pr); err != nil {
+			return err
+		}
+
+		return cb(pr)
+	})
+
+	return err
+}
+
+func (s store) QueryIssuesByLabel(context context.Context, orgLogin string, repoName string, labelName string, cb func(*storage.Issue) error) error {
+	sql := `SELECT Issues.* FROM Issues
+	JOIN IssueLabels ON Issues.OrgLogin = IssueLabels.OrgLogin AND
+	Issues.RepoName = IssueLabels.RepoName AND
+	Issues.IssueNumber = IssueLabels.IssueNumber
+	WHERE IssueLabels.OrgLogin = @orgLogin AND
+	IssueLabels.RepoName = @repoName AND
+	IssueLabels.LabelName = @labelName;`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["repoName"] = repoName
+	stmt.Params["labelName"] = labelName
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		issue := &storage.Issue{}
+		if err := row.ToStruct(issue); err != nil {
+			return err
+		}
+
+		return cb(issue)
+	})
+
+	return err
+}
+
+func (s store) QueryIssueCommentsByRepo(context context.Context, orgLogin string, repoName string,
+	cb func(*storage.IssueComment) error) error {
+	stmt := spanner.NewStatement("SELECT * FROM IssueComments WHERE OrgLogin = @orgLogin AND RepoName = @repoName;")
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["repoName"] = repoName
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		comment := &storage.IssueComment{}
+		if err := row.ToStruct(comment); err != nil {
+			return err
+		}
+
+		return cb(comment)
+	})
+
+	return err
+}
+
+func (s store) QueryIssueEventsByRepo(context context.Context, orgLogin string, repoName string,
+	cb func(*storage.IssueEvent) error) error {
+	stmt := spanner.NewStatement("SELECT * FROM IssueEvents WHERE OrgLogin = @orgLogin AND RepoName = @repoName;")
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["repoName"] = repoName
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		event := &storage.IssueEvent{}
+		if err := rowToIssueEvent(row, event); err != nil {
+			return err
+		}
+
+		return cb(event)
+	})
+
+	return err
+}
+
+// QueryStaleIssues returns the open issues in a repo which haven't been updated for longer than olderThan, which carry
+// all of the required labels, and which carry none of the excluded labels.
+func (s store) QueryStaleIssues(context context.Context, orgLogin string, repoName string, olderThan time.Duration,
+	requiredLabels []string, excludedLabels []string, cb func(*storage.Issue) error) error {
+	sql := `SELECT Issues.* FROM Issues
+	WHERE Issues.OrgLogin = @orgLogin AND
+	Issues.RepoName = @repoName AND
+	Issues.State = 'open' AND
+	NOT Issues.Deleted AND
+	Issues.UpdatedAt < @before AND
+	(SELECT COUNT(DISTINCT label) FROM UNNEST(Issues.Labels) AS label
+		WHERE label IN UNNEST(@requiredLabels)) = @requiredCount AND
+	NOT EXISTS (SELECT 1 FROM UNNEST(Issues.Labels) AS label
+		WHERE label IN UNNEST(@excludedLabels));`
+
+	// duplicates would otherwise prevent the count of required labels from ever matching
+	required := make(map[string]bool, len(requiredLabels))
+	for _, l := range requiredLabels {
+		required[l] = true
+	}
+
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["repoName"] = repoName
+	stmt.Params["before"] = time.Now().Add(-olderThan)
+	stmt.Params["requiredLabels"] = requiredLabels
+	stmt.Params["requiredCount"] = int64(len(required))
+	stmt.Params["excludedLabels"] = excludedLabels
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		issue := &storage.Issue{}
+		if err := row.ToStruct(issue); err != nil {
+			return err
+		}
+
+		return cb(issue)
+	})
+
+	return err
+}
+
+// QueryIssuesAwaitingResponse returns the open issues in a repo created before the given time which, as of the last
+// computation of issue SLOs, haven't been responded to by an org member.
+func (s store) QueryIssuesAwaitingResponse(context context.Context, orgLogin string, repoName string, before time.Time,
+	cb func(*storage.Issue) error) error {
+	sql := `SELECT Issues.* FROM Issues
+	JOIN IssueSLOs ON Issues.OrgLogin = IssueSLOs.OrgLogin AND
+	Issues.RepoName = IssueSLOs.RepoName AND
+	Issues.IssueNumber = IssueSLOs.IssueNumber
+	WHERE Issues.OrgLogin = @orgLogin AND
+	Issues.RepoName = @repoName AND
+	Issues.State = 'open' AND
+	NOT Issues.Deleted AND
+	Issues.CreatedAt < @before AND
+	IssueSLOs.FirstResponder = '';`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["repoName"] = repoName
+	stmt.Params["before"] = before
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		issue := &storage.Issue{}
+		if err := row.ToStruct(issue); err != nil {
+			return err
+		}
+
+		return cb(issue)
+	})
+
+	return err
+}
+
+// QueryIssuesByActivity returns the open issues in a repo which have seen no activity since the given time. Activity
+// consists of the creation of the issue, comments, and issue events, leaving out anything done by ignoredActor.
+func (s store) QueryIssuesByActivity(context context.Context, orgLogin string, repoName string, ignoredActor string,
+	before time.Time, cb func(*storage.Issue) error) error {
+	sql := `SELECT Issues.* FROM Issues
+	WHERE Issues.OrgLogin = @orgLogin AND
+	Issues.RepoName = @repoName AND
+	Issues.State = 'open' AND
+	NOT Issues.Deleted AND
+	Issues.CreatedAt < @before AND
+	NOT EXISTS (SELECT 1 FROM IssueComments
+		WHERE IssueComments.OrgLogin = Issues.OrgLogin AND
+		IssueComments.RepoName = Issues.RepoName AND
+		IssueComments.IssueNumber = Issues.IssueNumber AND
+		IssueComments.Author != @ignoredActor AND
+		IssueComments.UpdatedAt >= @before) AND
+	NOT EXISTS (SELECT 1 FROM IssueEvents
+		WHERE IssueEvents.OrgLogin = Issues.OrgLogin AND
+		IssueEvents.RepoName = Issues.RepoName AND
+		IssueEvents.IssueNumber = Issues.IssueNumber AND
+		IssueEvents.Actor != @ignoredActor AND
+		IssueEvents.CreatedAt >= @before);`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["repoName"] = repoName
+	stmt.Params["ignoredActor"] = ignoredActor
+	stmt.Params["before"] = before
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		issue := &storage.Issue{}
+		if err := row.ToStruct(issue); err != nil {
+			return err
+		}
+
+		return cb(issue)
+	})
+
+	return err
+}
+
 func (s store) QueryTestResultByTestName(context context.Context, orgLogin string, repoName string, testName string, cb func(*storage.TestResult) error) error {
 	sql := `SELECT * from TestResults
 	WHERE OrgLogin = @orgLogin AND 
@@ -185,6 +450,34 @@ func (s store) QueryTestFlakeIssues(context context.Context, inactiveDays, creat
 	return issues, nil
 }
 
+// QueryContributionCount returns the number of issues authored and pull requests merged by a user across an org
+// before the given time.
+func (s store) QueryContributionCount(context context.Context, orgLogin string, userLogin string, before time.Time) (int64, error) {
+	sql := `SELECT
+	(SELECT COUNT(*) FROM Issues
+		WHERE Issues.OrgLogin = @orgLogin AND
+		Issues.Author = @userLogin AND
+		Issues.CreatedAt < @before) +
+	(SELECT COUNT(*) FROM PullRequests
+		WHERE PullRequests.OrgLogin = @orgLogin AND
+		PullRequests.Author = @userLogin AND
+		PullRequests.MergedAt > @unmerged AND
+		PullRequests.MergedAt < @before);`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["userLogin"] = userLogin
+	stmt.Params["before"] = before
+	stmt.Params["unmerged"] = time.Time{}
+
+	var count int64
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		return row.Column(0, &count)
+	})
+
+	return count, err
+}
+
 func (s store) QueryMaintainerInfo(context context.Context, maintainer *storage.Maintainer) (*storage.MaintainerInfo, error) {
 	info := &storage.MaintainerInfo{
 		Repos: make(map[string]*storage.RepoActivityInfo),
@@ -220,14 +513,14 @@ func (s store) QueryMaintainerInfo(context context.Context, maintainer *storage.
 		err := iter.Do(func(row *spanner.Row) error {
 
 			var pr storage.PullRequest
-			if err := row.ToStruct(&pr); err != nil {
+			if err := rowToPullRequest(row, &pr); err != nil {
 				return err
 			}
 
 			// if the pr affects any files in any of the maintainer's paths, update the timed entry for the path
 			for sp := range soughtPaths[repoName] {
 				for _, file := range pr.Files {
-					if strings.HasPrefix(file, sp) {
+					if codeowners.Match(sp, file) {
 						repoInfo.LastPullRequestCommittedByPath[sp] = storage.TimedEntry{
 							Time: pr.MergedAt,
 							ID:   pr.PullRequestNumber,
@@ -258,3 +551,24 @@ func (s store) QueryMaintainerInfo(context context.Context, maintainer *storage.
 
 	return info, nil
 }
+
+func (s store) QueryWebhookPayloads(context context.Context, from time.Time, to time.Time, cb func(*storage.WebhookPayload) error) error {
+	sql := `SELECT * from WebhookPayloads
+	WHERE ReceivedAt >= @from AND
+	ReceivedAt < @to
+	ORDER BY ReceivedAt;`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["from"] = from
+	stmt.Params["to"] = to
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		payload := &storage.WebhookPayload{}
+		if err := row.ToStruct(payload); err != nil {
+			return err
+		}
+
+		return cb(payload)
+	})
+
+	return err
+}
@@ -88,6 +88,31 @@ func (s store) ReadIssueComment(context context.Context, orgLogin string, repoNa
 	return &result, nil
 }
 
+func (s store) ReadIssueCommentByID(context context.Context, orgLogin string, repoName string, issueCommentID int64) (*storage.IssueComment, error) {
+	sql := `SELECT * FROM IssueComments
+	WHERE OrgLogin = @orgLogin AND
+	RepoName = @repoName AND
+	IssueCommentID = @issueCommentID
+	LIMIT 1;`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["repoName"] = repoName
+	stmt.Params["issueCommentID"] = issueCommentID
+
+	var result *storage.IssueComment
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		result = &storage.IssueComment{}
+		return row.ToStruct(result)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
 func (s store) ReadIssuePipeline(context context.Context, orgLogin string, repoName string, issueNumber int) (*storage.IssuePipeline, error) {
 	row, err := s.client.Single().ReadRow(context, issuePipelineTable, issuePipelineKey(orgLogin, repoName, int64(issueNumber)), issuePipelineColumns)
 	if spanner.ErrCode(err) == codes.NotFound {
@@ -97,7 +122,7 @@ func (s store) ReadIssuePipeline(context context.Context, orgLogin string, repoN
 	}
 
 	var result storage.IssuePipeline
-	if err := row.ToStruct(&result); err != nil {
+	if err := rowToIssuePipeline(row, &result); err != nil {
 		return nil, err
 	}
 
@@ -113,7 +138,7 @@ func (s store) ReadPullRequest(context context.Context, orgLogin string, repoNam
 	}
 
 	var result storage.PullRequest
-	if err := row.ToStruct(&result); err != nil {
+	if err := rowToPullRequest(row, &result); err != nil {
 		return nil, err
 	}
 
@@ -138,17 +163,114 @@ func (s store) ReadPullRequestReviewComment(context context.Context, orgLogin st
 	return &result, nil
 }
 
-func (s store) ReadPullRequestReview(context context.Context, orgLogin string, repoName string, issueNumber int,
-	pullRequestReviewID int) (*storage.PullRequestReview, error) {
-	row, err := s.client.Single().ReadRow(context, pullRequestReviewTable, pullRequestReviewKey(orgLogin, repoName,
-		int64(issueNumber), int64(pullRequestReviewID)), pullRequestReviewColumns)
+func (s store) ReadPullRequestReview(context context.Context, orgLogin string, repoName string,
+	reviewID int64) (*storage.PullRequestReview, error) {
+	// review IDs are unique, but reviews are keyed by PR so they need to be looked up
+	sql := `SELECT * FROM PullRequestReviews
+	WHERE OrgLogin = @orgLogin AND
+	RepoName = @repoName AND
+	PullRequestReviewID = @reviewID
+	LIMIT 1;`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["repoName"] = repoName
+	stmt.Params["reviewID"] = reviewID
+
+	var result *storage.PullRequestReview
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		result = &storage.PullRequestReview{}
+		return row.ToStruct(result)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s store) ReadLatestIssuePipelineEvent(context context.Context, orgLogin string, repoName string,
+	issueNumber int64) (*storage.IssuePipelineEvent, error) {
+	sql := `SELECT * FROM IssuePipelineEvents
+	WHERE OrgLogin = @orgLogin AND
+	RepoName = @repoName AND
+	IssueNumber = @issueNumber
+	ORDER BY CreatedAt DESC
+	LIMIT 1;`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["repoName"] = repoName
+	stmt.Params["issueNumber"] = issueNumber
+
+	var result *storage.IssuePipelineEvent
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		result = &storage.IssuePipelineEvent{}
+		return row.ToStruct(result)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s store) ReadLatestReviewByReviewer(context context.Context, orgLogin string, repoName string, prNumber int64,
+	reviewerLogin string) (*storage.PullRequestReview, error) {
+	sql := `SELECT * FROM PullRequestReviews
+	WHERE OrgLogin = @orgLogin AND
+	RepoName = @repoName AND
+	PullRequestNumber = @prNumber AND
+	Author = @reviewerLogin
+	ORDER BY SubmittedAt DESC
+	LIMIT 1;`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["repoName"] = repoName
+	stmt.Params["prNumber"] = prNumber
+	stmt.Params["reviewerLogin"] = reviewerLogin
+
+	var result *storage.PullRequestReview
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		result = &storage.PullRequestReview{}
+		return row.ToStruct(result)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s store) ReadCodeOwners(context context.Context, orgLogin string, repoName string) (*storage.CodeOwners, error) {
+	row, err := s.client.Single().ReadRow(context, codeOwnersTable, codeOwnersKey(orgLogin, repoName), codeOwnersColumns)
+	if spanner.ErrCode(err) == codes.NotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var result storage.CodeOwners
+	if err := row.ToStruct(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s store) ReadCombinedStatus(context context.Context, orgLogin string, repoName string, sha string) (*storage.CombinedStatus, error) {
+	row, err := s.client.Single().ReadRow(context, combinedStatusTable, combinedStatusKey(orgLogin, repoName, sha), combinedStatusColumns)
 	if spanner.ErrCode(err) == codes.NotFound {
 		return nil, nil
 	} else if err != nil {
 		return nil, err
 	}
 
-	var result storage.PullRequestReview
+	var result storage.CombinedStatus
 	if err := row.ToStruct(&result); err != nil {
 		return nil, err
 	}
@@ -197,6 +319,73 @@ func (s store) ReadBotActivity(context context.Context, orgLogin string, repoNam
 	}
 
 	var result storage.BotActivity
+	if err := rowToBotActivity(row, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s store) ReadFirstInteraction(context context.Context, orgLogin string, repoName string,
+	userLogin string) (*storage.FirstInteraction, error) {
+	row, err := s.client.Single().ReadRow(context, firstInteractionTable, firstInteractionKey(orgLogin, repoName, userLogin), firstInteractionColumns)
+	if spanner.ErrCode(err) == codes.NotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var result storage.FirstInteraction
+	if err := row.ToStruct(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s store) ReadCherryPick(context context.Context, orgLogin string, repoName string, prNumber int64,
+	targetBranch string) (*storage.CherryPick, error) {
+	row, err := s.client.Single().ReadRow(context, cherryPickTable, cherryPickKey(orgLogin, repoName, prNumber, targetBranch), cherryPickColumns)
+	if spanner.ErrCode(err) == codes.NotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var result storage.CherryPick
+	if err := row.ToStruct(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s store) ReadETag(context context.Context, url string) (*storage.ETag, error) {
+	row, err := s.client.Single().ReadRow(context, etagTable, etagKey(url), etagColumns)
+	if spanner.ErrCode(err) == codes.NotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var result storage.ETag
+	if err := row.ToStruct(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s store) ReadIssueTransfer(context context.Context, orgLogin string, repoName string,
+	issueNumber int64) (*storage.IssueTransfer, error) {
+	row, err := s.client.Single().ReadRow(context, issueTransferTable, issueKey(orgLogin, repoName, issueNumber), issueTransferColumns)
+	if spanner.ErrCode(err) == codes.NotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var result storage.IssueTransfer
 	if err := row.ToStruct(&result); err != nil {
 		return nil, err
 	}
@@ -235,3 +424,42 @@ func (s store) ReadMaintainer(context context.Context, orgLogin string, userLogi
 
 	return &result, nil
 }
+
+func (s store) ReadMember(context context.Context, orgLogin string, userLogin string) (*storage.Member, error) {
+	row, err := s.client.Single().ReadRow(context, memberTable, memberKey(orgLogin, userLogin), memberColumns)
+	if spanner.ErrCode(err) == codes.NotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var result storage.Member
+	if err := row.ToStruct(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s store) ReadTeamBySlug(context context.Context, orgLogin string, teamSlug string) (*storage.Team, error) {
+	sql := `SELECT * FROM Teams
+	WHERE OrgLogin = @orgLogin AND
+	TeamSlug = @teamSlug
+	LIMIT 1;`
+	stmt := spanner.NewStatement(sql)
+	stmt.Params["orgLogin"] = orgLogin
+	stmt.Params["teamSlug"] = teamSlug
+
+	var result *storage.Team
+	iter := s.client.Single().Query(context, stmt)
+	err := iter.Do(func(row *spanner.Row) error {
+		result = &storage.Team{}
+		return row.ToStruct(result)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -31,22 +31,41 @@ const (
 	repoCommentTable                   = "RepoComments"
 	userTable                          = "Users"
 	labelTable                         = "Labels"
+	milestoneTable                     = "Milestones"
+	releaseTable                       = "Releases"
+	codeOwnersTable                    = "CodeOwners"
+	combinedStatusTable                = "CombinedStatuses"
+	checkRunTable                      = "CheckRuns"
 	issueTable                         = "Issues"
 	issueCommentTable                  = "IssueComments"
 	issuePipelineTable                 = "IssuePipelines"
+	issuePipelineEventTable            = "IssuePipelineEvents"
+	issueAssigneeTable                 = "IssueAssignees"
+	issueLabelTable                    = "IssueLabels"
+	issueEpicTable                     = "IssueEpics"
+	epicTable                          = "Epics"
 	pullRequestTable                   = "PullRequests"
+	pullRequestReviewerTable           = "PullRequestReviewers"
 	pullRequestReviewCommentTable      = "PullRequestReviewComments"
 	pullRequestReviewTable             = "PullRequestReviews"
 	memberTable                        = "Members"
+	teamTable                          = "Teams"
+	teamMemberTable                    = "TeamMembers"
 	botActivityTable                   = "BotActivity"
 	maintainerTable                    = "Maintainers"
+	firstInteractionTable              = "FirstInteractions"
+	cherryPickTable                    = "CherryPicks"
 	issueEventTable                    = "IssueEvents"
+	issueSLOTable                      = "IssueSLOs"
+	issueTransferTable                 = "IssueTransfers"
 	issueCommentEventTable             = "IssueCommentEvents"
 	pullRequestEventTable              = "PullRequestEvents"
 	pullRequestReviewCommentEventTable = "PullRequestReviewCommentEvents"
 	pullRequestReviewEventTable        = "PullRequestReviewEvents"
 	repoCommentEventTable              = "RepoCommentEvents"
 	testResultTable                    = "TestResults"
+	webhookPayloadTable                = "WebhookPayloads"
+	etagTable                          = "ETags"
 )
 
 // Holds the column names for each table or index in the database (filled in at startup)
@@ -62,8 +81,15 @@ var (
 	pullRequestReviewCommentColumns []string
 	pullRequestReviewColumns        []string
 	botActivityColumns              []string
+	firstInteractionColumns         []string
+	cherryPickColumns               []string
+	issueTransferColumns            []string
+	etagColumns                     []string
 	maintainerColumns               []string
+	memberColumns                   []string
 	testResultColumns               []string
+	codeOwnersColumns               []string
+	combinedStatusColumns           []string
 )
 
 // Bunch of functions to from keys for the tables and indices in the DB
@@ -84,6 +110,10 @@ func labelKey(orgLogin string, repoName string, labelName string) spanner.Key {
 	return spanner.Key{orgLogin, repoName, labelName}
 }
 
+func etagKey(url string) spanner.Key {
+	return spanner.Key{url}
+}
+
 func issueKey(orgLogin string, repoName string, issueNumber int64) spanner.Key {
 	return spanner.Key{orgLogin, repoName, issueNumber}
 }
@@ -104,18 +134,38 @@ func pullRequestReviewCommentKey(orgLogin string, repoName string, prNumber int6
 	return spanner.Key{orgLogin, repoName, prNumber, commentID}
 }
 
-func pullRequestReviewKey(orgLogin string, repoName string, prNumber int64, reviewID int64) spanner.Key {
-	return spanner.Key{orgLogin, repoName, prNumber, reviewID}
-}
-
 func botActivityKey(orgLogin string, repoName string) spanner.Key {
 	return spanner.Key{orgLogin, repoName}
 }
 
+func firstInteractionKey(orgLogin string, repoName string, userLogin string) spanner.Key {
+	return spanner.Key{orgLogin, repoName, userLogin}
+}
+
+func cherryPickKey(orgLogin string, repoName string, prNumber int64, targetBranch string) spanner.Key {
+	return spanner.Key{orgLogin, repoName, prNumber, targetBranch}
+}
+
 func maintainerKey(orgLogin string, userLogin string) spanner.Key {
 	return spanner.Key{orgLogin, userLogin}
 }
 
+func memberKey(orgLogin string, userLogin string) spanner.Key {
+	return spanner.Key{orgLogin, userLogin}
+}
+
+func milestoneKey(orgLogin string, repoName string, milestoneNumber int64) spanner.Key {
+	return spanner.Key{orgLogin, repoName, milestoneNumber}
+}
+
+func codeOwnersKey(orgLogin string, repoName string) spanner.Key {
+	return spanner.Key{orgLogin, repoName}
+}
+
+func combinedStatusKey(orgLogin string, repoName string, sha string) spanner.Key {
+	return spanner.Key{orgLogin, repoName, sha}
+}
+
 func testResultKey(orgLogin string, repoName string, testName string, prNum int64, runNumber int64) spanner.Key {
 	return spanner.Key{orgLogin, repoName, testName, prNum, runNumber}
 }
@@ -131,8 +181,15 @@ func init() {
 	pullRequestColumns = getFields(storage.PullRequest{})
 	pullRequestReviewColumns = getFields(storage.PullRequestReview{})
 	botActivityColumns = getFields(storage.BotActivity{})
+	firstInteractionColumns = getFields(storage.FirstInteraction{})
+	cherryPickColumns = getFields(storage.CherryPick{})
+	issueTransferColumns = getFields(storage.IssueTransfer{})
+	etagColumns = getFields(storage.ETag{})
 	maintainerColumns = getFields(storage.Maintainer{})
+	memberColumns = getFields(storage.Member{})
 	testResultColumns = getFields(storage.TestResult{})
+	codeOwnersColumns = getFields(storage.CodeOwners{})
+	combinedStatusColumns = getFields(storage.CombinedStatus{})
 }
 
 // Produces a string array representing all the fields in the input object
@@ -145,3 +202,100 @@ func getFields(o interface{}) []string {
 
 	return result
 }
+
+// issueEventRow decodes a row of the IssueEvents table, whose Label and Assignee columns are NULL
+// for events recorded before they were introduced.
+type issueEventRow struct {
+	storage.IssueEvent
+	Label    spanner.NullString
+	Assignee spanner.NullString
+}
+
+// Decodes an IssueEvents row, leaving a NULL label or assignee empty.
+func rowToIssueEvent(row *spanner.Row, event *storage.IssueEvent) error {
+	var r issueEventRow
+	if err := row.ToStruct(&r); err != nil {
+		return err
+	}
+
+	*event = r.IssueEvent
+	event.Label = r.Label.StringVal
+	event.Assignee = r.Assignee.StringVal
+
+	return nil
+}
+
+// issuePipelineRow decodes a row of the IssuePipelines table, whose Estimate column is NULL for
+// pipelines recorded before it was introduced.
+type issuePipelineRow struct {
+	storage.IssuePipeline
+	Estimate spanner.NullInt64
+}
+
+// Decodes an IssuePipelines row, leaving a NULL estimate as 0.
+func rowToIssuePipeline(row *spanner.Row, pipeline *storage.IssuePipeline) error {
+	var r issuePipelineRow
+	if err := row.ToStruct(&r); err != nil {
+		return err
+	}
+
+	*pipeline = r.IssuePipeline
+	pipeline.Estimate = r.Estimate.Int64
+
+	return nil
+}
+
+// botActivityRow decodes a row of the BotActivity table, whose ZenHub watermark and page columns are
+// NULL for repos that were tracked before they were introduced.
+type botActivityRow struct {
+	storage.BotActivity
+	LastZenHubSyncStart              spanner.NullTime
+	LastIssuePage                    spanner.NullInt64
+	LastIssueCommentPage             spanner.NullInt64
+	LastPullRequestPage              spanner.NullInt64
+	LastPullRequestReviewCommentPage spanner.NullInt64
+}
+
+// Decodes a BotActivity row, leaving a NULL ZenHub watermark as the zero time and NULL pages as 0.
+func rowToBotActivity(row *spanner.Row, activity *storage.BotActivity) error {
+	var r botActivityRow
+	if err := row.ToStruct(&r); err != nil {
+		return err
+	}
+
+	*activity = r.BotActivity
+	activity.LastZenHubSyncStart = r.LastZenHubSyncStart.Time
+	activity.LastIssuePage = r.LastIssuePage.Int64
+	activity.LastIssueCommentPage = r.LastIssueCommentPage.Int64
+	activity.LastPullRequestPage = r.LastPullRequestPage.Int64
+	activity.LastPullRequestReviewCommentPage = r.LastPullRequestReviewCommentPage.Int64
+
+	return nil
+}
+
+// pullRequestRow decodes a row of the PullRequests table. The columns below were added after the
+// table was first populated and are NULL in older rows, which ToStruct refuses to decode into the
+// plain fields of storage.PullRequest.
+type pullRequestRow struct {
+	storage.PullRequest
+	MergedBy       spanner.NullString
+	MergeCommitSHA spanner.NullString
+	Draft          spanner.NullBool
+	Mergeable      spanner.NullBool
+}
+
+// Decodes a PullRequests row, leaving NULL columns with their zero value.
+func rowToPullRequest(row *spanner.Row, pr *storage.PullRequest) error {
+	var r pullRequestRow
+	if err := row.ToStruct(&r); err != nil {
+		return err
+	}
+
+	*pr = r.PullRequest
+	pr.MergedBy = r.MergedBy.StringVal
+	pr.MergeCommitSHA = r.MergeCommitSHA.StringVal
+	pr.Draft = r.Draft.Bool
+	pr.Mergeable = r.Mergeable.Bool
+
+	return nil
+}
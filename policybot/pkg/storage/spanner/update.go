@@ -16,6 +16,7 @@ package spanner
 
 import (
 	"context"
+	"time"
 
 	"google.golang.org/grpc/codes"
 
@@ -37,7 +38,7 @@ func (s store) UpdateBotActivity(ctx1 context.Context, orgLogin string, repoName
 			result.RepoName = repoName
 		} else if err != nil {
 			return err
-		} else if err = row.ToStruct(&result); err != nil {
+		} else if err = rowToBotActivity(row, &result); err != nil {
 			return err
 		}
 
@@ -57,3 +58,106 @@ func (s store) UpdateBotActivity(ctx1 context.Context, orgLogin string, repoName
 
 	return err
 }
+
+func (s store) MarkIssuesDeleted(context context.Context, orgLogin string, repoName string, issueNumbers []int64) error {
+	scope.Debugf("Marking %d issues as deleted in repo %s/%s", len(issueNumbers), orgLogin, repoName)
+
+	now := time.Now()
+	mutations := make([]*spanner.Mutation, len(issueNumbers))
+	for i, number := range issueNumbers {
+		mutations[i] = spanner.Update(issueTable,
+			[]string{"OrgLogin", "RepoName", "IssueNumber", "Deleted", "DeletedAt"},
+			[]interface{}{orgLogin, repoName, number, true, now})
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+// DeleteIssue removes an issue along with its comments, events, and pipeline history. The issue's
+// assignees and labels are interleaved in the issue's row and so go away with it.
+func (s store) DeleteIssue(ctx1 context.Context, orgLogin string, repoName string, issueNumber int64) error {
+	scope.Debugf("Deleting issue %d in repo %s/%s", issueNumber, orgLogin, repoName)
+
+	_, err := s.client.ReadWriteTransaction(ctx1, func(ctx2 context.Context, txn *spanner.ReadWriteTransaction) error {
+		mutations := []*spanner.Mutation{
+			spanner.Delete(issueTable, issueKey(orgLogin, repoName, issueNumber)),
+			spanner.Delete(issuePipelineTable, issuePipelineKey(orgLogin, repoName, issueNumber)),
+			spanner.Delete(issuePipelineEventTable, issueKey(orgLogin, repoName, issueNumber).AsPrefix()),
+			spanner.Delete(issueCommentTable, issueKey(orgLogin, repoName, issueNumber).AsPrefix()),
+			spanner.Delete(issueCommentEventTable, issueKey(orgLogin, repoName, issueNumber).AsPrefix()),
+		}
+
+		// issue events are keyed by time rather than by issue, so they need to be looked up
+		stmt := spanner.NewStatement(`SELECT CreatedAt FROM IssueEvents
+		WHERE OrgLogin = @orgLogin AND RepoName = @repoName AND IssueNumber = @issueNumber`)
+		stmt.Params["orgLogin"] = orgLogin
+		stmt.Params["repoName"] = repoName
+		stmt.Params["issueNumber"] = issueNumber
+
+		if err := txn.Query(ctx2, stmt).Do(func(row *spanner.Row) error {
+			var createdAt time.Time
+			if err := row.Column(0, &createdAt); err != nil {
+				return err
+			}
+
+			mutations = append(mutations, spanner.Delete(issueEventTable, spanner.Key{orgLogin, repoName, createdAt}))
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		return txn.BufferWrite(mutations)
+	})
+
+	return err
+}
+
+func (s store) DeleteIssuePipelines(context context.Context, orgLogin string, repoName string, issueNumbers []int64) error {
+	scope.Debugf("Deleting %d issue pipelines in repo %s/%s", len(issueNumbers), orgLogin, repoName)
+
+	mutations := make([]*spanner.Mutation, len(issueNumbers))
+	for i, number := range issueNumbers {
+		mutations[i] = spanner.Delete(issuePipelineTable, issuePipelineKey(orgLogin, repoName, number))
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+func (s store) DeleteMilestones(context context.Context, orgLogin string, repoName string, milestoneNumbers []int64) error {
+	scope.Debugf("Deleting %d milestones in repo %s/%s", len(milestoneNumbers), orgLogin, repoName)
+
+	mutations := make([]*spanner.Mutation, len(milestoneNumbers))
+	for i, number := range milestoneNumbers {
+		mutations[i] = spanner.Delete(milestoneTable, milestoneKey(orgLogin, repoName, number))
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+func (s store) DeleteLabel(context context.Context, orgLogin string, repoName string, labelName string) error {
+	scope.Debugf("Deleting label %s in repo %s/%s", labelName, orgLogin, repoName)
+
+	_, err := s.client.Apply(context, []*spanner.Mutation{spanner.Delete(labelTable, labelKey(orgLogin, repoName, labelName))})
+	return err
+}
+
+func (s store) DeleteIssueComment(context context.Context, orgLogin string, repoName string, issueNumber int64, commentID int64) error {
+	scope.Debugf("Deleting comment %d on issue %d in repo %s/%s", commentID, issueNumber, orgLogin, repoName)
+
+	_, err := s.client.Apply(context, []*spanner.Mutation{
+		spanner.Delete(issueCommentTable, issueCommentKey(orgLogin, repoName, issueNumber, commentID)),
+	})
+	return err
+}
+
+func (s store) DeletePullRequestReviewComment(context context.Context, orgLogin string, repoName string, prNumber int64, commentID int64) error {
+	scope.Debugf("Deleting review comment %d on PR %d in repo %s/%s", commentID, prNumber, orgLogin, repoName)
+
+	_, err := s.client.Apply(context, []*spanner.Mutation{
+		spanner.Delete(pullRequestReviewCommentTable, pullRequestReviewCommentKey(orgLogin, repoName, prNumber, commentID)),
+	})
+	return err
+}
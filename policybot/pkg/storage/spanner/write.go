@@ -82,6 +82,122 @@ func (s store) WriteIssues(context context.Context, issues []*storage.Issue) err
 	return err
 }
 
+// WriteAllIssueAssignees replaces the recorded assignees of each of the given issues with the issue's current assignees.
+func (s store) WriteAllIssueAssignees(context context.Context, issues []*storage.Issue) error {
+	scope.Debugf("Writing assignees for %d issues", len(issues))
+
+	var mutations []*spanner.Mutation
+	for _, issue := range issues {
+		// mutations are applied in order, so stale assignees are removed before the current ones are written
+		mutations = append(mutations, spanner.Delete(issueAssigneeTable,
+			issueKey(issue.OrgLogin, issue.RepoName, issue.IssueNumber).AsPrefix()))
+
+		for _, assignee := range issue.Assignees {
+			m, err := spanner.InsertOrUpdateStruct(issueAssigneeTable, &storage.IssueAssignee{
+				OrgLogin:    issue.OrgLogin,
+				RepoName:    issue.RepoName,
+				IssueNumber: issue.IssueNumber,
+				UserLogin:   assignee,
+			})
+			if err != nil {
+				return err
+			}
+			mutations = append(mutations, m)
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+// WriteAllPullRequestReviewers replaces the recorded reviewers of each of the given PRs with the PR's currently
+// requested reviewers.
+func (s store) WriteAllPullRequestReviewers(context context.Context, prs []*storage.PullRequest) error {
+	scope.Debugf("Writing reviewers for %d pull requests", len(prs))
+
+	var mutations []*spanner.Mutation
+	for _, pr := range prs {
+		// mutations are applied in order, so stale reviewers are removed before the current ones are written
+		mutations = append(mutations, spanner.Delete(pullRequestReviewerTable,
+			pullRequestKey(pr.OrgLogin, pr.RepoName, pr.PullRequestNumber).AsPrefix()))
+
+		for _, reviewer := range pr.RequestedReviewers {
+			m, err := spanner.InsertOrUpdateStruct(pullRequestReviewerTable, &storage.PullRequestReviewer{
+				OrgLogin:          pr.OrgLogin,
+				RepoName:          pr.RepoName,
+				PullRequestNumber: pr.PullRequestNumber,
+				UserLogin:         reviewer,
+			})
+			if err != nil {
+				return err
+			}
+			mutations = append(mutations, m)
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+// WriteAllIssueLabels replaces the recorded labels of each of the given issues with the issue's current labels.
+func (s store) WriteAllIssueLabels(context context.Context, issues []*storage.Issue) error {
+	scope.Debugf("Writing labels for %d issues", len(issues))
+
+	var mutations []*spanner.Mutation
+	for _, issue := range issues {
+		// mutations are applied in order, so stale labels are removed before the current ones are written
+		mutations = append(mutations, spanner.Delete(issueLabelTable,
+			issueKey(issue.OrgLogin, issue.RepoName, issue.IssueNumber).AsPrefix()))
+
+		for _, label := range issue.Labels {
+			m, err := spanner.InsertOrUpdateStruct(issueLabelTable, &storage.IssueLabel{
+				OrgLogin:    issue.OrgLogin,
+				RepoName:    issue.RepoName,
+				IssueNumber: issue.IssueNumber,
+				LabelName:   label,
+			})
+			if err != nil {
+				return err
+			}
+			mutations = append(mutations, m)
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+// WriteAllEpics replaces the recorded epics of a repo along with their child issues.
+func (s store) WriteAllEpics(context context.Context, orgLogin string, repoName string, epics []*storage.Epic,
+	children []*storage.IssueEpic) error {
+	scope.Debugf("Writing %d epics with %d child issues for repo %s/%s", len(epics), len(children), orgLogin, repoName)
+
+	// both tables are replaced in a single batch, such that the recorded children always match the recorded epics
+	mutations := []*spanner.Mutation{
+		spanner.Delete(epicTable, repoKey(orgLogin, repoName).AsPrefix()),
+		spanner.Delete(issueEpicTable, repoKey(orgLogin, repoName).AsPrefix()),
+	}
+
+	for _, epic := range epics {
+		m, err := spanner.InsertOrUpdateStruct(epicTable, epic)
+		if err != nil {
+			return err
+		}
+		mutations = append(mutations, m)
+	}
+
+	for _, child := range children {
+		m, err := spanner.InsertOrUpdateStruct(issueEpicTable, child)
+		if err != nil {
+			return err
+		}
+		mutations = append(mutations, m)
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
 func (s store) WriteIssueComments(context context.Context, issueComments []*storage.IssueComment) error {
 	scope.Debugf("Writing %d issue comments", len(issueComments))
 
@@ -97,6 +213,21 @@ func (s store) WriteIssueComments(context context.Context, issueComments []*stor
 	return err
 }
 
+func (s store) WriteIssuePipelineEvents(context context.Context, events []*storage.IssuePipelineEvent) error {
+	scope.Debugf("Writing %d issue pipeline events", len(events))
+
+	mutations := make([]*spanner.Mutation, len(events))
+	for i := 0; i < len(events); i++ {
+		var err error
+		if mutations[i], err = spanner.InsertOrUpdateStruct(issuePipelineEventTable, events[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
 func (s store) WriteIssuePipelines(context context.Context, issuePipelines []*storage.IssuePipeline) error {
 	scope.Debugf("Writing %d issue pipelines", len(issuePipelines))
 
@@ -187,8 +318,83 @@ func (s store) WriteLabels(context context.Context, labels []*storage.Label) err
 	return err
 }
 
-func (s store) WriteAllMembers(ctx1 context.Context, members []*storage.Member) error {
-	scope.Debugf("Writing %d members", len(members))
+func (s store) WriteMilestones(context context.Context, milestones []*storage.Milestone) error {
+	scope.Debugf("Writing %d milestones", len(milestones))
+
+	mutations := make([]*spanner.Mutation, len(milestones))
+	for i := 0; i < len(milestones); i++ {
+		var err error
+		if mutations[i], err = spanner.InsertOrUpdateStruct(milestoneTable, milestones[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+func (s store) WriteReleases(context context.Context, releases []*storage.Release) error {
+	scope.Debugf("Writing %d releases", len(releases))
+
+	mutations := make([]*spanner.Mutation, len(releases))
+	for i := 0; i < len(releases); i++ {
+		var err error
+		if mutations[i], err = spanner.InsertOrUpdateStruct(releaseTable, releases[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+func (s store) WriteCombinedStatuses(context context.Context, statuses []*storage.CombinedStatus) error {
+	scope.Debugf("Writing %d combined statuses", len(statuses))
+
+	mutations := make([]*spanner.Mutation, len(statuses))
+	for i := 0; i < len(statuses); i++ {
+		var err error
+		if mutations[i], err = spanner.InsertOrUpdateStruct(combinedStatusTable, statuses[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+func (s store) WriteCheckRuns(context context.Context, checkRuns []*storage.CheckRun) error {
+	scope.Debugf("Writing %d check runs", len(checkRuns))
+
+	mutations := make([]*spanner.Mutation, len(checkRuns))
+	for i := 0; i < len(checkRuns); i++ {
+		var err error
+		if mutations[i], err = spanner.InsertOrUpdateStruct(checkRunTable, checkRuns[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+func (s store) WriteCodeOwners(context context.Context, codeOwners []*storage.CodeOwners) error {
+	scope.Debugf("Writing %d CODEOWNERS files", len(codeOwners))
+
+	mutations := make([]*spanner.Mutation, len(codeOwners))
+	for i := 0; i < len(codeOwners); i++ {
+		var err error
+		if mutations[i], err = spanner.InsertOrUpdateStruct(codeOwnersTable, codeOwners[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+func (s store) WriteAllMembers(ctx1 context.Context, orgLogins []string, members []*storage.Member) error {
+	scope.Debugf("Writing %d members for orgs %v", len(members), orgLogins)
 
 	mutations := make([]*spanner.Mutation, len(members))
 	for i, member := range members {
@@ -199,21 +405,68 @@ func (s store) WriteAllMembers(ctx1 context.Context, members []*storage.Member)
 	}
 
 	_, err := s.client.ReadWriteTransaction(ctx1, func(ctx2 context.Context, txn *spanner.ReadWriteTransaction) error {
-		// Remove all existing members
-		iter := txn.Query(ctx2, spanner.Statement{SQL: "DELETE FROM Members WHERE true;"})
+		// Remove all existing members of the given orgs, even those which have no members left, such that
+		// orgs can be refreshed independently
+		for _, org := range orgLogins {
+			stmt := spanner.NewStatement("DELETE FROM Members WHERE OrgLogin = @orgLogin;")
+			stmt.Params["orgLogin"] = org
+			iter := txn.Query(ctx2, stmt)
+			if err := iter.Do(func(_ *spanner.Row) error { return nil }); err != nil {
+				return err
+			}
+		}
+
+		// write all the new members
+		return txn.BufferWrite(mutations)
+	})
+
+	return err
+}
+
+func (s store) WriteTeams(context context.Context, teams []*storage.Team) error {
+	scope.Debugf("Writing %d teams", len(teams))
+
+	mutations := make([]*spanner.Mutation, len(teams))
+	for i := 0; i < len(teams); i++ {
+		var err error
+		if mutations[i], err = spanner.InsertOrUpdateStruct(teamTable, teams[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+func (s store) WriteAllTeamMembers(ctx1 context.Context, orgLogin string, members []*storage.TeamMember) error {
+	scope.Debugf("Writing %d team members for org %s", len(members), orgLogin)
+
+	mutations := make([]*spanner.Mutation, len(members))
+	for i, member := range members {
+		var err error
+		if mutations[i], err = spanner.InsertStruct(teamMemberTable, member); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.ReadWriteTransaction(ctx1, func(ctx2 context.Context, txn *spanner.ReadWriteTransaction) error {
+		// Remove all existing team members for the org
+		stmt := spanner.NewStatement("DELETE FROM TeamMembers WHERE OrgLogin = @orgLogin;")
+		stmt.Params["orgLogin"] = orgLogin
+		iter := txn.Query(ctx2, stmt)
 		if err := iter.Do(func(_ *spanner.Row) error { return nil }); err != nil {
 			return err
 		}
 
-		// write all the new members
+		// write all the new team members
 		return txn.BufferWrite(mutations)
 	})
 
 	return err
 }
 
-func (s store) WriteAllMaintainers(ctx1 context.Context, maintainers []*storage.Maintainer) error {
-	scope.Debugf("Writing %d maintainers", len(maintainers))
+func (s store) WriteAllMaintainers(ctx1 context.Context, orgLogin string, maintainers []*storage.Maintainer) error {
+	scope.Debugf("Writing %d maintainers for org %s", len(maintainers), orgLogin)
 
 	mutations := make([]*spanner.Mutation, len(maintainers))
 	for i, maintainer := range maintainers {
@@ -224,8 +477,10 @@ func (s store) WriteAllMaintainers(ctx1 context.Context, maintainers []*storage.
 	}
 
 	_, err := s.client.ReadWriteTransaction(ctx1, func(ctx2 context.Context, txn *spanner.ReadWriteTransaction) error {
-		// Remove all existing maintainers
-		iter := txn.Query(ctx2, spanner.Statement{SQL: "DELETE FROM Maintainers WHERE true;"})
+		// Remove all existing maintainers for the org
+		stmt := spanner.NewStatement("DELETE FROM Maintainers WHERE OrgLogin = @orgLogin;")
+		stmt.Params["orgLogin"] = orgLogin
+		iter := txn.Query(ctx2, stmt)
 		if err := iter.Do(func(_ *spanner.Row) error { return nil }); err != nil {
 			return err
 		}
@@ -252,6 +507,36 @@ func (s store) WriteBotActivities(context context.Context, activities []*storage
 	return err
 }
 
+func (s store) WriteFirstInteractions(context context.Context, interactions []*storage.FirstInteraction) error {
+	scope.Debugf("Writing %d first interactions", len(interactions))
+
+	mutations := make([]*spanner.Mutation, len(interactions))
+	for i := 0; i < len(interactions); i++ {
+		var err error
+		if mutations[i], err = spanner.InsertOrUpdateStruct(firstInteractionTable, interactions[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+func (s store) WriteCherryPicks(context context.Context, cherryPicks []*storage.CherryPick) error {
+	scope.Debugf("Writing %d cherry-picks", len(cherryPicks))
+
+	mutations := make([]*spanner.Mutation, len(cherryPicks))
+	for i := 0; i < len(cherryPicks); i++ {
+		var err error
+		if mutations[i], err = spanner.InsertOrUpdateStruct(cherryPickTable, cherryPicks[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
 func (s store) WriteTestResults(context context.Context, testResults []*storage.TestResult) error {
 	scope.Debugf("Writing %d test results", len(testResults))
 
@@ -267,6 +552,51 @@ func (s store) WriteTestResults(context context.Context, testResults []*storage.
 	return err
 }
 
+func (s store) WriteIssueSLOs(context context.Context, slos []*storage.IssueSLO) error {
+	scope.Debugf("Writing %d issue SLOs", len(slos))
+
+	mutations := make([]*spanner.Mutation, len(slos))
+	for i := 0; i < len(slos); i++ {
+		var err error
+		if mutations[i], err = spanner.InsertOrUpdateStruct(issueSLOTable, slos[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+func (s store) WriteETags(context context.Context, etags []*storage.ETag) error {
+	scope.Debugf("Writing %d ETags", len(etags))
+
+	mutations := make([]*spanner.Mutation, len(etags))
+	for i := 0; i < len(etags); i++ {
+		var err error
+		if mutations[i], err = spanner.InsertOrUpdateStruct(etagTable, etags[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
+func (s store) WriteIssueTransfers(context context.Context, transfers []*storage.IssueTransfer) error {
+	scope.Debugf("Writing %d issue transfers", len(transfers))
+
+	mutations := make([]*spanner.Mutation, len(transfers))
+	for i := 0; i < len(transfers); i++ {
+		var err error
+		if mutations[i], err = spanner.InsertOrUpdateStruct(issueTransferTable, transfers[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
+
 func (s store) WriteIssueEvents(context context.Context, events []*storage.IssueEvent) error {
 	scope.Debugf("Writing %d issue events", len(events))
 
@@ -356,3 +686,18 @@ func (s store) WriteRepoCommentEvents(context context.Context, events []*storage
 	_, err := s.client.Apply(context, mutations)
 	return err
 }
+
+func (s store) WriteWebhookPayloads(context context.Context, payloads []*storage.WebhookPayload) error {
+	scope.Debugf("Writing %d webhook payloads", len(payloads))
+
+	mutations := make([]*spanner.Mutation, len(payloads))
+	for i := 0; i < len(payloads); i++ {
+		var err error
+		if mutations[i], err = spanner.InsertOrUpdateStruct(webhookPayloadTable, payloads[i]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.Apply(context, mutations)
+	return err
+}
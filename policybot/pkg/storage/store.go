@@ -17,6 +17,7 @@ package storage
 import (
 	"context"
 	"io"
+	"time"
 )
 
 // Store defines how the bot interacts with the database
@@ -29,46 +30,95 @@ type Store interface {
 	WriteIssues(context context.Context, issues []*Issue) error
 	WriteIssueComments(context context.Context, issueComments []*IssueComment) error
 	WriteIssuePipelines(context context.Context, issueData []*IssuePipeline) error
+	WriteIssuePipelineEvents(context context.Context, events []*IssuePipelineEvent) error
+	WriteAllIssueAssignees(context context.Context, issues []*Issue) error
+	WriteAllIssueLabels(context context.Context, issues []*Issue) error
+	WriteAllEpics(context context.Context, orgLogin string, repoName string, epics []*Epic, children []*IssueEpic) error
 	WritePullRequests(context context.Context, prs []*PullRequest) error
+	WriteAllPullRequestReviewers(context context.Context, prs []*PullRequest) error
 	WritePullRequestReviewComments(context context.Context, prComments []*PullRequestReviewComment) error
 	WritePullRequestReviews(context context.Context, prReviews []*PullRequestReview) error
 	WriteUsers(context context.Context, users []*User) error
 	WriteLabels(context context.Context, labels []*Label) error
-	WriteAllMembers(context context.Context, members []*Member) error
-	WriteAllMaintainers(context context.Context, maintainers []*Maintainer) error
+	WriteMilestones(context context.Context, milestones []*Milestone) error
+	WriteReleases(context context.Context, releases []*Release) error
+	WriteCombinedStatuses(context context.Context, statuses []*CombinedStatus) error
+	WriteCheckRuns(context context.Context, checkRuns []*CheckRun) error
+	WriteCodeOwners(context context.Context, codeOwners []*CodeOwners) error
+	WriteTeams(context context.Context, teams []*Team) error
+	WriteAllTeamMembers(context context.Context, orgLogin string, members []*TeamMember) error
+	WriteAllMembers(context context.Context, orgLogins []string, members []*Member) error
+	WriteAllMaintainers(context context.Context, orgLogin string, maintainers []*Maintainer) error
 	WriteBotActivities(context context.Context, activities []*BotActivity) error
+	WriteFirstInteractions(context context.Context, interactions []*FirstInteraction) error
+	WriteCherryPicks(context context.Context, cherryPicks []*CherryPick) error
 	WriteTestResults(context context.Context, testResults []*TestResult) error
 	WriteIssueEvents(context context.Context, events []*IssueEvent) error
+	WriteIssueSLOs(context context.Context, slos []*IssueSLO) error
+	WriteIssueTransfers(context context.Context, transfers []*IssueTransfer) error
 	WriteIssueCommentEvents(context context.Context, events []*IssueCommentEvent) error
 	WritePullRequestEvents(context context.Context, events []*PullRequestEvent) error
 	WritePullRequestReviewCommentEvents(context context.Context, events []*PullRequestReviewCommentEvent) error
 	WritePullRequestReviewEvents(context context.Context, events []*PullRequestReviewEvent) error
 	WriteRepoCommentEvents(context context.Context, events []*RepoCommentEvent) error
+	WriteWebhookPayloads(context context.Context, payloads []*WebhookPayload) error
+	WriteETags(context context.Context, etags []*ETag) error
 
 	UpdateBotActivity(context context.Context, orgLogin string, repoName string, cb func(*BotActivity) error) error
+	MarkIssuesDeleted(context context.Context, orgLogin string, repoName string, issueNumbers []int64) error
+	DeleteIssue(context context.Context, orgLogin string, repoName string, issueNumber int64) error
+	DeleteIssuePipelines(context context.Context, orgLogin string, repoName string, issueNumbers []int64) error
+	DeleteMilestones(context context.Context, orgLogin string, repoName string, milestoneNumbers []int64) error
+	DeleteLabel(context context.Context, orgLogin string, repoName string, labelName string) error
+	DeleteIssueComment(context context.Context, orgLogin string, repoName string, issueNumber int64, commentID int64) error
+	DeletePullRequestReviewComment(context context.Context, orgLogin string, repoName string, prNumber int64, commentID int64) error
 
 	ReadOrg(context context.Context, orgLogin string) (*Org, error)
 	ReadRepo(context context.Context, orgLogin string, repoName string) (*Repo, error)
 	ReadIssue(context context.Context, orgLogin string, repoName string, number int) (*Issue, error)
 	ReadIssueComment(context context.Context, orgLogin string, repoName string, issueNumber int, issueCommentID int) (*IssueComment, error)
+	ReadIssueCommentByID(context context.Context, orgLogin string, repoName string, issueCommentID int64) (*IssueComment, error)
 	ReadIssuePipeline(context context.Context, orgLogin string, repoName string, issueNumber int) (*IssuePipeline, error)
+	ReadCodeOwners(context context.Context, orgLogin string, repoName string) (*CodeOwners, error)
+	ReadCombinedStatus(context context.Context, orgLogin string, repoName string, sha string) (*CombinedStatus, error)
 	ReadLabel(context context.Context, orgLogin string, repoName string, labelName string) (*Label, error)
 	ReadUser(context context.Context, userLogin string) (*User, error)
 	ReadPullRequest(context context.Context, orgLogin string, repoName string, prNumber int) (*PullRequest, error)
 	ReadPullRequestReviewComment(context context.Context, orgLogin string, repoName string, prNumber int, prCommentID int) (*PullRequestReviewComment, error)
-	ReadPullRequestReview(context context.Context, orgLogin string, repoName string, prNumber int, prReviewID int) (*PullRequestReview, error)
+	ReadPullRequestReview(context context.Context, orgLogin string, repoName string, reviewID int64) (*PullRequestReview, error)
+	ReadLatestIssuePipelineEvent(context context.Context, orgLogin string, repoName string, issueNumber int64) (*IssuePipelineEvent, error)
+	ReadLatestReviewByReviewer(context context.Context, orgLogin string, repoName string, prNumber int64, reviewerLogin string) (*PullRequestReview, error)
 	ReadBotActivity(context context.Context, orgLogin string, repoName string) (*BotActivity, error)
+	ReadFirstInteraction(context context.Context, orgLogin string, repoName string, userLogin string) (*FirstInteraction, error)
+	ReadCherryPick(context context.Context, orgLogin string, repoName string, prNumber int64, targetBranch string) (*CherryPick, error)
+	ReadETag(context context.Context, url string) (*ETag, error)
+	ReadIssueTransfer(context context.Context, orgLogin string, repoName string, issueNumber int64) (*IssueTransfer, error)
 	ReadMaintainer(context context.Context, orgLogin string, userLogin string) (*Maintainer, error)
+	ReadMember(context context.Context, orgLogin string, userLogin string) (*Member, error)
+	ReadTeamBySlug(context context.Context, orgLogin string, teamSlug string) (*Team, error)
 	ReadTestResult(context context.Context, orgLogin string, repoName string, testName string, pullRequestNumber int64, runNumber int64) (*TestResult, error)
 
 	QueryMembersByOrg(context context.Context, orgLogin string, cb func(*Member) error) error
+	QueryTeamMembers(context context.Context, orgLogin string, teamID int64, cb func(*TeamMember) error) error
 	QueryMaintainersByOrg(context context.Context, orgLogin string, cb func(*Maintainer) error) error
 	QueryMaintainerInfo(context context.Context, maintainer *Maintainer) (*MaintainerInfo, error)
 	QueryIssuesByRepo(context context.Context, orgLogin string, repoName string, cb func(*Issue) error) error
+	QueryIssuesUpdatedSince(context context.Context, orgLogin string, repoName string, since time.Time, cb func(*Issue) error) error
+	QueryIssuesByAssignee(context context.Context, orgLogin string, userLogin string, cb func(*Issue) error) error
+	QueryPullRequestsByReviewer(context context.Context, orgLogin string, userLogin string, cb func(*PullRequest) error) error
+	QueryIssuesByLabel(context context.Context, orgLogin string, repoName string, labelName string, cb func(*Issue) error) error
+	QueryIssuesByActivity(context context.Context, orgLogin string, repoName string, ignoredActor string, before time.Time, cb func(*Issue) error) error
+	QueryIssueCommentsByRepo(context context.Context, orgLogin string, repoName string, cb func(*IssueComment) error) error
+	QueryIssueEventsByRepo(context context.Context, orgLogin string, repoName string, cb func(*IssueEvent) error) error
+	QueryStaleIssues(context context.Context, orgLogin string, repoName string, olderThan time.Duration, requiredLabels []string,
+		excludedLabels []string, cb func(*Issue) error) error
+	QueryIssuesAwaitingResponse(context context.Context, orgLogin string, repoName string, before time.Time, cb func(*Issue) error) error
+	QueryContributionCount(context context.Context, orgLogin string, userLogin string, before time.Time) (int64, error)
 	QueryTestResultByPrNumber(context context.Context, orgLogin string, repoName string, pullRequestNumber int64, cb func(*TestResult) error) error
 	QueryTestResultByUndone(context context.Context, orgLogin string, repoName string, cb func(*TestResult) error) error
 	QueryAllTestResults(context context.Context, orgLogin string, repoName string, cb func(*TestResult) error) error
 	QueryTestResultByTestName(context context.Context, orgLogin string, repoName string, testName string, cb func(*TestResult) error) error
+	QueryWebhookPayloads(context context.Context, from time.Time, to time.Time, cb func(*WebhookPayload) error) error
 
 	// TODO: needs to be org-specific and/or repo-specific
 	QueryTestFlakeIssues(context context.Context, inactiveDays, createdDays int) ([]*Issue, error)
@@ -21,29 +21,33 @@ import (
 // This file defines the shapes we csn read/write to/from the DB.
 
 type Issue struct {
-	OrgLogin    string
-	RepoName    string
-	IssueNumber int64
-	Title       string
-	Body        string
-	Labels      []string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	ClosedAt    time.Time
-	State       string
-	Author      string
-	Assignees   []string
+	OrgLogin        string
+	RepoName        string
+	IssueNumber     int64
+	Title           string
+	Body            string
+	Labels          []string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	ClosedAt        time.Time
+	State           string
+	Author          string
+	Assignees       []string
+	Deleted         bool
+	DeletedAt       time.Time
+	MilestoneNumber int64 // 0 when the issue isn't part of a milestone
 }
 
 type IssueComment struct {
-	OrgLogin       string
-	RepoName       string
-	IssueNumber    int64
-	IssueCommentID int64
-	Author         string
-	Body           string
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
+	OrgLogin          string
+	RepoName          string
+	IssueNumber       int64
+	IssueCommentID    int64
+	Author            string
+	AuthorAssociation string
+	Body              string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
 }
 
 type User struct {
@@ -61,6 +65,58 @@ type Label struct {
 	Color       string
 }
 
+type CodeOwners struct {
+	OrgLogin string
+	RepoName string
+	Lines    []string // raw lines of the repo's CODEOWNERS file
+}
+
+type Milestone struct {
+	OrgLogin        string
+	RepoName        string
+	MilestoneNumber int64
+	Title           string
+	State           string
+	Description     string
+	DueOn           time.Time
+	OpenIssues      int64
+	ClosedIssues    int64
+}
+
+type Release struct {
+	OrgLogin    string
+	RepoName    string
+	ReleaseID   int64
+	TagName     string
+	Name        string
+	Draft       bool
+	Prerelease  bool
+	CreatedAt   time.Time
+	PublishedAt time.Time
+	Author      string
+}
+
+type CombinedStatus struct {
+	OrgLogin      string
+	RepoName      string
+	SHA           string
+	State         string
+	Contexts      []string // the contexts reporting individual statuses
+	ContextStates []string // the state reported by each context, in the same order as Contexts
+}
+
+type CheckRun struct {
+	OrgLogin    string
+	RepoName    string
+	SHA         string
+	CheckRunID  int64
+	Name        string
+	Status      string
+	Conclusion  string
+	StartedAt   time.Time
+	CompletedAt time.Time
+}
+
 type Org struct {
 	OrgLogin    string
 	Company     string
@@ -69,10 +125,11 @@ type Org struct {
 }
 
 type Repo struct {
-	OrgLogin    string
-	RepoName    string
-	Description string
-	RepoNumber  int64
+	OrgLogin      string
+	RepoName      string
+	Description   string
+	RepoNumber    int64
+	DefaultBranch string
 }
 
 type PullRequest struct {
@@ -91,6 +148,14 @@ type PullRequest struct {
 	Files              []string
 	Author             string
 	State              string
+	MilestoneNumber    int64 // 0 when the PR isn't part of a milestone
+	Additions          int64 // 0 when GitHub didn't report diff stats, as is the case when listing PRs
+	Deletions          int64
+	ChangedFiles       int64
+	MergedBy           string
+	MergeCommitSHA     string
+	Draft              bool
+	Mergeable          bool // false when GitHub hasn't computed mergeability, as is the case when listing PRs
 }
 
 type PullRequestReviewComment struct {
@@ -99,6 +164,7 @@ type PullRequestReviewComment struct {
 	PullRequestNumber          int64
 	PullRequestReviewCommentID int64
 	Author                     string
+	AuthorAssociation          string
 	Body                       string
 	CreatedAt                  time.Time
 	UpdatedAt                  time.Time
@@ -118,6 +184,22 @@ type PullRequestReview struct {
 type Member struct {
 	OrgLogin  string
 	UserLogin string
+	Role      string // either "admin" or "member"
+}
+
+type Team struct {
+	OrgLogin     string
+	TeamID       int64
+	TeamSlug     string
+	TeamName     string
+	Description  string
+	ParentTeamID int64 // 0 for top-level teams
+}
+
+type TeamMember struct {
+	OrgLogin  string
+	TeamID    int64
+	UserLogin string
 }
 
 type BotActivity struct {
@@ -126,13 +208,85 @@ type BotActivity struct {
 	LastIssueSyncStart                    time.Time
 	LastIssueCommentSyncStart             time.Time
 	LastPullRequestReviewCommentSyncStart time.Time
+	LastPullRequestSyncStart              time.Time
+	LastZenHubSyncStart                   time.Time
+
+	// the next page to fetch for syncs that were interrupted before completing, 0 once they complete
+	LastIssuePage                    int64
+	LastIssueCommentPage             int64
+	LastPullRequestPage              int64
+	LastPullRequestReviewCommentPage int64
 }
 
-type Maintainer struct {
+// CherryPick tracks the cherry-picking of a merged PR to a release branch
+type CherryPick struct {
+	OrgLogin          string
+	RepoName          string
+	PullRequestNumber int64
+	TargetBranch      string
+	State             string // either "succeeded" or "failed"
+	CherryPickNumber  int64  // number of the PR opened against the target branch
+	Error             string // why the cherry-pick failed
+	UpdatedAt         time.Time
+}
+
+// FirstInteraction records that a user was welcomed as a first-time contributor to a repo
+type FirstInteraction struct {
 	OrgLogin  string
+	RepoName  string
 	UserLogin string
-	Paths     []string // where each path is of the form RepoID/path_in_repo
-	Emeritus  bool
+	CreatedAt time.Time
+}
+
+type Maintainer struct {
+	OrgLogin      string
+	UserLogin     string
+	Paths         []string // paths the maintainer can approve, where each path is of the form RepoID/path_in_repo
+	ReviewerPaths []string // paths the maintainer reviews without being able to approve, in the same form as Paths
+	Emeritus      bool
+}
+
+// IssueAssignee associates an issue with one of its assignees
+type IssueAssignee struct {
+	OrgLogin    string
+	RepoName    string
+	IssueNumber int64
+	UserLogin   string
+}
+
+// PullRequestReviewer associates a PR with one of its requested reviewers
+type PullRequestReviewer struct {
+	OrgLogin          string
+	RepoName          string
+	PullRequestNumber int64
+	UserLogin         string
+}
+
+// IssueLabel associates an issue with one of its labels
+type IssueLabel struct {
+	OrgLogin    string
+	RepoName    string
+	IssueNumber int64
+	LabelName   string
+}
+
+// Epic is a ZenHub epic, which groups issues from any of the repos in a ZenHub workspace
+type Epic struct {
+	OrgLogin   string
+	RepoName   string
+	EpicNumber int64
+	Estimate   int64 // 0 when the epic isn't estimated
+}
+
+// IssueEpic records an issue belonging to an epic. The child issue may live in another repo of
+// the epic's ZenHub workspace, which is identified by its repo number.
+type IssueEpic struct {
+	OrgLogin         string
+	RepoName         string
+	EpicNumber       int64
+	ChildRepoNumber  int64
+	ChildIssueNumber int64
+	ChildEstimate    int64 // 0 when the child issue isn't estimated
 }
 
 type IssuePipeline struct {
@@ -140,6 +294,18 @@ type IssuePipeline struct {
 	RepoName    string
 	IssueNumber int64
 	Pipeline    string
+	Estimate    int64 // 0 when the issue isn't estimated
+}
+
+// IssuePipelineEvent records an issue moving between ZenHub pipelines.
+type IssuePipelineEvent struct {
+	OrgLogin     string
+	RepoName     string
+	IssueNumber  int64
+	CreatedAt    time.Time
+	ActorID      int64  // the GitHub ID of the user who moved the issue, as reported by ZenHub
+	FromPipeline string // empty when the issue first entered a pipeline
+	ToPipeline   string
 }
 
 type TimedEntry struct {
@@ -212,6 +378,7 @@ type PullRequestEvent struct {
 	CreatedAt         time.Time
 	Actor             string
 	Action            string
+	Assignee          string // set for assigned/unassigned events, empty otherwise
 }
 
 type PullRequestReviewCommentEvent struct {
@@ -241,4 +408,44 @@ type IssueEvent struct {
 	CreatedAt   time.Time
 	Actor       string
 	Action      string
+	Label       string // set for labeled/unlabeled events, empty otherwise
+	Assignee    string // set for assigned/unassigned events, empty otherwise
+}
+
+// IssueTransfer maps an issue transferred to another repo to its new location, where it has a new number.
+type IssueTransfer struct {
+	OrgLogin       string
+	RepoName       string
+	IssueNumber    int64
+	NewOrgLogin    string
+	NewRepoName    string
+	NewIssueNumber int64
+}
+
+// IssueSLO records how long it took for an org member other than the author to first respond to an issue.
+type IssueSLO struct {
+	OrgLogin        string
+	RepoName        string
+	IssueNumber     int64
+	FirstResponseAt time.Time // zero when the issue hasn't been responded to yet
+	FirstResponder  string    // empty when the issue hasn't been responded to yet
+	ResponseLatency int64     // in seconds
+}
+
+// WebhookPayload is the raw content of a webhook event received from GitHub, kept so the event can be replayed.
+type WebhookPayload struct {
+	DeliveryID string
+	EventType  string
+	ReceivedAt time.Time
+	Payload    string
+}
+
+// ETag is the entity tag GitHub returned for a GET request, along with the response it applies to, such that the
+// response can be reused when GitHub reports it hasn't changed.
+type ETag struct {
+	URL       string
+	ETag      string
+	Link      string // the pagination links of the response
+	Body      []byte
+	UpdatedAt time.Time
 }
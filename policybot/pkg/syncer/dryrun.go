@@ -0,0 +1,328 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syncer
+
+import (
+	"context"
+
+	"istio.io/bots/policybot/pkg/storage"
+)
+
+// dryRunStore wraps a store such that reads go through while mutations are only logged.
+type dryRunStore struct {
+	storage.Store
+}
+
+func wouldWrite(count int, what string, orgLogin string, repoName string) {
+	if count == 0 {
+		return
+	}
+
+	if repoName != "" {
+		scope.Infof("Dry run: would write %d %s for %s/%s", count, what, orgLogin, repoName)
+	} else if orgLogin != "" {
+		scope.Infof("Dry run: would write %d %s for %s", count, what, orgLogin)
+	} else {
+		scope.Infof("Dry run: would write %d %s", count, what)
+	}
+}
+
+func (ds dryRunStore) WriteOrgs(_ context.Context, orgs []*storage.Org) error {
+	wouldWrite(len(orgs), "orgs", "", "")
+	return nil
+}
+
+func (ds dryRunStore) WriteRepos(_ context.Context, repos []*storage.Repo) error {
+	wouldWrite(len(repos), "repos", "", "")
+	return nil
+}
+
+func (ds dryRunStore) WriteRepoComments(_ context.Context, comments []*storage.RepoComment) error {
+	if len(comments) > 0 {
+		wouldWrite(len(comments), "repo comments", comments[0].OrgLogin, comments[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteIssues(_ context.Context, issues []*storage.Issue) error {
+	if len(issues) > 0 {
+		wouldWrite(len(issues), "issues", issues[0].OrgLogin, issues[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteIssueComments(_ context.Context, issueComments []*storage.IssueComment) error {
+	if len(issueComments) > 0 {
+		wouldWrite(len(issueComments), "issue comments", issueComments[0].OrgLogin, issueComments[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteIssuePipelines(_ context.Context, issueData []*storage.IssuePipeline) error {
+	if len(issueData) > 0 {
+		wouldWrite(len(issueData), "issue pipelines", issueData[0].OrgLogin, issueData[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteAllIssueAssignees(_ context.Context, issues []*storage.Issue) error {
+	if len(issues) > 0 {
+		wouldWrite(len(issues), "issue assignee sets", issues[0].OrgLogin, issues[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteAllPullRequestReviewers(_ context.Context, prs []*storage.PullRequest) error {
+	if len(prs) > 0 {
+		wouldWrite(len(prs), "pull request reviewer sets", prs[0].OrgLogin, prs[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteAllIssueLabels(_ context.Context, issues []*storage.Issue) error {
+	if len(issues) > 0 {
+		wouldWrite(len(issues), "issue label sets", issues[0].OrgLogin, issues[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteAllEpics(_ context.Context, orgLogin string, repoName string, epics []*storage.Epic,
+	children []*storage.IssueEpic) error {
+	wouldWrite(len(epics), "epics", orgLogin, repoName)
+	wouldWrite(len(children), "epic issues", orgLogin, repoName)
+	return nil
+}
+
+func (ds dryRunStore) WritePullRequests(_ context.Context, prs []*storage.PullRequest) error {
+	if len(prs) > 0 {
+		wouldWrite(len(prs), "pull requests", prs[0].OrgLogin, prs[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WritePullRequestReviewComments(_ context.Context, prComments []*storage.PullRequestReviewComment) error {
+	if len(prComments) > 0 {
+		wouldWrite(len(prComments), "pull request review comments", prComments[0].OrgLogin, prComments[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WritePullRequestReviews(_ context.Context, prReviews []*storage.PullRequestReview) error {
+	if len(prReviews) > 0 {
+		wouldWrite(len(prReviews), "pull request reviews", prReviews[0].OrgLogin, prReviews[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteUsers(_ context.Context, users []*storage.User) error {
+	wouldWrite(len(users), "users", "", "")
+	return nil
+}
+
+func (ds dryRunStore) WriteLabels(_ context.Context, labels []*storage.Label) error {
+	if len(labels) > 0 {
+		wouldWrite(len(labels), "labels", labels[0].OrgLogin, labels[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteMilestones(_ context.Context, milestones []*storage.Milestone) error {
+	if len(milestones) > 0 {
+		wouldWrite(len(milestones), "milestones", milestones[0].OrgLogin, milestones[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteReleases(_ context.Context, releases []*storage.Release) error {
+	if len(releases) > 0 {
+		wouldWrite(len(releases), "releases", releases[0].OrgLogin, releases[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteCombinedStatuses(_ context.Context, statuses []*storage.CombinedStatus) error {
+	if len(statuses) > 0 {
+		wouldWrite(len(statuses), "combined statuses", statuses[0].OrgLogin, statuses[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteCheckRuns(_ context.Context, checkRuns []*storage.CheckRun) error {
+	if len(checkRuns) > 0 {
+		wouldWrite(len(checkRuns), "check runs", checkRuns[0].OrgLogin, checkRuns[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteCodeOwners(_ context.Context, codeOwners []*storage.CodeOwners) error {
+	if len(codeOwners) > 0 {
+		wouldWrite(len(codeOwners), "CODEOWNERS files", codeOwners[0].OrgLogin, codeOwners[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteTeams(_ context.Context, teams []*storage.Team) error {
+	if len(teams) > 0 {
+		wouldWrite(len(teams), "teams", teams[0].OrgLogin, "")
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteAllTeamMembers(_ context.Context, orgLogin string, members []*storage.TeamMember) error {
+	wouldWrite(len(members), "team members", orgLogin, "")
+	return nil
+}
+
+func (ds dryRunStore) WriteAllMembers(_ context.Context, orgLogins []string, members []*storage.Member) error {
+	for _, orgLogin := range orgLogins {
+		count := 0
+		for _, member := range members {
+			if member.OrgLogin == orgLogin {
+				count++
+			}
+		}
+		wouldWrite(count, "members", orgLogin, "")
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteAllMaintainers(_ context.Context, orgLogin string, maintainers []*storage.Maintainer) error {
+	wouldWrite(len(maintainers), "maintainers", orgLogin, "")
+	return nil
+}
+
+func (ds dryRunStore) WriteBotActivities(_ context.Context, activities []*storage.BotActivity) error {
+	wouldWrite(len(activities), "bot activities", "", "")
+	return nil
+}
+
+func (ds dryRunStore) WriteTestResults(_ context.Context, testResults []*storage.TestResult) error {
+	wouldWrite(len(testResults), "test results", "", "")
+	return nil
+}
+
+func (ds dryRunStore) WriteIssuePipelineEvents(_ context.Context, events []*storage.IssuePipelineEvent) error {
+	if len(events) > 0 {
+		wouldWrite(len(events), "issue pipeline events", events[0].OrgLogin, events[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteIssueEvents(_ context.Context, events []*storage.IssueEvent) error {
+	if len(events) > 0 {
+		wouldWrite(len(events), "issue events", events[0].OrgLogin, events[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteIssueSLOs(_ context.Context, slos []*storage.IssueSLO) error {
+	if len(slos) > 0 {
+		wouldWrite(len(slos), "issue SLOs", slos[0].OrgLogin, slos[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteIssueTransfers(_ context.Context, transfers []*storage.IssueTransfer) error {
+	if len(transfers) > 0 {
+		wouldWrite(len(transfers), "issue transfers", transfers[0].OrgLogin, transfers[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteIssueCommentEvents(_ context.Context, events []*storage.IssueCommentEvent) error {
+	if len(events) > 0 {
+		wouldWrite(len(events), "issue comment events", events[0].OrgLogin, events[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WritePullRequestEvents(_ context.Context, events []*storage.PullRequestEvent) error {
+	if len(events) > 0 {
+		wouldWrite(len(events), "pull request events", events[0].OrgLogin, events[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WritePullRequestReviewCommentEvents(_ context.Context, events []*storage.PullRequestReviewCommentEvent) error {
+	if len(events) > 0 {
+		wouldWrite(len(events), "pull request review comment events", events[0].OrgLogin, events[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WritePullRequestReviewEvents(_ context.Context, events []*storage.PullRequestReviewEvent) error {
+	if len(events) > 0 {
+		wouldWrite(len(events), "pull request review events", events[0].OrgLogin, events[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteRepoCommentEvents(_ context.Context, events []*storage.RepoCommentEvent) error {
+	if len(events) > 0 {
+		wouldWrite(len(events), "repo comment events", events[0].OrgLogin, events[0].RepoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) WriteWebhookPayloads(_ context.Context, payloads []*storage.WebhookPayload) error {
+	wouldWrite(len(payloads), "webhook payloads", "", "")
+	return nil
+}
+
+func (ds dryRunStore) UpdateBotActivity(_ context.Context, orgLogin string, repoName string, _ func(*storage.BotActivity) error) error {
+	scope.Infof("Dry run: would update bot activity for %s/%s", orgLogin, repoName)
+	return nil
+}
+
+func (ds dryRunStore) MarkIssuesDeleted(_ context.Context, orgLogin string, repoName string, issueNumbers []int64) error {
+	if len(issueNumbers) > 0 {
+		scope.Infof("Dry run: would mark %d issues as deleted for %s/%s", len(issueNumbers), orgLogin, repoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) DeleteIssue(_ context.Context, orgLogin string, repoName string, issueNumber int64) error {
+	scope.Infof("Dry run: would delete issue %d for %s/%s", issueNumber, orgLogin, repoName)
+	return nil
+}
+
+func (ds dryRunStore) DeleteIssuePipelines(_ context.Context, orgLogin string, repoName string, issueNumbers []int64) error {
+	if len(issueNumbers) > 0 {
+		scope.Infof("Dry run: would delete %d issue pipelines for %s/%s", len(issueNumbers), orgLogin, repoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) DeleteMilestones(_ context.Context, orgLogin string, repoName string, milestoneNumbers []int64) error {
+	if len(milestoneNumbers) > 0 {
+		scope.Infof("Dry run: would delete %d milestones for %s/%s", len(milestoneNumbers), orgLogin, repoName)
+	}
+	return nil
+}
+
+func (ds dryRunStore) DeleteLabel(_ context.Context, orgLogin string, repoName string, labelName string) error {
+	scope.Infof("Dry run: would delete label %s for %s/%s", labelName, orgLogin, repoName)
+	return nil
+}
+
+func (ds dryRunStore) DeleteIssueComment(_ context.Context, orgLogin string, repoName string, issueNumber int64, commentID int64) error {
+	scope.Infof("Dry run: would delete comment %d on issue %d for %s/%s", commentID, issueNumber, orgLogin, repoName)
+	return nil
+}
+
+func (ds dryRunStore) DeletePullRequestReviewComment(_ context.Context, orgLogin string, repoName string, prNumber int64, commentID int64) error {
+	scope.Infof("Dry run: would delete review comment %d on PR %d for %s/%s", commentID, prNumber, orgLogin, repoName)
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syncer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RepoError records a failure to sync a single repo. RepoName is empty for failures
+// affecting a whole org, such as syncing its members or maintainers.
+type RepoError struct {
+	OrgLogin string
+	RepoName string
+	Err      error
+}
+
+func (e *RepoError) Error() string {
+	if e.RepoName == "" {
+		return fmt.Sprintf("unable to sync org %s: %v", e.OrgLogin, e.Err)
+	}
+
+	return fmt.Sprintf("unable to sync repo %s/%s: %v", e.OrgLogin, e.RepoName, e.Err)
+}
+
+func (e *RepoError) Unwrap() error {
+	return e.Err
+}
+
+// SyncError is returned when a sync completes but some orgs or repos couldn't be synced.
+type SyncError struct {
+	Failures []*RepoError
+}
+
+func (e *SyncError) Error() string {
+	msgs := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		msgs[i] = f.Error()
+	}
+
+	return fmt.Sprintf("%d sync failure(s): %s", len(e.Failures), strings.Join(msgs, "; "))
+}
+
+func (e *SyncError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, f := range e.Failures {
+		errs[i] = f
+	}
+
+	return errs
+}
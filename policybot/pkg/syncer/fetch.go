@@ -21,11 +21,39 @@ import (
 
 	"github.com/google/go-github/v26/github"
 
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
 	"istio.io/bots/policybot/pkg/storage"
 )
 
-func (s *Syncer) fetchOrgs(context context.Context, cb func(organization *github.Organization) error) error {
-	for _, o := range s.orgs {
+// pageCursor tracks how far a paged fetch has gotten, such that a sync interrupted partway through can pick
+// up from there. A nil cursor starts from the first page and records nothing.
+type pageCursor struct {
+	start int            // the page to start from, 0 for the first page
+	save  func(page int) // records the next page to fetch, once all the previous pages have been handled
+}
+
+func (c *pageCursor) firstPage() int {
+	if c == nil {
+		return 0
+	}
+
+	return c.start
+}
+
+// resumed indicates that the fetch skips the pages handled by an earlier sync
+func (c *pageCursor) resumed() bool {
+	return c != nil && c.start > 1
+}
+
+func (c *pageCursor) advance(page int) {
+	if c != nil && c.save != nil {
+		c.save(page)
+	}
+}
+
+func (s *Syncer) fetchOrgs(context context.Context, orgs []config.Org, cb func(organization *github.Organization) error) error {
+	for _, o := range orgs {
 		org, _, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
 			return client.Organizations.Get(context, o.Name)
 		})
@@ -42,8 +70,8 @@ func (s *Syncer) fetchOrgs(context context.Context, cb func(organization *github
 	return nil
 }
 
-func (s *Syncer) fetchRepos(context context.Context, cb func(repo *github.Repository) error) error {
-	for _, o := range s.orgs {
+func (s *Syncer) fetchRepos(context context.Context, orgs []config.Org, cb func(repo *github.Repository) error) error {
+	for _, o := range orgs {
 		for _, r := range o.Repos {
 			repo, _, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
 				return client.Repositories.Get(context, o.Name, r.Name)
@@ -69,7 +97,7 @@ func (s *Syncer) fetchRepoComments(context context.Context, repo *storage.Repo,
 
 	for {
 		comments, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
-			return client.Repositories.ListComments(context, repo.OrgLogin, repo.RepoName, opt)
+			return client.Repositories.ListComments(gh.WithETags(context), repo.OrgLogin, repo.RepoName, opt)
 		})
 
 		if err != nil {
@@ -117,6 +145,91 @@ func (s *Syncer) fetchMembers(context context.Context, org *storage.Org, cb func
 	}
 }
 
+// fetchAdmins returns the org's admins. Unlike fetchMembers, this includes users whose membership isn't
+// public since GitHub only supports filtering by role when listing all members.
+func (s *Syncer) fetchAdmins(context context.Context, org *storage.Org, cb func([]*github.User) error) error {
+	opt := &github.ListMembersOptions{
+		Role: "admin",
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	for {
+		admins, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Organizations.ListMembers(context, org.OrgLogin, opt)
+		})
+
+		if err != nil {
+			return fmt.Errorf("unable to list admins of org %s: %v", org.OrgLogin, err)
+		}
+
+		if err := cb(admins.([]*github.User)); err != nil {
+			return err
+		}
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		opt.ListOptions.Page = resp.NextPage
+	}
+}
+
+func (s *Syncer) fetchTeams(context context.Context, org *storage.Org, cb func([]*github.Team) error) error {
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+
+	for {
+		teams, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Teams.ListTeams(context, org.OrgLogin, opt)
+		})
+
+		if err != nil {
+			return fmt.Errorf("unable to list teams of org %s: %v", org.OrgLogin, err)
+		}
+
+		if err := cb(teams.([]*github.Team)); err != nil {
+			return err
+		}
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+func (s *Syncer) fetchTeamMembers(context context.Context, org *storage.Org, team *storage.Team, cb func([]*github.User) error) error {
+	opt := &github.TeamListTeamMembersOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	for {
+		members, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Teams.ListTeamMembers(context, team.TeamID, opt)
+		})
+
+		if err != nil {
+			return fmt.Errorf("unable to list members of team %s in org %s: %v", team.TeamSlug, org.OrgLogin, err)
+		}
+
+		if err := cb(members.([]*github.User)); err != nil {
+			return err
+		}
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		opt.ListOptions.Page = resp.NextPage
+	}
+}
+
 func (s *Syncer) fetchLabels(context context.Context, repo *storage.Repo, cb func([]*github.Label) error) error {
 	opt := &github.ListOptions{
 		PerPage: 100,
@@ -124,7 +237,7 @@ func (s *Syncer) fetchLabels(context context.Context, repo *storage.Repo, cb fun
 
 	for {
 		labels, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
-			return client.Issues.ListLabels(context, repo.OrgLogin, repo.RepoName, opt)
+			return client.Issues.ListLabels(gh.WithETags(context), repo.OrgLogin, repo.RepoName, opt)
 		})
 
 		if err != nil {
@@ -143,18 +256,81 @@ func (s *Syncer) fetchLabels(context context.Context, repo *storage.Repo, cb fun
 	}
 }
 
-func (s *Syncer) fetchIssues(context context.Context, repo *storage.Repo, startTime time.Time, cb func([]*github.Issue) error) error {
+func (s *Syncer) fetchMilestones(context context.Context, repo *storage.Repo, cb func([]*github.Milestone) error) error {
+	opt := &github.MilestoneListOptions{
+		State: "all",
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	for {
+		milestones, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Issues.ListMilestones(gh.WithETags(context), repo.OrgLogin, repo.RepoName, opt)
+		})
+
+		if err != nil {
+			return fmt.Errorf("unable to list all milestones in repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+		}
+
+		if err := cb(milestones.([]*github.Milestone)); err != nil {
+			return err
+		}
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+func (s *Syncer) fetchReleases(context context.Context, repo *storage.Repo, cb func([]*github.RepositoryRelease) error) error {
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+
+	for {
+		releases, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Repositories.ListReleases(gh.WithETags(context), repo.OrgLogin, repo.RepoName, opt)
+		})
+
+		if err != nil {
+			return fmt.Errorf("unable to list all releases in repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+		}
+
+		if err := cb(releases.([]*github.RepositoryRelease)); err != nil {
+			return err
+		}
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+func (s *Syncer) fetchIssues(context context.Context, repo *storage.Repo, startTime time.Time, cursor *pageCursor,
+	cb func([]*github.Issue) error) error {
 	opt := &github.IssueListByRepoOptions{
 		State: "all",
 		Since: startTime,
 		ListOptions: github.ListOptions{
 			PerPage: 100,
+			Page:    cursor.firstPage(),
 		},
 	}
 
+	// the URLs of delta syncs vary with the start time, so only full syncs can reuse previous responses
+	listContext := context
+	if startTime.IsZero() {
+		listContext = gh.WithETags(context)
+	}
+
 	for {
 		issues, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
-			return client.Issues.ListByRepo(context, repo.OrgLogin, repo.RepoName, opt)
+			return client.Issues.ListByRepo(listContext, repo.OrgLogin, repo.RepoName, opt)
 		})
 
 		if err != nil {
@@ -169,16 +345,18 @@ func (s *Syncer) fetchIssues(context context.Context, repo *storage.Repo, startT
 			return nil
 		}
 
+		cursor.advance(resp.NextPage)
 		opt.ListOptions.Page = resp.NextPage
 	}
 }
 
-func (s *Syncer) fetchIssueComments(context context.Context, repo *storage.Repo, startTime time.Time,
+func (s *Syncer) fetchIssueComments(context context.Context, repo *storage.Repo, startTime time.Time, cursor *pageCursor,
 	cb func([]*github.IssueComment) error) error {
 	opt := &github.IssueListCommentsOptions{
 		Since: startTime,
 		ListOptions: github.ListOptions{
 			PerPage: 100,
+			Page:    cursor.firstPage(),
 		},
 	}
 
@@ -199,16 +377,102 @@ func (s *Syncer) fetchIssueComments(context context.Context, repo *storage.Repo,
 			return nil
 		}
 
+		cursor.advance(resp.NextPage)
 		opt.ListOptions.Page = resp.NextPage
 	}
 }
 
-func (s *Syncer) fetchPullRequestReviewComments(context context.Context, repo *storage.Repo, startTime time.Time,
+// fetchIssuesBulk is like fetchIssues, except that the issues are fetched together with their most recent comments
+// using GitHub's GraphQL API. The issues are reported as pages of issues followed by pages of pull requests, the same
+// as the REST API lists them. Issues whose labels or assignees didn't fit in the bulk query are refreshed individually.
+func (s *Syncer) fetchIssuesBulk(context context.Context, repo *storage.Repo, startTime time.Time, cb func([]*gh.BulkIssue) error) error {
+	for _, pullRequests := range []bool{false, true} {
+		cursor := ""
+		for {
+			issues, next, err := s.gc.QueryIssuesBulk(context, repo.OrgLogin, repo.RepoName, pullRequests, cursor)
+			if err != nil {
+				return fmt.Errorf("unable to query issues in repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+			}
+
+			// since issues are sorted by update time, everything after the first stale issue is stale too
+			done := false
+			for i, issue := range issues {
+				if issue.Issue.GetUpdatedAt().Before(startTime) {
+					issues = issues[:i]
+					done = true
+					break
+				}
+			}
+
+			for _, issue := range issues {
+				if !issue.Incomplete {
+					continue
+				}
+
+				result, _, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+					return client.Issues.Get(context, repo.OrgLogin, repo.RepoName, issue.Issue.GetNumber())
+				})
+
+				if err != nil {
+					return fmt.Errorf("unable to get issue %d in repo %s/%s: %v", issue.Issue.GetNumber(), repo.OrgLogin, repo.RepoName, err)
+				}
+
+				issue.Issue = result.(*github.Issue)
+			}
+
+			if len(issues) > 0 {
+				if err := cb(issues); err != nil {
+					return err
+				}
+			}
+
+			if done || next == "" {
+				break
+			}
+
+			cursor = next
+		}
+	}
+
+	return nil
+}
+
+// fetchCommentsForIssue returns all the comments on a single issue.
+func (s *Syncer) fetchCommentsForIssue(context context.Context, repo *storage.Repo, issueNumber int, cb func([]*github.IssueComment) error) error {
+	opt := &github.IssueListCommentsOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	for {
+		comments, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Issues.ListComments(context, repo.OrgLogin, repo.RepoName, issueNumber, opt)
+		})
+
+		if err != nil {
+			return fmt.Errorf("unable to list comments for issue %d in repo %s/%s: %v", issueNumber, repo.OrgLogin, repo.RepoName, err)
+		}
+
+		if err := cb(comments.([]*github.IssueComment)); err != nil {
+			return err
+		}
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		opt.ListOptions.Page = resp.NextPage
+	}
+}
+
+func (s *Syncer) fetchPullRequestReviewComments(context context.Context, repo *storage.Repo, startTime time.Time, cursor *pageCursor,
 	cb func([]*github.PullRequestComment) error) error {
 	opt := &github.PullRequestListCommentsOptions{
 		Since: startTime,
 		ListOptions: github.ListOptions{
 			PerPage: 100,
+			Page:    cursor.firstPage(),
 		},
 	}
 
@@ -229,6 +493,7 @@ func (s *Syncer) fetchPullRequestReviewComments(context context.Context, repo *s
 			return nil
 		}
 
+		cursor.advance(resp.NextPage)
 		opt.ListOptions.Page = resp.NextPage
 	}
 }
@@ -264,16 +529,74 @@ func (s *Syncer) fetchFiles(context context.Context, repo *storage.Repo, prNumbe
 	}
 }
 
-func (s *Syncer) fetchPullRequests(context context.Context, repo *storage.Repo, cb func([]*github.PullRequest) error) error {
+// fetchPullRequestsBulk is like fetchPullRequests, except that the pull requests are fetched together with their
+// reviews and files using GitHub's GraphQL API. Pull requests whose data didn't fit in the bulk query are refreshed
+// individually.
+func (s *Syncer) fetchPullRequestsBulk(context context.Context, repo *storage.Repo, startTime time.Time, cb func([]*gh.BulkPullRequest) error) error {
+	cursor := ""
+	for {
+		prs, next, err := s.gc.QueryPullRequestsBulk(context, repo.OrgLogin, repo.RepoName, cursor)
+		if err != nil {
+			return fmt.Errorf("unable to query pull requests in repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+		}
+
+		// since PRs are sorted by update time, everything after the first stale PR is stale too
+		done := false
+		for i, pr := range prs {
+			if pr.PullRequest.GetUpdatedAt().Before(startTime) {
+				prs = prs[:i]
+				done = true
+				break
+			}
+		}
+
+		for _, pr := range prs {
+			if !pr.Incomplete {
+				continue
+			}
+
+			result, _, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+				return client.PullRequests.Get(context, repo.OrgLogin, repo.RepoName, pr.PullRequest.GetNumber())
+			})
+
+			if err != nil {
+				return fmt.Errorf("unable to get pull request %d in repo %s/%s: %v", pr.PullRequest.GetNumber(), repo.OrgLogin, repo.RepoName, err)
+			}
+
+			pr.PullRequest = result.(*github.PullRequest)
+		}
+
+		if len(prs) > 0 {
+			if err := cb(prs); err != nil {
+				return err
+			}
+		}
+
+		if done || next == "" {
+			break
+		}
+
+		cursor = next
+	}
+
+	return nil
+}
+
+// fetchPullRequests returns the PRs updated since the given time, most recently updated first.
+func (s *Syncer) fetchPullRequests(context context.Context, repo *storage.Repo, startTime time.Time, cursor *pageCursor,
+	cb func([]*github.PullRequest) error) error {
 	opt := &github.PullRequestListOptions{
-		State: "all",
+		State:     "all",
+		Sort:      "updated",
+		Direction: "desc",
 		ListOptions: github.ListOptions{
 			PerPage: 100,
+			Page:    cursor.firstPage(),
 		},
 	}
 
 	for {
-		prs, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+		result, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
 			return client.PullRequests.List(context, repo.OrgLogin, repo.RepoName, opt)
 		})
 
@@ -281,20 +604,89 @@ func (s *Syncer) fetchPullRequests(context context.Context, repo *storage.Repo,
 			return fmt.Errorf("unable to list pull requests in repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
 		}
 
-		if err := cb(prs.([]*github.PullRequest)); err != nil {
-			return err
+		prs := result.([]*github.PullRequest)
+
+		// since PRs are sorted by update time, everything after the first stale PR is stale too
+		done := false
+		for i, pr := range prs {
+			if pr.GetUpdatedAt().Before(startTime) {
+				prs = prs[:i]
+				done = true
+				break
+			}
 		}
 
-		if resp.NextPage == 0 {
+		if len(prs) > 0 {
+			if err := cb(prs); err != nil {
+				return err
+			}
+		}
+
+		if done || resp.NextPage == 0 {
 			break
 		}
 
+		cursor.advance(resp.NextPage)
 		opt.ListOptions.Page = resp.NextPage
 	}
 
 	return nil
 }
 
+func (s *Syncer) fetchCombinedStatus(context context.Context, repo *storage.Repo, sha string, cb func(*github.CombinedStatus) error) error {
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+
+	for {
+		status, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Repositories.GetCombinedStatus(context, repo.OrgLogin, repo.RepoName, sha, opt)
+		})
+
+		if err != nil {
+			return fmt.Errorf("unable to get combined status for %s in repo %s/%s: %v", sha, repo.OrgLogin, repo.RepoName, err)
+		}
+
+		if err := cb(status.(*github.CombinedStatus)); err != nil {
+			return err
+		}
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+func (s *Syncer) fetchCheckRuns(context context.Context, repo *storage.Repo, sha string, cb func([]*github.CheckRun) error) error {
+	opt := &github.ListCheckRunsOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	for {
+		result, resp, err := s.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Checks.ListCheckRunsForRef(context, repo.OrgLogin, repo.RepoName, sha, opt)
+		})
+
+		if err != nil {
+			return fmt.Errorf("unable to list check runs for %s in repo %s/%s: %v", sha, repo.OrgLogin, repo.RepoName, err)
+		}
+
+		if err := cb(result.(*github.ListCheckRunsResults).CheckRuns); err != nil {
+			return err
+		}
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		opt.ListOptions.Page = resp.NextPage
+	}
+}
+
 func (s *Syncer) fetchReviews(context context.Context, repo *storage.Repo, prNumber int, cb func([]*github.PullRequestReview) error) error {
 	opt := &github.ListOptions{
 		PerPage: 100,
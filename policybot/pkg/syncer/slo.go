@@ -0,0 +1,141 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/storage"
+)
+
+// handleIssueSLOs computes how long it took for org members to first respond to each of the repos' issues.
+func (ss *syncState) handleIssueSLOs(org *storage.Org, repos []*storage.Repo) error {
+	scope.Debugf("Computing issue SLOs for org %s", org.OrgLogin)
+
+	members := make(map[string]bool)
+	if err := ss.syncer.store.QueryMembersByOrg(ss.ctx, org.OrgLogin, func(m *storage.Member) error {
+		members[m.UserLogin] = true
+		return nil
+	}); err != nil {
+		return fmt.Errorf("unable to read members of org %s: %v", org.OrgLogin, err)
+	}
+
+	var excludeMemberIssues bool
+	if o := ss.syncer.orgConfig(org.OrgLogin); o != nil {
+		excludeMemberIssues = o.ResponseSLO.ExcludeMemberIssues
+	}
+
+	for _, repo := range repos {
+		if err := ss.handleRepoIssueSLOs(repo, members, excludeMemberIssues); err != nil {
+			ss.failures = append(ss.failures, &RepoError{OrgLogin: repo.OrgLogin, RepoName: repo.RepoName, Err: err})
+		}
+	}
+
+	return nil
+}
+
+func (ss *syncState) handleRepoIssueSLOs(repo *storage.Repo, members map[string]bool, excludeMemberIssues bool) error {
+	issues := make(map[int64]*storage.Issue)
+	if err := ss.syncer.store.QueryIssuesByRepo(ss.ctx, repo.OrgLogin, repo.RepoName, func(issue *storage.Issue) error {
+		if !issue.Deleted && !(excludeMemberIssues && members[issue.Author]) {
+			issues[issue.IssueNumber] = issue
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("unable to read issues: %v", err)
+	}
+
+	slos := make(map[int64]*storage.IssueSLO, len(issues))
+	for number := range issues {
+		slos[number] = &storage.IssueSLO{
+			OrgLogin:    repo.OrgLogin,
+			RepoName:    repo.RepoName,
+			IssueNumber: number,
+		}
+	}
+
+	// keeps the earliest response by an org member other than the issue's author
+	respond := func(number int64, responder string, at time.Time) {
+		issue := issues[number]
+		if issue == nil || !members[responder] || responder == issue.Author {
+			return
+		}
+
+		slo := slos[number]
+		if slo.FirstResponder == "" || at.Before(slo.FirstResponseAt) {
+			slo.FirstResponder = responder
+			slo.FirstResponseAt = at
+			slo.ResponseLatency = int64(at.Sub(issue.CreatedAt) / time.Second)
+		}
+	}
+
+	if err := ss.syncer.store.QueryIssueCommentsByRepo(ss.ctx, repo.OrgLogin, repo.RepoName, func(c *storage.IssueComment) error {
+		respond(c.IssueNumber, c.Author, c.CreatedAt)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("unable to read issue comments: %v", err)
+	}
+
+	if err := ss.syncer.store.QueryIssueEventsByRepo(ss.ctx, repo.OrgLogin, repo.RepoName, func(e *storage.IssueEvent) error {
+		respond(e.IssueNumber, e.Actor, e.CreatedAt)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("unable to read issue events: %v", err)
+	}
+
+	result := make([]*storage.IssueSLO, 0, len(slos))
+	for _, slo := range slos {
+		result = append(result, slo)
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+
+	if err := ss.syncer.store.WriteIssueSLOs(ss.ctx, result); err != nil {
+		return fmt.Errorf("unable to write issue SLOs to storage: %v", err)
+	}
+
+	return nil
+}
+
+// IssuesBreachingResponseSLO invokes cb for each open issue in a repo which has been waiting for a first response
+// from an org member for longer than the org's configured threshold, as of the last computation of issue SLOs.
+func (s *Syncer) IssuesBreachingResponseSLO(context context.Context, orgLogin string, repoName string,
+	cb func(*storage.Issue) error) error {
+	o := s.orgConfig(orgLogin)
+	if o == nil {
+		return fmt.Errorf("org %s is not configured", orgLogin)
+	} else if o.ResponseSLO.ThresholdHours <= 0 {
+		return nil
+	}
+
+	before := time.Now().Add(-time.Duration(o.ResponseSLO.ThresholdHours) * time.Hour)
+	return s.store.QueryIssuesAwaitingResponse(context, orgLogin, repoName, before, cb)
+}
+
+// orgConfig returns the configuration of the named org, or nil if the org isn't configured.
+func (s *Syncer) orgConfig(orgLogin string) *config.Org {
+	for i := range s.orgs {
+		if s.orgs[i].Name == orgLogin {
+			return &s.orgs[i]
+		}
+	}
+
+	return nil
+}
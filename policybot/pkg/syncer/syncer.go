@@ -17,17 +17,17 @@ package syncer
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/ghodss/yaml"
 	"github.com/google/go-github/v26/github"
 
+	"istio.io/bots/policybot/pkg/codeowners"
 	"istio.io/bots/policybot/pkg/config"
 	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/maintainers"
 	"istio.io/bots/policybot/pkg/storage"
 	"istio.io/bots/policybot/pkg/storage/cache"
 	"istio.io/bots/policybot/pkg/zh"
@@ -41,8 +41,37 @@ type Syncer struct {
 	zc    *zh.ThrottledClient
 	store storage.Store
 	orgs  []config.Org
+
+	// SyncConcurrency is the maximum number of repos synced in parallel within an org
+	SyncConcurrency int
+
+	// DryRun indicates that data is fetched as usual, but nothing is written to the store
+	DryRun bool
+
+	// UseGraphQL indicates that issues and pull requests are fetched in bulk through GitHub's GraphQL API
+	UseGraphQL bool
+
+	// Resume indicates that issues, pull requests, and their comments are fetched starting from the page reached
+	// by an earlier sync which didn't complete, rather than from the first page. This only applies to data fetched
+	// through the REST API.
+	Resume bool
+
+	// RateLimitFloor is the number of core GitHub API calls to leave for others, such as the webhook filters. Syncing
+	// pauses until the rate limit resets whenever fewer calls remain, 0 to never pause.
+	RateLimitFloor int
 }
 
+// the longest a sync pauses for when GitHub's rate limit runs low, in case the reported reset time is off
+const maxRateLimitPause = time.Hour
+
+const defaultSyncConcurrency = 4
+
+// the number of concurrent calls made to ZenHub when fetching issue data
+const zenHubConcurrency = 4
+
+// the number of concurrent calls made to GitHub when completing partial user info
+const userConcurrency = 8
+
 type FilterFlags int
 
 // the things to sync
@@ -55,34 +84,89 @@ const (
 	ZenHub                   = 1 << 5
 	RepoComments             = 1 << 6
 	Events                   = 1 << 7
+	Milestones               = 1 << 8
+	Teams                    = 1 << 9
+	Statuses                 = 1 << 10
+	Releases                 = 1 << 11
+	IssueSLOs                = 1 << 12
 )
 
+// SyncReport summarizes the outcome of a sync operation.
+type SyncReport struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Orgs     []string      `json:"orgs"`
+	Repos    []string      `json:"repos"`
+	Users    int           `json:"users"`
+	Stats    SyncStats     `json:"stats"`
+}
+
+// RepoStats tracks the amount of data written while syncing a single repo.
+type RepoStats struct {
+	Issues       int           `json:"issues"`
+	PullRequests int           `json:"pullRequests"`
+	Comments     int           `json:"comments"`
+	Events       int           `json:"events"`
+	Duration     time.Duration `json:"duration"`
+}
+
+// SyncStats tracks the work done during a sync operation, in total and per repo.
+type SyncStats struct {
+	RepoStats
+
+	GitHubCalls int64                 `json:"gitHubCalls"`
+	ETagHits    int64                 `json:"etagHits"` // GitHub calls answered with a 304, included in GitHubCalls
+	ZenHubCalls int64                 `json:"zenHubCalls"`
+	Repos       map[string]*RepoStats `json:"repos"`
+}
+
 // The state in Syncer is immutable once created. syncState on the other hand represents
 // the mutable state used during a single sync operation.
 type syncState struct {
-	syncer *Syncer
-	users  map[string]*storage.User
-	flags  FilterFlags
-	ctx    context.Context
+	syncer   *Syncer
+	users    map[string]*storage.User
+	flags    FilterFlags
+	ctx      context.Context
+	failures []*RepoError
+
+	// stats for each synced repo by org/repo, and for the repo currently being synced
+	repoStats   map[string]*RepoStats
+	currentRepo *RepoStats
+
+	// commits whose statuses have already been synced
+	statusSHAs map[string]bool
+
+	// whether to fetch issues and pull requests through GitHub's GraphQL API
+	useGraphQL bool
+
+	// whether the current repo's issue comments were synced along with its issues
+	issueCommentsSynced bool
 }
 
 var scope = log.RegisterScope("syncer", "The GitHub data syncer", 0)
 
 func New(gc *gh.ThrottledClient, cache *cache.Cache,
-	zc *zh.ThrottledClient, store storage.Store, orgs []config.Org) *Syncer {
+	zc *zh.ThrottledClient, store storage.Store, orgs []config.Org, dryRun bool) *Syncer {
+	if dryRun {
+		store = dryRunStore{store}
+	}
+
 	return &Syncer{
-		gc:    gc,
-		cache: cache,
-		zc:    zc,
-		store: store,
-		orgs:  orgs,
+		gc:              gc,
+		cache:           cache,
+		zc:              zc,
+		store:           store,
+		orgs:            orgs,
+		SyncConcurrency: defaultSyncConcurrency,
+		DryRun:          dryRun,
 	}
 }
 
 func ConvFilterFlags(filter string) (FilterFlags, error) {
 	if filter == "" {
 		// defaults to everything
-		return Issues | Prs | Maintainers | Members | Labels | ZenHub | RepoComments | Events, nil
+		return Issues | Prs | Maintainers | Members | Labels | ZenHub | RepoComments | Events | Milestones | Teams | Statuses |
+			Releases | IssueSLOs, nil
 	}
 
 	var result FilterFlags
@@ -104,6 +188,16 @@ func ConvFilterFlags(filter string) (FilterFlags, error) {
 			result |= RepoComments
 		case "events":
 			result |= Events
+		case "milestones":
+			result |= Milestones
+		case "teams":
+			result |= Teams
+		case "statuses":
+			result |= Statuses
+		case "releases":
+			result |= Releases
+		case "slos":
+			result |= IssueSLOs
 		default:
 			return 0, fmt.Errorf("unknown filter flag %s", f)
 		}
@@ -112,77 +206,278 @@ func ConvFilterFlags(filter string) (FilterFlags, error) {
 	return result, nil
 }
 
-func (s *Syncer) Sync(context context.Context, flags FilterFlags) error {
+// Sync synchronizes the configured orgs and repos. If repos is non-empty, only the listed
+// repos (in org/repo form) are synchronized, along with the orgs they belong to.
+func (s *Syncer) Sync(context context.Context, flags FilterFlags, repos []string) (*SyncReport, error) {
+	selected, err := s.selectOrgs(repos)
+	if err != nil {
+		return nil, err
+	}
+
 	ss := &syncState{
-		syncer: s,
-		users:  make(map[string]*storage.User),
-		flags:  flags,
-		ctx:    context,
+		syncer:     s,
+		users:      make(map[string]*storage.User),
+		flags:      flags,
+		ctx:        context,
+		repoStats:  make(map[string]*RepoStats),
+		useGraphQL: s.UseGraphQL,
+	}
+
+	report := &SyncReport{
+		Start: time.Now().UTC(),
+	}
+
+	if s.RateLimitFloor > 0 {
+		s.gc.SetLowBudgetCallback(s.RateLimitFloor, func(rl gh.RateLimitSnapshot) {
+			pauseForRateLimit(ss.ctx, rl)
+		})
+		defer s.gc.SetLowBudgetCallback(0, nil)
+	}
+
+	// these are shared by all syncs using the same clients, so they're only accurate when syncs don't overlap
+	gitHubCalls := s.gc.Calls()
+	etagHits := s.gc.ETagHits()
+	var zenHubCalls int64
+	if s.zc != nil {
+		zenHubCalls = s.zc.Calls()
 	}
 
 	var orgs []*storage.Org
-	var repos []*storage.Repo
+	var storageRepos []*storage.Repo
 
 	// get all the org & repo info
-	if err := s.fetchOrgs(ss.ctx, func(org *github.Organization) error {
+	if err := s.fetchOrgs(ss.ctx, selected, func(org *github.Organization) error {
 		orgs = append(orgs, gh.ConvertOrg(org))
-		return s.fetchRepos(ss.ctx, func(repo *github.Repository) error {
-			repos = append(repos, gh.ConvertRepo(repo))
-			return nil
-		})
+		return nil
 	}); err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := s.fetchRepos(ss.ctx, selected, func(repo *github.Repository) error {
+		storageRepos = append(storageRepos, gh.ConvertRepo(repo))
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
 	if err := s.store.WriteOrgs(ss.ctx, orgs); err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := s.store.WriteRepos(ss.ctx, repos); err != nil {
-		return err
+	if err := s.store.WriteRepos(ss.ctx, storageRepos); err != nil {
+		return nil, err
 	}
 
 	for _, org := range orgs {
+		report.Orgs = append(report.Orgs, org.OrgLogin)
+
 		var orgRepos []*storage.Repo
-		for _, repo := range repos {
+		for _, repo := range storageRepos {
 			if repo.OrgLogin == org.OrgLogin {
 				orgRepos = append(orgRepos, repo)
+				report.Repos = append(report.Repos, repo.OrgLogin+"/"+repo.RepoName)
 			}
 		}
 
-		if flags&(Members|Labels|Issues|Prs|ZenHub|RepoComments|Events) != 0 {
+		if flags&(Members|Labels|Issues|Prs|ZenHub|RepoComments|Events|Milestones|Teams|Releases) != 0 {
 			if err := ss.handleOrg(org, orgRepos); err != nil {
-				return err
+				return nil, err
 			}
 		}
 
 		if flags&Maintainers != 0 {
-			if err := ss.handleMaintainers(org, orgRepos); err != nil {
-				return err
+			if len(repos) > 0 {
+				// the org's maintainers are written all at once, so only the share of the synced repos is recomputed
+				for _, repo := range orgRepos {
+					if err := maintainers.RefreshRepo(ss.ctx, s.gc, s.cache, s.store, repo); err != nil {
+						ss.failures = append(ss.failures, &RepoError{OrgLogin: repo.OrgLogin, RepoName: repo.RepoName, Err: err})
+					}
+				}
+			} else if err := ss.handleMaintainers(org, orgRepos); err != nil {
+				ss.failures = append(ss.failures, &RepoError{OrgLogin: org.OrgLogin, Err: err})
+			}
+		}
+
+		// computed last since it depends on the members, issues, comments, and events synced above
+		if flags&IssueSLOs != 0 {
+			if err := ss.handleIssueSLOs(org, orgRepos); err != nil {
+				ss.failures = append(ss.failures, &RepoError{OrgLogin: org.OrgLogin, Err: err})
 			}
 		}
 	}
 
 	if err := ss.pushUsers(); err != nil {
+		return nil, err
+	}
+
+	report.Users = len(ss.users)
+	report.Duration = time.Since(report.Start)
+
+	report.Stats.Repos = ss.repoStats
+	for _, rs := range ss.repoStats {
+		report.Stats.Issues += rs.Issues
+		report.Stats.PullRequests += rs.PullRequests
+		report.Stats.Comments += rs.Comments
+		report.Stats.Events += rs.Events
+		report.Stats.Duration += rs.Duration
+	}
+
+	report.Stats.GitHubCalls = s.gc.Calls() - gitHubCalls
+	report.Stats.ETagHits = s.gc.ETagHits() - etagHits
+	scope.Infof("%d of %d GitHub calls were for unchanged data", report.Stats.ETagHits, report.Stats.GitHubCalls)
+	if s.zc != nil {
+		report.Stats.ZenHubCalls = s.zc.Calls() - zenHubCalls
+	}
+
+	if len(ss.failures) > 0 {
+		return report, &SyncError{Failures: ss.failures}
+	}
+
+	return report, nil
+}
+
+// pauseForRateLimit waits for GitHub's core rate limit to reset, or for the sync to be canceled.
+func pauseForRateLimit(context context.Context, rl gh.RateLimitSnapshot) {
+	d := time.Until(rl.Core.Reset.Time)
+	if d <= 0 {
+		return
+	} else if d > maxRateLimitPause {
+		d = maxRateLimitPause
+	}
+
+	scope.Infof("Pausing sync for %v since the GitHub rate limit is running low: %v", d, rl)
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-context.Done():
+	}
+}
+
+// SyncRepo synchronizes a single configured repo. Org-wide data such as members and maintainers is only
+// synchronized when included in flags, and the maintainers recorded for the org's other repos are kept.
+func (s *Syncer) SyncRepo(context context.Context, orgLogin string, repoName string, flags FilterFlags) (*SyncReport, error) {
+	return s.Sync(context, flags, []string{orgLogin + "/" + repoName})
+}
+
+// selectOrgs returns the subset of the configured orgs containing the given org/repo pairs,
+// or all the configured orgs if no repos are given.
+func (s *Syncer) selectOrgs(repos []string) ([]config.Org, error) {
+	if len(repos) == 0 {
+		return s.orgs, nil
+	}
+
+	var result []config.Org
+	for _, r := range repos {
+		parts := strings.Split(r, "/")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid repo %s, expecting org/repo", r)
+		}
+
+		var org *config.Org
+		var repo *config.Repo
+		for i := range s.orgs {
+			if s.orgs[i].Name != parts[0] {
+				continue
+			}
+
+			for j := range s.orgs[i].Repos {
+				if s.orgs[i].Repos[j].Name == parts[1] {
+					org = &s.orgs[i]
+					repo = &s.orgs[i].Repos[j]
+					break
+				}
+			}
+		}
+
+		if repo == nil {
+			return nil, fmt.Errorf("repo %s is not configured", r)
+		}
+
+		i := 0
+		for i < len(result) && result[i].Name != org.Name {
+			i++
+		}
+
+		if i == len(result) {
+			// keep the rest of the org's configuration, but only the selected repos
+			selected := *org
+			selected.Repos = nil
+			result = append(result, selected)
+		}
+
+		result[i].Repos = append(result[i].Repos, *repo)
+	}
+
+	return result, nil
+}
+
+// SyncMembers refreshes the membership of a single configured org, without syncing anything else.
+func (s *Syncer) SyncMembers(context context.Context, orgLogin string) error {
+	found := false
+	for _, o := range s.orgs {
+		if o.Name == orgLogin {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("org %s is not configured", orgLogin)
+	}
+
+	ss := &syncState{
+		syncer: s,
+		users:  make(map[string]*storage.User),
+		flags:  Members,
+		ctx:    context,
+	}
+
+	if err := ss.handleMembers(&storage.Org{OrgLogin: orgLogin}); err != nil {
 		return err
 	}
 
-	return nil
+	return ss.pushUsers()
 }
 
+// pushUsers writes out all the users discovered during the sync. Most listing operations only return
+// partial users, so those without a name are completed from the cache or, failing that, fetched from
+// GitHub using a bounded pool of workers. Users that can't be fetched are still written as they are,
+// and an error summarizing the failed fetches is returned once everything has been written.
 func (ss *syncState) pushUsers() error {
 	users := make([]*storage.User, 0, len(ss.users))
+	var incomplete []interface{}
 	for _, user := range ss.users {
-
 		if user.Name == "" {
-			// Turns out most listing operations return only incomplete users. If we find
-			// a user without a name, we try to fetch the full user info from GitHub.
+			if cached := ss.cachedUser(user.UserLogin); cached != nil {
+				user = cached
+			} else {
+				incomplete = append(incomplete, user)
+				continue
+			}
+		}
+
+		users = append(users, user)
+	}
 
-			if u, _, err := ss.syncer.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
-				return client.Users.Get(ss.ctx, user.UserLogin)
-			}); err == nil {
-				user = gh.ConvertUser(u.(*github.User))
+	results, errs := ss.syncer.gc.BulkCall(ss.ctx, incomplete, func(item interface{}) (interface{}, *github.Response, error) {
+		u, err := ss.syncer.gc.GetUserCached(ss.ctx, item.(*storage.User).UserLogin)
+		return u, nil, err
+	}, userConcurrency)
+
+	var failed []string
+	var firstErr error
+	for i, item := range incomplete {
+		user := item.(*storage.User)
+		if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = errs[i]
 			}
+			failed = append(failed, user.UserLogin)
+		} else {
+			user = gh.ConvertUser(results[i].(*github.User))
 		}
 
 		users = append(users, user)
@@ -192,29 +487,128 @@ func (ss *syncState) pushUsers() error {
 		return err
 	}
 
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to get full user info from GitHub for %d user(s), including %s: %v", len(failed), failed[0], firstErr)
+	}
+
+	return nil
+}
+
+// cachedUser returns the cached info for the given user if it's complete, or nil otherwise.
+func (ss *syncState) cachedUser(userLogin string) *storage.User {
+	if ss.syncer.cache == nil {
+		return nil
+	}
+
+	if user, err := ss.syncer.cache.ReadUser(ss.ctx, userLogin); err == nil && user != nil && user.Name != "" {
+		return user
+	}
+
 	return nil
 }
 
 func (ss *syncState) handleOrg(org *storage.Org, repos []*storage.Repo) error {
 	scope.Infof("Syncing org %s", org.OrgLogin)
 
-	for _, repo := range repos {
-		if err := ss.handleRepo(repo); err != nil {
-			return err
-		}
+	if err := ss.handleRepos(repos); err != nil {
+		return err
 	}
 
 	if ss.flags&Members != 0 {
 		if err := ss.handleMembers(org); err != nil {
-			return err
+			ss.failures = append(ss.failures, &RepoError{OrgLogin: org.OrgLogin, Err: err})
+		}
+	}
+
+	if ss.flags&Teams != 0 {
+		if err := ss.handleTeams(org); err != nil {
+			ss.failures = append(ss.failures, &RepoError{OrgLogin: org.OrgLogin, Err: err})
 		}
 	}
 
 	return nil
 }
 
+// handleRepos syncs the given repos using a bounded pool of workers. Each worker operates on its
+// own syncState so that discovered users and failures can be collected without locking, and these
+// are merged back once the worker is done. A repo failing to sync doesn't prevent the other repos
+// from being synced, the only error returned is when the sync's context is canceled.
+func (ss *syncState) handleRepos(repos []*storage.Repo) error {
+	workers := ss.syncer.SyncConcurrency
+	if workers <= 0 {
+		workers = 1
+	}
+
+	work := make(chan *storage.Repo)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			wss := &syncState{
+				syncer:     ss.syncer,
+				users:      make(map[string]*storage.User),
+				flags:      ss.flags,
+				ctx:        ss.ctx,
+				repoStats:  make(map[string]*RepoStats),
+				useGraphQL: ss.useGraphQL,
+			}
+
+			for repo := range work {
+				if ss.ctx.Err() != nil {
+					// drain the remaining work once canceled
+					continue
+				}
+
+				if err := wss.handleRepo(repo); err != nil {
+					scope.Errorf("Unable to sync repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+					wss.failures = append(wss.failures, &RepoError{OrgLogin: repo.OrgLogin, RepoName: repo.RepoName, Err: err})
+				}
+			}
+
+			mu.Lock()
+			for login, user := range wss.users {
+				ss.users[login] = user
+			}
+			ss.failures = append(ss.failures, wss.failures...)
+			for key, rs := range wss.repoStats {
+				ss.repoStats[key] = rs
+			}
+			mu.Unlock()
+		}()
+	}
+
+	for _, repo := range repos {
+		if ss.ctx.Err() != nil {
+			break
+		}
+		work <- repo
+	}
+	close(work)
+	wg.Wait()
+
+	return ss.ctx.Err()
+}
+
 func (ss *syncState) handleRepo(repo *storage.Repo) error {
-	scope.Infof("Syncing repo %s/%s", repo.OrgLogin, repo.RepoName)
+	scope.Infof("Syncing repo %s/%s, GitHub rate limit: %v", repo.OrgLogin, repo.RepoName, ss.syncer.gc.RateLimitSnapshot())
+
+	start := time.Now()
+	rs := &RepoStats{}
+	ss.currentRepo = rs
+	defer func() {
+		rs.Duration = time.Since(start)
+		ss.repoStats[repo.OrgLogin+"/"+repo.RepoName] = rs
+		ss.currentRepo = nil
+
+		scope.Infof("Synced repo %s/%s in %v: %d issues, %d pull requests, %d comments, %d events",
+			repo.OrgLogin, repo.RepoName, rs.Duration, rs.Issues, rs.PullRequests, rs.Comments, rs.Events)
+
+		scope.Infof("GitHub rate limit after syncing repo %s/%s: %v", repo.OrgLogin, repo.RepoName, ss.syncer.gc.RateLimitSnapshot())
+	}()
 
 	if ss.flags&Labels != 0 {
 		if err := ss.handleLabels(repo); err != nil {
@@ -222,37 +616,78 @@ func (ss *syncState) handleRepo(repo *storage.Repo) error {
 		}
 	}
 
+	if ss.flags&Milestones != 0 {
+		if err := ss.handleMilestones(repo); err != nil {
+			return err
+		}
+	}
+
+	if ss.flags&Releases != 0 {
+		if err := ss.handleReleases(repo); err != nil {
+			return err
+		}
+	}
+
+	// the sync checkpoints only advance once the whole repo has synced cleanly
+	var checkpoints []func()
+
 	if ss.flags&Issues != 0 {
-		if err := ss.handleActivity(repo, ss.handleIssues, func(activity *storage.BotActivity) *time.Time {
+		checkpoint, err := ss.handleActivity(repo, ss.handleIssues, func(activity *storage.BotActivity) *time.Time {
 			return &activity.LastIssueSyncStart
-		}); err != nil {
+		}, func(activity *storage.BotActivity) *int64 {
+			return &activity.LastIssuePage
+		})
+		if err != nil {
 			return err
 		}
+		checkpoints = append(checkpoints, checkpoint)
 
-		if err := ss.handleActivity(repo, ss.handleIssueComments, func(activity *storage.BotActivity) *time.Time {
+		checkpoint, err = ss.handleActivity(repo, ss.handleIssueComments, func(activity *storage.BotActivity) *time.Time {
 			return &activity.LastIssueCommentSyncStart
-		}); err != nil {
+		}, func(activity *storage.BotActivity) *int64 {
+			return &activity.LastIssueCommentPage
+		})
+		if err != nil {
 			return err
 		}
-
+		checkpoints = append(checkpoints, checkpoint)
 	}
 
 	if ss.flags&ZenHub != 0 {
-		if err := ss.handleZenHub(repo); err != nil {
+		// ZenHub issue data isn't paged, so there's nothing to resume from
+		handleZenHub := func(repo *storage.Repo, startTime time.Time, _ *pageCursor) error {
+			return ss.handleZenHub(repo, startTime)
+		}
+
+		checkpoint, err := ss.handleActivity(repo, handleZenHub, func(activity *storage.BotActivity) *time.Time {
+			return &activity.LastZenHubSyncStart
+		}, nil)
+		if err != nil {
 			return err
 		}
+		checkpoints = append(checkpoints, checkpoint)
 	}
 
 	if ss.flags&Prs != 0 {
-		if err := ss.handlePullRequests(repo); err != nil {
+		checkpoint, err := ss.handleActivity(repo, ss.handlePullRequests, func(activity *storage.BotActivity) *time.Time {
+			return &activity.LastPullRequestSyncStart
+		}, func(activity *storage.BotActivity) *int64 {
+			return &activity.LastPullRequestPage
+		})
+		if err != nil {
 			return err
 		}
+		checkpoints = append(checkpoints, checkpoint)
 
-		if err := ss.handleActivity(repo, ss.handlePullRequestReviewComments, func(activity *storage.BotActivity) *time.Time {
+		checkpoint, err = ss.handleActivity(repo, ss.handlePullRequestReviewComments, func(activity *storage.BotActivity) *time.Time {
 			return &activity.LastPullRequestReviewCommentSyncStart
-		}); err != nil {
+		}, func(activity *storage.BotActivity) *int64 {
+			return &activity.LastPullRequestReviewCommentPage
+		})
+		if err != nil {
 			return err
 		}
+		checkpoints = append(checkpoints, checkpoint)
 	}
 
 	if ss.flags&RepoComments != 0 {
@@ -267,43 +702,114 @@ func (ss *syncState) handleRepo(repo *storage.Repo) error {
 		}
 	}
 
+	for _, checkpoint := range checkpoints {
+		checkpoint()
+	}
+
 	return nil
 }
 
-func (ss *syncState) handleActivity(repo *storage.Repo, cb func(*storage.Repo, time.Time) error,
-	getField func(*storage.BotActivity) *time.Time) error {
+// handleActivity invokes the callback with the time of the last sync, and returns a function
+// which advances that time to the start of this sync. When getPage is given, the callback also
+// gets a cursor recording how far it has paged, which lets a sync started with Resume pick up
+// where an interrupted one left off. The cursor is cleared once the sync advances.
+func (ss *syncState) handleActivity(repo *storage.Repo, cb func(*storage.Repo, time.Time, *pageCursor) error,
+	getField func(*storage.BotActivity) *time.Time, getPage func(*storage.BotActivity) *int64) (func(), error) {
 
 	start := time.Now().UTC()
 	priorStart := time.Time{}
 
-	if activity, _ := ss.syncer.store.ReadBotActivity(ss.ctx, repo.OrgLogin, repo.RepoName); activity != nil {
+	activity, _ := ss.syncer.store.ReadBotActivity(ss.ctx, repo.OrgLogin, repo.RepoName)
+	if activity != nil {
 		priorStart = *getField(activity)
 	}
 
-	if err := cb(repo, priorStart); err != nil {
-		return err
-	}
+	var cursor *pageCursor
+	if getPage != nil {
+		cursor = &pageCursor{
+			save: func(page int) {
+				if err := ss.syncer.store.UpdateBotActivity(ss.ctx, repo.OrgLogin, repo.RepoName, func(act *storage.BotActivity) error {
+					if *getField(act) == priorStart {
+						*getPage(act) = int64(page)
+					}
+					return nil
+				}); err != nil {
+					scope.Warnf("unable to record sync progress for repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+				}
+			},
+		}
 
-	if err := ss.syncer.store.UpdateBotActivity(ss.ctx, repo.OrgLogin, repo.RepoName, func(act *storage.BotActivity) error {
-		if *getField(act) == priorStart {
-			*getField(act) = start
+		if ss.syncer.Resume && activity != nil {
+			cursor.start = int(*getPage(activity))
 		}
-		return nil
-	}); err != nil {
-		scope.Warnf("unable to update bot activity for repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
 	}
 
-	return nil
+	if err := cb(repo, priorStart, cursor); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if err := ss.syncer.store.UpdateBotActivity(ss.ctx, repo.OrgLogin, repo.RepoName, func(act *storage.BotActivity) error {
+			if *getField(act) == priorStart {
+				// Data updated since the interrupted sync started may have landed on the pages a resumed sync
+				// skipped, so leave the time alone to have the next sync pick it up.
+				if !cursor.resumed() {
+					*getField(act) = start
+				}
+				if getPage != nil {
+					*getPage(act) = 0
+				}
+			}
+			return nil
+		}); err != nil {
+			scope.Warnf("unable to update bot activity for repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+		}
+	}, nil
 }
 
 func (ss *syncState) handleMembers(org *storage.Org) error {
 	scope.Debugf("Getting members from org %s", org.OrgLogin)
 
+	// GitHub doesn't report roles when listing members, so find out who the admins are separately
+	admins := make(map[string]bool)
+	if err := ss.syncer.fetchAdmins(ss.ctx, org, func(users []*github.User) error {
+		for _, user := range users {
+			admins[user.GetLogin()] = true
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
 	var storageMembers []*storage.Member
 	if err := ss.syncer.fetchMembers(ss.ctx, org, func(members []*github.User) error {
 		for _, member := range members {
+			role := "member"
+			if admins[member.GetLogin()] {
+				role = "admin"
+			}
+
 			ss.addUsers(gh.ConvertUser(member))
-			storageMembers = append(storageMembers, &storage.Member{OrgLogin: org.OrgLogin, UserLogin: member.GetLogin()})
+			storageMembers = append(storageMembers, &storage.Member{OrgLogin: org.OrgLogin, UserLogin: member.GetLogin(), Role: role})
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return ss.syncer.store.WriteAllMembers(ss.ctx, []string{org.OrgLogin}, storageMembers)
+}
+
+func (ss *syncState) handleTeams(org *storage.Org) error {
+	scope.Debugf("Getting teams from org %s", org.OrgLogin)
+
+	// the list of teams includes nested teams, which record their parent
+	var storageTeams []*storage.Team
+	if err := ss.syncer.fetchTeams(ss.ctx, org, func(teams []*github.Team) error {
+		for _, team := range teams {
+			storageTeams = append(storageTeams, gh.ConvertTeam(org.OrgLogin, team))
 		}
 
 		return nil
@@ -311,7 +817,34 @@ func (ss *syncState) handleMembers(org *storage.Org) error {
 		return err
 	}
 
-	return ss.syncer.store.WriteAllMembers(ss.ctx, storageMembers)
+	if err := ss.syncer.store.WriteTeams(ss.ctx, storageTeams); err != nil {
+		return err
+	}
+
+	var storageMembers []*storage.TeamMember
+	for _, team := range storageTeams {
+		if err := ss.syncer.fetchTeamMembers(ss.ctx, org, team, func(members []*github.User) error {
+			for _, member := range members {
+				ss.addUsers(gh.ConvertUser(member))
+				storageMembers = append(storageMembers, &storage.TeamMember{
+					OrgLogin:  org.OrgLogin,
+					TeamID:    team.TeamID,
+					UserLogin: member.GetLogin(),
+				})
+			}
+
+			return nil
+		}); err != nil {
+			if ss.ctx.Err() != nil {
+				return err
+			}
+
+			// secret teams and those we lack access to can't be listed, don't let them hold back the others
+			scope.Warnf("Unable to get members of team %s in org %s, skipping it: %v", team.TeamSlug, org.OrgLogin, err)
+		}
+	}
+
+	return ss.syncer.store.WriteAllTeamMembers(ss.ctx, org.OrgLogin, storageMembers)
 }
 
 func (ss *syncState) handleLabels(repo *storage.Repo) error {
@@ -327,11 +860,44 @@ func (ss *syncState) handleLabels(repo *storage.Repo) error {
 	})
 }
 
+func (ss *syncState) handleMilestones(repo *storage.Repo) error {
+	scope.Debugf("Getting milestones from repo %s/%s", repo.OrgLogin, repo.RepoName)
+
+	return ss.syncer.fetchMilestones(ss.ctx, repo, func(milestones []*github.Milestone) error {
+		storageMilestones := make([]*storage.Milestone, 0, len(milestones))
+		for _, milestone := range milestones {
+			storageMilestones = append(storageMilestones, gh.ConvertMilestone(repo.OrgLogin, repo.RepoName, milestone))
+		}
+
+		return ss.syncer.store.WriteMilestones(ss.ctx, storageMilestones)
+	})
+}
+
+func (ss *syncState) handleReleases(repo *storage.Repo) error {
+	scope.Debugf("Getting releases from repo %s/%s", repo.OrgLogin, repo.RepoName)
+
+	return ss.syncer.fetchReleases(ss.ctx, repo, func(releases []*github.RepositoryRelease) error {
+		storageReleases := make([]*storage.Release, 0, len(releases))
+		for _, release := range releases {
+			r, users := gh.ConvertRelease(repo.OrgLogin, repo.RepoName, release)
+			storageReleases = append(storageReleases, r)
+			ss.addUsers(users...)
+		}
+
+		return ss.syncer.store.WriteReleases(ss.ctx, storageReleases)
+	})
+}
+
 func (ss *syncState) handleEvents(repo *storage.Repo) error {
 	scope.Debugf("Getting events from repo %s/%s", repo.OrgLogin, repo.RepoName)
 
 	total := 0
 	err := ss.syncer.fetchRepoEvents(ss.ctx, repo, func(events []*github.Event) error {
+		if err := ss.ctx.Err(); err != nil {
+			// the sync has been canceled, don't bother with the remaining pages
+			return err
+		}
+
 		var issueEvents []*storage.IssueEvent
 		var issueCommentEvents []*storage.IssueCommentEvent
 		var prEvents []*storage.PullRequestEvent
@@ -358,6 +924,8 @@ func (ss *syncState) handleEvents(repo *storage.Repo) error {
 					CreatedAt:   event.GetCreatedAt(),
 					Actor:       event.GetActor().GetLogin(),
 					Action:      p.GetEvent(),
+					Label:       gh.ConvertIssueEventLabel(p),
+					Assignee:    gh.ConvertIssueEventAssignee(p),
 				})
 
 			case "IssueCommentEvent":
@@ -393,7 +961,7 @@ func (ss *syncState) handleEvents(repo *storage.Repo) error {
 					PullRequestNumber: int64(p.GetPullRequest().GetNumber()),
 					CreatedAt:         event.GetCreatedAt(),
 					Actor:             event.GetActor().GetLogin(),
-					Action:            p.GetAction(),
+					Action:            gh.ConvertPullRequestAction(p.GetAction(), p.GetPullRequest()),
 				})
 
 			case "PullRequestCommentEvent":
@@ -438,30 +1006,35 @@ func (ss *syncState) handleEvents(repo *storage.Repo) error {
 			if err := ss.syncer.store.WriteIssueEvents(ss.ctx, issueEvents); err != nil {
 				return fmt.Errorf("unable to write issue events to storage: %v", err)
 			}
+			ss.currentRepo.Events += len(issueEvents)
 		}
 
 		if len(issueCommentEvents) > 0 {
 			if err := ss.syncer.store.WriteIssueCommentEvents(ss.ctx, issueCommentEvents); err != nil {
 				return fmt.Errorf("unable to write issue comment events to storage: %v", err)
 			}
+			ss.currentRepo.Events += len(issueCommentEvents)
 		}
 
 		if len(prEvents) > 0 {
 			if err := ss.syncer.store.WritePullRequestEvents(ss.ctx, prEvents); err != nil {
 				return fmt.Errorf("unable to write pull request events to storage: %v", err)
 			}
+			ss.currentRepo.Events += len(prEvents)
 		}
 
 		if len(prCommentEvents) > 0 {
 			if err := ss.syncer.store.WritePullRequestReviewCommentEvents(ss.ctx, prCommentEvents); err != nil {
 				return fmt.Errorf("unable to write pull request review comment events to storage: %v", err)
 			}
+			ss.currentRepo.Events += len(prCommentEvents)
 		}
 
 		if len(prReviewEvents) > 0 {
 			if err := ss.syncer.store.WritePullRequestReviewEvents(ss.ctx, prReviewEvents); err != nil {
 				return fmt.Errorf("unable to write pull request review events to storage: %v", err)
 			}
+			ss.currentRepo.Events += len(prReviewEvents)
 		}
 
 		return nil
@@ -472,6 +1045,11 @@ func (ss *syncState) handleEvents(repo *storage.Repo) error {
 	}
 
 	return ss.syncer.fetchIssueEvents(ss.ctx, repo, func(events []*github.IssueEvent) error {
+		if err := ss.ctx.Err(); err != nil {
+			// the sync has been canceled, don't bother with the remaining pages
+			return err
+		}
+
 		var issueEvents []*storage.IssueEvent
 
 		total += len(events)
@@ -492,6 +1070,7 @@ func (ss *syncState) handleEvents(repo *storage.Repo) error {
 			if err := ss.syncer.store.WriteIssueEvents(ss.ctx, issueEvents); err != nil {
 				return fmt.Errorf("unable to write issue events to storage: %v", err)
 			}
+			ss.currentRepo.Events += len(issueEvents)
 		}
 
 		return nil
@@ -509,35 +1088,230 @@ func (ss *syncState) handleRepoComments(repo *storage.Repo) error {
 			ss.addUsers(users...)
 		}
 
-		return ss.syncer.store.WriteRepoComments(ss.ctx, storageComments)
+		if err := ss.syncer.store.WriteRepoComments(ss.ctx, storageComments); err != nil {
+			return err
+		}
+
+		ss.currentRepo.Comments += len(storageComments)
+		return nil
 	})
 }
 
-func (ss *syncState) handleIssues(repo *storage.Repo, startTime time.Time) error {
+func (ss *syncState) handleIssues(repo *storage.Repo, startTime time.Time, cursor *pageCursor) error {
 	scope.Debugf("Getting issues from repo %s/%s", repo.OrgLogin, repo.RepoName)
 
-	total := 0
-	return ss.syncer.fetchIssues(ss.ctx, repo, startTime, func(issues []*github.Issue) error {
-		var storageIssues []*storage.Issue
-
-		total += len(issues)
-		scope.Infof("Received %d issues", total)
+	ss.issueCommentsSynced = false
 
-		for _, issue := range issues {
-			t, users := gh.ConvertIssue(repo.OrgLogin, repo.RepoName, issue)
-			storageIssues = append(storageIssues, t)
-			ss.addUsers(users...)
+	seen := make(map[int64]bool)
+	if ss.useGraphQL {
+		err := ss.handleIssuesBulk(repo, startTime, seen)
+		if err == nil {
+			ss.issueCommentsSynced = true
+			return ss.finishIssues(repo, startTime, seen)
+		}
+
+		if ss.ctx.Err() != nil {
+			return err
+		}
+
+		scope.Warnf("Unable to bulk fetch issues from repo %s/%s, falling back to individual calls: %v", repo.OrgLogin, repo.RepoName, err)
+	}
+
+	if cursor.resumed() {
+		scope.Infof("Resuming sync of issues from repo %s/%s at page %d", repo.OrgLogin, repo.RepoName, cursor.firstPage())
+	}
+
+	total := 0
+	if err := ss.syncer.fetchIssues(ss.ctx, repo, startTime, cursor, func(issues []*github.Issue) error {
+		if err := ss.ctx.Err(); err != nil {
+			// the sync has been canceled, don't bother with the remaining pages
+			return err
+		}
+
+		total += len(issues)
+		scope.Infof("Received %d issues", total)
+
+		return ss.writeIssues(repo, issues, seen)
+	}); err != nil {
+		return err
+	}
+
+	if cursor.resumed() {
+		// the issues on the pages handled before the sync was interrupted weren't seen this time around
+		return nil
+	}
+
+	return ss.finishIssues(repo, startTime, seen)
+}
+
+// handleIssuesBulk syncs issues like handleIssues, but fetches them along with their comments using GitHub's
+// GraphQL API, which takes far fewer calls. The comments of issues with more comments than fit in the bulk query are
+// fetched individually.
+func (ss *syncState) handleIssuesBulk(repo *storage.Repo, startTime time.Time, seen map[int64]bool) error {
+	total := 0
+	return ss.syncer.fetchIssuesBulk(ss.ctx, repo, startTime, func(bulk []*gh.BulkIssue) error {
+		if err := ss.ctx.Err(); err != nil {
+			// the sync has been canceled, don't bother with the remaining pages
+			return err
+		}
+
+		total += len(bulk)
+		scope.Infof("Received %d issues", total)
+
+		issues := make([]*github.Issue, len(bulk))
+		for i, b := range bulk {
+			issues[i] = b.Issue
 		}
 
-		return ss.syncer.store.WriteIssues(ss.ctx, storageIssues)
+		if err := ss.writeIssues(repo, issues, seen); err != nil {
+			return err
+		}
+
+		for _, b := range bulk {
+			if !b.IncompleteComments {
+				if err := ss.writeIssueComments(repo, b.Issue.GetNumber(), b.Comments); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := ss.syncer.fetchCommentsForIssue(ss.ctx, repo, b.Issue.GetNumber(), func(comments []*github.IssueComment) error {
+				return ss.writeIssueComments(repo, b.Issue.GetNumber(), comments)
+			}); err != nil {
+				return err
+			}
+		}
+
+		return nil
 	})
 }
 
-func (ss *syncState) handleIssueComments(repo *storage.Repo, startTime time.Time) error {
+// writeIssues stores a page of issues, noting which issues were seen.
+func (ss *syncState) writeIssues(repo *storage.Repo, issues []*github.Issue, seen map[int64]bool) error {
+	var storageIssues []*storage.Issue
+	for _, issue := range issues {
+		t, users := gh.ConvertIssue(repo.OrgLogin, repo.RepoName, issue)
+		storageIssues = append(storageIssues, t)
+		ss.addUsers(users...)
+		seen[t.IssueNumber] = true
+	}
+
+	if err := ss.syncer.store.WriteIssues(ss.ctx, storageIssues); err != nil {
+		return err
+	}
+
+	if err := ss.syncer.store.WriteAllIssueAssignees(ss.ctx, storageIssues); err != nil {
+		return err
+	}
+
+	if err := ss.syncer.store.WriteAllIssueLabels(ss.ctx, storageIssues); err != nil {
+		return err
+	}
+
+	ss.currentRepo.Issues += len(storageIssues)
+	return nil
+}
+
+// writeIssueComments stores comments on a single issue.
+func (ss *syncState) writeIssueComments(repo *storage.Repo, issueNumber int, comments []*github.IssueComment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	storageIssueComments := make([]*storage.IssueComment, 0, len(comments))
+	for _, comment := range comments {
+		t, users := gh.ConvertIssueComment(repo.OrgLogin, repo.RepoName, issueNumber, comment)
+		storageIssueComments = append(storageIssueComments, t)
+		ss.addUsers(users...)
+	}
+
+	if err := ss.syncer.store.WriteIssueComments(ss.ctx, storageIssueComments); err != nil {
+		return err
+	}
+
+	ss.currentRepo.Comments += len(storageIssueComments)
+	return nil
+}
+
+// finishIssues reconciles the stored issues with those seen by a full sync.
+func (ss *syncState) finishIssues(repo *storage.Repo, startTime time.Time, seen map[int64]bool) error {
+	if !startTime.IsZero() {
+		// a delta sync only sees the issues updated in the sync window, so we can't tell what's been deleted
+		return nil
+	}
+
+	return ss.reconcileIssues(repo, seen)
+}
+
+// reconcileIssues marks as deleted any stored issues for the repo that GitHub no longer reports,
+// which happens when an issue is deleted or transferred to another repo. Since GitHub lists PRs
+// as issues too, this also covers PRs.
+func (ss *syncState) reconcileIssues(repo *storage.Repo, seen map[int64]bool) error {
+	var missing []int64
+	if err := ss.syncer.store.QueryIssuesByRepo(ss.ctx, repo.OrgLogin, repo.RepoName, func(issue *storage.Issue) error {
+		if !issue.Deleted && !seen[issue.IssueNumber] {
+			missing = append(missing, issue.IssueNumber)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("unable to read issues from repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if err := ss.handleIssueTransfers(repo, missing); err != nil {
+		return err
+	}
+
+	scope.Infof("Marking %d issues as deleted in repo %s/%s", len(missing), repo.OrgLogin, repo.RepoName)
+	return ss.syncer.store.MarkIssuesDeleted(ss.ctx, repo.OrgLogin, repo.RepoName, missing)
+}
+
+// handleIssueTransfers records where the given issues, which GitHub no longer reports in the repo, were transferred.
+// GitHub redirects requests for a transferred issue to its new location, whereas deleted issues can't be fetched.
+func (ss *syncState) handleIssueTransfers(repo *storage.Repo, missing []int64) error {
+	var transfers []*storage.IssueTransfer
+	for _, number := range missing {
+		issue, _, err := ss.syncer.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
+			return client.Issues.Get(ss.ctx, repo.OrgLogin, repo.RepoName, int(number))
+		})
+
+		if err != nil {
+			// most likely deleted
+			scope.Debugf("Unable to get issue %d from repo %s/%s: %v", number, repo.OrgLogin, repo.RepoName, err)
+			continue
+		}
+
+		if t := gh.ConvertIssueTransfer(repo.OrgLogin, repo.RepoName, int(number), issue.(*github.Issue)); t != nil {
+			transfers = append(transfers, t)
+		}
+	}
+
+	if len(transfers) == 0 {
+		return nil
+	}
+
+	scope.Infof("Recording %d issues transferred out of repo %s/%s", len(transfers), repo.OrgLogin, repo.RepoName)
+	if err := ss.syncer.store.WriteIssueTransfers(ss.ctx, transfers); err != nil {
+		return fmt.Errorf("unable to write issue transfers for repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+	}
+
+	return nil
+}
+
+func (ss *syncState) handleIssueComments(repo *storage.Repo, startTime time.Time, cursor *pageCursor) error {
+	if ss.issueCommentsSynced {
+		// comments on the issues updated since the last sync were fetched together with the issues
+		scope.Debugf("Issue comments from repo %s/%s were synced along with the issues", repo.OrgLogin, repo.RepoName)
+		return nil
+	}
+
 	scope.Debugf("Getting issue comments from repo %s/%s", repo.OrgLogin, repo.RepoName)
 
 	total := 0
-	return ss.syncer.fetchIssueComments(ss.ctx, repo, startTime, func(comments []*github.IssueComment) error {
+	return ss.syncer.fetchIssueComments(ss.ctx, repo, startTime, cursor, func(comments []*github.IssueComment) error {
 		var storageIssueComments []*storage.IssueComment
 
 		total += len(comments)
@@ -551,323 +1325,498 @@ func (ss *syncState) handleIssueComments(repo *storage.Repo, startTime time.Time
 			ss.addUsers(users...)
 		}
 
-		return ss.syncer.store.WriteIssueComments(ss.ctx, storageIssueComments)
+		if err := ss.syncer.store.WriteIssueComments(ss.ctx, storageIssueComments); err != nil {
+			return err
+		}
+
+		ss.currentRepo.Comments += len(storageIssueComments)
+		return nil
 	})
 }
 
-func (ss *syncState) handleZenHub(repo *storage.Repo) error {
+// handleZenHub refreshes the ZenHub pipelines of the issues updated since the last ZenHub sync, or of all
+// issues when startTime is zero. Pipeline moves don't touch the GitHub issue, but those are delivered to
+// the ZenHub webhook as they happen, so this only needs to catch up on issues it hasn't seen yet.
+func (ss *syncState) handleZenHub(repo *storage.Repo, startTime time.Time) error {
 	scope.Debugf("Getting ZenHub issue data for repo %s/%s", repo.OrgLogin, repo.RepoName)
 
-	// get all the issues
+	// get the issues to look up, skipping those known to be gone from GitHub
 	var issues []*storage.Issue
-	if err := ss.syncer.store.QueryIssuesByRepo(ss.ctx, repo.OrgLogin, repo.RepoName, func(issue *storage.Issue) error {
-		issues = append(issues, issue)
+	cb := func(issue *storage.Issue) error {
+		if !issue.Deleted {
+			issues = append(issues, issue)
+		}
 		return nil
-	}); err != nil {
+	}
+
+	var err error
+	if startTime.IsZero() {
+		err = ss.syncer.store.QueryIssuesByRepo(ss.ctx, repo.OrgLogin, repo.RepoName, cb)
+	} else {
+		err = ss.syncer.store.QueryIssuesUpdatedSince(ss.ctx, repo.OrgLogin, repo.RepoName, startTime, cb)
+	}
+
+	if err != nil {
 		return fmt.Errorf("unable to read issues from repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
 	}
 
 	// now get the ZenHub data for all issues
-	var pipelines []*storage.IssuePipeline
-	for _, issue := range issues {
+	items := make([]interface{}, len(issues))
+	for i, issue := range issues {
+		items[i] = issue
+	}
+
+	results, errs := ss.syncer.gc.BulkCall(ss.ctx, items, func(item interface{}) (interface{}, *github.Response, error) {
+		if err := ss.ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		issue := item.(*storage.Issue)
 		issueData, err := ss.syncer.zc.ThrottledCall(func(client *zh.Client) (interface{}, error) {
 			return client.GetIssueData(int(repo.RepoNumber), int(issue.IssueNumber))
 		})
-
 		if err != nil {
-			if err == zh.ErrNotFound {
-				// not found, so nothing to do...
-				return nil
+			return nil, nil, err
+		}
+
+		// the issue data is still recorded if its pipeline history can't be fetched
+		result := &zenHubIssue{data: issueData.(*zh.IssueData)}
+		result.events, err = ss.fetchPipelineEvents(repo, issue)
+		return result, nil, err
+	}, zenHubConcurrency)
+
+	if err := ss.ctx.Err(); err != nil {
+		// some of the issues were never looked up
+		return err
+	}
+
+	var pipelines []*storage.IssuePipeline
+	var events []*storage.IssuePipelineEvent
+	var skipped []int64
+	var failed []int64
+	var firstErr error
+	for i, issue := range issues {
+		result, err := results[i], errs[i]
+		if err == zh.ErrNotFound {
+			// not found, so there's no pipeline for this issue
+			skipped = append(skipped, issue.IssueNumber)
+			continue
+		} else if err != nil {
+			// keep going so one bad issue doesn't hold back the rest of the repo
+			scope.Warnf("Unable to get issue data from ZenHub for issue %d in repo %s/%s: %v", issue.IssueNumber, repo.OrgLogin, repo.RepoName, err)
+			if firstErr == nil {
+				firstErr = err
 			}
+			failed = append(failed, issue.IssueNumber)
+		}
 
-			return fmt.Errorf("unable to get issue data from ZenHub for issue %d in repo %s/%s: %v", issue.IssueNumber, repo.OrgLogin, repo.RepoName, err)
+		if result == nil {
+			continue
 		}
 
+		zi := result.(*zenHubIssue)
 		pipelines = append(pipelines, &storage.IssuePipeline{
 			OrgLogin:    repo.OrgLogin,
 			RepoName:    repo.RepoName,
 			IssueNumber: issue.IssueNumber,
-			Pipeline:    issueData.(*zh.IssueData).Pipeline.Name,
+			Pipeline:    zi.data.Pipeline.Name,
+			Estimate:    int64(zi.data.Estimate.Value),
 		})
+		events = append(events, zi.events...)
 
 		if len(pipelines)%100 == 0 {
-			if err = ss.syncer.store.WriteIssuePipelines(ss.ctx, pipelines); err != nil {
+			if err = ss.writePipelines(pipelines, events); err != nil {
 				return err
 			}
 			pipelines = pipelines[:0]
+			events = events[:0]
 		}
 	}
 
-	return ss.syncer.store.WriteIssuePipelines(ss.ctx, pipelines)
-}
+	if err := ss.writePipelines(pipelines, events); err != nil {
+		return err
+	}
 
-func (ss *syncState) handlePullRequests(repo *storage.Repo) error {
-	scope.Debugf("Getting pull requests from repo %s/%s", repo.OrgLogin, repo.RepoName)
+	if len(skipped) > 0 {
+		scope.Infof("Skipped %d issues in repo %s/%s since they have no ZenHub data", len(skipped), repo.OrgLogin, repo.RepoName)
 
-	total := 0
-	return ss.syncer.fetchPullRequests(ss.ctx, repo, func(prs []*github.PullRequest) error {
-		var storagePRs []*storage.PullRequest
-		var storagePRReviews []*storage.PullRequestReview
+		// get rid of any stale pipelines for the issues ZenHub doesn't know about
+		if err := ss.syncer.store.DeleteIssuePipelines(ss.ctx, repo.OrgLogin, repo.RepoName, skipped); err != nil {
+			return err
+		}
+	}
 
-		total += len(prs)
-		scope.Infof("Received %d pull requests", total)
+	if err := ss.handleEpics(repo); err != nil {
+		return err
+	}
 
-		for _, pr := range prs {
-			// if this pr is already known to us and is up to date, skip further processing
-			if existing, _ := ss.syncer.cache.ReadPullRequest(ss.ctx, repo.OrgLogin, repo.RepoName, pr.GetNumber()); existing != nil {
-				if existing.UpdatedAt == pr.GetUpdatedAt() {
-					continue
-				}
-			}
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to get issue data from ZenHub for %d issue(s) in repo %s/%s, including issue %d: %v",
+			len(failed), repo.OrgLogin, repo.RepoName, failed[0], firstErr)
+	}
 
-			if err := ss.syncer.fetchReviews(ss.ctx, repo, pr.GetNumber(), func(reviews []*github.PullRequestReview) error {
-				for _, review := range reviews {
-					t, users := gh.ConvertPullRequestReview(repo.OrgLogin, repo.RepoName, pr.GetNumber(), review)
-					storagePRReviews = append(storagePRReviews, t)
-					ss.addUsers(users...)
-				}
+	return nil
+}
 
-				return nil
-			}); err != nil {
-				return err
-			}
+// zenHubIssue holds what's fetched from ZenHub for a single issue
+type zenHubIssue struct {
+	data   *zh.IssueData
+	events []*storage.IssuePipelineEvent
+}
 
-			var prFiles []string
-			if err := ss.syncer.fetchFiles(ss.ctx, repo, pr.GetNumber(), func(files []string) error {
-				prFiles = append(prFiles, files...)
-				return nil
-			}); err != nil {
-				return err
-			}
+// fetchPipelineEvents returns the pipeline moves of an issue that happened after the latest one already
+// recorded. ZenHub always returns the full history of an issue, so this only saves on storage writes.
+func (ss *syncState) fetchPipelineEvents(repo *storage.Repo, issue *storage.Issue) ([]*storage.IssuePipelineEvent, error) {
+	var since time.Time
+	latest, err := ss.syncer.store.ReadLatestIssuePipelineEvent(ss.ctx, repo.OrgLogin, repo.RepoName, issue.IssueNumber)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read pipeline events of issue %d in repo %s/%s: %v", issue.IssueNumber, repo.OrgLogin, repo.RepoName, err)
+	} else if latest != nil {
+		since = latest.CreatedAt
+	}
 
-			t, users := gh.ConvertPullRequest(repo.OrgLogin, repo.RepoName, pr, prFiles)
-			storagePRs = append(storagePRs, t)
-			ss.addUsers(users...)
+	result, err := ss.syncer.zc.ThrottledCall(func(client *zh.Client) (interface{}, error) {
+		return client.GetIssueEvents(int(repo.RepoNumber), int(issue.IssueNumber))
+	})
+	if err == zh.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get events from ZenHub for issue %d in repo %s/%s: %v", issue.IssueNumber, repo.OrgLogin, repo.RepoName, err)
+	}
+
+	var events []*storage.IssuePipelineEvent
+	for _, e := range result.([]zh.IssueEvent) {
+		if e.Type != zh.TransferIssueEvent || e.ToPipeline == nil || !e.CreatedAt.After(since) {
+			continue
 		}
 
-		err := ss.syncer.store.WritePullRequests(ss.ctx, storagePRs)
-		if err == nil {
-			err = ss.syncer.store.WritePullRequestReviews(ss.ctx, storagePRReviews)
+		event := &storage.IssuePipelineEvent{
+			OrgLogin:    repo.OrgLogin,
+			RepoName:    repo.RepoName,
+			IssueNumber: issue.IssueNumber,
+			CreatedAt:   e.CreatedAt,
+			ActorID:     int64(e.UserID),
+			ToPipeline:  e.ToPipeline.Name,
 		}
 
-		return err
-	})
+		if e.FromPipeline != nil {
+			event.FromPipeline = e.FromPipeline.Name
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
 }
 
-func (ss *syncState) handlePullRequestReviewComments(repo *storage.Repo, start time.Time) error {
-	scope.Debugf("Getting pull requests review comments from repo %s/%s", repo.OrgLogin, repo.RepoName)
+func (ss *syncState) writePipelines(pipelines []*storage.IssuePipeline, events []*storage.IssuePipelineEvent) error {
+	if err := ss.syncer.store.WriteIssuePipelines(ss.ctx, pipelines); err != nil {
+		return err
+	}
 
-	total := 0
-	return ss.syncer.fetchPullRequestReviewComments(ss.ctx, repo, start, func(comments []*github.PullRequestComment) error {
-		var storagePRComments []*storage.PullRequestReviewComment
+	return ss.syncer.store.WriteIssuePipelineEvents(ss.ctx, events)
+}
 
-		total += len(comments)
-		scope.Infof("Received %d pull request review comments", total)
+func (ss *syncState) handleEpics(repo *storage.Repo) error {
+	scope.Debugf("Getting ZenHub epics for repo %s/%s", repo.OrgLogin, repo.RepoName)
 
-		for _, comment := range comments {
-			prURL := comment.GetPullRequestURL()
-			prNumber, _ := strconv.Atoi(prURL[strings.LastIndex(prURL, "/")+1:])
-			t, users := gh.ConvertPullRequestReviewComment(repo.OrgLogin, repo.RepoName, prNumber, comment)
-			storagePRComments = append(storagePRComments, t)
-			ss.addUsers(users...)
-		}
+	var epics []*storage.Epic
+	var children []*storage.IssueEpic
 
-		return ss.syncer.store.WritePullRequestReviewComments(ss.ctx, storagePRComments)
+	result, err := ss.syncer.zc.ThrottledCall(func(client *zh.Client) (interface{}, error) {
+		return client.GetEpics(int(repo.RepoNumber))
 	})
-}
 
-func (ss *syncState) handleMaintainers(org *storage.Org, repos []*storage.Repo) error {
-	scope.Debugf("Getting maintainers for org %s", org.OrgLogin)
+	if err == zh.ErrNotFound {
+		// not found, so the repo has no epics and any recorded ones are stale
+		return ss.syncer.store.WriteAllEpics(ss.ctx, repo.OrgLogin, repo.RepoName, epics, children)
+	} else if err != nil {
+		return fmt.Errorf("unable to get epics from ZenHub for repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+	}
 
-	maintainers := make(map[string]*storage.Maintainer)
+	for _, epic := range result.(*zh.Epics).EpicIssues {
+		if err := ss.ctx.Err(); err != nil {
+			return err
+		}
 
-	for _, repo := range repos {
-		fc, _, _, err := ss.syncer.gc.ThrottledCallTwoResult(func(client *github.Client) (interface{}, interface{}, *github.Response, error) {
-			return client.Repositories.GetContents(ss.ctx, repo.OrgLogin, repo.RepoName, "CODEOWNERS", nil)
+		if epic.RepoID != 0 && int64(epic.RepoID) != repo.RepoNumber {
+			// the epic lives in another repo of the workspace and is recorded when that repo is synced
+			continue
+		}
+
+		data, err := ss.syncer.zc.ThrottledCall(func(client *zh.Client) (interface{}, error) {
+			return client.GetEpicData(int(repo.RepoNumber), epic.IssueNumber)
 		})
 
-		if err == nil {
-			err = ss.handleCODEOWNERS(org, repo, maintainers, fc.(*github.RepositoryContent))
-		} else {
-			err = ss.handleOWNERS(org, repo, maintainers)
+		if err == zh.ErrNotFound {
+			// the epic went away since we listed it
+			continue
+		} else if err != nil {
+			return fmt.Errorf("unable to get data from ZenHub for epic %d in repo %s/%s: %v", epic.IssueNumber, repo.OrgLogin, repo.RepoName, err)
 		}
 
-		if err != nil {
-			scope.Warnf("Unable to establish maintainers for repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
-		}
-	}
+		epicData := data.(*zh.EpicData)
+		epics = append(epics, &storage.Epic{
+			OrgLogin:   repo.OrgLogin,
+			RepoName:   repo.RepoName,
+			EpicNumber: int64(epic.IssueNumber),
+			Estimate:   int64(epicData.Estimate.Value),
+		})
 
-	storageMaintainers := make([]*storage.Maintainer, 0, len(maintainers))
-	for _, maintainer := range maintainers {
-		storageMaintainers = append(storageMaintainers, maintainer)
+		// children are identified by repo number since they may be in any repo of the ZenHub workspace
+		for _, child := range epicData.Issues {
+			children = append(children, &storage.IssueEpic{
+				OrgLogin:         repo.OrgLogin,
+				RepoName:         repo.RepoName,
+				EpicNumber:       int64(epic.IssueNumber),
+				ChildRepoNumber:  int64(child.RepoID),
+				ChildIssueNumber: int64(child.IssueNumber),
+				ChildEstimate:    int64(child.Estimate.Value),
+			})
+		}
 	}
 
-	return ss.syncer.store.WriteAllMaintainers(ss.ctx, storageMaintainers)
+	return ss.syncer.store.WriteAllEpics(ss.ctx, repo.OrgLogin, repo.RepoName, epics, children)
 }
 
-func (ss *syncState) handleCODEOWNERS(org *storage.Org, repo *storage.Repo, maintainers map[string]*storage.Maintainer, fc *github.RepositoryContent) error {
-	content, err := fc.GetContent()
-	if err != nil {
-		return fmt.Errorf("unable to read CODEOWNERS body from repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+func (ss *syncState) handlePullRequests(repo *storage.Repo, startTime time.Time, cursor *pageCursor) error {
+	scope.Debugf("Getting pull requests from repo %s/%s", repo.OrgLogin, repo.RepoName)
+
+	if ss.useGraphQL {
+		err := ss.handlePullRequestsBulk(repo, startTime)
+		if err == nil || ss.ctx.Err() != nil {
+			return err
+		}
+
+		scope.Warnf("Unable to bulk fetch pull requests from repo %s/%s, falling back to individual calls: %v", repo.OrgLogin, repo.RepoName, err)
 	}
 
-	lines := strings.Split(content, "\n")
+	total := 0
+	return ss.syncer.fetchPullRequests(ss.ctx, repo, startTime, cursor, func(prs []*github.PullRequest) error {
+		if err := ss.ctx.Err(); err != nil {
+			// the sync has been canceled, don't bother with the remaining pages
+			return err
+		}
+
+		total += len(prs)
+		scope.Infof("Received %d pull requests", total)
 
-	scope.Debugf("%d lines in CODEOWNERS file for repo %s/%s", len(lines), repo.OrgLogin, repo.RepoName)
+		return ss.writePullRequests(repo, prs, func(pr *github.PullRequest) ([]*github.PullRequestReview, []string, error) {
+			return ss.fetchPullRequestDetails(repo, pr.GetNumber())
+		})
+	})
+}
 
-	// go through each line of the CODEOWNERS file
-	for _, line := range lines {
-		l := strings.Trim(line, " \t")
-		if strings.HasPrefix(l, "#") || l == "" {
-			// skip comment lines or empty lines
-			continue
+// handlePullRequestsBulk syncs pull requests like handlePullRequests, but fetches them along with their reviews
+// and files using GitHub's GraphQL API, which takes far fewer calls.
+func (ss *syncState) handlePullRequestsBulk(repo *storage.Repo, startTime time.Time) error {
+	total := 0
+	return ss.syncer.fetchPullRequestsBulk(ss.ctx, repo, startTime, func(bulk []*gh.BulkPullRequest) error {
+		if err := ss.ctx.Err(); err != nil {
+			// the sync has been canceled, don't bother with the remaining pages
+			return err
 		}
 
-		fields := strings.Fields(l)
-		logins := fields[1:]
+		total += len(bulk)
+		scope.Infof("Received %d pull requests", total)
 
-		for _, login := range logins {
-			login = strings.TrimPrefix(login, "@")
+		prs := make([]*github.PullRequest, len(bulk))
+		byNumber := make(map[int]*gh.BulkPullRequest, len(bulk))
+		for i, b := range bulk {
+			prs[i] = b.PullRequest
+			byNumber[b.PullRequest.GetNumber()] = b
+		}
 
-			// add the path to this maintainer's list
-			path := strings.TrimPrefix(fields[0], "/")
-			path = strings.TrimSuffix(path, "/*")
-			if path == "*" {
-				path = ""
+		return ss.writePullRequests(repo, prs, func(pr *github.PullRequest) ([]*github.PullRequestReview, []string, error) {
+			b := byNumber[pr.GetNumber()]
+			if b.Incomplete {
+				return ss.fetchPullRequestDetails(repo, pr.GetNumber())
 			}
+			return b.Reviews, b.Files, nil
+		})
+	})
+}
 
-			scope.Debugf("User '%s' can review path '%s/%s/%s'", login, repo.OrgLogin, repo.RepoName, path)
+// fetchPullRequestDetails gets the reviews and files of a pull request.
+func (ss *syncState) fetchPullRequestDetails(repo *storage.Repo, prNumber int) ([]*github.PullRequestReview, []string, error) {
+	var reviews []*github.PullRequestReview
+	if err := ss.syncer.fetchReviews(ss.ctx, repo, prNumber, func(r []*github.PullRequestReview) error {
+		reviews = append(reviews, r...)
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
 
-			maintainer, err := ss.getMaintainer(org, maintainers, login)
-			if maintainer == nil || err != nil {
-				scope.Warnf("Couldn't get info on potential maintainer %s: %v", login, err)
+	var files []string
+	if err := ss.syncer.fetchFiles(ss.ctx, repo, prNumber, func(f []string) error {
+		files = append(files, f...)
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	return reviews, files, nil
+}
+
+// writePullRequests converts and stores a page of pull requests, using the given function to get the reviews and
+// files of the pull requests which have changed since they were last synced.
+func (ss *syncState) writePullRequests(repo *storage.Repo, prs []*github.PullRequest,
+	details func(*github.PullRequest) ([]*github.PullRequestReview, []string, error)) error {
+
+	var storagePRs []*storage.PullRequest
+	var storagePRReviews []*storage.PullRequestReview
+
+	for _, pr := range prs {
+		// if this pr is already known to us and is up to date, skip further processing
+		if existing, _ := ss.syncer.cache.ReadPullRequest(ss.ctx, repo.OrgLogin, repo.RepoName, pr.GetNumber()); existing != nil {
+			if existing.UpdatedAt == pr.GetUpdatedAt() {
 				continue
 			}
+		}
 
-			maintainer.Paths = append(maintainer.Paths, repo.RepoName+"/"+path)
+		reviews, prFiles, err := details(pr)
+		if err != nil {
+			return err
 		}
-	}
 
-	return nil
-}
+		for _, review := range reviews {
+			t, users := gh.ConvertPullRequestReview(repo.OrgLogin, repo.RepoName, pr.GetNumber(), review)
+			storagePRReviews = append(storagePRReviews, t)
+			ss.addUsers(users...)
+		}
 
-type ownersFile struct {
-	Approvers []string `json:"approvers"`
-	Reviewers []string `json:"reviewers"`
-}
+		t, users := gh.ConvertPullRequest(repo.OrgLogin, repo.RepoName, pr, prFiles)
+		storagePRs = append(storagePRs, t)
+		ss.addUsers(users...)
 
-func (ss *syncState) handleOWNERS(org *storage.Org, repo *storage.Repo, maintainers map[string]*storage.Maintainer) error {
-	opt := &github.CommitsListOptions{
-		ListOptions: github.ListOptions{
-			PerPage: 1,
-		},
+		if ss.flags&Statuses != 0 {
+			if err := ss.handleStatuses(repo, pr.GetHead().GetSHA()); err != nil {
+				return err
+			}
+		}
 	}
 
-	// TODO: we need to get the SHA for the latest commit on the master branch, not just any branch
-	rc, _, err := ss.syncer.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
-		return client.Repositories.ListCommits(ss.ctx, repo.OrgLogin, repo.RepoName, opt)
-	})
-
-	if err != nil {
-		return fmt.Errorf("unable to get latest commit in repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+	if err := ss.syncer.store.WritePullRequests(ss.ctx, storagePRs); err != nil {
+		return err
 	}
 
-	tree, _, err := ss.syncer.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
-		return client.Git.GetTree(ss.ctx, repo.OrgLogin, repo.RepoName, rc.([]*github.RepositoryCommit)[0].GetSHA(), true)
-	})
+	if err := ss.syncer.store.WriteAllPullRequestReviewers(ss.ctx, storagePRs); err != nil {
+		return err
+	}
 
-	if err != nil {
-		return fmt.Errorf("unable to get tree in repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
+	if err := ss.syncer.store.WritePullRequestReviews(ss.ctx, storagePRReviews); err != nil {
+		return err
 	}
 
-	files := make(map[string]ownersFile)
-	for _, entry := range tree.(*github.Tree).Entries {
-		components := strings.Split(entry.GetPath(), "/")
-		if components[len(components)-1] == "OWNERS" && components[0] != "vendor" { // HACK: skip Go's vendor directory
+	ss.currentRepo.PullRequests += len(storagePRs)
+	return nil
+}
 
-			url := "https://raw.githubusercontent.com/" + repo.OrgLogin + "/" + repo.RepoName + "/master/" + entry.GetPath()
+// handleStatuses syncs the combined status and check runs for a commit.
+func (ss *syncState) handleStatuses(repo *storage.Repo, sha string) error {
+	key := repo.OrgLogin + "/" + repo.RepoName + "/" + sha
+	if sha == "" || ss.statusSHAs[key] {
+		return nil
+	}
 
-			resp, err := http.Get(url)
-			if err != nil {
-				return fmt.Errorf("unable to get %s: %v", url, err)
-			}
+	// once all the statuses for a commit are final, they don't change anymore
+	if existing, _ := ss.syncer.store.ReadCombinedStatus(ss.ctx, repo.OrgLogin, repo.RepoName, sha); existing != nil && existing.State != "pending" {
+		return nil
+	}
 
-			body, err := ioutil.ReadAll(resp.Body)
-			_ = resp.Body.Close()
+	var combined *github.CombinedStatus
+	if err := ss.syncer.fetchCombinedStatus(ss.ctx, repo, sha, func(status *github.CombinedStatus) error {
+		if combined == nil {
+			combined = status
+		} else {
+			combined.Statuses = append(combined.Statuses, status.Statuses...)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
 
-			if err != nil {
-				return fmt.Errorf("unable to read body for %s: %v", url, err)
-			}
+	var checkRuns []*storage.CheckRun
+	if err := ss.syncer.fetchCheckRuns(ss.ctx, repo, sha, func(runs []*github.CheckRun) error {
+		for _, run := range runs {
+			checkRuns = append(checkRuns, gh.ConvertCheckRun(repo.OrgLogin, repo.RepoName, run))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
 
-			var f ownersFile
-			if err := yaml.Unmarshal(body, &f); err != nil {
-				return fmt.Errorf("unable to parse body for %s: %v", url, err)
-			}
+	if err := ss.syncer.store.WriteCombinedStatuses(ss.ctx, []*storage.CombinedStatus{gh.ConvertCombinedStatus(repo.OrgLogin, repo.RepoName, combined)}); err != nil {
+		return err
+	}
 
-			files[entry.GetPath()] = f
-		}
+	if err := ss.syncer.store.WriteCheckRuns(ss.ctx, checkRuns); err != nil {
+		return err
 	}
 
-	scope.Debugf("%d OWNERS files found in repo %s/%s", len(files), org.OrgLogin, repo.RepoName)
+	if ss.statusSHAs == nil {
+		ss.statusSHAs = make(map[string]bool)
+	}
+	ss.statusSHAs[key] = true
 
-	for path, file := range files {
-		for _, user := range file.Approvers {
-			maintainer, err := ss.getMaintainer(org, maintainers, user)
-			if maintainer == nil || err != nil {
-				scope.Warnf("Couldn't get info on potential maintainer %s: %v", user, err)
-				continue
-			}
+	return nil
+}
 
-			p := strings.TrimSuffix(path, "OWNERS")
+func (ss *syncState) handlePullRequestReviewComments(repo *storage.Repo, start time.Time, cursor *pageCursor) error {
+	scope.Debugf("Getting pull requests review comments from repo %s/%s", repo.OrgLogin, repo.RepoName)
+
+	total := 0
+	return ss.syncer.fetchPullRequestReviewComments(ss.ctx, repo, start, cursor, func(comments []*github.PullRequestComment) error {
+		var storagePRComments []*storage.PullRequestReviewComment
 
-			scope.Debugf("User '%s' can approve path %s/%s/%s", user, org.OrgLogin, repo.RepoName, p)
+		total += len(comments)
+		scope.Infof("Received %d pull request review comments", total)
 
-			maintainer.Paths = append(maintainer.Paths, repo.RepoName+"/"+p)
+		for _, comment := range comments {
+			prURL := comment.GetPullRequestURL()
+			prNumber, _ := strconv.Atoi(prURL[strings.LastIndex(prURL, "/")+1:])
+			t, users := gh.ConvertPullRequestReviewComment(repo.OrgLogin, repo.RepoName, prNumber, comment)
+			storagePRComments = append(storagePRComments, t)
+			ss.addUsers(users...)
 		}
-	}
 
-	return nil
-}
+		if err := ss.syncer.store.WritePullRequestReviewComments(ss.ctx, storagePRComments); err != nil {
+			return err
+		}
 
-func (ss *syncState) addUsers(users ...*storage.User) {
-	for _, user := range users {
-		ss.users[user.UserLogin] = user
-	}
+		ss.currentRepo.Comments += len(storagePRComments)
+		return nil
+	})
 }
 
-func (ss *syncState) getMaintainer(org *storage.Org, maintainers map[string]*storage.Maintainer, login string) (*storage.Maintainer, error) {
-	user, ok := ss.users[login]
-	if !ok {
-		var err error
-		user, err = ss.syncer.cache.ReadUser(ss.ctx, login)
-		if err != nil {
-			return nil, fmt.Errorf("unable to read information from storage for user %s: %v", login, err)
+func (ss *syncState) handleMaintainers(org *storage.Org, repos []*storage.Repo) error {
+	scope.Debugf("Getting maintainers for org %s", org.OrgLogin)
+
+	b := maintainers.NewBuilder(ss.ctx, ss.syncer.gc, ss.syncer.cache, ss.syncer.store, org, ss.users)
+	for _, repo := range repos {
+		if err := b.AddRepo(repo); err != nil {
+			scope.Warnf("Unable to establish maintainers for repo %s/%s: %v", repo.OrgLogin, repo.RepoName, err)
 		}
 	}
 
-	if user == nil {
-		// couldn't find user info, ask GitHub directly
-		u, _, err := ss.syncer.gc.ThrottledCall(func(client *github.Client) (interface{}, *github.Response, error) {
-			return client.Users.Get(ss.ctx, login)
-		})
-
-		if err != nil {
-			return nil, fmt.Errorf("unable to read information from GitHub on user %s: %v", login, err)
-		}
+	return ss.syncer.store.WriteAllMaintainers(ss.ctx, org.OrgLogin, b.Maintainers())
+}
 
-		user = gh.ConvertUser(u.(*github.User))
-		ss.users[user.UserLogin] = user
+// OwnersOf returns the owners of a file in a repo, based on the repo's CODEOWNERS file as
+// recorded by the last sync. Owners are user logins or org/team names.
+func (s *Syncer) OwnersOf(context context.Context, orgLogin string, repoName string, path string) ([]string, error) {
+	co, err := s.store.ReadCodeOwners(context, orgLogin, repoName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CODEOWNERS for repo %s/%s: %v", orgLogin, repoName, err)
+	} else if co == nil {
+		return nil, nil
 	}
 
-	maintainer, ok := maintainers[user.UserLogin]
-	if !ok {
-		// unknown maintainer, so create a record
-		maintainer = &storage.Maintainer{
-			OrgLogin:  org.OrgLogin,
-			UserLogin: user.UserLogin,
-		}
-		maintainers[user.UserLogin] = maintainer
-	}
+	return codeowners.Owners(codeowners.Parse(strings.Join(co.Lines, "\n")), path), nil
+}
 
-	return maintainer, nil
+func (ss *syncState) addUsers(users ...*storage.User) {
+	for _, user := range users {
+		ss.users[user.UserLogin] = user
+	}
 }
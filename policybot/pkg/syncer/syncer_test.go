@@ -0,0 +1,1355 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v26/github"
+
+	"istio.io/bots/policybot/pkg/config"
+	"istio.io/bots/policybot/pkg/gh"
+	"istio.io/bots/policybot/pkg/maintainers"
+	"istio.io/bots/policybot/pkg/storage"
+	"istio.io/bots/policybot/pkg/storage/cache"
+	"istio.io/bots/policybot/pkg/zh"
+)
+
+// fakeStore implements the parts of storage.Store exercised by the tests
+type fakeStore struct {
+	storage.Store
+
+	mu             sync.Mutex
+	issues         []*storage.Issue
+	pipelines      map[int64]string
+	estimates      map[int64]int64
+	deleted        []int64
+	codeOwners     []*storage.CodeOwners
+	members        []*storage.Member
+	memberOrgs     []string
+	activity       *storage.BotActivity
+	epics          []*storage.Epic
+	epicIssues     []*storage.IssueEpic
+	prs            []*storage.PullRequest
+	prReviews      []*storage.PullRequestReview
+	releases       []*storage.Release
+	events         []*storage.IssueEvent
+	comments       []*storage.IssueComment
+	slos           []*storage.IssueSLO
+	transfers      []*storage.IssueTransfer
+	deletedIssues  []int64
+	users          []*storage.User
+	pipelineEvents []*storage.IssuePipelineEvent
+	maintainers    []*storage.Maintainer
+
+	// invoked whenever a batch of issues is written
+	onWriteIssues func([]*storage.Issue)
+}
+
+func (fs *fakeStore) WriteAllMembers(_ context.Context, orgLogins []string, members []*storage.Member) error {
+	fs.memberOrgs = orgLogins
+	fs.members = members
+	return nil
+}
+
+func (fs *fakeStore) WriteIssueEvents(_ context.Context, events []*storage.IssueEvent) error {
+	fs.events = append(fs.events, events...)
+	return nil
+}
+
+func (fs *fakeStore) QueryMembersByOrg(_ context.Context, _ string, cb func(*storage.Member) error) error {
+	for _, m := range fs.members {
+		if err := cb(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fs *fakeStore) QueryIssueCommentsByRepo(_ context.Context, _ string, _ string, cb func(*storage.IssueComment) error) error {
+	for _, c := range fs.comments {
+		if err := cb(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fs *fakeStore) QueryIssueEventsByRepo(_ context.Context, _ string, _ string, cb func(*storage.IssueEvent) error) error {
+	for _, e := range fs.events {
+		if err := cb(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fs *fakeStore) WriteIssueSLOs(_ context.Context, slos []*storage.IssueSLO) error {
+	fs.slos = append(fs.slos, slos...)
+	return nil
+}
+
+func (fs *fakeStore) WriteIssueTransfers(_ context.Context, transfers []*storage.IssueTransfer) error {
+	fs.transfers = append(fs.transfers, transfers...)
+	return nil
+}
+
+func (fs *fakeStore) MarkIssuesDeleted(_ context.Context, _ string, _ string, issueNumbers []int64) error {
+	fs.deletedIssues = append(fs.deletedIssues, issueNumbers...)
+	return nil
+}
+
+func (fs *fakeStore) ReadPullRequest(_ context.Context, _ string, _ string, _ int) (*storage.PullRequest, error) {
+	return nil, nil
+}
+
+func (fs *fakeStore) WritePullRequests(_ context.Context, prs []*storage.PullRequest) error {
+	fs.prs = append(fs.prs, prs...)
+	return nil
+}
+
+func (fs *fakeStore) WritePullRequestReviews(_ context.Context, reviews []*storage.PullRequestReview) error {
+	fs.prReviews = append(fs.prReviews, reviews...)
+	return nil
+}
+
+func (fs *fakeStore) WriteReleases(_ context.Context, releases []*storage.Release) error {
+	fs.releases = append(fs.releases, releases...)
+	return nil
+}
+
+func (fs *fakeStore) WriteAllPullRequestReviewers(_ context.Context, _ []*storage.PullRequest) error {
+	return nil
+}
+
+func (fs *fakeStore) WriteAllIssueAssignees(_ context.Context, _ []*storage.Issue) error {
+	return nil
+}
+
+func (fs *fakeStore) WriteAllIssueLabels(_ context.Context, _ []*storage.Issue) error {
+	return nil
+}
+
+func (fs *fakeStore) WriteAllEpics(_ context.Context, _ string, _ string, epics []*storage.Epic, epicIssues []*storage.IssueEpic) error {
+	fs.epics = epics
+	fs.epicIssues = epicIssues
+	return nil
+}
+
+func (fs *fakeStore) WriteOrgs(_ context.Context, _ []*storage.Org) error {
+	return nil
+}
+
+func (fs *fakeStore) WriteRepos(_ context.Context, _ []*storage.Repo) error {
+	return nil
+}
+
+func (fs *fakeStore) ReadBotActivity(_ context.Context, _ string, _ string) (*storage.BotActivity, error) {
+	if fs.activity == nil {
+		return nil, nil
+	}
+
+	activity := *fs.activity
+	return &activity, nil
+}
+
+func (fs *fakeStore) UpdateBotActivity(_ context.Context, _ string, _ string, cb func(*storage.BotActivity) error) error {
+	if fs.activity == nil {
+		fs.activity = &storage.BotActivity{}
+	}
+
+	return cb(fs.activity)
+}
+
+func (fs *fakeStore) ReadUser(_ context.Context, userLogin string) (*storage.User, error) {
+	return &storage.User{UserLogin: userLogin}, nil
+}
+
+func (fs *fakeStore) QueryMaintainersByOrg(_ context.Context, _ string, cb func(*storage.Maintainer) error) error {
+	for _, m := range fs.maintainers {
+		if err := cb(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *fakeStore) WriteAllMaintainers(_ context.Context, _ string, maintainers []*storage.Maintainer) error {
+	fs.maintainers = maintainers
+	return nil
+}
+
+func (fs *fakeStore) WriteIssues(_ context.Context, issues []*storage.Issue) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.issues = append(fs.issues, issues...)
+	if fs.onWriteIssues != nil {
+		fs.onWriteIssues(issues)
+	}
+
+	return nil
+}
+
+func (fs *fakeStore) WriteIssueComments(_ context.Context, comments []*storage.IssueComment) error {
+	fs.comments = append(fs.comments, comments...)
+	return nil
+}
+
+func (fs *fakeStore) WriteCodeOwners(_ context.Context, codeOwners []*storage.CodeOwners) error {
+	fs.codeOwners = append(fs.codeOwners, codeOwners...)
+	return nil
+}
+
+func (fs *fakeStore) QueryIssuesByRepo(_ context.Context, _ string, _ string, cb func(*storage.Issue) error) error {
+	for _, issue := range fs.issues {
+		if err := cb(issue); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fs *fakeStore) QueryIssuesUpdatedSince(_ context.Context, _ string, _ string, since time.Time,
+	cb func(*storage.Issue) error) error {
+	for _, issue := range fs.issues {
+		if issue.UpdatedAt.Before(since) {
+			continue
+		}
+
+		if err := cb(issue); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fs *fakeStore) ReadLatestIssuePipelineEvent(_ context.Context, _ string, _ string, issueNumber int64) (*storage.IssuePipelineEvent, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var latest *storage.IssuePipelineEvent
+	for _, e := range fs.pipelineEvents {
+		if e.IssueNumber == issueNumber && (latest == nil || e.CreatedAt.After(latest.CreatedAt)) {
+			latest = e
+		}
+	}
+
+	return latest, nil
+}
+
+func (fs *fakeStore) WriteIssuePipelineEvents(_ context.Context, events []*storage.IssuePipelineEvent) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.pipelineEvents = append(fs.pipelineEvents, events...)
+	return nil
+}
+
+func (fs *fakeStore) WriteIssuePipelines(_ context.Context, pipelines []*storage.IssuePipeline) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, p := range pipelines {
+		fs.pipelines[p.IssueNumber] = p.Pipeline
+		if fs.estimates != nil {
+			fs.estimates[p.IssueNumber] = p.Estimate
+		}
+	}
+
+	return nil
+}
+
+func (fs *fakeStore) DeleteIssuePipelines(_ context.Context, _ string, _ string, issueNumbers []int64) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.deleted = append(fs.deleted, issueNumbers...)
+	return nil
+}
+
+func (fs *fakeStore) WriteUsers(_ context.Context, users []*storage.User) error {
+	fs.users = append(fs.users, users...)
+	return nil
+}
+
+func TestPushUsers(t *testing.T) {
+	// GitHub knows about alice but not bob
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+
+		if r.URL.Path == "/users/alice" {
+			_, _ = fmt.Fprint(w, `{"login": "alice", "name": "Alice"}`)
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{}
+	s := New(gh.NewThrottledClientForClient(client), nil, nil, store, nil, false)
+	ss := &syncState{
+		syncer: s,
+		users: map[string]*storage.User{
+			"alice": {UserLogin: "alice"},
+			"bob":   {UserLogin: "bob"},
+			"carol": {UserLogin: "carol", Name: "Carol"},
+		},
+		ctx: context.Background(),
+	}
+
+	err := ss.pushUsers()
+	if err == nil || !strings.Contains(err.Error(), "bob") {
+		t.Errorf("Got error %v, expecting a failure for bob", err)
+	}
+
+	written := make(map[string]string)
+	for _, u := range store.users {
+		written[u.UserLogin] = u.Name
+	}
+
+	expected := map[string]string{"alice": "Alice", "bob": "", "carol": "Carol"}
+	if !reflect.DeepEqual(written, expected) {
+		t.Errorf("Got users %v, expecting %v", written, expected)
+	}
+
+	for _, path := range requested {
+		if path == "/users/carol" {
+			t.Errorf("Got a GitHub lookup for carol, whose info was already complete")
+		}
+	}
+}
+
+func TestHandleZenHubSkipsMissingIssues(t *testing.T) {
+	// ZenHub doesn't know about the first issue or the second epic, but has data for all the others. The
+	// third epic lives in another repo of the workspace and so shouldn't be looked up through this one.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/events") {
+			_, _ = fmt.Fprint(w, `[]`)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/p1/repositories/42/issues/1", "/p1/repositories/42/epics/9":
+			w.WriteHeader(http.StatusNotFound)
+		case "/p1/repositories/42/epics":
+			_, _ = fmt.Fprint(w, `{"epic_issues": [{"issue_number": 2, "repo_id": 42}, {"issue_number": 9, "repo_id": 42}, {"issue_number": 11, "repo_id": 7}]}`)
+		case "/p1/repositories/42/epics/2":
+			_, _ = fmt.Fprint(w, `{"estimate": {"value": 8}, "issues": [{"issue_number": 3, "repo_id": 42, "estimate": {"value": 3}}, {"issue_number": 4, "repo_id": 7}]}`)
+		case "/p1/repositories/42/epics/11":
+			t.Errorf("Got a lookup of epic 11 through repo 42, expecting none")
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			_, _ = fmt.Fprint(w, `{"pipeline": {"name": "In Progress"}}`)
+		}
+	}))
+	defer server.Close()
+
+	store := &fakeStore{
+		pipelines: make(map[int64]string),
+	}
+
+	for i := 1; i <= 5; i++ {
+		store.issues = append(store.issues, &storage.Issue{OrgLogin: "istio", RepoName: "istio", IssueNumber: int64(i)})
+	}
+
+	zc := zh.NewThrottledClientForClient(zh.NewClientWithBaseURL("", server.URL))
+	s := New(&gh.ThrottledClient{}, nil, zc, store, nil, false)
+	ss := &syncState{
+		syncer: s,
+		users:  make(map[string]*storage.User),
+		flags:  ZenHub,
+		ctx:    context.Background(),
+	}
+
+	repo := &storage.Repo{OrgLogin: "istio", RepoName: "istio", RepoNumber: 42}
+	if err := ss.handleZenHub(repo, time.Time{}); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if _, ok := store.pipelines[1]; ok {
+		t.Errorf("Got a pipeline for issue 1, expecting none")
+	}
+
+	for i := int64(2); i <= 5; i++ {
+		if store.pipelines[i] != "In Progress" {
+			t.Errorf("Got pipeline %q for issue %d, expecting %q", store.pipelines[i], i, "In Progress")
+		}
+	}
+
+	if len(store.deleted) != 1 || store.deleted[0] != 1 {
+		t.Errorf("Got deleted pipelines %v, expecting [1]", store.deleted)
+	}
+
+	if len(store.epics) != 1 || store.epics[0].EpicNumber != 2 || store.epics[0].Estimate != 8 {
+		t.Errorf("Got unexpected epics %v", store.epics)
+	}
+
+	if len(store.epicIssues) != 2 ||
+		store.epicIssues[0].EpicNumber != 2 || store.epicIssues[0].ChildRepoNumber != 42 || store.epicIssues[0].ChildIssueNumber != 3 ||
+		store.epicIssues[0].ChildEstimate != 3 ||
+		store.epicIssues[1].EpicNumber != 2 || store.epicIssues[1].ChildRepoNumber != 7 || store.epicIssues[1].ChildIssueNumber != 4 ||
+		store.epicIssues[1].ChildEstimate != 0 {
+		t.Errorf("Got unexpected epic membership %v", store.epicIssues)
+	}
+}
+
+func TestHandleZenHubSinceLastSync(t *testing.T) {
+	// ZenHub fails on the third issue, but has data for all the others
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+
+		if strings.HasSuffix(r.URL.Path, "/events") {
+			_, _ = fmt.Fprint(w, `[]`)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/p1/repositories/42/epics":
+			w.WriteHeader(http.StatusNotFound)
+		case "/p1/repositories/42/issues/3":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			_, _ = fmt.Fprint(w, `{"pipeline": {"name": "Review/QA"}}`)
+		}
+	}))
+	defer server.Close()
+
+	store := &fakeStore{
+		pipelines: make(map[int64]string),
+	}
+
+	lastSync := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 4; i++ {
+		// only the first issue hasn't been updated since the last sync
+		updated := lastSync.Add(time.Hour)
+		if i == 1 {
+			updated = lastSync.Add(-time.Hour)
+		}
+		store.issues = append(store.issues, &storage.Issue{OrgLogin: "istio", RepoName: "istio", IssueNumber: int64(i), UpdatedAt: updated})
+	}
+
+	zc := zh.NewThrottledClientForClient(zh.NewClientWithBaseURL("", server.URL))
+	s := New(&gh.ThrottledClient{}, nil, zc, store, nil, false)
+	ss := &syncState{
+		syncer: s,
+		users:  make(map[string]*storage.User),
+		flags:  ZenHub,
+		ctx:    context.Background(),
+	}
+
+	repo := &storage.Repo{OrgLogin: "istio", RepoName: "istio", RepoNumber: 42}
+	err := ss.handleZenHub(repo, lastSync)
+	if err == nil || !strings.Contains(err.Error(), "1 issue(s)") {
+		t.Fatalf("Got error %v, expecting a failure for one issue", err)
+	}
+
+	if len(store.pipelines) != 2 || store.pipelines[2] != "Review/QA" || store.pipelines[4] != "Review/QA" {
+		t.Errorf("Got pipelines %v, expecting issues 2 and 4 in Review/QA", store.pipelines)
+	}
+
+	for _, path := range requested {
+		if path == "/p1/repositories/42/issues/1" {
+			t.Errorf("Got a ZenHub lookup for issue 1, which wasn't updated since the last sync")
+		}
+	}
+}
+
+func TestHandleZenHubPipelineEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/p1/repositories/42/issues/1":
+			_, _ = fmt.Fprint(w, `{"pipeline": {"name": "Done"}, "estimate": {"value": 5}}`)
+		case "/p1/repositories/42/issues/1/events":
+			_, _ = fmt.Fprint(w, `[
+				{"user_id": 7, "type": "transferIssue", "created_at": "2019-06-03T00:00:00Z",
+				 "from_pipeline": {"name": "In Progress"}, "to_pipeline": {"name": "Done"}},
+				{"user_id": 7, "type": "estimateIssue", "created_at": "2019-06-02T00:00:00Z", "to_estimate": {"value": 5}},
+				{"user_id": 8, "type": "transferIssue", "created_at": "2019-06-01T00:00:00Z",
+				 "from_pipeline": {"name": "Backlog"}, "to_pipeline": {"name": "In Progress"}}
+			]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// the first move was recorded by an earlier sync
+	recorded := &storage.IssuePipelineEvent{
+		OrgLogin:     "istio",
+		RepoName:     "istio",
+		IssueNumber:  1,
+		CreatedAt:    time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		ActorID:      8,
+		FromPipeline: "Backlog",
+		ToPipeline:   "In Progress",
+	}
+
+	store := &fakeStore{
+		issues:         []*storage.Issue{{OrgLogin: "istio", RepoName: "istio", IssueNumber: 1}},
+		pipelines:      make(map[int64]string),
+		estimates:      make(map[int64]int64),
+		pipelineEvents: []*storage.IssuePipelineEvent{recorded},
+	}
+
+	zc := zh.NewThrottledClientForClient(zh.NewClientWithBaseURL("", server.URL))
+	s := New(&gh.ThrottledClient{}, nil, zc, store, nil, false)
+	ss := &syncState{
+		syncer: s,
+		users:  make(map[string]*storage.User),
+		flags:  ZenHub,
+		ctx:    context.Background(),
+	}
+
+	repo := &storage.Repo{OrgLogin: "istio", RepoName: "istio", RepoNumber: 42}
+	if err := ss.handleZenHub(repo, time.Time{}); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if store.pipelines[1] != "Done" || store.estimates[1] != 5 {
+		t.Errorf("Got pipeline %q with estimate %d, expecting %q with estimate 5", store.pipelines[1], store.estimates[1], "Done")
+	}
+
+	expected := []*storage.IssuePipelineEvent{
+		recorded,
+		{
+			OrgLogin:     "istio",
+			RepoName:     "istio",
+			IssueNumber:  1,
+			CreatedAt:    time.Date(2019, 6, 3, 0, 0, 0, 0, time.UTC),
+			ActorID:      7,
+			FromPipeline: "In Progress",
+			ToPipeline:   "Done",
+		},
+	}
+
+	if !reflect.DeepEqual(store.pipelineEvents, expected) {
+		t.Errorf("Got pipeline events %+v, expecting %+v", store.pipelineEvents, expected)
+	}
+}
+
+func TestDryRunDoesNotWrite(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/events") {
+			_, _ = fmt.Fprint(w, `[]`)
+			return
+		}
+
+		if r.URL.Path == "/p1/repositories/42/issues/1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = fmt.Fprint(w, `{"pipeline": {"name": "In Progress"}}`)
+	}))
+	defer server.Close()
+
+	store := &fakeStore{
+		pipelines: make(map[int64]string),
+	}
+
+	for i := 1; i <= 3; i++ {
+		store.issues = append(store.issues, &storage.Issue{OrgLogin: "istio", RepoName: "istio", IssueNumber: int64(i)})
+	}
+
+	zc := zh.NewThrottledClientForClient(zh.NewClientWithBaseURL("", server.URL))
+	s := New(&gh.ThrottledClient{}, nil, zc, store, nil, true)
+	ss := &syncState{
+		syncer: s,
+		users:  map[string]*storage.User{"alice": {UserLogin: "alice"}},
+		flags:  ZenHub,
+		ctx:    context.Background(),
+	}
+
+	repo := &storage.Repo{OrgLogin: "istio", RepoName: "istio", RepoNumber: 42}
+	if err := ss.handleZenHub(repo, time.Time{}); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	b := maintainers.NewBuilder(ss.ctx, s.gc, s.cache, s.store, &storage.Org{OrgLogin: "istio"}, ss.users)
+	if err := b.AddCODEOWNERS(repo, &github.RepositoryContent{Content: github.String("/pilot/ @alice\n")}); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if len(store.pipelines) != 0 || len(store.deleted) != 0 || len(store.codeOwners) != 0 {
+		t.Errorf("Got %d pipelines, %d deleted pipelines, and %d CODEOWNERS files written, expecting none",
+			len(store.pipelines), len(store.deleted), len(store.codeOwners))
+	}
+}
+
+func TestSyncCanceledAfterFirstPage(t *testing.T) {
+	var server *httptest.Server
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/istio", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"login": "istio"}`)
+	})
+	mux.HandleFunc("/repos/istio/istio", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"name": "istio", "organization": {"login": "istio"}}`)
+	})
+	mux.HandleFunc("/repos/istio/istio/issues", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		// there's always another page
+		w.Header().Set("Link", fmt.Sprintf(`<%s/repos/istio/istio/issues?page=%d>; rel="next"`, server.URL, page+1))
+		_, _ = fmt.Fprintf(w, `[{"number": %d}]`, page)
+	})
+
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := &fakeStore{
+		onWriteIssues: func(_ []*storage.Issue) { cancel() },
+	}
+
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}}}}
+	s := New(gh.NewThrottledClientForClient(client), nil, nil, store, orgs, false)
+
+	if _, err := s.Sync(ctx, Issues, nil); err != context.Canceled {
+		t.Errorf("Got error %v, expecting %v", err, context.Canceled)
+	}
+
+	if len(store.issues) != 1 {
+		t.Errorf("Got %d issues written, expecting 1", len(store.issues))
+	}
+}
+
+func TestHandleMembersRecordsRoles(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/istio/members", func(w http.ResponseWriter, r *http.Request) {
+		if role := r.URL.Query().Get("role"); role != "admin" {
+			t.Errorf("Got members listed for role %q, expecting admin", role)
+		}
+
+		// dave's membership is private, so dave shouldn't be recorded as a member
+		_, _ = fmt.Fprint(w, `[{"login": "alice"}, {"login": "dave"}]`)
+	})
+	mux.HandleFunc("/orgs/istio/public_members", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `[{"login": "alice"}, {"login": "bob"}, {"login": "carol"}]`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{}
+	s := New(gh.NewThrottledClientForClient(client), nil, nil, store, nil, false)
+	ss := &syncState{
+		syncer: s,
+		users:  make(map[string]*storage.User),
+		flags:  Members,
+		ctx:    context.Background(),
+	}
+
+	if err := ss.handleMembers(&storage.Org{OrgLogin: "istio"}); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	expected := map[string]string{
+		"alice": "admin",
+		"bob":   "member",
+		"carol": "member",
+	}
+
+	// the org's stored members are replaced even when it has none left
+	if fmt.Sprint(store.memberOrgs) != "[istio]" {
+		t.Errorf("Got members replaced for orgs %v, expecting [istio]", store.memberOrgs)
+	}
+
+	if len(store.members) != len(expected) {
+		t.Errorf("Got %d members, expecting %d", len(store.members), len(expected))
+	}
+
+	for _, m := range store.members {
+		if m.OrgLogin != "istio" || m.Role != expected[m.UserLogin] {
+			t.Errorf("Got member %+v, expecting role %q", m, expected[m.UserLogin])
+		}
+	}
+}
+
+func TestBulkPullRequestsMatchREST(t *testing.T) {
+	graphQLCalls := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/pulls", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `[{
+			"number": 1, "title": "Fix pilot", "body": "Fixes it", "state": "closed",
+			"created_at": "2019-06-01T00:00:00Z", "updated_at": "2019-06-03T00:00:00Z",
+			"closed_at": "2019-06-02T00:00:00Z", "merged_at": "2019-06-02T00:00:00Z",
+			"user": {"login": "alice"},
+			"merged_by": {"login": "dave", "avatar_url": "https://example.com/dave"},
+			"merge_commit_sha": "def456", "draft": false, "mergeable": true,
+			"head": {"sha": "abc123"},
+			"milestone": {"number": 7},
+			"labels": [{"name": "area/networking"}],
+			"assignees": [{"login": "bob", "avatar_url": "https://example.com/bob"}],
+			"requested_reviewers": [{"login": "carol", "avatar_url": "https://example.com/carol"}]
+		}]`)
+	})
+	mux.HandleFunc("/repos/istio/istio/pulls/1/reviews", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `[{"id": 42, "body": "LGTM", "state": "APPROVED", "submitted_at": "2019-06-02T00:00:00Z",
+			"user": {"login": "bob", "avatar_url": "https://example.com/bob"}}]`)
+	})
+	mux.HandleFunc("/repos/istio/istio/pulls/1/files", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `[{"filename": "pilot/main.go"}, {"filename": "pilot/main_test.go"}]`)
+	})
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		graphQLCalls++
+		_, _ = fmt.Fprint(w, `{"data": {"repository": {"pullRequests": {
+			"pageInfo": {"hasNextPage": false},
+			"nodes": [{
+				"number": 1, "title": "Fix pilot", "body": "Fixes it", "state": "MERGED",
+				"createdAt": "2019-06-01T00:00:00Z", "updatedAt": "2019-06-03T00:00:00Z",
+				"closedAt": "2019-06-02T00:00:00Z", "mergedAt": "2019-06-02T00:00:00Z",
+				"headRefOid": "abc123", "isDraft": false, "mergeable": "MERGEABLE",
+				"author": {"login": "alice"},
+				"mergedBy": {"login": "dave", "avatarUrl": "https://example.com/dave"},
+				"mergeCommit": {"oid": "def456"},
+				"milestone": {"number": 7},
+				"labels": {"nodes": [{"name": "area/networking"}]},
+				"assignees": {"nodes": [{"login": "bob", "avatarUrl": "https://example.com/bob"}]},
+				"reviewRequests": {"nodes": [{"requestedReviewer": {"login": "carol", "avatarUrl": "https://example.com/carol"}}]},
+				"reviews": {"nodes": [{"databaseId": 42, "body": "LGTM", "state": "APPROVED", "submittedAt": "2019-06-02T00:00:00Z",
+					"author": {"login": "bob", "avatarUrl": "https://example.com/bob"}}]},
+				"files": {"nodes": [{"path": "pilot/main.go"}, {"path": "pilot/main_test.go"}]}
+			}]
+		}}}}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	syncPRs := func(useGraphQL bool) (*fakeStore, map[string]*storage.User) {
+		store := &fakeStore{}
+		s := New(gh.NewThrottledClientForClient(client), cache.New(store, time.Minute), nil, store, nil, false)
+		ss := &syncState{
+			syncer:      s,
+			users:       make(map[string]*storage.User),
+			ctx:         context.Background(),
+			currentRepo: &RepoStats{},
+			useGraphQL:  useGraphQL,
+		}
+
+		if err := ss.handlePullRequests(&storage.Repo{OrgLogin: "istio", RepoName: "istio"}, time.Time{}, nil); err != nil {
+			t.Fatalf("Unable to sync pull requests: %v", err)
+		}
+
+		return store, ss.users
+	}
+
+	restStore, restUsers := syncPRs(false)
+	bulkStore, bulkUsers := syncPRs(true)
+
+	if graphQLCalls != 1 {
+		t.Errorf("Got %d GraphQL calls, expecting 1", graphQLCalls)
+	}
+
+	if len(restStore.prs) != 1 || len(restStore.prReviews) != 1 {
+		t.Fatalf("Got %d pull requests and %d reviews from REST, expecting 1 of each", len(restStore.prs), len(restStore.prReviews))
+	}
+
+	if pr := restStore.prs[0]; pr.MergedBy != "dave" || pr.MergeCommitSHA != "def456" || pr.Draft || !pr.Mergeable {
+		t.Errorf("Got pull request %+v, expecting it merged by dave as def456", pr)
+	}
+
+	if !reflect.DeepEqual(restStore.prs, bulkStore.prs) {
+		t.Errorf("Got pull requests %+v from GraphQL, expecting %+v", bulkStore.prs[0], restStore.prs[0])
+	}
+
+	if !reflect.DeepEqual(restStore.prReviews, bulkStore.prReviews) {
+		t.Errorf("Got reviews %+v from GraphQL, expecting %+v", bulkStore.prReviews, restStore.prReviews)
+	}
+
+	if !reflect.DeepEqual(restUsers, bulkUsers) {
+		t.Errorf("Got users %v from GraphQL, expecting %v", bulkUsers, restUsers)
+	}
+}
+
+func TestBulkIssuesMatchREST(t *testing.T) {
+	graphQLCalls := 0
+	restCommentCalls := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/issues", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `[{
+			"number": 1, "title": "Pilot crashes", "body": "It crashes", "state": "open",
+			"created_at": "2019-06-01T00:00:00Z", "updated_at": "2019-06-03T00:00:00Z",
+			"user": {"login": "alice"},
+			"milestone": {"number": 7},
+			"labels": [{"name": "kind/bug"}],
+			"assignees": [{"login": "bob", "avatar_url": "https://example.com/bob"}]
+		}, {
+			"number": 2, "title": "Fix pilot", "body": "Fixes #1", "state": "closed",
+			"created_at": "2019-06-01T00:00:00Z", "updated_at": "2019-06-02T00:00:00Z",
+			"closed_at": "2019-06-02T00:00:00Z",
+			"user": {"login": "carol"},
+			"pull_request": {"url": "https://api.github.com/repos/istio/istio/pulls/2"}
+		}]`)
+	})
+	mux.HandleFunc("/repos/istio/istio/issues/comments", func(w http.ResponseWriter, r *http.Request) {
+		restCommentCalls++
+		_, _ = fmt.Fprint(w, `[{
+			"id": 10, "body": "Same here", "author_association": "MEMBER",
+			"created_at": "2019-06-02T00:00:00Z", "updated_at": "2019-06-02T00:00:00Z",
+			"issue_url": "https://api.github.com/repos/istio/istio/issues/1",
+			"user": {"login": "dave", "avatar_url": "https://example.com/dave"}
+		}, {
+			"id": 11, "body": "Thanks!", "author_association": "CONTRIBUTOR",
+			"created_at": "2019-06-02T00:00:00Z", "updated_at": "2019-06-02T00:00:00Z",
+			"issue_url": "https://api.github.com/repos/istio/istio/issues/2",
+			"user": {"login": "alice", "avatar_url": "https://example.com/alice"}
+		}]`)
+	})
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		graphQLCalls++
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(body), "pullRequests(") {
+			_, _ = fmt.Fprint(w, `{"data": {"repository": {"items": {
+				"pageInfo": {"hasNextPage": false},
+				"nodes": [{
+					"number": 2, "title": "Fix pilot", "body": "Fixes #1", "state": "MERGED",
+					"createdAt": "2019-06-01T00:00:00Z", "updatedAt": "2019-06-02T00:00:00Z",
+					"closedAt": "2019-06-02T00:00:00Z",
+					"author": {"login": "carol"},
+					"labels": {"nodes": []},
+					"assignees": {"nodes": []},
+					"comments": {"nodes": [{"databaseId": 11, "body": "Thanks!", "authorAssociation": "CONTRIBUTOR",
+						"createdAt": "2019-06-02T00:00:00Z", "updatedAt": "2019-06-02T00:00:00Z",
+						"author": {"login": "alice", "avatarUrl": "https://example.com/alice"}}]}
+				}]
+			}}}}`)
+			return
+		}
+
+		_, _ = fmt.Fprint(w, `{"data": {"repository": {"items": {
+			"pageInfo": {"hasNextPage": false},
+			"nodes": [{
+				"number": 1, "title": "Pilot crashes", "body": "It crashes", "state": "OPEN",
+				"createdAt": "2019-06-01T00:00:00Z", "updatedAt": "2019-06-03T00:00:00Z",
+				"author": {"login": "alice"},
+				"milestone": {"number": 7},
+				"labels": {"nodes": [{"name": "kind/bug"}]},
+				"assignees": {"nodes": [{"login": "bob", "avatarUrl": "https://example.com/bob"}]},
+				"comments": {"nodes": [{"databaseId": 10, "body": "Same here", "authorAssociation": "MEMBER",
+					"createdAt": "2019-06-02T00:00:00Z", "updatedAt": "2019-06-02T00:00:00Z",
+					"author": {"login": "dave", "avatarUrl": "https://example.com/dave"}}]}
+			}]
+		}}}}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	syncIssues := func(useGraphQL bool) (*fakeStore, map[string]*storage.User) {
+		store := &fakeStore{}
+		s := New(gh.NewThrottledClientForClient(client), cache.New(store, time.Minute), nil, store, nil, false)
+		ss := &syncState{
+			syncer:      s,
+			users:       make(map[string]*storage.User),
+			ctx:         context.Background(),
+			currentRepo: &RepoStats{},
+			useGraphQL:  useGraphQL,
+		}
+
+		repo := &storage.Repo{OrgLogin: "istio", RepoName: "istio"}
+		if err := ss.handleIssues(repo, time.Time{}, nil); err != nil {
+			t.Fatalf("Unable to sync issues: %v", err)
+		}
+
+		if err := ss.handleIssueComments(repo, time.Time{}, nil); err != nil {
+			t.Fatalf("Unable to sync issue comments: %v", err)
+		}
+
+		return store, ss.users
+	}
+
+	restStore, restUsers := syncIssues(false)
+	bulkStore, bulkUsers := syncIssues(true)
+
+	if graphQLCalls != 2 {
+		t.Errorf("Got %d GraphQL calls, expecting one for issues and one for pull requests", graphQLCalls)
+	}
+
+	if restCommentCalls != 1 {
+		t.Errorf("Got %d calls listing comments, expecting only the REST sync to list them", restCommentCalls)
+	}
+
+	if len(restStore.issues) != 2 || len(restStore.comments) != 2 {
+		t.Fatalf("Got %d issues and %d comments from REST, expecting 2 of each", len(restStore.issues), len(restStore.comments))
+	}
+
+	if !reflect.DeepEqual(restStore.issues, bulkStore.issues) {
+		t.Errorf("Got issues %+v from GraphQL, expecting %+v", bulkStore.issues, restStore.issues)
+	}
+
+	if !reflect.DeepEqual(restStore.comments, bulkStore.comments) {
+		t.Errorf("Got comments %+v from GraphQL, expecting %+v", bulkStore.comments, restStore.comments)
+	}
+
+	if !reflect.DeepEqual(restUsers, bulkUsers) {
+		t.Errorf("Got users %v from GraphQL, expecting %v", bulkUsers, restUsers)
+	}
+}
+
+func TestHandleReleasesPaginates(t *testing.T) {
+	var server *httptest.Server
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/releases", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = fmt.Fprint(w, `[{"id": 1, "tag_name": "1.0.0", "name": "Istio 1.0", "author": {"login": "alice"}}]`)
+			return
+		}
+
+		w.Header().Set("Link", fmt.Sprintf(`<%s/repos/istio/istio/releases?page=2>; rel="next"`, server.URL))
+		_, _ = fmt.Fprint(w, `[{"id": 2, "tag_name": "1.1.0-rc.1", "prerelease": true, "author": {"login": "bob"}}]`)
+	})
+
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{}
+	s := New(gh.NewThrottledClientForClient(client), nil, nil, store, nil, false)
+	ss := &syncState{
+		syncer: s,
+		users:  make(map[string]*storage.User),
+		flags:  Releases,
+		ctx:    context.Background(),
+	}
+
+	if err := ss.handleReleases(&storage.Repo{OrgLogin: "istio", RepoName: "istio"}); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if len(store.releases) != 2 {
+		t.Fatalf("Got %d releases, expecting 2", len(store.releases))
+	}
+
+	if r := store.releases[0]; r.TagName != "1.1.0-rc.1" || !r.Prerelease || r.Author != "bob" {
+		t.Errorf("Got release %+v, expecting prerelease 1.1.0-rc.1 by bob", r)
+	}
+
+	if r := store.releases[1]; r.TagName != "1.0.0" || r.Name != "Istio 1.0" || r.Author != "alice" {
+		t.Errorf("Got release %+v, expecting 1.0.0 by alice", r)
+	}
+
+	if ss.users["alice"] == nil || ss.users["bob"] == nil {
+		t.Errorf("Got users %v, expecting the release authors", ss.users)
+	}
+}
+
+func TestHandleEventsRecordsLabels(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/events", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `[
+			{"type": "IssueEvent", "actor": {"login": "alice"}, "created_at": "2019-06-01T00:00:00Z",
+			 "payload": {"event": "labeled", "issue": {"number": 1}, "label": {"name": "kind/bug"}}},
+			{"type": "IssueEvent", "actor": {"login": "bob"}, "created_at": "2019-06-02T00:00:00Z",
+			 "payload": {"event": "unlabeled", "issue": {"number": 1}, "label": {"name": "kind/bug"}}},
+			{"type": "IssueEvent", "actor": {"login": "bob"}, "created_at": "2019-06-03T00:00:00Z",
+			 "payload": {"event": "closed", "issue": {"number": 1}}}]`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{}
+	s := New(gh.NewThrottledClientForClient(client), nil, nil, store, nil, false)
+	ss := &syncState{
+		syncer:      s,
+		users:       make(map[string]*storage.User),
+		flags:       Events,
+		ctx:         context.Background(),
+		currentRepo: &RepoStats{},
+	}
+
+	if err := ss.handleEvents(&storage.Repo{OrgLogin: "istio", RepoName: "istio"}); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if len(store.events) != 3 {
+		t.Fatalf("Got %d events, expecting 3", len(store.events))
+	}
+
+	expected := []struct {
+		action string
+		label  string
+	}{
+		{"labeled", "kind/bug"},
+		{"unlabeled", "kind/bug"},
+		{"closed", ""},
+	}
+
+	for i, e := range expected {
+		if store.events[i].Action != e.action || store.events[i].Label != e.label {
+			t.Errorf("Got event %+v, expecting action %q with label %q", store.events[i], e.action, e.label)
+		}
+	}
+}
+
+func TestHandleIssueSLOs(t *testing.T) {
+	created := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	store := &fakeStore{
+		members: []*storage.Member{
+			{OrgLogin: "istio", UserLogin: "alice"},
+			{OrgLogin: "istio", UserLogin: "bob"},
+		},
+		issues: []*storage.Issue{
+			{OrgLogin: "istio", RepoName: "istio", IssueNumber: 1, Author: "carol", CreatedAt: created},
+			{OrgLogin: "istio", RepoName: "istio", IssueNumber: 2, Author: "carol", CreatedAt: created},
+			{OrgLogin: "istio", RepoName: "istio", IssueNumber: 3, Author: "alice", CreatedAt: created},
+		},
+		comments: []*storage.IssueComment{
+			// non-members and authors don't count as responses
+			{IssueNumber: 1, Author: "dave", CreatedAt: created.Add(time.Minute)},
+			{IssueNumber: 1, Author: "alice", CreatedAt: created.Add(3 * time.Hour)},
+			{IssueNumber: 3, Author: "alice", CreatedAt: created.Add(time.Minute)},
+		},
+		events: []*storage.IssueEvent{
+			{IssueNumber: 1, Actor: "bob", Action: "labeled", CreatedAt: created.Add(2 * time.Hour)},
+			{IssueNumber: 2, Actor: "carol", Action: "closed", CreatedAt: created.Add(time.Hour)},
+		},
+	}
+
+	orgs := []config.Org{{Name: "istio", ResponseSLO: config.ResponseSLO{ExcludeMemberIssues: true}}}
+	s := New(nil, nil, nil, store, orgs, false)
+	ss := &syncState{
+		syncer: s,
+		users:  make(map[string]*storage.User),
+		flags:  IssueSLOs,
+		ctx:    context.Background(),
+	}
+
+	if err := ss.handleIssueSLOs(&storage.Org{OrgLogin: "istio"}, []*storage.Repo{{OrgLogin: "istio", RepoName: "istio"}}); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if len(ss.failures) > 0 {
+		t.Fatalf("Got failures %v, expecting none", ss.failures)
+	}
+
+	slos := make(map[int64]*storage.IssueSLO)
+	for _, slo := range store.slos {
+		slos[slo.IssueNumber] = slo
+	}
+
+	if len(slos) != 2 {
+		t.Fatalf("Got SLOs %+v, expecting them for issues 1 and 2 only", store.slos)
+	}
+
+	if slo := slos[1]; slo == nil || slo.FirstResponder != "bob" || slo.ResponseLatency != 7200 ||
+		!slo.FirstResponseAt.Equal(created.Add(2*time.Hour)) {
+		t.Errorf("Got SLO %+v, expecting a response from bob after 2 hours", slo)
+	}
+
+	if slo := slos[2]; slo == nil || slo.FirstResponder != "" || slo.ResponseLatency != 0 {
+		t.Errorf("Got SLO %+v, expecting no response", slo)
+	}
+}
+
+func TestReconcileIssuesRecordsTransfers(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/issues/1", func(w http.ResponseWriter, r *http.Request) {
+		// GitHub follows the transfer and returns the issue from its new repo
+		_, _ = fmt.Fprint(w, `{"number": 7, "repository_url": "https://api.github.com/repos/istio/api"}`)
+	})
+	mux.HandleFunc("/repos/istio/istio/issues/2", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{
+		issues: []*storage.Issue{
+			{OrgLogin: "istio", RepoName: "istio", IssueNumber: 1},
+			{OrgLogin: "istio", RepoName: "istio", IssueNumber: 2},
+			{OrgLogin: "istio", RepoName: "istio", IssueNumber: 3},
+		},
+	}
+
+	s := New(gh.NewThrottledClientForClient(client), nil, nil, store, nil, false)
+	ss := &syncState{
+		syncer: s,
+		users:  make(map[string]*storage.User),
+		flags:  Issues,
+		ctx:    context.Background(),
+	}
+
+	if err := ss.reconcileIssues(&storage.Repo{OrgLogin: "istio", RepoName: "istio"}, map[int64]bool{3: true}); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if !reflect.DeepEqual(store.deletedIssues, []int64{1, 2}) {
+		t.Errorf("Got deleted issues %v, expecting [1 2]", store.deletedIssues)
+	}
+
+	expected := []*storage.IssueTransfer{{
+		OrgLogin:       "istio",
+		RepoName:       "istio",
+		IssueNumber:    1,
+		NewOrgLogin:    "istio",
+		NewRepoName:    "api",
+		NewIssueNumber: 7,
+	}}
+
+	if !reflect.DeepEqual(store.transfers, expected) {
+		t.Errorf("Got transfers %+v, expecting %+v", store.transfers, expected)
+	}
+}
+
+func TestResumeIssueSync(t *testing.T) {
+	var server *httptest.Server
+	var pages []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/istio/istio/issues", func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		pages = append(pages, page)
+
+		if page != "3" {
+			next, _ := strconv.Atoi(page)
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/istio/istio/issues?page=%d>; rel="next"`, server.URL, next+1))
+		}
+		_, _ = fmt.Fprintf(w, `[{"number": %s, "title": "Issue %s", "user": {"login": "alice"}}]`, page, page)
+	})
+
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	// an earlier full sync got through the first page of issues before being interrupted
+	store := &fakeStore{
+		issues:   []*storage.Issue{{OrgLogin: "istio", RepoName: "istio", IssueNumber: 1}},
+		activity: &storage.BotActivity{OrgLogin: "istio", RepoName: "istio", LastIssuePage: 2},
+	}
+
+	s := New(gh.NewThrottledClientForClient(client), cache.New(store, time.Minute), nil, store, nil, false)
+	s.Resume = true
+	ss := &syncState{
+		syncer:      s,
+		users:       make(map[string]*storage.User),
+		flags:       Issues,
+		ctx:         context.Background(),
+		currentRepo: &RepoStats{},
+	}
+
+	repo := &storage.Repo{OrgLogin: "istio", RepoName: "istio"}
+	checkpoint, err := ss.handleActivity(repo, ss.handleIssues, func(activity *storage.BotActivity) *time.Time {
+		return &activity.LastIssueSyncStart
+	}, func(activity *storage.BotActivity) *int64 {
+		return &activity.LastIssuePage
+	})
+	if err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if !reflect.DeepEqual(pages, []string{"2", "3"}) {
+		t.Errorf("Got pages %v fetched, expecting [2 3]", pages)
+	}
+
+	if store.activity.LastIssuePage != 3 {
+		t.Errorf("Got page %d recorded, expecting 3", store.activity.LastIssuePage)
+	}
+
+	if len(store.deletedIssues) != 0 {
+		t.Errorf("Got issues %v marked as deleted, expecting none since the first page was skipped", store.deletedIssues)
+	}
+
+	checkpoint()
+
+	// issues updated while the interrupted sync ran may have moved onto the skipped page, so the next sync starts over
+	if store.activity.LastIssuePage != 0 || !store.activity.LastIssueSyncStart.IsZero() {
+		t.Errorf("Got page %d and sync start %v after completing, expecting the page to be cleared and the start left alone",
+			store.activity.LastIssuePage, store.activity.LastIssueSyncStart)
+	}
+
+	pages = nil
+	checkpoint, err = ss.handleActivity(repo, ss.handleIssues, func(activity *storage.BotActivity) *time.Time {
+		return &activity.LastIssueSyncStart
+	}, func(activity *storage.BotActivity) *int64 {
+		return &activity.LastIssuePage
+	})
+	if err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	if !reflect.DeepEqual(pages, []string{"1", "2", "3"}) {
+		t.Errorf("Got pages %v fetched, expecting [1 2 3]", pages)
+	}
+
+	checkpoint()
+
+	if store.activity.LastIssuePage != 0 || store.activity.LastIssueSyncStart.IsZero() {
+		t.Errorf("Got page %d and sync start %v after completing, expecting the page to be cleared and the start recorded",
+			store.activity.LastIssuePage, store.activity.LastIssueSyncStart)
+	}
+}
+
+func TestSelectOrgs(t *testing.T) {
+	crash := config.AutoLabel{Name: "crash", MatchTitle: []string{"crash"}, Labels: []string{"kind/bug"}}
+	orgs := []config.Org{
+		{Name: "istio", AutoLabels: []config.AutoLabel{crash}, Repos: []config.Repo{{Name: "istio"}, {Name: "proxy"}, {Name: "api"}}},
+		{Name: "istio-ecosystem", Repos: []config.Repo{{Name: "authservice"}}},
+	}
+
+	cases := []struct {
+		name     string
+		repos    []string
+		expected []config.Org
+		err      bool
+	}{
+		{"all", nil, orgs, false},
+		{"one repo", []string{"istio/proxy"}, []config.Org{
+			{Name: "istio", AutoLabels: []config.AutoLabel{crash}, Repos: []config.Repo{{Name: "proxy"}}},
+		}, false},
+		{"several orgs", []string{"istio/api", "istio-ecosystem/authservice", "istio/istio"}, []config.Org{
+			{Name: "istio", AutoLabels: []config.AutoLabel{crash}, Repos: []config.Repo{{Name: "api"}, {Name: "istio"}}},
+			{Name: "istio-ecosystem", Repos: []config.Repo{{Name: "authservice"}}},
+		}, false},
+		{"not configured", []string{"istio/bots"}, nil, true},
+		{"not a repo", []string{"istio"}, nil, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := &Syncer{orgs: orgs}
+			selected, err := s.selectOrgs(c.repos)
+			if c.err {
+				if err == nil {
+					t.Errorf("Got orgs %+v, expecting an error", selected)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Got error %v, expecting success", err)
+			}
+
+			if !reflect.DeepEqual(selected, c.expected) {
+				t.Errorf("Got orgs %+v, expecting %+v", selected, c.expected)
+			}
+		})
+	}
+}
+
+func TestSyncLimitedToReposKeepsMaintainers(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/istio", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"login": "istio"}`)
+	})
+	mux.HandleFunc("/repos/istio/istio", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"name": "istio", "organization": {"login": "istio"}}`)
+	})
+	mux.HandleFunc("/repos/istio/istio/contents/CODEOWNERS", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"type": "file", "name": "CODEOWNERS", "path": "CODEOWNERS", "content": "/galley/ @carol\n"}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	store := &fakeStore{
+		maintainers: []*storage.Maintainer{
+			{OrgLogin: "istio", UserLogin: "alice", Paths: []string{"istio/pilot/**", "proxy/src/**"}},
+		},
+	}
+
+	orgs := []config.Org{{Name: "istio", Repos: []config.Repo{{Name: "istio"}, {Name: "proxy"}}}}
+	s := New(gh.NewThrottledClientForClient(client), cache.New(store, time.Minute), nil, store, orgs, false)
+
+	if _, err := s.Sync(context.Background(), Maintainers, []string{"istio/istio"}); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	// only the synced repo's share of the maintainers is recomputed
+	expected := map[string][]string{
+		"alice": {"proxy/src/**"},
+		"carol": {"istio/galley/**"},
+	}
+
+	if len(store.maintainers) != len(expected) {
+		t.Fatalf("Got maintainers %v, expecting %v", store.maintainers, expected)
+	}
+
+	for _, m := range store.maintainers {
+		if fmt.Sprint(m.Paths) != fmt.Sprint(expected[m.UserLogin]) {
+			t.Errorf("Got paths %v for %s, expecting %v", m.Paths, m.UserLogin, expected[m.UserLogin])
+		}
+	}
+}
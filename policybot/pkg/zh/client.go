@@ -31,11 +31,18 @@ const (
 
 type Client struct {
 	authToken string
+	baseURL   string
 }
 
 func NewClient(authToken string) *Client {
+	return NewClientWithBaseURL(authToken, baseURL)
+}
+
+// NewClientWithBaseURL returns a client which talks to a ZenHub API server other than the default one.
+func NewClientWithBaseURL(authToken string, baseURL string) *Client {
 	return &Client{
 		authToken: authToken,
+		baseURL:   baseURL,
 	}
 }
 
@@ -48,7 +55,7 @@ const (
 )
 
 func (c *Client) sendRequest(method, urlPath string) (*http.Response, error) {
-	req, err := http.NewRequest(method, baseURL+urlPath, nil)
+	req, err := http.NewRequest(method, c.baseURL+urlPath, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,82 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zh
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+type EpicIssue struct {
+	IssueNumber int      `json:"issue_number"`
+	RepoID      int      `json:"repo_id"`
+	IsEpic      bool     `json:"is_epic"`
+	Estimate    Estimate `json:"estimate"`
+}
+
+type Epics struct {
+	EpicIssues []EpicIssue `json:"epic_issues"`
+}
+
+type EpicData struct {
+	Estimate Estimate    `json:"estimate"`
+	Pipeline Pipeline    `json:"pipeline"`
+	Issues   []EpicIssue `json:"issues"`
+}
+
+// Query ZenHub for the epics in a repo
+func (c *Client) GetEpics(repo int) (*Epics, error) {
+	resp, err := c.sendRequest("GET", fmt.Sprintf("/p1/repositories/%d/epics", repo))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &Epics{}
+	if err = json.Unmarshal(body, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Query ZenHub for the issues belonging to an epic
+func (c *Client) GetEpicData(repo, epic int) (*EpicData, error) {
+	resp, err := c.sendRequest("GET", fmt.Sprintf("/p1/repositories/%d/epics/%d", repo, epic))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &EpicData{}
+	if err = json.Unmarshal(body, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zh
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// the type of event recorded when an issue moves between pipelines
+const TransferIssueEvent = "transferIssue"
+
+type IssueEvent struct {
+	UserID       int       `json:"user_id"`
+	Type         string    `json:"type"`
+	CreatedAt    time.Time `json:"created_at"`
+	FromPipeline *Pipeline `json:"from_pipeline"`
+	ToPipeline   *Pipeline `json:"to_pipeline"`
+	FromEstimate *Estimate `json:"from_estimate"`
+	ToEstimate   *Estimate `json:"to_estimate"`
+}
+
+// Query ZenHub for the pipeline and estimate changes of an issue
+func (c *Client) GetIssueEvents(repo, issue int) ([]IssueEvent, error) {
+	resp, err := c.sendRequest("GET", fmt.Sprintf("/p1/repositories/%d/issues/%d/events", repo, issue))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []IssueEvent
+	if err = json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
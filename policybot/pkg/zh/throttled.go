@@ -15,6 +15,7 @@
 package zh
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/google/go-github/v26/github"
@@ -25,12 +26,18 @@ import (
 // ZenHubThrottle is used to throttle our use of the ZenHub API in order to
 // prevent hitting rate limits or abuse limits.
 type ThrottledClient struct {
+	calls  int64 // accessed atomically, keep first for alignment
 	client *Client
 }
 
 func NewThrottledClient(zenhubToken string) *ThrottledClient {
+	return NewThrottledClientForClient(NewClient(zenhubToken))
+}
+
+// NewThrottledClientForClient returns a throttled client which wraps the given client.
+func NewThrottledClientForClient(client *Client) *ThrottledClient {
 	return &ThrottledClient{
-		client: NewClient(zenhubToken),
+		client: client,
 	}
 }
 
@@ -39,6 +46,7 @@ func NewThrottledClient(zenhubToken string) *ThrottledClient {
 // specified in the error.
 func (tc *ThrottledClient) ThrottledCall(cb func(*Client) (interface{}, error)) (interface{}, error) {
 	for {
+		atomic.AddInt64(&tc.calls, 1)
 		result, err := cb(tc.client)
 		if err == nil {
 			return result, nil
@@ -53,6 +61,11 @@ func (tc *ThrottledClient) ThrottledCall(cb func(*Client) (interface{}, error))
 	}
 }
 
+// Calls returns the number of ZenHub API calls made through this client so far, including retries.
+func (tc *ThrottledClient) Calls() int64 {
+	return atomic.LoadInt64(&tc.calls)
+}
+
 func sleep(rle *github.RateLimitError) {
 	// wait for the reset time
 	// TODO: would be nice to wait in a cancellable way, per a context